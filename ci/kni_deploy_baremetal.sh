@@ -30,31 +30,13 @@ wget https://raw.githubusercontent.com/openshift/installer/master/scripts/mainte
 chmod a+x ./virsh-cleanup.sh
 sudo -E bash -c "yes Y | ./virsh-cleanup.sh"
 
-# Stop the containers so they can be removed and their names re-used later.
-podman stop kni-dnsmasq-prov || /bin/true
-podman stop kni-dnsmasq-bm || /bin/true
-podman stop kni-haproxy || /bin/true
-podman stop kni-coredns || /bin/true
-podman stop kni-matchbox || /bin/true
-
-# Removed the stopped containers.
-podman rm kni-dnsmasq-prov || /bin/true
-podman rm kni-dnsmasq-bm || /bin/true
-podman rm kni-haproxy || /bin/true
-podman rm kni-coredns || /bin/true
-podman rm kni-matchbox || /bin/true
-
-# In case a container removal happened while a VM was still running,
-# it will no longer appear in CLI output as a container that podman
-# knows about, but the storage will remain and an entry will still be
-# present in containers.json which will prevent creating a container
-# with the same name.  This should clean up that situation, but
-# otherwise is not normally necessary.
-podman rm -f --storage kni-dnsmasq-prov || /bin/true
-podman rm -f --storage kni-dnsmasq-bm || /bin/true
-podman rm -f --storage kni-haproxy || /bin/true
-podman rm -f --storage kni-coredns || /bin/true
-podman rm -f --storage kni-matchbox || /bin/true
+# Stop and remove the bastion containers so their names can be re-used later.
+# This also cleans up the storage of containers that were removed while a VM
+# was still running, that would prevent creating a container with the same
+# name. Missing containers are not an error, so any failure is a real one.
+pushd $HOME/go/src/gerrit.akraino.org/kni/installer
+./bin/knictl bastion stop $SITE_NAME --remove
+popd
 
 rm -rf $HOME/.kni/$SITE_NAME || true
 pushd $HOME/go/src/gerrit.akraino.org/kni/installer
@@ -81,9 +63,6 @@ popd
 pushd $HOME/go/src/gerrit.akraino.org/kni/installer
 ./bin/knictl deploy_workers $SITE_NAME
 
-# destroy bootstrap node
-virsh destroy ${UPI_NAME}-bootstrap
-
 # just sleep for some time, and workers should be up
 sleep 20m
 popd
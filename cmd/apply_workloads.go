@@ -74,9 +74,11 @@ var applyWorkloadsCmd = &cobra.Command{
 			Delay, _ = strconv.Atoi(delay)
 		}
 
+		provenance, _ := cmd.Flags().GetBool("provenance")
+
 		// define a site object and proceed with applying workloads
 		s := site.NewWithName(siteName, buildPath)
-		s.ApplyWorkloads(kubeconfig, RetryCount, Delay)
+		s.ApplyWorkloads(kubeconfig, RetryCount, Delay, provenance)
 	},
 }
 
@@ -87,5 +89,6 @@ func init() {
 	applyWorkloadsCmd.Flags().StringP("kubeconfig", "", "", "Path to kubeconfig file. By default it will be the one generated with prepare_manifests. If set to 'local', no kubeconfig will be used and it will assume running on local cluster")
 	applyWorkloadsCmd.Flags().StringP("retry_count", "", "", "Number of retries")
 	applyWorkloadsCmd.Flags().StringP("delay", "", "", "Delay between each retry")
+	applyWorkloadsCmd.Flags().BoolP("provenance", "", true, "Annotate every workload with its source layer, blueprint, site commit and knictl version")
 
 }
@@ -0,0 +1,123 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/container"
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// bastionCmd represents the bastion command
+var bastionCmd = &cobra.Command{
+	Use:   "bastion",
+	Short: "Commands to manage the services of the bastion of a baremetal site",
+	Long:  ``,
+}
+
+// returns the site of the first argument. The bastion services do not need the
+// site to be fetched, so that they can be stopped after its build path is removed
+func bastionSite(cmd *cobra.Command, args []string) site.Site {
+	if len(args) == 0 {
+		log.Fatalln("Please specify site name as first argument")
+	}
+
+	return site.NewWithName(args[0], fmt.Sprintf("%s/.kni", os.Getenv("HOME")))
+}
+
+// adds the flag selecting the container runtime of the bastion services
+func addContainerRuntimeFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("container_runtime", "", "", fmt.Sprintf("Container runtime of the bastion services, one of %s. The first one found in the PATH by default", strings.Join(container.Runtimes(), ", ")))
+}
+
+// bastionStatusCmd represents the bastion status command
+var bastionStatusCmd = &cobra.Command{
+	Use:              "status siteName [--container_runtime=<podman|docker>]",
+	Short:            "Command to show whether the bastion services are running and healthy",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := bastionSite(cmd, args)
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		s.BastionStatus(runtimeName)
+	},
+}
+
+// bastionStartCmd represents the bastion start command
+var bastionStartCmd = &cobra.Command{
+	Use:              "start siteName [service...] [--container_runtime=<podman|docker>]",
+	Short:            "Command to start the bastion services, all of them by default, creating their containers if needed",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := bastionSite(cmd, args)
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		s.BastionStart(runtimeName, args[1:])
+	},
+}
+
+// bastionStopCmd represents the bastion stop command
+var bastionStopCmd = &cobra.Command{
+	Use:              "stop siteName [service...] [--container_runtime=<podman|docker>] [--remove]",
+	Short:            "Command to stop the bastion services, all of them by default",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := bastionSite(cmd, args)
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		remove, _ := cmd.Flags().GetBool("remove")
+		s.BastionStop(runtimeName, args[1:], remove)
+	},
+}
+
+// bastionLogsCmd represents the bastion logs command
+var bastionLogsCmd = &cobra.Command{
+	Use:              "logs siteName service [--container_runtime=<podman|docker>] [--tail=<lines>] [--follow]",
+	Short:            "Command to show the logs of a bastion service",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			log.Fatalln("Please specify site name and service as arguments")
+		}
+		s := bastionSite(cmd, args)
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		tail, _ := cmd.Flags().GetInt("tail")
+		follow, _ := cmd.Flags().GetBool("follow")
+		s.BastionLogs(runtimeName, args[1], tail, follow)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bastionCmd)
+
+	for _, subCmd := range []*cobra.Command{bastionStatusCmd, bastionStartCmd, bastionStopCmd, bastionLogsCmd} {
+		bastionCmd.AddCommand(subCmd)
+		addContainerRuntimeFlag(subCmd)
+	}
+
+	bastionStopCmd.Flags().BoolP("remove", "", false, "Remove the stopped containers, so that they can be created again")
+	bastionLogsCmd.Flags().IntP("tail", "", 0, "Number of lines to show from the end of the logs, all of them by default")
+	bastionLogsCmd.Flags().BoolP("follow", "", false, "Keep streaming the logs")
+}
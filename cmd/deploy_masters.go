@@ -19,13 +19,14 @@ import (
 	"log"
 	"os"
 
+	"gerrit.akraino.org/kni/installer/pkg/automation"
 	"gerrit.akraino.org/kni/installer/pkg/site"
 	"github.com/spf13/cobra"
 )
 
 // deployMastersCmd represents the deploy_masters command
 var deployMastersCmd = &cobra.Command{
-	Use:              "deploy_masters siteName [--build_path=<local_build_path>]",
+	Use:              "deploy_masters siteName [--build_path=<local_build_path>] [--plan | --plan_file=<plan_file>...] [--container_runtime=<podman|docker>] [--rollback]",
 	Short:            "Command to automate the deployment of the master nodes of a previously-prepared site",
 	Long:             ``,
 	TraverseChildren: true,
@@ -48,7 +49,19 @@ var deployMastersCmd = &cobra.Command{
 		// so the site directory should be available on disk already (if not,
 		// s.AutomateMastersDeployment will error-out appropriately)
 		s := site.NewWithName(siteName, buildPath)
-		s.AutomateMastersDeployment()
+		plan, _ := cmd.Flags().GetBool("plan")
+		planFiles, _ := cmd.Flags().GetStringSlice("plan_file")
+		if plan && len(planFiles) > 0 {
+			log.Fatalln("Please specify either --plan or --plan_file, not both")
+		}
+
+		rollback, _ := cmd.Flags().GetBool("rollback")
+		if plan && rollback {
+			log.Fatalln("Please specify either --plan or --rollback, not both")
+		}
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		s.AutomateMastersDeployment(automation.DeploymentOptions{Plan: plan, PlanFiles: planFiles, Rollback: rollback, ContainerRuntime: runtimeName})
 	},
 }
 
@@ -56,4 +69,8 @@ func init() {
 	rootCmd.AddCommand(deployMastersCmd)
 
 	deployMastersCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	deployMastersCmd.Flags().BoolP("plan", "", false, "Only plan the terraform changes and print a summary of them, saving the plans for review")
+	deployMastersCmd.Flags().StringSliceP("plan_file", "", []string{}, "Apply the given reviewed terraform plans, created with --plan, instead of planning again")
+	deployMastersCmd.Flags().BoolP("rollback", "", false, "Undo the started deployment steps in reverse order if one of them fails, for baremetal and libvirt sites")
+	addContainerRuntimeFlag(deployMastersCmd)
 }
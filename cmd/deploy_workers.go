@@ -19,13 +19,14 @@ import (
 	"log"
 	"os"
 
+	"gerrit.akraino.org/kni/installer/pkg/automation"
 	"gerrit.akraino.org/kni/installer/pkg/site"
 	"github.com/spf13/cobra"
 )
 
 // deployWorkersCmd represents the deploy_workers command
 var deployWorkersCmd = &cobra.Command{
-	Use:              "deploy_workers siteName [--build_path=<local_build_path>]",
+	Use:              "deploy_workers siteName [--build_path=<local_build_path>] [--plan | --plan_file=<plan_file>...] [--container_runtime=<podman|docker>] [--keep_bootstrap]",
 	Short:            "Command to automate the deployment of the worker nodes of a previously-prepared site",
 	Long:             ``,
 	TraverseChildren: true,
@@ -48,7 +49,16 @@ var deployWorkersCmd = &cobra.Command{
 		// and deploy_masters, so the site directory and required automation
 		// configs are already available on disk
 		s := site.NewWithName(siteName, buildPath)
-		s.AutomateWorkersDeployment()
+		plan, _ := cmd.Flags().GetBool("plan")
+		planFiles, _ := cmd.Flags().GetStringSlice("plan_file")
+		if plan && len(planFiles) > 0 {
+			log.Fatalln("Please specify either --plan or --plan_file, not both")
+		}
+
+		keepBootstrap, _ := cmd.Flags().GetBool("keep_bootstrap")
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		s.AutomateWorkersDeployment(automation.DeploymentOptions{Plan: plan, PlanFiles: planFiles, KeepBootstrap: keepBootstrap, ContainerRuntime: runtimeName})
 	},
 }
 
@@ -56,4 +66,8 @@ func init() {
 	rootCmd.AddCommand(deployWorkersCmd)
 
 	deployWorkersCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	deployWorkersCmd.Flags().BoolP("plan", "", false, "Only plan the terraform changes and print a summary of them, saving the plans for review")
+	deployWorkersCmd.Flags().StringSliceP("plan_file", "", []string{}, "Apply the given reviewed terraform plans, created with --plan, instead of planning again")
+	deployWorkersCmd.Flags().BoolP("keep_bootstrap", "", false, "Keep the bootstrap node once the bootstrap completes, instead of removing it")
+	addContainerRuntimeFlag(deployWorkersCmd)
 }
@@ -19,13 +19,15 @@ import (
 	"log"
 	"os"
 
+	"gerrit.akraino.org/kni/installer/pkg/automation"
 	"gerrit.akraino.org/kni/installer/pkg/site"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // destroyClusterCmd represents the destroy_cluster command
 var destroyClusterCmd = &cobra.Command{
-	Use:              "destroy_cluster siteName [--build_path=<local_build_path>]",
+	Use:              "destroy_cluster siteName [--build_path=<local_build_path>] [--plan | --plan_file=<plan_file>...] [--container_runtime=<podman|docker>] [--fail_fast | --fail-fast]",
 	Short:            "Command to automate the teardown of master and workers nodes of an automated-deployment cluster",
 	Long:             ``,
 	TraverseChildren: true,
@@ -48,7 +50,16 @@ var destroyClusterCmd = &cobra.Command{
 		// so the site directory should be available on disk already (if not,
 		// s.AutomateMastersDeployment will error-out appropriately)
 		s := site.NewWithName(siteName, buildPath)
-		s.AutomateClusterDestroy()
+		plan, _ := cmd.Flags().GetBool("plan")
+		planFiles, _ := cmd.Flags().GetStringSlice("plan_file")
+		if plan && len(planFiles) > 0 {
+			log.Fatalln("Please specify either --plan or --plan_file, not both")
+		}
+
+		failFast, _ := cmd.Flags().GetBool("fail_fast")
+
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		s.AutomateClusterDestroy(automation.DeploymentOptions{Plan: plan, PlanFiles: planFiles, FailFast: failFast, ContainerRuntime: runtimeName})
 	},
 }
 
@@ -56,4 +67,16 @@ func init() {
 	rootCmd.AddCommand(destroyClusterCmd)
 
 	destroyClusterCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	destroyClusterCmd.Flags().BoolP("plan", "", false, "Only plan the terraform changes and print a summary of them, saving the plans for review")
+	destroyClusterCmd.Flags().StringSliceP("plan_file", "", []string{}, "Apply the given reviewed terraform plans, created with --plan, instead of planning again")
+	addContainerRuntimeFlag(destroyClusterCmd)
+	destroyClusterCmd.Flags().BoolP("fail_fast", "", false, "Stop at the first failed teardown step, instead of running all the steps and reporting the failed ones")
+
+	// --fail-fast is accepted too, as an alias of --fail_fast
+	destroyClusterCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "fail-fast" {
+			name = "fail_fast"
+		}
+		return pflag.NormalizedName(name)
+	})
 }
@@ -0,0 +1,67 @@
+// Copyright © 2019 Red Hat <yroblamo@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:              "explain siteName <kind>/<name> [--build_path=<local_build_path>] [--namespace=<namespace>]",
+	Short:            "Command to show where a rendered object of a site comes from",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// we need site name and object as arguments
+		if len(args) < 2 {
+			log.Fatalln("Please specify site name as first argument and <kind>/<name> as second argument")
+		}
+		siteName := args[0]
+		object := args[1]
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
+		if len(kubeconfig) == 0 {
+			// set to default value
+			kubeconfig = fmt.Sprintf("%s/%s/final_manifests/auth/kubeconfig", buildPath, siteName)
+		} else if kubeconfig == "local" {
+			kubeconfig = ""
+		}
+
+		namespace, _ := cmd.Flags().GetString("namespace")
+
+		s := site.NewWithName(siteName, buildPath)
+		s.ExplainObject(object, namespace, kubeconfig)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+
+	explainCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	explainCmd.Flags().StringP("kubeconfig", "", "", "Path to kubeconfig file, used when the object is not in the final manifests. By default it will be the one generated with prepare_manifests. If set to 'local', no kubeconfig will be used")
+	explainCmd.Flags().StringP("namespace", "", "", "Namespace of the object, when looking for it on the cluster")
+}
@@ -0,0 +1,67 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// hostCmd represents the host command
+var hostCmd = &cobra.Command{
+	Use:   "host",
+	Short: "Commands to manage the baremetal hosts of a site",
+	Long:  ``,
+}
+
+// hostPowerCmd represents the host power command
+var hostPowerCmd = &cobra.Command{
+	Use:              "power siteName hostName <on|off|cycle|status> [--build_path=<local_build_path>] [--pxe]",
+	Short:            "Command to power on, off or cycle a host of a site through its BMC, or show its power state",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// we need site name, host name and action as arguments
+		if len(args) < 3 {
+			log.Fatalln("Please specify site name, host name and power action (on, off, cycle or status) as arguments")
+		}
+		siteName := args[0]
+		hostName := args[1]
+		action := args[2]
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		pxe, _ := cmd.Flags().GetBool("pxe")
+
+		s := site.NewWithName(siteName, buildPath)
+		s.HostPower(hostName, action, pxe)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hostCmd)
+	hostCmd.AddCommand(hostPowerCmd)
+
+	hostPowerCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	hostPowerCmd.Flags().BoolP("pxe", "", false, "Boot the host from the network once, when powering it on or cycling it")
+}
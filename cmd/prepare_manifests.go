@@ -44,10 +44,14 @@ var prepareManifestsCmd = &cobra.Command{
 			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
 		}
 
+		provenance, _ := cmd.Flags().GetBool("provenance")
+		hardening, _ := cmd.Flags().GetString("hardening")
+
 		// define a site object and proceed with requirements fetch
 		s := site.NewWithName(siteName, buildPath)
+		s.ValidatePullSecret()
 		s.WriteEnvFile()
-		s.PrepareManifests()
+		s.PrepareManifests(provenance, hardening)
 	},
 }
 
@@ -55,5 +59,7 @@ func init() {
 	rootCmd.AddCommand(prepareManifestsCmd)
 
 	prepareManifestsCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	prepareManifestsCmd.Flags().StringP("hardening", "", "", "Hardening profile whose MachineConfigs and RBAC fixes are merged into the final manifests. Supported profiles: akraino")
+	prepareManifestsCmd.Flags().BoolP("provenance", "", true, "Annotate every manifest with its source layer, blueprint, site commit and knictl version")
 
 }
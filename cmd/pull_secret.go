@@ -0,0 +1,98 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// pullSecretCmd represents the pull_secret command
+var pullSecretCmd = &cobra.Command{
+	Use:   "pull_secret",
+	Short: "Commands to manage the registry credentials of the pull secret",
+	Long:  ``,
+}
+
+// pullSecretAddCmd represents the pull_secret add command
+var pullSecretAddCmd = &cobra.Command{
+	Use:              "add registry --username=<username> [--password=<password>|--password_stdin] [--email=<email>] [--site=<siteName>] [--build_path=<local_build_path>]",
+	Short:            "Command to merge the credentials of a registry, such as a mirror, into the pull secret",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var registry string
+		if len(args) == 0 {
+			log.Fatalln("Please specify registry as first argument")
+		} else {
+			registry = args[0]
+		}
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		username, _ := cmd.Flags().GetString("username")
+		if len(username) == 0 {
+			log.Fatalln("Please specify the registry username with --username")
+		}
+
+		password, _ := cmd.Flags().GetString("password")
+		passwordStdin, _ := cmd.Flags().GetBool("password_stdin")
+		if passwordStdin {
+			// avoids leaving the password in the shell history
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && len(line) == 0 {
+				log.Fatalf("Error reading password from stdin: %s\n", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+		if len(password) == 0 {
+			log.Fatalln("Please specify the registry password with --password or --password_stdin")
+		}
+
+		email, _ := cmd.Flags().GetString("email")
+
+		// by default the global pull secret is updated
+		pullSecretPath := fmt.Sprintf("%s/pull-secret.json", buildPath)
+		siteName, _ := cmd.Flags().GetString("site")
+		if len(siteName) > 0 {
+			s := site.NewWithName(siteName, buildPath)
+			pullSecretPath = s.GetPullSecretPath()
+		}
+
+		site.AddPullSecretAuth(pullSecretPath, registry, username, password, email)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pullSecretCmd)
+	pullSecretCmd.AddCommand(pullSecretAddCmd)
+
+	pullSecretAddCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	pullSecretAddCmd.Flags().StringP("username", "", "", "Username for the registry")
+	pullSecretAddCmd.Flags().StringP("password", "", "", "Password for the registry")
+	pullSecretAddCmd.Flags().BoolP("password_stdin", "", false, "Read the password for the registry from stdin")
+	pullSecretAddCmd.Flags().StringP("email", "", "", "Email for the registry, optional")
+	pullSecretAddCmd.Flags().StringP("site", "", "", "Site whose pull secret is updated, in its credentials directory. By default the global pull secret in the build path is updated")
+}
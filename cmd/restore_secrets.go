@@ -0,0 +1,62 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// restoreSecretsCmd represents the restore_secrets command
+var restoreSecretsCmd = &cobra.Command{
+	Use:              "restore_secrets siteName --encryption_key=<key_file> [--build_path=<local_build_path>]",
+	Short:            "Command to decrypt the secrets of a site previously encrypted with scrub",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// retrieve config values and start restoring
+		var siteName string
+		if len(args) == 0 {
+			log.Fatalln("Please specify site name as first argument")
+		} else {
+			siteName = args[0]
+		}
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		encryptionKey, _ := cmd.Flags().GetString("encryption_key")
+		if len(encryptionKey) == 0 {
+			log.Fatalln("Please specify the encryption key file with --encryption_key")
+		}
+
+		s := site.NewWithName(siteName, buildPath)
+		s.RestoreSecrets(encryptionKey)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreSecretsCmd)
+
+	restoreSecretsCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	restoreSecretsCmd.Flags().StringP("encryption_key", "", "", "File containing the key that was used to encrypt the secrets")
+}
@@ -0,0 +1,71 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/automation"
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// scaleWorkersCmd represents the scale_workers command
+var scaleWorkersCmd = &cobra.Command{
+	Use:              "scale_workers siteName [--build_path=<local_build_path>] [--site_repo=<site_repo>] [--plan | --plan_file=<plan_file>...] [--container_runtime=<podman|docker>]",
+	Short:            "Command to add and remove worker nodes of a deployed site, to match its site config",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// retrieve config values and start scaling
+		var siteName string
+		if len(args) == 0 {
+			log.Fatalln("Please specify site name as first argument")
+		} else {
+			siteName = args[0]
+		}
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		siteRepo, _ := cmd.Flags().GetString("site_repo")
+		plan, _ := cmd.Flags().GetBool("plan")
+		planFiles, _ := cmd.Flags().GetStringSlice("plan_file")
+		if plan && len(planFiles) > 0 {
+			log.Fatalln("Please specify either --plan or --plan_file, not both")
+		}
+
+		// This command is used on a site deployed with deploy_masters and
+		// deploy_workers, whose terraform state lists the deployed workers
+		s := site.NewWithName(siteName, buildPath)
+		runtimeName, _ := cmd.Flags().GetString("container_runtime")
+		s.AutomateWorkersScaling(siteRepo, automation.DeploymentOptions{Plan: plan, PlanFiles: planFiles, ContainerRuntime: runtimeName})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scaleWorkersCmd)
+
+	scaleWorkersCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	scaleWorkersCmd.Flags().StringP("site_repo", "", "", "Site repository to download the updated site config from. By default the site config of the build path is used")
+	scaleWorkersCmd.Flags().BoolP("plan", "", false, "Only plan the terraform changes and print a summary of them, saving the plans for review")
+	scaleWorkersCmd.Flags().StringSliceP("plan_file", "", []string{}, "Apply the given reviewed terraform plans, created with --plan, instead of planning again")
+	addContainerRuntimeFlag(scaleWorkersCmd)
+}
@@ -0,0 +1,61 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// scrubCmd represents the scrub command
+var scrubCmd = &cobra.Command{
+	Use:              "scrub siteName [--build_path=<local_build_path>] [--encryption_key=<key_file>] [--remove_credentials]",
+	Short:            "Command to remove, redact or encrypt the secrets of a site once it has been installed",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// retrieve config values and start scrubbing
+		var siteName string
+		if len(args) == 0 {
+			log.Fatalln("Please specify site name as first argument")
+		} else {
+			siteName = args[0]
+		}
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		encryptionKey, _ := cmd.Flags().GetString("encryption_key")
+		removeCredentials, _ := cmd.Flags().GetBool("remove_credentials")
+
+		s := site.NewWithName(siteName, buildPath)
+		s.ScrubSecrets(encryptionKey, removeCredentials)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scrubCmd)
+
+	scrubCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	scrubCmd.Flags().StringP("encryption_key", "", "", "File containing the key used to encrypt the secrets at rest. If not supplied, secrets are removed or redacted")
+	scrubCmd.Flags().BoolP("remove_credentials", "", false, "Also remove the cluster credentials (kubeconfig, kubeadmin-password and profile.env), that are kept by default")
+}
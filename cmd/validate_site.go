@@ -0,0 +1,64 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"github.com/spf13/cobra"
+)
+
+// validateSiteCmd represents the validate_site command
+var validateSiteCmd = &cobra.Command{
+	Use:              "validate_site siteName [--build_path=<local_build_path>] [--schema]",
+	Short:            "Command to validate the site-config.yaml of a site, or print its JSON schema",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// print the schema if requested, no site is needed for it
+		schema, _ := cmd.Flags().GetBool("schema")
+		if schema {
+			fmt.Print(siteconfig.Schema)
+			return
+		}
+
+		var siteName string
+		if len(args) == 0 {
+			log.Fatalln("Please specify site name as first argument")
+		} else {
+			siteName = args[0]
+		}
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		s := site.NewWithName(siteName, buildPath)
+		s.ValidateSiteConfig()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateSiteCmd)
+
+	validateSiteCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	validateSiteCmd.Flags().BoolP("schema", "", false, "Print the JSON schema of the SiteConfig object instead of validating a site")
+}
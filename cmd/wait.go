@@ -0,0 +1,76 @@
+// Copyright © 2019 Red Hat <abays@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gerrit.akraino.org/kni/installer/pkg/site"
+	"github.com/spf13/cobra"
+)
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:              "wait siteName --for=<bootstrap-complete|install-complete|workers-ready|workloads-ready> [--build_path=<local_build_path>] [--timeout=<duration>] [--output=<text|json>]",
+	Short:            "Command to wait for an installation milestone of a deployed site",
+	Long:             ``,
+	TraverseChildren: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// retrieve config values and start waiting
+		var siteName string
+		if len(args) == 0 {
+			log.Fatalln("Please specify site name as first argument")
+		} else {
+			siteName = args[0]
+		}
+
+		milestone, _ := cmd.Flags().GetString("for")
+		if len(milestone) == 0 {
+			log.Fatalln("Please specify the milestone to wait for with --for")
+		}
+
+		buildPath, _ := cmd.Flags().GetString("build_path")
+		if len(buildPath) == 0 {
+			// will generate a temporary directory
+			buildPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+		}
+
+		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			log.Fatalf("Invalid output %s, it needs to be text or json\n", output)
+		}
+
+		s := site.NewWithName(siteName, buildPath)
+		s.WaitFor(milestone, kubeconfig, timeout, interval, output == "json")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().StringP("for", "", "", "Milestone to wait for: bootstrap-complete, install-complete, workers-ready or workloads-ready")
+	waitCmd.Flags().StringP("build_path", "", "", "Directory to use as build path. If that doesn't exist, the installer will generate a default directory")
+	waitCmd.Flags().StringP("kubeconfig", "", "", "Path to kubeconfig file. By default it will be the one generated by the installer for the site. If set to 'local', no kubeconfig will be used")
+	waitCmd.Flags().DurationP("timeout", "", 0, "Maximum time to wait, like 45m. By default it depends on the milestone")
+	waitCmd.Flags().DurationP("interval", "", 15*time.Second, "Time between two checks of the cluster")
+	waitCmd.Flags().StringP("output", "", "text", "Format of the progress events: text, or json for one JSON object per line")
+}
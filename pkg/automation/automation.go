@@ -13,6 +13,20 @@ type AutomatedDeploymentParams struct {
 	SiteBuildPath string
 	SiteName      string
 	SiteRepo      string
+	Options       DeploymentOptions
+}
+
+// DeploymentOptions : how the deploy and destroy operations run, as requested
+// on the command line. Only the terraform-based automation supports plans, and
+// the installer removes the bootstrap node itself on cloud platforms
+type DeploymentOptions struct {
+	Plan          bool     // plan the terraform changes and summarize them, without applying them
+	PlanFiles     []string // previously reviewed plans to apply, instead of planning again
+	KeepBootstrap bool     // keep the bootstrap node once the bootstrap completes, for debugging
+	FailFast      bool     // stop destroying the cluster at the first failed step
+	Rollback      bool     // undo the started steps of a masters deployment that fails
+
+	ContainerRuntime string // runtime of the bastion containers, detected if empty
 }
 
 type AutomatedDeploymentInterface interface {
@@ -23,6 +37,12 @@ type AutomatedDeploymentInterface interface {
 	DestroyCluster() error                     // Destroy the cluster
 }
 
+// WorkerScalingInterface : implemented by the automation that can add and remove
+// workers of a deployed cluster
+type WorkerScalingInterface interface {
+	ScaleWorkers() error // Add and remove workers to match the site config
+}
+
 var (
 	// If we find that different profile types (libvirt, aws, etc) that we add
 	// in the future require different constructor parameter count/types, then
@@ -34,6 +54,27 @@ func init() {
 	// Add new automation profile types here
 	automatedDeploymentConstructors = map[string]func(AutomatedDeploymentParams) (AutomatedDeploymentInterface, error){}
 	automatedDeploymentConstructors["baremetal"] = newBaremetal
+	automatedDeploymentConstructors["libvirt"] = newLibvirt
+	automatedDeploymentConstructors["aws"] = newAWS
+	automatedDeploymentConstructors["gcp"] = newGCP
+}
+
+// installerDirectories : directory where the automation of each profile type
+// runs openshift-install, relative to the site build path
+var installerDirectories = map[string]string{
+	"baremetal": "baremetal_automation/ocp",
+	"libvirt":   "libvirt_automation/ocp",
+}
+
+// InstallerDirectory : directory of a site, relative to its build path, that holds
+// the installer state and cluster credentials for a profile type. Sites deployed
+// with openshift-install create cluster use the final manifests directly
+func InstallerDirectory(profileType string) string {
+	if directory, ok := installerDirectories[profileType]; ok {
+		return directory
+	}
+
+	return "final_manifests"
 }
 
 // Generates a new automation deployment instance
@@ -67,3 +108,12 @@ func New(params AutomatedDeploymentParams) (AutomatedDeploymentInterface, error)
 	// It's up to the caller to decide how to respond to nil and non-nil automatedDeployment
 	return automatedDeployment, nil
 }
+
+// Rejects the deployment options that only the terraform-based automation supports
+func checkNoTerraformPlan(params AutomatedDeploymentParams) error {
+	if params.Options.Plan || len(params.Options.PlanFiles) > 0 {
+		return fmt.Errorf("AutomatedDeployment: terraform plans are not supported for profile type '%s'", params.ProfileType)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package automation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"github.com/otiai10/copy"
+
+	getter "github.com/hashicorp/go-getter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// BaremetalAutomationRequirement : name of the blueprint requirement that sets the
+	// baremetal automation source, that is not fetched as a binary
+	BaremetalAutomationRequirement = "baremetal-automation"
+
+	defaultAutomationSource = "github.com/redhat-nfvpe/kni-upi-lab.git"
+
+	// record of the automation code fetched for a site, relative to its build path
+	automationSourceRecordFile = "baremetal_automation_source.yaml"
+)
+
+// automationSourceRecord : the automation source used for a site, and the commit it resolved to
+type automationSourceRecord struct {
+	Source string `yaml:"source"`
+	Ref    string `yaml:"ref,omitempty"`
+	Commit string `yaml:"commit,omitempty"`
+	Dirty  bool   `yaml:"dirty,omitempty"` // local directory with uncommitted changes
+}
+
+// Selects the automation source of the site config, then the one of the blueprint
+// requirements, then the default one
+func resolveAutomationSource(siteSource siteconfig.AutomationSource, requirements map[string]string) siteconfig.AutomationSource {
+	if siteSource.Source != "" {
+		return siteSource
+	}
+
+	if blueprintSource, ok := requirements[BaremetalAutomationRequirement]; ok && blueprintSource != "" {
+		return siteconfig.AutomationSource{Source: blueprintSource}
+	}
+
+	return siteconfig.AutomationSource{Source: defaultAutomationSource}
+}
+
+// Fetches the automation code into destination, returning the record of what was fetched.
+// Local directories are copied, so that the automation preparation does not modify them
+func fetchAutomationSource(source siteconfig.AutomationSource, destination string) (automationSourceRecord, error) {
+	record := automationSourceRecord{Source: source.Source, Ref: source.Ref}
+
+	if source.IsLocal() {
+		sourcePath := strings.TrimPrefix(source.Source, "file://")
+
+		info, err := os.Stat(sourcePath)
+
+		if err != nil || !info.IsDir() {
+			return record, fmt.Errorf("fetchAutomationSource: local automation source %s is not a directory", sourcePath)
+		}
+
+		err = copy.Copy(sourcePath, destination)
+
+		if err != nil {
+			return record, fmt.Errorf("fetchAutomationSource: error copying local automation source %s: %s", sourcePath, err)
+		}
+
+		record.Commit = gitCommit(sourcePath)
+
+		if record.Commit != "" {
+			status, err := gitOutput(sourcePath, "status", "--porcelain")
+			record.Dirty = err == nil && status != ""
+		}
+	} else {
+		src := source.Source
+
+		if source.Ref != "" {
+			separator := "?"
+			if strings.Contains(src, "?") {
+				separator = "&"
+			}
+			src = fmt.Sprintf("%s%sref=%s", src, separator, source.Ref)
+		}
+
+		client := &getter.Client{Src: src, Dst: destination, Mode: getter.ClientModeAny}
+		err := client.Get()
+
+		if err != nil {
+			return record, fmt.Errorf("fetchAutomationSource: error fetching automation source %s: %s", src, err)
+		}
+
+		record.Commit = gitCommit(destination)
+
+		if source.Ref == "" && !strings.Contains(source.Source, "ref=") {
+			log.Printf("WARNING: baremetal automation source %s is not pinned to a ref, using its current commit %s\n", source.Source, record.Commit)
+		}
+	}
+
+	if record.Commit == "" {
+		log.Printf("WARNING: baremetal automation source %s is not the root of a git repository, its code can not be checked when deploying\n", source.Source)
+	}
+
+	return record, nil
+}
+
+// Returns the commit checked out in a git repository, or an empty string if the
+// path is not the root of one: a subdirectory of a repository is copied without
+// it, and a repository the path is nested in, like a versioned build directory,
+// does not hold the automation code
+func gitCommit(path string) string {
+	topLevel, err := gitOutput(path, "rev-parse", "--show-toplevel")
+
+	if err != nil || !samePath(topLevel, path) {
+		return ""
+	}
+
+	commit, err := gitOutput(path, "rev-parse", "HEAD")
+
+	if err != nil {
+		return ""
+	}
+
+	return commit
+}
+
+// Whether two paths are the same directory, once made absolute and their symlinks resolved
+func samePath(path string, other string) bool {
+	resolved := []string{}
+
+	for _, p := range []string{path, other} {
+		absolutePath, err := filepath.Abs(p)
+
+		if err == nil {
+			absolutePath, err = filepath.EvalSymlinks(absolutePath)
+		}
+
+		if err != nil {
+			return false
+		}
+
+		resolved = append(resolved, absolutePath)
+	}
+
+	return resolved[0] == resolved[1]
+}
+
+func gitOutput(path string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	output, err := cmd.Output()
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func writeAutomationSourceRecord(sitePath string, record automationSourceRecord) error {
+	content, err := yaml.Marshal(record)
+
+	if err != nil {
+		return fmt.Errorf("writeAutomationSourceRecord: error marshalling automation source record: %s", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(sitePath, automationSourceRecordFile), content, 0644)
+
+	if err != nil {
+		return fmt.Errorf("writeAutomationSourceRecord: error writing automation source record: %s", err)
+	}
+
+	return nil
+}
+
+// Checks that the automation code of the site is still the one recorded by
+// fetch_requirements, and that the site config did not select another source since
+func checkAutomationSource(sitePath string, automationRepoPath string, siteSource siteconfig.AutomationSource) error {
+	content, err := ioutil.ReadFile(filepath.Join(sitePath, automationSourceRecordFile))
+
+	if os.IsNotExist(err) {
+		log.Printf("WARNING: no baremetal automation source recorded for the site, run fetch_requirements to record it\n")
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("checkAutomationSource: error reading automation source record: %s", err)
+	}
+
+	var record automationSourceRecord
+
+	err = yaml.Unmarshal(content, &record)
+
+	if err != nil {
+		return fmt.Errorf("checkAutomationSource: invalid automation source record: %s", err)
+	}
+
+	if siteSource.Source != "" && (siteSource.Source != record.Source || siteSource.Ref != record.Ref) {
+		return fmt.Errorf("checkAutomationSource: site config selects automation source %s (ref '%s'), but %s (ref '%s') was fetched, run fetch_requirements again",
+			siteSource.Source, siteSource.Ref, record.Source, record.Ref)
+	}
+
+	if record.Commit == "" {
+		return nil
+	}
+
+	currentCommit := gitCommit(automationRepoPath)
+
+	if currentCommit != record.Commit {
+		return fmt.Errorf("checkAutomationSource: automation code at %s is at commit '%s', but commit %s was fetched from %s, run fetch_requirements again",
+			automationRepoPath, currentCommit, record.Commit, record.Source)
+	}
+
+	if record.Dirty {
+		log.Printf("WARNING: baremetal automation was copied from %s with uncommitted changes on top of commit %s\n", record.Source, record.Commit)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package automation
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// runs git in dir, failing the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+// a git repository with the automation code committed in its root and in a
+// subdirectory, returning its path and commit
+func testAutomationRepo(t *testing.T) (string, string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	repo := filepath.Join(t.TempDir(), "kni-upi-lab")
+	os.MkdirAll(filepath.Join(repo, "upi"), 0755)
+	ioutil.WriteFile(filepath.Join(repo, "Makefile"), []byte("all:\n"), 0644)
+	ioutil.WriteFile(filepath.Join(repo, "upi", "main.tf"), []byte("# upi\n"), 0644)
+
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "automation")
+
+	commit, err := gitOutput(repo, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo, commit
+}
+
+func TestResolveAutomationSource(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		siteSource   siteconfig.AutomationSource
+		requirements map[string]string
+		want         siteconfig.AutomationSource
+	}{
+		{"site config", siteconfig.AutomationSource{Source: "/src/kni-upi-lab", Ref: "v1"}, map[string]string{BaremetalAutomationRequirement: "github.com/blueprint/automation.git"}, siteconfig.AutomationSource{Source: "/src/kni-upi-lab", Ref: "v1"}},
+		{"blueprint", siteconfig.AutomationSource{}, map[string]string{BaremetalAutomationRequirement: "github.com/blueprint/automation.git"}, siteconfig.AutomationSource{Source: "github.com/blueprint/automation.git"}},
+		{"empty requirement", siteconfig.AutomationSource{}, map[string]string{BaremetalAutomationRequirement: ""}, siteconfig.AutomationSource{Source: defaultAutomationSource}},
+		{"default", siteconfig.AutomationSource{}, nil, siteconfig.AutomationSource{Source: defaultAutomationSource}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := resolveAutomationSource(tc.siteSource, tc.requirements); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFetchLocalAutomationSource(t *testing.T) {
+	repo, commit := testAutomationRepo(t)
+
+	for _, tc := range []struct {
+		name   string
+		source string
+		file   string // file expected in the copy
+		want   automationSourceRecord
+	}{
+		{"repository root", repo, "Makefile", automationSourceRecord{Source: repo, Commit: commit}},
+		{"file url", "file://" + repo, "upi/main.tf", automationSourceRecord{Source: "file://" + repo, Commit: commit}},
+		{"subdirectory", filepath.Join(repo, "upi"), "main.tf", automationSourceRecord{Source: filepath.Join(repo, "upi")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "baremetal_automation")
+
+			record, err := fetchAutomationSource(siteconfig.AutomationSource{Source: tc.source}, destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if record != tc.want {
+				t.Errorf("expected record %+v, got %+v", tc.want, record)
+			}
+			if _, err := os.Stat(filepath.Join(destination, tc.file)); err != nil {
+				t.Errorf("expected %s to be copied: %s", tc.file, err)
+			}
+
+			// the copy is checked against the record
+			if gitCommit(destination) != tc.want.Commit {
+				t.Errorf("expected the copy to be at commit %q, got %q", tc.want.Commit, gitCommit(destination))
+			}
+		})
+	}
+
+	// uncommitted changes are recorded
+	ioutil.WriteFile(filepath.Join(repo, "Makefile"), []byte("all: changed\n"), 0644)
+	record, err := fetchAutomationSource(siteconfig.AutomationSource{Source: repo}, filepath.Join(t.TempDir(), "baremetal_automation"))
+	if err != nil || record.Commit != commit || !record.Dirty {
+		t.Errorf("expected a dirty record at commit %s, got %+v %v", commit, record, err)
+	}
+
+	_, err = fetchAutomationSource(siteconfig.AutomationSource{Source: filepath.Join(repo, "Makefile")}, filepath.Join(t.TempDir(), "baremetal_automation"))
+	if err == nil || !strings.HasSuffix(err.Error(), "Makefile is not a directory") {
+		t.Errorf("expected the file to be rejected, got %v", err)
+	}
+}
+
+func TestCheckAutomationSource(t *testing.T) {
+	repo, commit := testAutomationRepo(t)
+	source := siteconfig.AutomationSource{Source: repo}
+
+	// the build directory is itself versioned, its commit is not the automation's
+	sitePath := t.TempDir()
+	runGit(t, sitePath, "init", "-q")
+	runGit(t, sitePath, "commit", "-q", "--allow-empty", "-m", "build")
+
+	if err := checkAutomationSource(sitePath, filepath.Join(sitePath, "baremetal_automation"), source); err != nil {
+		t.Errorf("expected no error without record, got %s", err)
+	}
+
+	automationRepoPath := filepath.Join(sitePath, "baremetal_automation")
+	record, err := fetchAutomationSource(source, automationRepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeAutomationSourceRecord(sitePath, record)
+
+	subdirectory := siteconfig.AutomationSource{Source: filepath.Join(repo, "upi")}
+	subdirectoryPath := filepath.Join(sitePath, "upi")
+	subdirectoryRecord, err := fetchAutomationSource(subdirectory, subdirectoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name               string
+		record             automationSourceRecord
+		automationRepoPath string
+		siteSource         siteconfig.AutomationSource
+		err                string
+	}{
+		{"matching", record, automationRepoPath, source, ""},
+		{"no site source", record, automationRepoPath, siteconfig.AutomationSource{}, ""},
+		{"subdirectory in a versioned build directory", subdirectoryRecord, subdirectoryPath, subdirectory, ""},
+		{"other source", record, automationRepoPath, siteconfig.AutomationSource{Source: "github.com/other/automation.git"}, "site config selects automation source github.com/other/automation.git (ref ''), but " + repo + " (ref '') was fetched"},
+		{"other ref", record, automationRepoPath, siteconfig.AutomationSource{Source: repo, Ref: "v2"}, "(ref 'v2'), but " + repo + " (ref '') was fetched"},
+		{"other commit", automationSourceRecord{Source: repo, Commit: "0123456789"}, automationRepoPath, source, "is at commit '" + commit + "', but commit 0123456789 was fetched"},
+		{"not a repository", record, subdirectoryPath, source, "is at commit '', but commit " + commit + " was fetched"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := writeAutomationSourceRecord(sitePath, tc.record); err != nil {
+				t.Fatal(err)
+			}
+
+			err := checkAutomationSource(sitePath, tc.automationRepoPath, tc.siteSource)
+
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	ioutil.WriteFile(filepath.Join(sitePath, automationSourceRecordFile), []byte("source: [\n"), 0644)
+	err = checkAutomationSource(sitePath, automationRepoPath, source)
+	if err == nil || !strings.HasPrefix(err.Error(), "checkAutomationSource: invalid automation source record") {
+		t.Errorf("expected the invalid record to be reported, got %v", err)
+	}
+}
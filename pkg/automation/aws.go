@@ -0,0 +1,85 @@
+package automation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func newAWS(params AutomatedDeploymentParams) (AutomatedDeploymentInterface, error) {
+	err := checkNoTerraformPlan(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ipiAutomatedDeployment{
+		siteBuildPath:    params.SiteBuildPath,
+		siteName:         params.SiteName,
+		siteRepo:         params.SiteRepo,
+		platform:         "aws",
+		checkCredentials: checkAWSCredentials,
+	}, nil
+}
+
+// checks that the installer will find AWS credentials, either in the environment
+// or in the selected profile of $HOME/.aws/credentials
+func checkAWSCredentials() error {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" && os.Getenv("AWS_SECRET_ACCESS_KEY") != "" {
+		return nil
+	}
+
+	credentialsPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsPath == "" {
+		credentialsPath = fmt.Sprintf("%s/.aws/credentials", os.Getenv("HOME"))
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	credentialsFile, err := os.Open(credentialsPath)
+
+	if err != nil {
+		return fmt.Errorf("Automation: checkAWSCredentials: error reading AWS credentials, see the README for the content of %s: %s", credentialsPath, err)
+	}
+
+	defer credentialsFile.Close()
+
+	// the credentials file is an ini file with a section per profile
+	keys := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(credentialsFile)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if section == profile && len(parts) == 2 {
+			keys[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Automation: checkAWSCredentials: error reading %s: %s", credentialsPath, err)
+	}
+
+	for _, key := range []string{"aws_access_key_id", "aws_secret_access_key"} {
+		if keys[key] == "" {
+			return fmt.Errorf("Automation: checkAWSCredentials: %s not set for profile [%s] in %s", key, profile, credentialsPath)
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package automation
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,31 +11,46 @@ import (
 	"path/filepath"
 	"strings"
 
+	"gerrit.akraino.org/kni/installer/pkg/bastion"
+	"gerrit.akraino.org/kni/installer/pkg/container"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
 	"gerrit.akraino.org/kni/installer/pkg/utils"
 	"github.com/otiai10/copy"
 
-	getter "github.com/hashicorp/go-getter"
 	yaml "gopkg.in/yaml.v2"
 )
 
-var (
-	automationRemoteSource string
-)
-
-func init() {
-	automationRemoteSource = "github.com/redhat-nfvpe/kni-upi-lab.git"
-}
-
 type baremetalAutomatedDeployment struct {
 	siteBuildPath string
 	siteName      string
 	siteRepo      string
+	siteConfig    siteconfig.SiteConfig
+	options       DeploymentOptions
+}
+
+// terraform resources of the bootstrap VM in the cluster target of the automation
+const bootstrapTerraformTarget = "module.bootstrap"
+
+// name the plan of the bootstrap removal is saved with, as for a terraform target
+const bootstrapPlanName = "bootstrap"
+
+// marker written in the automation repo once the bootstrap node is removed, so that
+// the regenerated configuration does not point to it anymore
+const bootstrapRemovedFile = ".bootstrap_removed"
+
+// baremetalInstallConfig : the fields of install-config.yaml used to render the bastion configuration
+type baremetalInstallConfig struct {
+	BaseDomain string `yaml:"baseDomain"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
 }
 
 type scriptRunInstance struct {
 	description string
 	scriptFile  string
 	args        []string
+	env         []string // added to the environment of the script
 }
 
 type terraformOperation string
@@ -48,25 +64,16 @@ const (
 func newBaremetal(params AutomatedDeploymentParams) (AutomatedDeploymentInterface, error) {
 	// Examine site's site-config and determine if automation is even possible for this site
 	siteConfigSourcePath := fmt.Sprintf("%s/%s/site/00_install-config/site-config.yaml", params.SiteBuildPath, params.SiteName)
-	filename, _ := filepath.Abs(siteConfigSourcePath)
-	siteConfigFile, err := ioutil.ReadFile(filename)
-
-	if err != nil {
-		return nil, fmt.Errorf("Automation: newBaremetal: error reading site-config.yaml: %s", err)
-	}
-
-	var siteConfig map[string]interface{}
-
-	err = yaml.Unmarshal(siteConfigFile, &siteConfig)
+	siteConfig, err := siteconfig.Load(siteConfigSourcePath)
 
 	if err != nil {
-		return nil, fmt.Errorf("Automation: newBaremetal: error unmarshalling site-config.yaml: %s", err)
+		return nil, fmt.Errorf("Automation: newBaremetal: %s", err)
 	}
 
-	// Check unmarshalled YAML for a "provisioningInfrastructure" map.  If it has one, this indicates
+	// Check the SiteConfig for a "provisioningInfrastructure" block.  If it has one, this indicates
 	// that this site supports automation.  If it doesn't have one, this isn't necessarily an error,
 	// (depending on what is calling into the automation package in this context) so just return nils.
-	if _, ok := siteConfig["provisioningInfrastructure"].(map[interface{}]interface{}); !ok {
+	if siteConfig.ProvisioningInfrastructure == nil {
 		return nil, nil
 	}
 
@@ -74,6 +81,8 @@ func newBaremetal(params AutomatedDeploymentParams) (AutomatedDeploymentInterfac
 		siteBuildPath: params.SiteBuildPath,
 		siteName:      params.SiteName,
 		siteRepo:      params.SiteRepo,
+		siteConfig:    siteConfig,
+		options:       params.Options,
 	}, nil
 }
 
@@ -84,47 +93,36 @@ func (bad baremetalAutomatedDeployment) PrepareAutomation(requirements map[strin
 	// Clear baremetal automation repo if it already exists
 	os.RemoveAll(automationDestination)
 
-	log.Printf("baremetalAutomatedDeployment: PrepareAutomation: downloading baremetal automation repo (%s)...\n", automationRemoteSource)
+	// The site config selects the automation source, or else the blueprint requirements
+	automationSource := resolveAutomationSource(bad.siteConfig.ProvisioningInfrastructure.Automation, requirements)
 
-	client := &getter.Client{Src: automationRemoteSource, Dst: automationDestination, Mode: getter.ClientModeAny}
-	err := client.Get()
+	log.Printf("baremetalAutomatedDeployment: PrepareAutomation: downloading baremetal automation repo (%s)...\n", automationSource.Source)
 
-	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error cloning baremetal automation repository: %s", err)
-	}
-
-	// Copy the site's site-config.yaml into the automation repo
-	siteConfigSource, err := os.Open(fmt.Sprintf("%s/%s/site/00_install-config/site-config.yaml", bad.siteBuildPath, bad.siteName))
+	automationSourceRecord, err := fetchAutomationSource(automationSource, automationDestination)
 
 	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error opening source site config file: %s", err)
+		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error cloning baremetal automation repository: %s", err)
 	}
 
-	defer siteConfigSource.Close()
-
-	// Remove the existing automation site config, if any
-	siteConfigDestinationPath := fmt.Sprintf("%s/cluster/site-config.yaml", automationDestination)
-	os.RemoveAll(siteConfigDestinationPath)
-
-	siteConfigDestination, err := os.OpenFile(siteConfigDestinationPath, os.O_RDWR|os.O_CREATE, 0666)
+	// Record the commit used, so that deployments can check they still run the same code
+	err = writeAutomationSourceRecord(fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName), automationSourceRecord)
 
 	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error opening destination site config file: %s", err)
+		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: %s", err)
 	}
 
-	defer siteConfigDestination.Close()
-
-	_, err = io.Copy(siteConfigDestination, siteConfigSource)
+	// Copy the site's site-config.yaml into the automation repo
+	err = bad.copySiteConfig(automationDestination)
 
 	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error writing destination site config file: %s", err)
+		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: %s", err)
 	}
 
 	// Create "requirements" directory in the automation repo (needed for later commands)
 	requirementsPath := fmt.Sprintf("%s/requirements", automationDestination)
 	os.Mkdir(requirementsPath, 0755)
 
-	log.Printf("baremetalAutomatedDeployment: PrepareAutomation: finished downloading baremetal automation repo (%s)\n", automationRemoteSource)
+	log.Printf("baremetalAutomatedDeployment: PrepareAutomation: finished downloading baremetal automation repo (%s, commit '%s')\n", automationSource.Source, automationSourceRecord.Commit)
 
 	log.Printf("baremetalAutomatedDeployment: PrepareAutomation: injecting version selections into automation repo...\n")
 
@@ -153,36 +151,25 @@ func (bad baremetalAutomatedDeployment) PrepareAutomation(requirements map[strin
 	// Check site-config.yaml's config block for a releaseImageOverride.  If found, extract
 	// the image's tag to get the requested version for RHCOS images, and inject that into
 	// automation's common.sh script to override the default
-	var siteConfig map[string]interface{}
-	siteConfigFile, err := ioutil.ReadFile(siteConfigDestinationPath)
-
-	err = yaml.Unmarshal(siteConfigFile, &siteConfig)
-
-	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error unmarshalling site-config.yaml: %s", err)
-	}
 	rhcosVersionsPath := fmt.Sprintf("%s/common.sh", automationDestination)
 
-	if config, ok := siteConfig["config"].(map[interface{}]interface{}); ok {
-		if releaseImageOverride, ok := config["releaseImageOverride"].(string); ok {
-			parts := strings.Split(releaseImageOverride, ":")
+	if releaseImageOverride, ok := bad.siteConfig.Config["releaseImageOverride"]; ok {
+		parts := strings.Split(releaseImageOverride, ":")
 
-			if len(parts) == 2 {
-				err = utils.ReplaceFileText(rhcosVersionsPath, "OPENSHIFT_RHCOS_MAJOR_REL=\"\"", fmt.Sprintf("OPENSHIFT_RHCOS_MAJOR_REL=\"%s\"", parts[1]))
+		if len(parts) == 2 {
+			err = utils.ReplaceFileText(rhcosVersionsPath, "OPENSHIFT_RHCOS_MAJOR_REL=\"\"", fmt.Sprintf("OPENSHIFT_RHCOS_MAJOR_REL=\"%s\"", parts[1]))
 
-				if err != nil {
-					return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error injecting RHCOS image version: %s", err)
-				}
+			if err != nil {
+				return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error injecting RHCOS image version: %s", err)
 			}
 		}
+	}
 
-		if virtualizedInstall, ok := config["virtualizedInstall"].(string); ok {
-
-			err = utils.ReplaceFileText(rhcosVersionsPath, "VIRTUALIZED_INSTALL=false", fmt.Sprintf("VIRTUALIZED_INSTALL=%s", virtualizedInstall))
+	if virtualizedInstall, ok := bad.siteConfig.Config["virtualizedInstall"]; ok {
+		err = utils.ReplaceFileText(rhcosVersionsPath, "VIRTUALIZED_INSTALL=false", fmt.Sprintf("VIRTUALIZED_INSTALL=%s", virtualizedInstall))
 
-			if err != nil {
-				return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error injecting virtualized install setting: %s", err)
-			}
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: PrepareAutomation: error injecting virtualized install setting: %s", err)
 		}
 	}
 
@@ -191,6 +178,37 @@ func (bad baremetalAutomatedDeployment) PrepareAutomation(requirements map[strin
 	return nil
 }
 
+// Copies the site's site-config.yaml into the automation repo, where the scripts read it from
+func (bad baremetalAutomatedDeployment) copySiteConfig(automationRepoPath string) error {
+	siteConfigSource, err := os.Open(fmt.Sprintf("%s/%s/site/00_install-config/site-config.yaml", bad.siteBuildPath, bad.siteName))
+
+	if err != nil {
+		return fmt.Errorf("copySiteConfig: error opening source site config file: %s", err)
+	}
+
+	defer siteConfigSource.Close()
+
+	// Remove the existing automation site config, if any
+	siteConfigDestinationPath := fmt.Sprintf("%s/cluster/site-config.yaml", automationRepoPath)
+	os.RemoveAll(siteConfigDestinationPath)
+
+	siteConfigDestination, err := os.OpenFile(siteConfigDestinationPath, os.O_RDWR|os.O_CREATE, 0600)
+
+	if err != nil {
+		return fmt.Errorf("copySiteConfig: error opening destination site config file: %s", err)
+	}
+
+	defer siteConfigDestination.Close()
+
+	_, err = io.Copy(siteConfigDestination, siteConfigSource)
+
+	if err != nil {
+		return fmt.Errorf("copySiteConfig: error writing destination site config file: %s", err)
+	}
+
+	return nil
+}
+
 func (bad baremetalAutomatedDeployment) FinalizeAutomationPreparation() error {
 	// Copy finalized manifests into the baremetal automation repo directory
 	automationManifestSource := fmt.Sprintf("%s/%s/automation", bad.siteBuildPath, bad.siteName)
@@ -236,7 +254,7 @@ func (bad baremetalAutomatedDeployment) FinalizeAutomationPreparation() error {
 		if _, ok := fileObject["kind"]; ok {
 			// Kind found, so we need to copy this into the baremetal automation
 			// cluster manifests directory
-			err = ioutil.WriteFile(fmt.Sprintf("%s/%s", automationManifestDestination, info.Name()), fileBytes, 0644)
+			err = ioutil.WriteFile(fmt.Sprintf("%s/%s", automationManifestDestination, info.Name()), fileBytes, 0600)
 
 			if err != nil {
 				return err
@@ -252,6 +270,13 @@ func (bad baremetalAutomatedDeployment) FinalizeAutomationPreparation() error {
 		return fmt.Errorf("baremetalAutomatedDeployment: FinalizeAutomationPreparation: error copying finalized manifests to automation repo: %s", err)
 	}
 
+	// The cluster manifests directory now holds the pull secret, so restrict it to the owner
+	err = utils.SecureDirectory(automationManifestDestination)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: FinalizeAutomationPreparation: error restricting permissions of automation cluster manifests: %s", err)
+	}
+
 	// Copy required versions of oc and openshift-install into the automation repo's "requirements"
 	// directory so that they're used with later automation calls
 	log.Printf("baremetalAutomatedDeployment: FinalizeAutomationPreparation: injecting OpenShift binaries for automation repo...\n")
@@ -299,6 +324,20 @@ func (bad baremetalAutomatedDeployment) FinalizeAutomationPreparation() error {
 }
 
 func (bad baremetalAutomatedDeployment) DeployMasters() error {
+	rb := &rollback{}
+
+	err := bad.deployMasters(rb)
+
+	if err != nil && bad.options.Rollback && !bad.options.Plan {
+		return rb.undo("baremetalAutomatedDeployment: DeployMasters", err)
+	}
+
+	return err
+}
+
+// deploys the masters, recording in rb the teardown steps undoing each step
+// before it starts, as a failed step can leave a partial deployment behind
+func (bad baremetalAutomatedDeployment) deployMasters(rb *rollback) error {
 	sitePath := fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName)
 
 	// Make sure final_manifests directory is available
@@ -329,36 +368,77 @@ func (bad baremetalAutomatedDeployment) DeployMasters() error {
 		return fmt.Errorf("baremetalAutomatedDeployment: DeployMasters: unable to access local automation repo at %s: %s", automationRepoPath, err)
 	}
 
+	// Make sure the automation code is still the one fetched with the requirements
+	err = checkAutomationSource(sitePath, automationRepoPath, bad.siteConfig.ProvisioningInfrastructure.Automation)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: DeployMasters: %s", err)
+	}
+
+	// A new bootstrap node is deployed with the masters
+	os.Remove(fmt.Sprintf("%s/%s", automationRepoPath, bootstrapRemovedFile))
+
+	rb.record(bad.configRemovalStep(automationRepoPath))
+
 	// Copy final_manifests into the automation repo's ocp directory (the ocp
 	// directory is the default location that the automation scripts use for
-	// various openshift-install calls)
-	err = copy.Copy(finalManifestsPath, fmt.Sprintf("%s/ocp", automationRepoPath))
+	// various openshift-install calls). Reviewed plans reuse the planned copy
+	if len(bad.options.PlanFiles) == 0 {
+		err = copy.Copy(finalManifestsPath, fmt.Sprintf("%s/ocp", automationRepoPath))
 
-	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: DeployMasters: error copying final_manifests into automation ocp directory: %s", err)
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: DeployMasters: error copying final_manifests into automation ocp directory: %s", err)
+		}
 	}
 
 	// Now run the actual automation scripts, including ignition-generation
-	err = bad.runConfigGenerationScripts(automationRepoPath, true)
+	err = bad.runConfigGenerationScripts(automationRepoPath, "cluster")
 
 	if err != nil {
 		return err
 	}
 
-	// Then start the containers
-	err = bad.runContainers(automationRepoPath)
+	// The ocp directory now holds the ignition files and the cluster credentials,
+	// so restrict it to the owner
+	err = utils.SecureDirectory(fmt.Sprintf("%s/ocp", automationRepoPath))
 
 	if err != nil {
-		return err
+		return fmt.Errorf("baremetalAutomatedDeployment: DeployMasters: error restricting permissions of automation ocp directory: %s", err)
 	}
 
-	// Finally run terraform commands to begin cluster deployment
+	// Then start the containers, that are not needed to only plan the deployment
+	if !bad.options.Plan {
+		rb.record(bad.containerRemovalSteps(automationRepoPath)...)
+
+		err = bad.runContainers(automationRepoPath)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Finally run terraform commands to begin cluster deployment. What a reviewed
+	// plan creates is rolled back without a reviewed destroy plan
+	rollbackDeployment := bad
+	rollbackDeployment.options.PlanFiles = nil
+	rb.record(rollbackDeployment.terraformDestroyStep(automationRepoPath, "cluster"))
+
 	err = bad.runTerraform(automationRepoPath, "cluster", terraformApply)
 
 	if err != nil {
 		return err
 	}
 
+	if bad.options.Plan {
+		return nil
+	}
+
+	err = bad.validateMatchbox(automationRepoPath, "master")
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: DeployMasters: %s", err)
+	}
+
 	log.Println("baremetalAutomatedDeployment: DeployMasters: bootstrap and master(s) deploy initiated...")
 
 	return nil
@@ -366,52 +446,39 @@ func (bad baremetalAutomatedDeployment) DeployMasters() error {
 
 // automationRepoPath: contains path to automation repo directory
 func (bad baremetalAutomatedDeployment) runContainers(automationRepoPath string) error {
-	// Add scripts to run
-	scripts := []scriptRunInstance{}
+	runtime, err := container.New(bad.options.ContainerRuntime)
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "dnsmasq provisioning container start",
-		scriptFile:  "gen_config_prov.sh",
-		args:        []string{"start"},
-	})
-
-	scripts = append(scripts, scriptRunInstance{
-		description: "dnsmasq baremetal container start",
-		scriptFile:  "gen_config_bm.sh",
-		args:        []string{"start"},
-	})
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: runContainers: %s", err)
+	}
 
-	// Need to make sure haproxy is built before running
-	scripts = append(scripts, scriptRunInstance{
-		description: "haproxy container build",
-		scriptFile:  "gen_haproxy.sh",
-		args:        []string{"build"},
-	})
+	// Add scripts to run, building the images that need it before starting them
+	scripts := []scriptRunInstance{}
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "haproxy container start",
-		scriptFile:  "gen_haproxy.sh",
-		args:        []string{"start"},
-	})
+	for _, service := range bastion.Services {
+		for _, args := range service.StartCommands() {
+			scripts = append(scripts, scriptRunInstance{
+				description: fmt.Sprintf("%s container %s", service.Name, args[0]),
+				scriptFile:  service.Script,
+				args:        args,
+				env:         bastion.ScriptEnv(runtime),
+			})
+		}
+	}
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "coredns container start",
-		scriptFile:  "gen_coredns.sh",
-		args:        []string{"start"},
-	})
+	log.Printf("baremetalAutomatedDeployment: runContainers: starting bastion containers with %s...\n", runtime.Name())
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "matchbox container start",
-		scriptFile:  "gen_matchbox.sh",
-		args:        []string{"start"},
-	})
+	err = bad.runScripts(automationRepoPath, scripts)
 
-	log.Println("baremetalAutomatedDeployment: runContainers: starting bastion containers...")
+	if err != nil {
+		return err
+	}
 
-	err := bad.runScripts(automationRepoPath, scripts)
+	// The scripts do not fail when a container exits right after starting
+	err = bastion.CheckServices(runtime, bastion.Services)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("baremetalAutomatedDeployment: runContainers: %s, see knictl bastion logs", err)
 	}
 
 	log.Println("baremetalAutomatedDeployment: runContainers: bastion containers successfully started")
@@ -420,51 +487,26 @@ func (bad baremetalAutomatedDeployment) runContainers(automationRepoPath string)
 }
 
 // automationRepoPath: contains path to automation repo directory
-// automationManifestsPath: contains path to directory containing site-config.yaml, install-config.yaml
-//                          and any required credential secret yamls
-func (bad baremetalAutomatedDeployment) runConfigGenerationScripts(automationRepoPath string, includeIgnition bool) error {
+// targetType: terraform target the configuration is generated for, only the cluster gets ignition configs
+func (bad baremetalAutomatedDeployment) runConfigGenerationScripts(automationRepoPath string, targetType string) error {
+	// Reviewed plans are applied on the configuration they were planned with, as
+	// generating it again would create new ignition configs and certificates
+	if len(bad.options.PlanFiles) > 0 {
+		return bad.checkPlanInputs(targetType)
+	}
+
 	// Add scripts to run
 	scripts := []scriptRunInstance{}
 
 	// Placeholder
 	commonArgs := []string{}
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "dnsmasq provisioning config generation",
-		scriptFile:  "gen_config_prov.sh",
-		args:        commonArgs,
-	})
-
-	scripts = append(scripts, scriptRunInstance{
-		description: "dnsmasq baremetal config generation",
-		scriptFile:  "gen_config_bm.sh",
-		args:        commonArgs,
-	})
-
-	scripts = append(scripts, scriptRunInstance{
-		description: "coredns config generation",
-		scriptFile:  "gen_coredns.sh",
-		args:        append([]string{"all"}, commonArgs...),
-	})
-
-	scripts = append(scripts, scriptRunInstance{
-		description: "haproxy config generation",
-		scriptFile:  "gen_haproxy.sh",
-		args:        append(commonArgs, "gen-config"),
-	})
-
 	scripts = append(scripts, scriptRunInstance{
 		description: "matchbox repo generation",
 		scriptFile:  "gen_matchbox.sh",
 		args:        append([]string{"repo"}, commonArgs...),
 	})
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "matchbox data generation",
-		scriptFile:  "gen_matchbox.sh",
-		args:        append([]string{"data"}, commonArgs...),
-	})
-
 	scripts = append(scripts, scriptRunInstance{
 		description: "terraform cluster/work config generation",
 		scriptFile:  "gen_terraform.sh",
@@ -477,7 +519,7 @@ func (bad baremetalAutomatedDeployment) runConfigGenerationScripts(automationRep
 		args:        append([]string{"install"}, commonArgs...),
 	})
 
-	if includeIgnition {
+	if targetType == "cluster" {
 		scripts = append(scripts, scriptRunInstance{
 			description: "ignition config generation",
 			scriptFile:  "gen_ignition.sh",
@@ -487,7 +529,19 @@ func (bad baremetalAutomatedDeployment) runConfigGenerationScripts(automationRep
 
 	log.Println("baremetalAutomatedDeployment: runConfigGenerationScripts: generating configuration...")
 
-	err := bad.runScripts(automationRepoPath, scripts)
+	err := bad.generateBastionConfig(automationRepoPath)
+
+	if err != nil {
+		return err
+	}
+
+	err = bad.runScripts(automationRepoPath, scripts)
+
+	if err != nil {
+		return err
+	}
+
+	err = bad.writeMatchboxIgnition(automationRepoPath)
 
 	if err != nil {
 		return err
@@ -495,9 +549,168 @@ func (bad baremetalAutomatedDeployment) runConfigGenerationScripts(automationRep
 
 	log.Println("baremetalAutomatedDeployment: runConfigGenerationScripts: configuration successfully generated")
 
+	if bad.options.Plan {
+		return bad.savePlanInputs(targetType)
+	}
+
+	return nil
+}
+
+// the file holding the fingerprint of the inputs a terraform target was planned
+// with, in the site's plan directory
+func (bad baremetalAutomatedDeployment) planInputsFile(targetType string) string {
+	return fmt.Sprintf("%s/%s/%s/%s-inputs.sha256", bad.siteBuildPath, bad.siteName, terraformPlanDirectory, targetType)
+}
+
+// fingerprints what the configuration is generated from: the final manifests,
+// the automation manifests and the site config
+func (bad baremetalAutomatedDeployment) planInputs() (string, error) {
+	sitePath := fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName)
+
+	siteConfig, err := yaml.Marshal(bad.siteConfig)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fingerprintInputs(siteConfig, fmt.Sprintf("%s/final_manifests", sitePath), fmt.Sprintf("%s/automation", sitePath))
+}
+
+func (bad baremetalAutomatedDeployment) savePlanInputs(targetType string) error {
+	fingerprint, err := bad.planInputs()
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: savePlanInputs: error fingerprinting the %s inputs: %s", targetType, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(bad.planInputsFile(targetType)), 0700)
+
+	if err == nil {
+		err = ioutil.WriteFile(bad.planInputsFile(targetType), []byte(fingerprint+"\n"), 0600)
+	}
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: savePlanInputs: error saving the %s inputs fingerprint: %s", targetType, err)
+	}
+
+	return nil
+}
+
+// checks that the inputs did not change since the target was planned, so that
+// the planned configuration can be used as is
+func (bad baremetalAutomatedDeployment) checkPlanInputs(targetType string) error {
+	planned, err := ioutil.ReadFile(bad.planInputsFile(targetType))
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: checkPlanInputs: no %s configuration planned, run --plan again: %s", targetType, err)
+	}
+
+	fingerprint, err := bad.planInputs()
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: checkPlanInputs: error fingerprinting the %s inputs: %s", targetType, err)
+	}
+
+	if strings.TrimSpace(string(planned)) != fingerprint {
+		return fmt.Errorf("baremetalAutomatedDeployment: checkPlanInputs: the final manifests, automation manifests or site config changed since the %s was planned, run --plan again", targetType)
+	}
+
+	log.Printf("baremetalAutomatedDeployment: checkPlanInputs: using the %s configuration generated with the plan\n", targetType)
+
+	return nil
+}
+
+// Renders the dnsmasq, coredns and haproxy configuration from the typed provisioning
+// infrastructure, into the automation repo directories that the bastion containers
+// started by the scripts mount
+func (bad baremetalAutomatedDeployment) generateBastionConfig(automationRepoPath string) error {
+	installConfigPath := fmt.Sprintf("%s/%s/automation/install-config.yaml", bad.siteBuildPath, bad.siteName)
+	installConfigFile, err := ioutil.ReadFile(installConfigPath)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: generateBastionConfig: error reading install-config.yaml: %s", err)
+	}
+
+	var installConfig baremetalInstallConfig
+
+	err = yaml.Unmarshal(installConfigFile, &installConfig)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: generateBastionConfig: error unmarshalling install-config.yaml: %s", err)
+	}
+
+	cluster := bastion.Cluster{Name: installConfig.Metadata.Name, BaseDomain: installConfig.BaseDomain}
+	provisioningInfrastructure := *bad.siteConfig.ProvisioningInfrastructure
+
+	// Once removed, the bootstrap node is not an api backend anymore
+	if _, err := os.Stat(fmt.Sprintf("%s/%s", automationRepoPath, bootstrapRemovedFile)); err == nil {
+		provisioningInfrastructure.Network.BootstrapIP = ""
+	}
+
+	// The matchbox profiles and groups are created by terraform with the hosts
+	generators := []struct {
+		service  string
+		generate func() (bastion.ConfigFiles, error)
+	}{
+		{"dnsmasq", func() (bastion.ConfigFiles, error) { return bastion.GenerateDnsmasq(provisioningInfrastructure) }},
+		{"coredns", func() (bastion.ConfigFiles, error) {
+			return bastion.GenerateCoreDNS(provisioningInfrastructure, cluster)
+		}},
+		{"haproxy", func() (bastion.ConfigFiles, error) { return bastion.GenerateHAProxy(provisioningInfrastructure) }},
+	}
+
+	for _, generator := range generators {
+		files, err := generator.generate()
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: generateBastionConfig: error generating %s configuration: %s", generator.service, err)
+		}
+
+		err = files.Write(automationRepoPath)
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: generateBastionConfig: error writing %s configuration: %s", generator.service, err)
+		}
+
+		log.Printf("baremetalAutomatedDeployment: generateBastionConfig: generated %s configuration\n", generator.service)
+	}
+
+	return nil
+}
+
+// Copies the ignition configs generated in the ocp directory to the matchbox data,
+// where matchbox serves them to the profiles of the hosts
+func (bad baremetalAutomatedDeployment) writeMatchboxIgnition(automationRepoPath string) error {
+	for _, role := range bastion.MatchboxIgnitionRoles {
+		content, err := ioutil.ReadFile(fmt.Sprintf("%s/ocp/%s.ign", automationRepoPath, role))
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: writeMatchboxIgnition: error reading %s ignition config: %s", role, err)
+		}
+
+		// Ignition configs hold the cluster certificates
+		err = utils.WriteSecretFile(fmt.Sprintf("%s/%s/%s.ign", automationRepoPath, bastion.MatchboxIgnitionDirectory, role), content)
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: writeMatchboxIgnition: error writing %s ignition config: %s", role, err)
+		}
+	}
+
 	return nil
 }
 
+// Checks that the hosts with those roles can boot from the matchbox data that
+// terraform deployed: every host needs a group selecting its MAC address, with
+// a profile booting it as the site config says, and the ignition config of
+// the profile
+func (bad baremetalAutomatedDeployment) validateMatchbox(automationRepoPath string, roles ...string) error {
+	return bastion.ValidateMatchbox(*bad.siteConfig.ProvisioningInfrastructure, automationRepoPath, roles...)
+}
+
 func (bad baremetalAutomatedDeployment) DeployWorkers() error {
 	sitePath := fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName)
 	automationRepoPath := fmt.Sprintf("%s/baremetal_automation", sitePath)
@@ -508,6 +721,23 @@ func (bad baremetalAutomatedDeployment) DeployWorkers() error {
 		return fmt.Errorf("baremetalAutomatedDeployment: DeployWorkers: unable to access local automation repo at %s: %s", automationRepoPath, err)
 	}
 
+	// Make sure the automation code is still the one fetched with the requirements
+	err = checkAutomationSource(sitePath, automationRepoPath, bad.siteConfig.ProvisioningInfrastructure.Automation)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: DeployWorkers: %s", err)
+	}
+
+	// The bootstrap node is removed once the workers are deployed, so its reviewed
+	// plan is needed before anything is applied
+	if len(bad.options.PlanFiles) > 0 && !bad.options.KeepBootstrap && !bad.bootstrapRemoved(automationRepoPath) {
+		_, err = findTerraformPlanFile(bad.options.PlanFiles, bootstrapPlanName, terraformDestroy)
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: DeployWorkers: %s, or pass --keep_bootstrap", err)
+		}
+	}
+
 	// Make sure automation-required manifests are available (these YAMLs should have been copied
 	// to the directory during prepare_manifests)
 	automationManifestsPath := fmt.Sprintf("%s/automation", sitePath)
@@ -519,17 +749,19 @@ func (bad baremetalAutomatedDeployment) DeployWorkers() error {
 	}
 
 	// Now run the actual automation scripts, minus ignition-generation
-	err = bad.runConfigGenerationScripts(automationRepoPath, false)
+	err = bad.runConfigGenerationScripts(automationRepoPath, "workers")
 
 	if err != nil {
 		return err
 	}
 
-	// Then start the containers
-	err = bad.runContainers(automationRepoPath)
+	// Then start the containers, that are not needed to only plan the deployment
+	if !bad.options.Plan {
+		err = bad.runContainers(automationRepoPath)
 
-	if err != nil {
-		return err
+		if err != nil {
+			return err
+		}
 	}
 
 	// Finally run terraform commands to begin workers deployment
@@ -539,87 +771,175 @@ func (bad baremetalAutomatedDeployment) DeployWorkers() error {
 		return err
 	}
 
+	if bad.options.Plan {
+		// The removal of the bootstrap node is reviewed with the workers
+		if bad.options.KeepBootstrap || bad.bootstrapRemoved(automationRepoPath) {
+			return nil
+		}
+
+		return bad.destroyBootstrapTerraform(automationRepoPath)
+	}
+
+	err = bad.validateMatchbox(automationRepoPath, "worker")
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: DeployWorkers: %s", err)
+	}
+
 	log.Println("baremetalAutomatedDeployment: DeployWorkers: worker(s) deploy initiated...")
 
-	return nil
+	if bad.options.KeepBootstrap {
+		log.Println("baremetalAutomatedDeployment: DeployWorkers: keeping the bootstrap node, run deploy_workers again without --keep_bootstrap to remove it")
+		return nil
+	}
+
+	return bad.removeBootstrap(automationRepoPath)
 }
 
-func (bad baremetalAutomatedDeployment) DestroyCluster() error {
-	sitePath := fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName)
-	automationRepoPath := fmt.Sprintf("%s/baremetal_automation", sitePath)
+// Waits for the bootstrap to complete, and then removes the bootstrap node from the
+// haproxy backends and destroys its terraform resources
+func (bad baremetalAutomatedDeployment) removeBootstrap(automationRepoPath string) error {
+	if bad.bootstrapRemoved(automationRepoPath) {
+		log.Println("baremetalAutomatedDeployment: removeBootstrap: bootstrap node already removed")
+		return nil
+	}
 
-	_, err := os.Stat(automationRepoPath)
+	err := waitForBootstrapComplete(fmt.Sprintf("%s/requirements/openshift-install", automationRepoPath), fmt.Sprintf("%s/ocp", automationRepoPath))
 
 	if err != nil {
-		return fmt.Errorf("baremetalAutomatedDeployment: DestroyCluster: unable to access local automation repo at %s: %s", automationRepoPath, err)
+		return fmt.Errorf("baremetalAutomatedDeployment: removeBootstrap: %s", err)
+	}
+
+	// Stop load balancing to the bootstrap node first
+	provisioningInfrastructure := *bad.siteConfig.ProvisioningInfrastructure
+	provisioningInfrastructure.Network.BootstrapIP = ""
+
+	haproxyFiles, err := bastion.GenerateHAProxy(provisioningInfrastructure)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: removeBootstrap: error generating haproxy configuration: %s", err)
 	}
 
-	// Destroy workers via terraform
-	err = bad.runTerraform(automationRepoPath, "workers", terraformDestroy)
+	err = haproxyFiles.Write(automationRepoPath)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: removeBootstrap: error writing haproxy configuration: %s", err)
+	}
+
+	// The haproxy image holds its configuration, so it is rebuilt
+	scripts := []scriptRunInstance{}
+
+	for _, action := range []string{"remove", "build", "start"} {
+		scripts = append(scripts, scriptRunInstance{
+			description: fmt.Sprintf("haproxy container %s", action),
+			scriptFile:  "gen_haproxy.sh",
+			args:        []string{action},
+		})
+	}
+
+	err = bad.runScripts(automationRepoPath, scripts)
 
 	if err != nil {
 		return err
 	}
 
-	// Destroy masters via terraform
-	err = bad.runTerraform(automationRepoPath, "cluster", terraformDestroy)
+	log.Println("baremetalAutomatedDeployment: removeBootstrap: destroying the bootstrap node...")
+
+	err = bad.destroyBootstrapTerraform(automationRepoPath)
 
 	if err != nil {
 		return err
 	}
 
-	// Remove bastion (provisioning host) containers
-	scripts := []scriptRunInstance{}
+	err = ioutil.WriteFile(fmt.Sprintf("%s/%s", automationRepoPath, bootstrapRemovedFile), []byte{}, 0644)
 
-	commonArgs := []string{"remove"}
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: removeBootstrap: error recording the bootstrap removal: %s", err)
+	}
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "dnsmasq provisioning container removal",
-		scriptFile:  "gen_config_prov.sh",
-		args:        commonArgs,
-	})
+	log.Println("baremetalAutomatedDeployment: removeBootstrap: bootstrap node removed")
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "dnsmasq baremetal container removal",
-		scriptFile:  "gen_config_bm.sh",
-		args:        commonArgs,
-	})
+	return nil
+}
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "haproxy container removal",
-		scriptFile:  "gen_haproxy.sh",
-		args:        commonArgs,
-	})
+// Whether the bootstrap node of the deployed masters was removed already
+func (bad baremetalAutomatedDeployment) bootstrapRemoved(automationRepoPath string) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/%s", automationRepoPath, bootstrapRemovedFile))
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "coredns container removal",
-		scriptFile:  "gen_coredns.sh",
-		args:        commonArgs,
-	})
+	return err == nil
+}
 
-	scripts = append(scripts, scriptRunInstance{
-		description: "matchbox container removal",
-		scriptFile:  "gen_matchbox.sh",
-		args:        commonArgs,
-	})
+// Destroys the terraform resources of the bootstrap node, that belong to the
+// cluster target. Like the other terraform operations, the destroy is only
+// planned with --plan, and applied from its reviewed plan with --plan_file
+func (bad baremetalAutomatedDeployment) destroyBootstrapTerraform(automationRepoPath string) error {
+	terraformPath := fmt.Sprintf("%s/terraform/cluster", automationRepoPath)
+	target := fmt.Sprintf("-target=%s", bootstrapTerraformTarget)
 
-	err = bad.runScripts(automationRepoPath, scripts)
+	if bad.options.Plan {
+		return bad.planTerraform(terraformPath, bootstrapPlanName, terraformDestroy, target)
+	}
+
+	var cmd *exec.Cmd
+
+	if len(bad.options.PlanFiles) > 0 {
+		planFile, err := findTerraformPlanFile(bad.options.PlanFiles, bootstrapPlanName, terraformDestroy)
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: destroyBootstrapTerraform: %s", err)
+		}
+
+		log.Printf("baremetalAutomatedDeployment: destroyBootstrapTerraform: applying reviewed terraform destroy plan %s...\n", planFile)
+
+		cmd = exec.Command("terraform", "apply", "-input=false", planFile)
+	} else {
+		cmd = exec.Command("terraform", string(terraformDestroy), target, "--auto-approve")
+	}
+
+	cmd.Dir = terraformPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
 
 	if err != nil {
-		return err
+		return fmt.Errorf("baremetalAutomatedDeployment: destroyBootstrapTerraform: error destroying the bootstrap terraform resources: %s", err)
+	}
+
+	return nil
+}
+
+func (bad baremetalAutomatedDeployment) DestroyCluster() error {
+	sitePath := fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName)
+	automationRepoPath := fmt.Sprintf("%s/baremetal_automation", sitePath)
+
+	_, err := os.Stat(automationRepoPath)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: DestroyCluster: unable to access local automation repo at %s: %s", automationRepoPath, err)
+	}
+
+	// Destroy workers and then masters via terraform. The workers destroy fails
+	// when they were never deployed, and the masters are still destroyed
+	steps := []teardownStep{
+		bad.terraformDestroyStep(automationRepoPath, "workers"),
+		bad.terraformDestroyStep(automationRepoPath, "cluster"),
 	}
 
-	// Clear config directories
-	dirs := []string{
-		"build",
-		"coredns",
-		"dnsmasq",
-		"haproxy",
-		"ocp",
+	// Only the terraform resources are planned, the bastion is left as is
+	if !bad.options.Plan {
+		steps = append(steps, bad.containerRemovalSteps(automationRepoPath)...)
+		steps = append(steps, bad.configRemovalStep(automationRepoPath))
 	}
 
-	for _, dir := range dirs {
-		os.RemoveAll(fmt.Sprintf("%s/%s", automationRepoPath, dir))
+	err = runTeardown("baremetalAutomatedDeployment: DestroyCluster", steps, bad.options.FailFast)
+
+	if err != nil {
+		return err
+	}
+
+	if bad.options.Plan {
+		return nil
 	}
 
 	log.Println("baremetalAutomatedDeployment: DestroyCluster: cluster teardown completed")
@@ -627,6 +947,80 @@ func (bad baremetalAutomatedDeployment) DestroyCluster() error {
 	return nil
 }
 
+// teardown step destroying the terraform resources of the target type
+func (bad baremetalAutomatedDeployment) terraformDestroyStep(automationRepoPath string, targetType string) teardownStep {
+	return teardownStep{
+		description: fmt.Sprintf("%s terraform destroy", targetType),
+		run: func() error {
+			return bad.runTerraform(automationRepoPath, targetType, terraformDestroy)
+		},
+	}
+}
+
+// teardown steps removing the bastion (provisioning host) containers
+func (bad baremetalAutomatedDeployment) containerRemovalSteps(automationRepoPath string) []teardownStep {
+	steps := []teardownStep{}
+
+	for _, service := range bastion.Services {
+		script := scriptRunInstance{
+			description: fmt.Sprintf("%s container removal", service.Name),
+			scriptFile:  service.Script,
+			args:        []string{"remove"},
+		}
+		steps = append(steps, teardownStep{
+			description: script.description,
+			run: func() error {
+				runtime, err := container.New(bad.options.ContainerRuntime)
+
+				if err != nil {
+					return err
+				}
+
+				script.env = bastion.ScriptEnv(runtime)
+				return bad.runScripts(automationRepoPath, []scriptRunInstance{script})
+			},
+		})
+	}
+
+	return steps
+}
+
+// teardown step clearing the config directories generated in the automation repo
+func (bad baremetalAutomatedDeployment) configRemovalStep(automationRepoPath string) teardownStep {
+	return teardownStep{
+		description: "config directories removal",
+		run: func() error {
+			dirs := []string{
+				"build",
+				"coredns",
+				"dnsmasq",
+				"haproxy",
+				"ocp",
+				bastion.MatchboxIgnitionDirectory,
+			}
+
+			for _, dir := range dirs {
+				err := os.RemoveAll(fmt.Sprintf("%s/%s", automationRepoPath, dir))
+
+				if err != nil {
+					return err
+				}
+			}
+
+			// The planned configuration is gone, so its plans cannot be applied anymore
+			for _, targetType := range []string{"cluster", "workers"} {
+				err := os.Remove(bad.planInputsFile(targetType))
+
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
 func (bad baremetalAutomatedDeployment) runTerraform(automationRepoPath string, targetType string, operation terraformOperation) error {
 	terraformPath := fmt.Sprintf("%s/terraform/%s", automationRepoPath, targetType)
 
@@ -645,10 +1039,30 @@ func (bad baremetalAutomatedDeployment) runTerraform(automationRepoPath string,
 	}
 
 	log.Println("baremetalAutomatedDeployment: runTerraform: terraform successfully initialized")
-	log.Printf("baremetalAutomatedDeployment: runTerraform: running terraform %s...\n", operation)
+
+	if bad.options.Plan {
+		return bad.planTerraform(terraformPath, targetType, operation)
+	}
+
+	if len(bad.options.PlanFiles) > 0 {
+		// Saved plans are applied without confirmation, as they have been reviewed,
+		// and terraform refuses them if the state changed since they were created
+		planFile, err := findTerraformPlanFile(bad.options.PlanFiles, targetType, operation)
+
+		if err != nil {
+			return fmt.Errorf("baremetalAutomatedDeployment: runTerraform: %s", err)
+		}
+
+		log.Printf("baremetalAutomatedDeployment: runTerraform: applying reviewed terraform %s plan %s...\n", operation, planFile)
+
+		cmd = exec.Command("terraform", "apply", "-input=false", planFile)
+	} else {
+		log.Printf("baremetalAutomatedDeployment: runTerraform: running terraform %s...\n", operation)
+
+		cmd = exec.Command("terraform", string(operation), "--auto-approve")
+	}
 
 	// Apply
-	cmd = exec.Command("terraform", string(operation), "--auto-approve")
 	cmd.Dir = terraformPath
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -664,10 +1078,64 @@ func (bad baremetalAutomatedDeployment) runTerraform(automationRepoPath string,
 	return nil
 }
 
+// Saves the plan of a terraform operation in the site's plan directory, and prints
+// a summary of the resources it changes. The plan output is only shown on errors.
+// The extra arguments are passed to terraform plan, like the resources to target
+func (bad baremetalAutomatedDeployment) planTerraform(terraformPath string, targetType string, operation terraformOperation, extraArgs ...string) error {
+	planDirectory := fmt.Sprintf("%s/%s/%s", bad.siteBuildPath, bad.siteName, terraformPlanDirectory)
+
+	// Plans hold the values of the terraform variables, so restrict them to the owner
+	err := os.MkdirAll(planDirectory, 0700)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: planTerraform: error creating plan directory: %s", err)
+	}
+
+	planFile := fmt.Sprintf("%s/%s", planDirectory, terraformPlanFileName(targetType, operation))
+	args := []string{"plan", "-input=false", fmt.Sprintf("-out=%s", planFile)}
+
+	if operation == terraformDestroy {
+		args = append(args, "-destroy")
+	}
+
+	args = append(args, extraArgs...)
+
+	log.Printf("baremetalAutomatedDeployment: planTerraform: planning terraform %s of %s...\n", operation, targetType)
+
+	var output bytes.Buffer
+
+	cmd := exec.Command("terraform", args...)
+	cmd.Dir = terraformPath
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err = cmd.Run()
+
+	if err != nil {
+		os.Stderr.Write(output.Bytes())
+		return fmt.Errorf("baremetalAutomatedDeployment: planTerraform: error running baremetal automation %s terraform plan: %s", targetType, err)
+	}
+
+	os.Chmod(planFile, 0600)
+
+	summary, err := summarizeTerraformPlan(terraformPath, planFile)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: planTerraform: %s", err)
+	}
+
+	fmt.Printf("Terraform %s plan of %s, saved to %s:\n%s", operation, targetType, planFile, summary)
+
+	return nil
+}
+
 func (bad baremetalAutomatedDeployment) runScripts(automationRepoPath string, scripts []scriptRunInstance) error {
 	for _, script := range scripts {
 		cmd := exec.Command(fmt.Sprintf("%s/scripts/%s", automationRepoPath, script.scriptFile), script.args...)
 		cmd.Dir = automationRepoPath
+		if len(script.env) > 0 {
+			cmd.Env = append(os.Environ(), script.env...)
+		}
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 
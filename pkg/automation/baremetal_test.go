@@ -0,0 +1,62 @@
+package automation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+func TestBaremetalPlanInputs(t *testing.T) {
+	buildPath := t.TempDir()
+	sitePath := filepath.Join(buildPath, "edge.example.com")
+
+	for _, path := range []string{"final_manifests/install-config.yaml", "final_manifests/openshift/99_kni.yaml", "automation/site-config.yaml"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(sitePath, path)), 0700)
+		ioutil.WriteFile(filepath.Join(sitePath, path), []byte(path), 0600)
+	}
+
+	bad := baremetalAutomatedDeployment{siteBuildPath: buildPath, siteName: "edge.example.com"}
+	bad.options.PlanFiles = []string{"cluster-apply.tfplan"}
+
+	err := bad.runConfigGenerationScripts(filepath.Join(sitePath, "baremetal_automation"), "cluster")
+	if err == nil || !strings.Contains(err.Error(), "no cluster configuration planned, run --plan again") {
+		t.Errorf("expected applying an unplanned configuration to fail, got %v", err)
+	}
+
+	err = bad.savePlanInputs("cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the scripts are not run, there are none in the automation repo
+	err = bad.runConfigGenerationScripts(filepath.Join(sitePath, "baremetal_automation"), "cluster")
+	if err != nil {
+		t.Errorf("expected the planned configuration to be reused, got %s", err)
+	}
+
+	for name, change := range map[string]func(){
+		"final manifest": func() {
+			ioutil.WriteFile(filepath.Join(sitePath, "final_manifests/openshift/99_kni.yaml"), []byte("changed"), 0600)
+		},
+		"new automation manifest": func() {
+			ioutil.WriteFile(filepath.Join(sitePath, "automation/pull-secret.yaml"), nil, 0600)
+		},
+		"site config": func() {
+			bad.siteConfig = siteconfig.SiteConfig{Metadata: siteconfig.Metadata{Name: "other"}}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bad.savePlanInputs("cluster")
+			change()
+
+			err := bad.checkPlanInputs("cluster")
+			if err == nil || !strings.Contains(err.Error(), "changed since the cluster was planned, run --plan again") {
+				t.Errorf("expected a changed %s to fail, got %v", name, err)
+			}
+		})
+	}
+}
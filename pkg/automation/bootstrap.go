@@ -0,0 +1,29 @@
+package automation
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// Waits until the installer reports that the control plane is up and does not
+// need the bootstrap node anymore. installDir is the directory the ignition
+// configs were generated in, that holds the installer state
+func waitForBootstrapComplete(installerPath string, installDir string) error {
+	log.Println("waitForBootstrapComplete: waiting for the bootstrap to complete...")
+
+	cmd := exec.Command(installerPath, "wait-for", "bootstrap-complete", fmt.Sprintf("--dir=%s", installDir), "--log-level=info")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+
+	if err != nil {
+		return fmt.Errorf("waitForBootstrapComplete: error waiting for the bootstrap to complete, see %s/.openshift_install.log: %s", installDir, err)
+	}
+
+	log.Println("waitForBootstrapComplete: bootstrap completed")
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package automation
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// gcpServiceAccount : the fields of a GCP service account key used by the installer
+type gcpServiceAccount struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	ClientID     string `json:"client_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// gcpInstallConfig : the fields of a GCP install-config.yaml checked against the service account
+type gcpInstallConfig struct {
+	Platform struct {
+		GCP *struct {
+			ProjectID string `yaml:"projectID"`
+		} `yaml:"gcp"`
+	} `yaml:"platform"`
+}
+
+func newGCP(params AutomatedDeploymentParams) (AutomatedDeploymentInterface, error) {
+	err := checkNoTerraformPlan(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	installConfigPath := fmt.Sprintf("%s/%s/automation/install-config.yaml", params.SiteBuildPath, params.SiteName)
+
+	return ipiAutomatedDeployment{
+		siteBuildPath: params.SiteBuildPath,
+		siteName:      params.SiteName,
+		siteRepo:      params.SiteRepo,
+		platform:      "gcp",
+		checkCredentials: func() error {
+			return checkGCPServiceAccount(installConfigPath)
+		},
+		checkInstallConfig: func() error {
+			return checkGCPProject(installConfigPath)
+		},
+	}, nil
+}
+
+// validates the service account key used by the installer, and checks that it
+// belongs to the project of the install-config. The install-config is only
+// available once the manifests are prepared, so the project is not checked before
+func checkGCPServiceAccount(installConfigPath string) error {
+	serviceAccount, serviceAccountPath, err := readGCPServiceAccount()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(installConfigPath)
+
+	if os.IsNotExist(err) {
+		log.Printf("Automation: checkGCPServiceAccount: install-config.yaml not prepared yet, the project of service account %s will be checked when deploying\n", serviceAccount.ClientEmail)
+		return nil
+	}
+
+	return checkGCPInstallConfigProject(installConfigPath, serviceAccount, serviceAccountPath)
+}
+
+// checks the project of the install-config once the manifests are prepared. The
+// service account may still be missing, it is only required when deploying
+func checkGCPProject(installConfigPath string) error {
+	serviceAccount, serviceAccountPath, err := readGCPServiceAccount()
+
+	if err != nil {
+		log.Printf("Automation: checkGCPProject: WARNING: %s\n", err)
+		return nil
+	}
+
+	return checkGCPInstallConfigProject(installConfigPath, serviceAccount, serviceAccountPath)
+}
+
+// reads and validates the service account key used by the installer, returning it with its path
+func readGCPServiceAccount() (gcpServiceAccount, string, error) {
+	var serviceAccount gcpServiceAccount
+
+	serviceAccountPath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if serviceAccountPath == "" {
+		serviceAccountPath = fmt.Sprintf("%s/.gcp/osServiceAccount.json", os.Getenv("HOME"))
+	}
+
+	serviceAccountFile, err := ioutil.ReadFile(serviceAccountPath)
+
+	if err != nil {
+		return serviceAccount, serviceAccountPath, fmt.Errorf("Automation: readGCPServiceAccount: error reading GCP service account, see the README for the content of %s: %s", serviceAccountPath, err)
+	}
+
+	err = json.Unmarshal(serviceAccountFile, &serviceAccount)
+
+	if err != nil {
+		return serviceAccount, serviceAccountPath, fmt.Errorf("Automation: readGCPServiceAccount: invalid JSON in %s: %s", serviceAccountPath, err)
+	}
+
+	problems := []string{}
+
+	if serviceAccount.Type != "service_account" {
+		problems = append(problems, fmt.Sprintf("type must be service_account, got %q", serviceAccount.Type))
+	}
+
+	requiredFields := map[string]string{
+		"project_id":     serviceAccount.ProjectID,
+		"private_key_id": serviceAccount.PrivateKeyID,
+		"private_key":    serviceAccount.PrivateKey,
+		"client_email":   serviceAccount.ClientEmail,
+		"client_id":      serviceAccount.ClientID,
+		"token_uri":      serviceAccount.TokenURI,
+	}
+
+	for _, field := range []string{"project_id", "private_key_id", "private_key", "client_email", "client_id", "token_uri"} {
+		if strings.TrimSpace(requiredFields[field]) == "" {
+			problems = append(problems, fmt.Sprintf("%s is required", field))
+		}
+	}
+
+	if serviceAccount.PrivateKey != "" {
+		if block, _ := pem.Decode([]byte(serviceAccount.PrivateKey)); block == nil {
+			problems = append(problems, "private_key is not a PEM encoded key")
+		}
+	}
+
+	if len(problems) > 0 {
+		return serviceAccount, serviceAccountPath, fmt.Errorf("Automation: readGCPServiceAccount: invalid service account %s:\n  - %s", serviceAccountPath, strings.Join(problems, "\n  - "))
+	}
+
+	return serviceAccount, serviceAccountPath, nil
+}
+
+// checks that the service account belongs to the project of the install-config
+func checkGCPInstallConfigProject(installConfigPath string, serviceAccount gcpServiceAccount, serviceAccountPath string) error {
+	installConfigFile, err := ioutil.ReadFile(installConfigPath)
+
+	if err != nil {
+		return fmt.Errorf("Automation: checkGCPInstallConfigProject: error reading install-config.yaml: %s", err)
+	}
+
+	var installConfig gcpInstallConfig
+
+	err = yaml.Unmarshal(installConfigFile, &installConfig)
+
+	if err != nil {
+		return fmt.Errorf("Automation: checkGCPInstallConfigProject: error unmarshalling install-config.yaml: %s", err)
+	}
+
+	if installConfig.Platform.GCP == nil {
+		return fmt.Errorf("Automation: checkGCPInstallConfigProject: install-config.yaml has no gcp platform")
+	}
+
+	if installConfig.Platform.GCP.ProjectID != serviceAccount.ProjectID {
+		return fmt.Errorf("Automation: checkGCPInstallConfigProject: service account %s belongs to project %q, but install-config.yaml platform.gcp.projectID is %q", serviceAccountPath, serviceAccount.ProjectID, installConfig.Platform.GCP.ProjectID)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package automation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// ipiAutomatedDeployment : automation for the platforms where openshift-install
+// provisions the infrastructure itself. The installer runs on the site's
+// final_manifests, so the kubeconfig ends up where apply_workloads expects it
+type ipiAutomatedDeployment struct {
+	siteBuildPath      string
+	siteName           string
+	siteRepo           string
+	platform           string
+	checkCredentials   func() error // checks the platform credentials before calling the installer
+	checkInstallConfig func() error // optional, checks the prepared install-config against the platform
+}
+
+// ipiMetadata : the fields of the metadata.json written by the installer, that
+// identifies the cluster resources to destroy
+type ipiMetadata struct {
+	ClusterName string `json:"clusterName"`
+	ClusterID   string `json:"clusterID"`
+	InfraID     string `json:"infraID"`
+}
+
+func (iad ipiAutomatedDeployment) PrepareAutomation(requirements map[string]string) error {
+	// Report missing credentials early, they are only required when calling the installer
+	err := iad.checkCredentials()
+
+	if err != nil {
+		log.Printf("ipiAutomatedDeployment: PrepareAutomation: WARNING: %s\n", err)
+	}
+
+	return nil
+}
+
+func (iad ipiAutomatedDeployment) FinalizeAutomationPreparation() error {
+	// The installer consumes the final manifests directly, so only the install-config
+	// is checked, now that it is prepared
+	if iad.checkInstallConfig == nil {
+		return nil
+	}
+
+	return iad.checkInstallConfig()
+}
+
+func (iad ipiAutomatedDeployment) DeployMasters() error {
+	err := iad.checkCredentials()
+
+	if err != nil {
+		return err
+	}
+
+	finalManifestsPath := fmt.Sprintf("%s/%s/final_manifests", iad.siteBuildPath, iad.siteName)
+
+	_, err = os.Stat(finalManifestsPath)
+
+	if err != nil {
+		return fmt.Errorf("ipiAutomatedDeployment: DeployMasters: unable to access final manifests at %s: %s", finalManifestsPath, err)
+	}
+
+	// The installer consumes the manifests, so a second run needs a destroy and a new prepare_manifests
+	if _, err := os.Stat(fmt.Sprintf("%s/metadata.json", finalManifestsPath)); err == nil {
+		return fmt.Errorf("ipiAutomatedDeployment: DeployMasters: a cluster was already created from %s, destroy it and prepare the manifests again before redeploying", finalManifestsPath)
+	}
+
+	log.Printf("ipiAutomatedDeployment: DeployMasters: creating %s cluster, this can take a while...\n", iad.platform)
+
+	err = iad.runInstaller("create", "cluster")
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("ipiAutomatedDeployment: DeployMasters: %s cluster created\n", iad.platform)
+
+	return nil
+}
+
+func (iad ipiAutomatedDeployment) DeployWorkers() error {
+	// Workers are created by the cluster itself, so this waits for the installation
+	// to complete, which includes them
+	_, err := iad.readMetadata()
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("ipiAutomatedDeployment: DeployWorkers: waiting for the installation to complete...")
+
+	err = iad.runInstaller("wait-for", "install-complete")
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("ipiAutomatedDeployment: DeployWorkers: installation complete")
+
+	return nil
+}
+
+func (iad ipiAutomatedDeployment) DestroyCluster() error {
+	metadata, err := iad.readMetadata()
+
+	if err != nil {
+		return err
+	}
+
+	err = iad.checkCredentials()
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("ipiAutomatedDeployment: DestroyCluster: destroying %s cluster %s (infrastructure id %s)...\n", iad.platform, metadata.ClusterName, metadata.InfraID)
+
+	err = iad.runInstaller("destroy", "cluster")
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("ipiAutomatedDeployment: DestroyCluster: cluster teardown completed")
+
+	return nil
+}
+
+// reads the metadata.json written by the installer when creating the cluster
+func (iad ipiAutomatedDeployment) readMetadata() (ipiMetadata, error) {
+	var metadata ipiMetadata
+
+	metadataPath := fmt.Sprintf("%s/%s/final_manifests/metadata.json", iad.siteBuildPath, iad.siteName)
+	metadataFile, err := ioutil.ReadFile(metadataPath)
+
+	if err != nil {
+		return metadata, fmt.Errorf("ipiAutomatedDeployment: readMetadata: error reading cluster metadata, the cluster may not have been created yet: %s", err)
+	}
+
+	err = json.Unmarshal(metadataFile, &metadata)
+
+	if err != nil {
+		return metadata, fmt.Errorf("ipiAutomatedDeployment: readMetadata: error parsing %s: %s", metadataPath, err)
+	}
+
+	if metadata.InfraID == "" {
+		return metadata, fmt.Errorf("ipiAutomatedDeployment: readMetadata: %s has no infraID", metadataPath)
+	}
+
+	return metadata, nil
+}
+
+// runs openshift-install on the final manifests with profile.env loaded,
+// streaming its output
+func (iad ipiAutomatedDeployment) runInstaller(args ...string) error {
+	sitePath := fmt.Sprintf("%s/%s", iad.siteBuildPath, iad.siteName)
+
+	environment, err := profileEnvironment(fmt.Sprintf("%s/profile.env", sitePath))
+
+	if err != nil {
+		return err
+	}
+
+	args = append(args, fmt.Sprintf("--dir=%s/final_manifests", sitePath), "--log-level=info")
+
+	cmd := exec.Command(fmt.Sprintf("%s/requirements/openshift-install", sitePath), args...)
+	cmd.Env = environment
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+
+	if err != nil {
+		return fmt.Errorf("ipiAutomatedDeployment: runInstaller: error running openshift-install %s %s, see %s/final_manifests/.openshift_install.log: %s", args[0], args[1], sitePath, err)
+	}
+
+	return nil
+}
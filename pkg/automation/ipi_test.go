@@ -0,0 +1,176 @@
+package automation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fake openshift-install logging its arguments and the profile.env variable it
+// was given, and writing the metadata.json of the created cluster to --dir
+const fakeOpenshiftInstall = `#!/bin/sh
+echo "$* PROFILE_VAR=$PROFILE_VAR" >> "$FAKE_INSTALLER_LOG"
+[ -n "$FAKE_INSTALLER_FAIL" ] && exit 1
+for arg in "$@"; do
+    case "$arg" in
+    --dir=*) dir="${arg#--dir=}" ;;
+    esac
+done
+if [ "$1 $2" = "create cluster" ]; then
+    echo '{"clusterName": "edge", "clusterID": "0a1b", "infraID": "edge-x7k2p"}' > "$dir/metadata.json"
+fi
+exit 0
+`
+
+// creates an AWS site with its final manifests, a profile.env and the fake
+// installer, returning the deployment and the log of the installer calls
+func newTestIPIDeployment(t *testing.T) (ipiAutomatedDeployment, string) {
+	t.Helper()
+
+	buildPath := t.TempDir()
+	sitePath := filepath.Join(buildPath, "edge.example.com")
+
+	os.MkdirAll(filepath.Join(sitePath, "final_manifests"), 0755)
+	os.MkdirAll(filepath.Join(sitePath, "requirements"), 0755)
+
+	err := ioutil.WriteFile(filepath.Join(sitePath, "profile.env"), []byte("export PROFILE_VAR=\"from-profile\"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(sitePath, "requirements/openshift-install"), []byte(fakeOpenshiftInstall), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	installerLog := filepath.Join(buildPath, "installer.log")
+	t.Setenv("FAKE_INSTALLER_LOG", installerLog)
+
+	// credentials only come from the environment of the test
+	t.Setenv("HOME", buildPath)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	iad := ipiAutomatedDeployment{
+		siteBuildPath:    buildPath,
+		siteName:         "edge.example.com",
+		platform:         "aws",
+		checkCredentials: checkAWSCredentials,
+	}
+
+	return iad, installerLog
+}
+
+func readInstallerCalls(t *testing.T, installerLog string) []string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(installerLog)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestIPILifecycle(t *testing.T) {
+	iad, installerLog := newTestIPIDeployment(t)
+	dir := "--dir=" + filepath.Join(iad.siteBuildPath, iad.siteName, "final_manifests")
+
+	for _, step := range []struct {
+		name string
+		run  func() error
+	}{
+		{"DeployMasters", iad.DeployMasters},
+		{"DeployWorkers", iad.DeployWorkers},
+		{"DestroyCluster", iad.DestroyCluster},
+	} {
+		err := step.run()
+		if err != nil {
+			t.Fatalf("unexpected error in %s: %s", step.name, err)
+		}
+	}
+
+	want := []string{
+		"create cluster " + dir + " --log-level=info PROFILE_VAR=from-profile",
+		"wait-for install-complete " + dir + " --log-level=info PROFILE_VAR=from-profile",
+		"destroy cluster " + dir + " --log-level=info PROFILE_VAR=from-profile",
+	}
+	got := readInstallerCalls(t, installerLog)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected installer calls:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestIPIMetadata(t *testing.T) {
+	iad, installerLog := newTestIPIDeployment(t)
+	metadataPath := filepath.Join(iad.siteBuildPath, iad.siteName, "final_manifests/metadata.json")
+
+	for _, tc := range []struct {
+		name     string
+		metadata string
+		run      func() error
+		err      string
+	}{
+		{"workers before masters", "", iad.DeployWorkers, "error reading cluster metadata, the cluster may not have been created yet"},
+		{"destroy before deploy", "", iad.DestroyCluster, "error reading cluster metadata, the cluster may not have been created yet"},
+		{"invalid metadata", "{", iad.DestroyCluster, "error parsing " + metadataPath},
+		{"metadata without infraID", `{"clusterName": "edge"}`, iad.DestroyCluster, metadataPath + " has no infraID"},
+		{"redeploy", `{"clusterName": "edge", "infraID": "edge-x7k2p"}`, iad.DeployMasters, "a cluster was already created from"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(metadataPath)
+			if tc.metadata != "" {
+				ioutil.WriteFile(metadataPath, []byte(tc.metadata), 0644)
+			}
+
+			err := tc.run()
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if calls := readInstallerCalls(t, installerLog); len(calls) != 0 {
+		t.Errorf("expected the installer not to be called, got %v", calls)
+	}
+}
+
+func TestIPIInstallerFailure(t *testing.T) {
+	iad, _ := newTestIPIDeployment(t)
+	t.Setenv("FAKE_INSTALLER_FAIL", "1")
+
+	err := iad.DeployMasters()
+	if err == nil || !strings.Contains(err.Error(), "error running openshift-install create cluster, see "+filepath.Join(iad.siteBuildPath, iad.siteName, "final_manifests/.openshift_install.log")) {
+		t.Errorf("expected the installer failure to point to its log, got %v", err)
+	}
+}
+
+func TestIPIMissingCredentials(t *testing.T) {
+	iad, installerLog := newTestIPIDeployment(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	// only a warning when fetching the requirements, the credentials may be set up later
+	err := iad.PrepareAutomation(nil)
+	if err != nil {
+		t.Errorf("expected missing credentials not to fail PrepareAutomation, got %s", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(iad.siteBuildPath, iad.siteName, "final_manifests/metadata.json"), []byte(`{"infraID": "edge-x7k2p"}`), 0644)
+
+	for name, run := range map[string]func() error{"DeployMasters": iad.DeployMasters, "DestroyCluster": iad.DestroyCluster} {
+		err := run()
+		if err == nil || !strings.Contains(err.Error(), "error reading AWS credentials") {
+			t.Errorf("expected %s to fail on missing credentials, got %v", name, err)
+		}
+	}
+
+	if calls := readInstallerCalls(t, installerLog); len(calls) != 0 {
+		t.Errorf("expected the installer not to be called, got %v", calls)
+	}
+}
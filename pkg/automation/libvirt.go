@@ -0,0 +1,876 @@
+package automation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+	getter "github.com/hashicorp/go-getter"
+	"github.com/otiai10/copy"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// settings read from profile.env, with their default values
+var libvirtSettingDefaults = map[string]string{
+	"TF_VAR_libvirt_image":            "",
+	"TF_VAR_libvirt_pool":             "default",
+	"TF_VAR_libvirt_disk_size":        "32G",
+	"TF_VAR_libvirt_bootstrap_memory": "4096",
+	"TF_VAR_libvirt_bootstrap_vcpu":   "2",
+	"TF_VAR_libvirt_master_memory":    "7168",
+	"TF_VAR_libvirt_master_vcpu":      "4",
+	"TF_VAR_libvirt_worker_memory":    "7168",
+	"TF_VAR_libvirt_worker_vcpu":      "2",
+}
+
+// libvirtInstallConfig : the fields of a libvirt install-config.yaml used to plan the cluster
+type libvirtInstallConfig struct {
+	BaseDomain string `yaml:"baseDomain"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	ControlPlane struct {
+		Replicas *int `yaml:"replicas"`
+	} `yaml:"controlPlane"`
+	Compute []struct {
+		Replicas *int `yaml:"replicas"`
+	} `yaml:"compute"`
+	Networking struct {
+		MachineCIDR    string `yaml:"machineCIDR"`
+		MachineNetwork []struct {
+			CIDR string `yaml:"cidr"`
+		} `yaml:"machineNetwork"`
+	} `yaml:"networking"`
+	Platform struct {
+		Libvirt *struct {
+			URI     string `yaml:"URI"`
+			Network struct {
+				If string `yaml:"if"`
+			} `yaml:"network"`
+		} `yaml:"libvirt"`
+	} `yaml:"platform"`
+}
+
+// libvirtHost : a VM of the cluster
+type libvirtHost struct {
+	Name     string
+	Role     string // bootstrap, master or worker, also the name of its ignition file
+	MAC      string
+	IP       string
+	Memory   string // MiB
+	VCPU     string
+	Ignition string // path of the ignition volume, set when the VM is created
+}
+
+// libvirtCluster : the network and VMs of a cluster, planned from the install-config
+type libvirtCluster struct {
+	URI        string
+	Name       string
+	Domain     string
+	Bridge     string
+	Gateway    string
+	Prefix     int
+	DHCPStart  string
+	DHCPEnd    string
+	DomainType string
+	Pool       string
+	DiskSize   string
+	Image      string
+	Bootstrap  libvirtHost
+	Masters    []libvirtHost
+	Workers    []libvirtHost
+}
+
+type libvirtAutomatedDeployment struct {
+	siteBuildPath string
+	siteName      string
+	siteRepo      string
+	options       DeploymentOptions
+}
+
+const libvirtNetworkTemplate = `<network xmlns:dnsmasq='http://libvirt.org/schemas/network/dnsmasq/1.0'>
+  <name>{{.Name}}</name>
+  <forward mode='nat'/>
+  <bridge name='{{.Bridge}}' stp='on' delay='0'/>
+  <domain name='{{.Domain}}' localOnly='yes'/>
+  <dns>
+{{- range .DNSHosts}}
+    <host ip='{{.IP}}'>{{range .Hostnames}}<hostname>{{.}}</hostname>{{end}}</host>
+{{- end}}
+{{- range $i, $master := .Masters}}
+    <srv service='etcd-server-ssl' protocol='tcp' domain='{{$.Domain}}' target='etcd-{{$i}}.{{$.Domain}}' port='2380' weight='10'/>
+{{- end}}
+  </dns>
+  <ip address='{{.Gateway}}' prefix='{{.Prefix}}'>
+    <dhcp>
+      <range start='{{.DHCPStart}}' end='{{.DHCPEnd}}'/>
+{{- range .AllHosts}}
+      <host mac='{{.MAC}}' name='{{.Name}}' ip='{{.IP}}'/>
+{{- end}}
+    </dhcp>
+  </ip>
+  <dnsmasq:options>
+    <dnsmasq:option value='address=/.apps.{{.Domain}}/{{.AppsIP}}'/>
+  </dnsmasq:options>
+</network>
+`
+
+const libvirtDomainTemplate = `<domain type='{{.DomainType}}' xmlns:qemu='http://libvirt.org/schemas/domain/qemu/1.0'>
+  <name>{{.Host.Name}}</name>
+  <memory unit='MiB'>{{.Host.Memory}}</memory>
+  <vcpu>{{.Host.VCPU}}</vcpu>
+  <os>
+    <type arch='x86_64'>hvm</type>
+    <boot dev='hd'/>
+  </os>
+  <features>
+    <acpi/>
+    <apic/>
+  </features>
+  <cpu mode='host-passthrough'/>
+  <devices>
+    <disk type='volume' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source pool='{{.Pool}}' volume='{{.Host.Name}}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <interface type='network'>
+      <source network='{{.Network}}'/>
+      <mac address='{{.Host.MAC}}'/>
+      <model type='virtio'/>
+    </interface>
+    <console type='pty'/>
+    <rng model='virtio'>
+      <backend model='random'>/dev/urandom</backend>
+    </rng>
+  </devices>
+  <qemu:commandline>
+    <qemu:arg value='-fw_cfg'/>
+    <qemu:arg value='name=opt/com.coreos/config,file={{.Host.Ignition}}'/>
+  </qemu:commandline>
+</domain>
+`
+
+func newLibvirt(params AutomatedDeploymentParams) (AutomatedDeploymentInterface, error) {
+	err := checkNoTerraformPlan(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The cluster is planned from the final install-config.yaml and profile.env, that
+	// only exist after prepare_manifests, so they are read when deploying
+	return libvirtAutomatedDeployment{
+		siteBuildPath: params.SiteBuildPath,
+		siteName:      params.SiteName,
+		siteRepo:      params.SiteRepo,
+		options:       params.Options,
+	}, nil
+}
+
+func (lad libvirtAutomatedDeployment) PrepareAutomation(requirements map[string]string) error {
+	// Clear any previous automation working directory, the cluster VMs are kept
+	automationPath := fmt.Sprintf("%s/%s/libvirt_automation", lad.siteBuildPath, lad.siteName)
+	os.RemoveAll(automationPath)
+
+	err := os.MkdirAll(automationPath, 0700)
+
+	if err != nil {
+		return fmt.Errorf("libvirtAutomatedDeployment: PrepareAutomation: error creating automation directory: %s", err)
+	}
+
+	if _, err := exec.LookPath("virsh"); err != nil {
+		log.Println("WARNING: libvirtAutomatedDeployment: PrepareAutomation: virsh not found, it is needed to deploy the cluster")
+	}
+
+	return nil
+}
+
+func (lad libvirtAutomatedDeployment) FinalizeAutomationPreparation() error {
+	// Nothing to do, ignition configs are generated from the final manifests when deploying masters
+	return nil
+}
+
+func (lad libvirtAutomatedDeployment) DeployMasters() error {
+	rb := &rollback{}
+
+	err := lad.deployMasters(rb)
+
+	if err != nil && lad.options.Rollback {
+		return rb.undo("libvirtAutomatedDeployment: DeployMasters", err)
+	}
+
+	return err
+}
+
+// deploys the masters, recording in rb the teardown steps undoing the network
+// and the VMs before they are created. The base image is kept, as it is reused
+func (lad libvirtAutomatedDeployment) deployMasters(rb *rollback) error {
+	sitePath := fmt.Sprintf("%s/%s", lad.siteBuildPath, lad.siteName)
+	automationPath := fmt.Sprintf("%s/libvirt_automation", sitePath)
+
+	cluster, err := lad.planCluster()
+
+	if err != nil {
+		return err
+	}
+
+	// Generate the ignition configs from a copy of the final manifests, as
+	// openshift-install consumes them
+	finalManifestsPath := fmt.Sprintf("%s/final_manifests", sitePath)
+
+	_, err = os.Stat(finalManifestsPath)
+
+	if err != nil {
+		return fmt.Errorf("libvirtAutomatedDeployment: DeployMasters: unable to access final manifests at %s: %s", finalManifestsPath, err)
+	}
+
+	ocpPath := fmt.Sprintf("%s/ocp", automationPath)
+	hosts := append([]libvirtHost{cluster.Bootstrap}, cluster.Masters...)
+
+	// The VMs of a previous attempt are kept, with the ignition configs they were
+	// created with, as new ones would hold other certificates
+	existing := map[string]bool{}
+	existingNames := []string{}
+	for _, host := range hosts {
+		if _, err := cluster.virsh("dominfo", host.Name); err == nil {
+			existing[host.Name] = true
+			existingNames = append(existingNames, host.Name)
+		}
+	}
+
+	if len(existing) > 0 {
+		for _, role := range []string{"bootstrap", "master"} {
+			if _, err := os.Stat(fmt.Sprintf("%s/%s.ign", ocpPath, role)); err != nil {
+				return fmt.Errorf("libvirtAutomatedDeployment: DeployMasters: VM(s) %s already exist, but not the ignition configs they were created with in %s, run destroy_cluster first", strings.Join(existingNames, ", "), ocpPath)
+			}
+		}
+
+		log.Printf("libvirtAutomatedDeployment: DeployMasters: VM(s) %s already exist, reusing the ignition configs in %s\n", strings.Join(existingNames, ", "), ocpPath)
+	} else {
+		err = lad.createIgnitionConfigs(sitePath, finalManifestsPath, ocpPath)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// A network that already exists is kept on rollback
+	if _, err := cluster.virsh("net-info", cluster.Name); err != nil {
+		rb.record(cluster.networkRemovalStep())
+	}
+
+	err = cluster.ensureNetwork()
+
+	if err != nil {
+		return err
+	}
+
+	err = cluster.ensureBaseVolume(automationPath)
+
+	if err != nil {
+		return err
+	}
+
+	// Like the network, only the VMs created now are removed on rollback
+	for _, host := range hosts {
+		if !existing[host.Name] {
+			rb.record(cluster.vmRemovalStep(host))
+		}
+	}
+
+	err = cluster.createHosts(hosts, ocpPath)
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("libvirtAutomatedDeployment: DeployMasters: bootstrap and master(s) deploy initiated...")
+
+	return nil
+}
+
+// generates the ignition configs in ocpPath, from a copy of the final manifests
+func (lad libvirtAutomatedDeployment) createIgnitionConfigs(sitePath string, finalManifestsPath string, ocpPath string) error {
+	os.RemoveAll(ocpPath)
+
+	err := copy.Copy(finalManifestsPath, ocpPath)
+
+	if err != nil {
+		return fmt.Errorf("libvirtAutomatedDeployment: createIgnitionConfigs: error copying final_manifests into automation ocp directory: %s", err)
+	}
+
+	os.Chmod(ocpPath, 0700)
+
+	log.Println("libvirtAutomatedDeployment: createIgnitionConfigs: generating ignition configs...")
+
+	cmd := exec.Command(fmt.Sprintf("%s/requirements/openshift-install", sitePath), "create", "ignition-configs", fmt.Sprintf("--dir=%s", ocpPath))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+
+	if err != nil {
+		return fmt.Errorf("libvirtAutomatedDeployment: createIgnitionConfigs: error generating ignition configs: %s", err)
+	}
+
+	return nil
+}
+
+func (lad libvirtAutomatedDeployment) DeployWorkers() error {
+	sitePath := fmt.Sprintf("%s/%s", lad.siteBuildPath, lad.siteName)
+	ocpPath := fmt.Sprintf("%s/libvirt_automation/ocp", sitePath)
+
+	cluster, err := lad.planCluster()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(fmt.Sprintf("%s/worker.ign", ocpPath))
+
+	if err != nil {
+		return fmt.Errorf("libvirtAutomatedDeployment: DeployWorkers: worker ignition config not found, masters need to be deployed first: %s", err)
+	}
+
+	if len(cluster.Workers) == 0 {
+		log.Println("libvirtAutomatedDeployment: DeployWorkers: no workers requested in install-config.yaml")
+	} else {
+		err = cluster.createHosts(cluster.Workers, ocpPath)
+
+		if err != nil {
+			return err
+		}
+
+		log.Println("libvirtAutomatedDeployment: DeployWorkers: worker(s) deploy initiated...")
+	}
+
+	if lad.options.KeepBootstrap {
+		log.Println("libvirtAutomatedDeployment: DeployWorkers: keeping the bootstrap VM, run deploy_workers again without --keep_bootstrap to remove it")
+		return nil
+	}
+
+	// Nothing to wait for once the bootstrap VM is gone
+	if _, err := cluster.virsh("dominfo", cluster.Bootstrap.Name); err != nil {
+		log.Println("libvirtAutomatedDeployment: DeployWorkers: bootstrap VM already removed")
+		return nil
+	}
+
+	err = waitForBootstrapComplete(fmt.Sprintf("%s/requirements/openshift-install", sitePath), ocpPath)
+
+	if err != nil {
+		return fmt.Errorf("libvirtAutomatedDeployment: DeployWorkers: %s", err)
+	}
+
+	return cluster.removeBootstrap()
+}
+
+func (lad libvirtAutomatedDeployment) DestroyCluster() error {
+	cluster, err := lad.planCluster()
+
+	if err != nil {
+		return err
+	}
+
+	steps := []teardownStep{}
+
+	// Remove the VMs and their disks
+	hosts := append(append([]libvirtHost{cluster.Bootstrap}, cluster.Masters...), cluster.Workers...)
+
+	for _, host := range hosts {
+		steps = append(steps, cluster.vmRemovalStep(host))
+	}
+
+	// Remove the ignition configs and the base image
+	steps = append(steps, teardownStep{
+		description: "ignition and base image volumes removal",
+		run: func() error {
+			for _, volume := range []string{"bootstrap.ign", "master.ign", "worker.ign", "base"} {
+				err := cluster.deleteVolume(fmt.Sprintf("%s-%s", cluster.Name, volume))
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+
+	// Remove the network, once no VM uses it
+	steps = append(steps, cluster.networkRemovalStep())
+
+	steps = append(steps, teardownStep{
+		description: "installer directory removal",
+		run: func() error {
+			return os.RemoveAll(fmt.Sprintf("%s/%s/libvirt_automation/ocp", lad.siteBuildPath, lad.siteName))
+		},
+	})
+
+	err = runTeardown("libvirtAutomatedDeployment: DestroyCluster", steps, lad.options.FailFast)
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("libvirtAutomatedDeployment: DestroyCluster: cluster teardown completed")
+
+	return nil
+}
+
+// plans the network and VMs of the cluster from the site's install-config.yaml
+// and profile.env
+func (lad libvirtAutomatedDeployment) planCluster() (libvirtCluster, error) {
+	sitePath := fmt.Sprintf("%s/%s", lad.siteBuildPath, lad.siteName)
+	cluster := libvirtCluster{}
+
+	installConfigFile, err := ioutil.ReadFile(fmt.Sprintf("%s/automation/install-config.yaml", sitePath))
+
+	if err != nil {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: error reading install-config.yaml, run prepare_manifests first: %s", err)
+	}
+
+	var installConfig libvirtInstallConfig
+
+	err = yaml.Unmarshal(installConfigFile, &installConfig)
+
+	if err != nil {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: error unmarshalling install-config.yaml: %s", err)
+	}
+
+	if installConfig.Platform.Libvirt == nil {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: install-config.yaml has no libvirt platform")
+	}
+
+	if installConfig.Metadata.Name == "" || installConfig.BaseDomain == "" {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: install-config.yaml needs metadata.name and baseDomain")
+	}
+
+	settings, err := readProfileEnv(fmt.Sprintf("%s/profile.env", sitePath), libvirtSettingDefaults)
+
+	if err != nil {
+		return cluster, err
+	}
+
+	// Like virsh, the default URI can be set in the environment
+	cluster.URI = installConfig.Platform.Libvirt.URI
+	if cluster.URI == "" {
+		cluster.URI = os.Getenv("LIBVIRT_DEFAULT_URI")
+	}
+	if cluster.URI == "" {
+		cluster.URI = "qemu:///system"
+	}
+
+	// The test driver only accepts test domains
+	cluster.DomainType = "kvm"
+	if strings.HasPrefix(cluster.URI, "test:") {
+		cluster.DomainType = "test"
+	}
+
+	cluster.Name = installConfig.Metadata.Name
+	cluster.Domain = fmt.Sprintf("%s.%s", installConfig.Metadata.Name, installConfig.BaseDomain)
+	cluster.Pool = settings["TF_VAR_libvirt_pool"]
+	cluster.DiskSize = settings["TF_VAR_libvirt_disk_size"]
+	cluster.Image = settings["TF_VAR_libvirt_image"]
+
+	cluster.Bridge = installConfig.Platform.Libvirt.Network.If
+	if cluster.Bridge == "" {
+		cluster.Bridge = "tt0"
+	}
+
+	machineCIDR := installConfig.Networking.MachineCIDR
+	if machineCIDR == "" && len(installConfig.Networking.MachineNetwork) > 0 {
+		machineCIDR = installConfig.Networking.MachineNetwork[0].CIDR
+	}
+	if machineCIDR == "" {
+		machineCIDR = "192.168.126.0/24"
+	}
+
+	_, machineNetwork, err := net.ParseCIDR(machineCIDR)
+
+	if err != nil || machineNetwork.IP.To4() == nil {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: invalid IPv4 machine CIDR %s", machineCIDR)
+	}
+
+	cluster.Prefix, _ = machineNetwork.Mask.Size()
+	if cluster.Prefix > 24 {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: machine CIDR %s is too small, at least a /24 is needed", machineCIDR)
+	}
+
+	// Addresses follow the layout of the installer: gateway at .1, bootstrap at .10,
+	// masters from .11 and workers from .51, leaving the rest of the /24 for DHCP
+	cluster.Gateway = utils.HostIP(machineNetwork, 1)
+	cluster.DHCPStart = utils.HostIP(machineNetwork, 100)
+	cluster.DHCPEnd = utils.HostIP(machineNetwork, 254)
+
+	masterCount := 3
+	if installConfig.ControlPlane.Replicas != nil {
+		masterCount = *installConfig.ControlPlane.Replicas
+	}
+
+	workerCount := 3
+	if len(installConfig.Compute) > 0 && installConfig.Compute[0].Replicas != nil {
+		workerCount = *installConfig.Compute[0].Replicas
+	}
+
+	if masterCount < 1 || masterCount > 39 || workerCount < 0 || workerCount > 48 {
+		return cluster, fmt.Errorf("libvirtAutomatedDeployment: planCluster: between 1 and 39 masters, and at most 48 workers are supported")
+	}
+
+	cluster.Bootstrap = cluster.newHost("bootstrap", "bootstrap", utils.HostIP(machineNetwork, 10), settings)
+
+	for i := 0; i < masterCount; i++ {
+		cluster.Masters = append(cluster.Masters, cluster.newHost(fmt.Sprintf("master-%d", i), "master", utils.HostIP(machineNetwork, 11+i), settings))
+	}
+
+	for i := 0; i < workerCount; i++ {
+		cluster.Workers = append(cluster.Workers, cluster.newHost(fmt.Sprintf("worker-%d", i), "worker", utils.HostIP(machineNetwork, 51+i), settings))
+	}
+
+	return cluster, nil
+}
+
+func (lc libvirtCluster) newHost(suffix string, role string, ip string, settings map[string]string) libvirtHost {
+	name := fmt.Sprintf("%s-%s", lc.Name, suffix)
+
+	// Stable locally administered MAC, so DHCP reservations survive redeployments
+	hash := sha256.Sum256([]byte(name))
+
+	return libvirtHost{
+		Name:   name,
+		Role:   role,
+		MAC:    fmt.Sprintf("52:54:00:%02x:%02x:%02x", hash[0], hash[1], hash[2]),
+		IP:     ip,
+		Memory: settings[fmt.Sprintf("TF_VAR_libvirt_%s_memory", role)],
+		VCPU:   settings[fmt.Sprintf("TF_VAR_libvirt_%s_vcpu", role)],
+	}
+}
+
+// runs a virsh command against the cluster libvirt URI
+func (lc libvirtCluster) virsh(args ...string) (string, error) {
+	cmd := exec.Command("virsh", append([]string{"--connect", lc.URI}, args...)...)
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		return "", fmt.Errorf("libvirtCluster: virsh %s: %s: %s", args[0], err, strings.TrimSpace(errb.String()))
+	}
+
+	return outb.String(), nil
+}
+
+// defines and starts the cluster network, with DHCP reservations and DNS
+// records for every VM, unless it already exists
+func (lc libvirtCluster) ensureNetwork() error {
+	if _, err := lc.virsh("net-info", lc.Name); err == nil {
+		log.Printf("libvirtCluster: ensureNetwork: network %s already exists\n", lc.Name)
+		return nil
+	}
+
+	// The *.apps wildcard points to the nodes running the ingress routers
+	appsIP := lc.Masters[0].IP
+	if len(lc.Workers) > 0 {
+		appsIP = lc.Workers[0].IP
+	}
+
+	// libvirt needs a single DNS host entry per address, with all its names
+	type dnsHost struct {
+		IP        string
+		Hostnames []string
+	}
+
+	dnsHosts := []dnsHost{{IP: lc.Bootstrap.IP, Hostnames: []string{fmt.Sprintf("api.%s", lc.Domain), fmt.Sprintf("api-int.%s", lc.Domain)}}}
+	for i, master := range lc.Masters {
+		dnsHosts = append(dnsHosts, dnsHost{IP: master.IP, Hostnames: []string{fmt.Sprintf("api.%s", lc.Domain), fmt.Sprintf("api-int.%s", lc.Domain), fmt.Sprintf("etcd-%d.%s", i, lc.Domain)}})
+	}
+
+	networkXML, err := utils.RenderTemplate("libvirt network", libvirtNetworkTemplate, map[string]interface{}{
+		"Name":      lc.Name,
+		"Bridge":    lc.Bridge,
+		"Domain":    lc.Domain,
+		"Gateway":   lc.Gateway,
+		"Prefix":    lc.Prefix,
+		"DHCPStart": lc.DHCPStart,
+		"DHCPEnd":   lc.DHCPEnd,
+		"DNSHosts":  dnsHosts,
+		"Masters":   lc.Masters,
+		"AllHosts":  append(append([]libvirtHost{lc.Bootstrap}, lc.Masters...), lc.Workers...),
+		"AppsIP":    appsIP,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("libvirtCluster: ensureNetwork: creating network %s (%s)...\n", lc.Name, lc.Bridge)
+
+	err = lc.defineFromXML("net-define", networkXML)
+
+	if err != nil {
+		return err
+	}
+
+	for _, args := range [][]string{{"net-start", lc.Name}, {"net-autostart", lc.Name}} {
+		if _, err := lc.virsh(args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// teardown step removing the VM of a host and its disk
+func (lc libvirtCluster) vmRemovalStep(host libvirtHost) teardownStep {
+	return teardownStep{
+		description: fmt.Sprintf("VM %s removal", host.Name),
+		run: func() error {
+			if _, err := lc.virsh("dominfo", host.Name); err == nil {
+				log.Printf("libvirtCluster: vmRemovalStep: removing VM %s...\n", host.Name)
+
+				// Fails when the VM is not running, which is fine
+				lc.virsh("destroy", host.Name)
+
+				_, err = lc.virsh("undefine", host.Name)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return lc.deleteVolume(host.Name)
+		},
+	}
+}
+
+// teardown step removing the network of the cluster
+func (lc libvirtCluster) networkRemovalStep() teardownStep {
+	return teardownStep{
+		description: fmt.Sprintf("network %s removal", lc.Name),
+		run: func() error {
+			if _, err := lc.virsh("net-info", lc.Name); err == nil {
+				log.Printf("libvirtCluster: networkRemovalStep: removing network %s...\n", lc.Name)
+
+				lc.virsh("net-destroy", lc.Name)
+
+				_, err = lc.virsh("net-undefine", lc.Name)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// removes the bootstrap VM, its volumes and its api DNS records, once the
+// control plane runs on the masters
+func (lc libvirtCluster) removeBootstrap() error {
+	log.Printf("libvirtCluster: removeBootstrap: removing bootstrap VM %s...\n", lc.Bootstrap.Name)
+
+	// Fails when the VM is not running, which is fine
+	lc.virsh("destroy", lc.Bootstrap.Name)
+
+	_, err := lc.virsh("undefine", lc.Bootstrap.Name)
+
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range []string{lc.Bootstrap.Name, fmt.Sprintf("%s-bootstrap.ign", lc.Name)} {
+		err = lc.deleteVolume(volume)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// The api names keep resolving to the masters only
+	dnsHostXML := fmt.Sprintf("<host ip='%s'><hostname>api.%s</hostname><hostname>api-int.%s</hostname></host>", lc.Bootstrap.IP, lc.Domain, lc.Domain)
+
+	_, err = lc.virsh("net-update", lc.Name, "delete", "dns-host", dnsHostXML, "--live", "--config")
+
+	if err != nil {
+		log.Printf("WARNING: bootstrap DNS records could not be removed from network %s: %s\n", lc.Name, err)
+	}
+
+	log.Println("libvirtCluster: removeBootstrap: bootstrap VM removed")
+
+	return nil
+}
+
+// uploads the RHCOS image set in TF_VAR_libvirt_image as the backing volume of
+// the VM disks, unless it already exists
+func (lc libvirtCluster) ensureBaseVolume(automationPath string) error {
+	baseVolume := fmt.Sprintf("%s-base", lc.Name)
+
+	if _, err := lc.virsh("vol-info", "--pool", lc.Pool, baseVolume); err == nil {
+		return nil
+	}
+
+	if lc.Image == "" {
+		return fmt.Errorf("libvirtCluster: ensureBaseVolume: TF_VAR_libvirt_image needs to be set in the site config to the RHCOS qemu image path or URL")
+	}
+
+	// Remote and compressed images are downloaded and decompressed first
+	imagePath := fmt.Sprintf("%s/rhcos.qcow2", automationPath)
+	os.RemoveAll(imagePath)
+
+	log.Printf("libvirtCluster: ensureBaseVolume: retrieving RHCOS image %s...\n", lc.Image)
+
+	client := &getter.Client{Src: lc.Image, Dst: imagePath, Mode: getter.ClientModeFile}
+
+	err := client.Get()
+
+	if err != nil {
+		return fmt.Errorf("libvirtCluster: ensureBaseVolume: error retrieving RHCOS image: %s", err)
+	}
+
+	defer os.Remove(imagePath)
+
+	return lc.uploadVolume(baseVolume, imagePath, "qcow2")
+}
+
+// creates the disk, the ignition volume and the VM of each host, skipping
+// existing VMs
+func (lc libvirtCluster) createHosts(hosts []libvirtHost, ocpPath string) error {
+	ignitionPaths := map[string]string{}
+
+	for _, host := range hosts {
+		if _, err := lc.virsh("dominfo", host.Name); err == nil {
+			log.Printf("libvirtCluster: createHosts: VM %s already exists\n", host.Name)
+			continue
+		}
+
+		// Ignition configs are uploaded to the pool, so qemu can always read them
+		ignitionPath, ok := ignitionPaths[host.Role]
+		if !ok {
+			ignitionVolume := fmt.Sprintf("%s-%s.ign", lc.Name, host.Role)
+
+			err := lc.deleteVolume(ignitionVolume)
+
+			if err != nil {
+				return err
+			}
+
+			err = lc.uploadVolume(ignitionVolume, fmt.Sprintf("%s/%s.ign", ocpPath, host.Role), "raw")
+
+			if err != nil {
+				return err
+			}
+
+			out, err := lc.virsh("vol-path", "--pool", lc.Pool, ignitionVolume)
+
+			if err != nil {
+				return err
+			}
+
+			ignitionPath = strings.TrimSpace(out)
+			ignitionPaths[host.Role] = ignitionPath
+		}
+		host.Ignition = ignitionPath
+
+		log.Printf("libvirtCluster: createHosts: creating VM %s (%s)...\n", host.Name, host.IP)
+
+		err := lc.deleteVolume(host.Name)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = lc.virsh("vol-create-as", lc.Pool, host.Name, lc.DiskSize, "--format", "qcow2", "--backing-vol", fmt.Sprintf("%s-base", lc.Name), "--backing-vol-format", "qcow2")
+
+		if err != nil {
+			return err
+		}
+
+		domainXML, err := utils.RenderTemplate("libvirt domain", libvirtDomainTemplate, map[string]interface{}{
+			"DomainType": lc.DomainType,
+			"Host":       host,
+			"Pool":       lc.Pool,
+			"Network":    lc.Name,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = lc.defineFromXML("define", domainXML)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = lc.virsh("start", host.Name)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// creates a volume in the pool with the contents of a local file
+func (lc libvirtCluster) uploadVolume(volume string, path string, format string) error {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return fmt.Errorf("libvirtCluster: uploadVolume: %s", err)
+	}
+
+	_, err = lc.virsh("vol-create-as", lc.Pool, volume, fmt.Sprintf("%d", info.Size()), "--format", format)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = lc.virsh("vol-upload", "--pool", lc.Pool, volume, path)
+
+	return err
+}
+
+// deletes a volume of the pool, if it exists
+func (lc libvirtCluster) deleteVolume(volume string) error {
+	if _, err := lc.virsh("vol-info", "--pool", lc.Pool, volume); err != nil {
+		return nil
+	}
+
+	_, err := lc.virsh("vol-delete", "--pool", lc.Pool, volume)
+
+	return err
+}
+
+// runs a virsh define command with the given XML
+func (lc libvirtCluster) defineFromXML(command string, xml string) error {
+	xmlFile, err := ioutil.TempFile("", "kni-libvirt-")
+
+	if err != nil {
+		return fmt.Errorf("libvirtCluster: defineFromXML: error creating temporary file: %s", err)
+	}
+
+	defer os.Remove(xmlFile.Name())
+
+	_, err = xmlFile.WriteString(xml)
+	xmlFile.Close()
+
+	if err != nil {
+		return fmt.Errorf("libvirtCluster: defineFromXML: error writing temporary file: %s", err)
+	}
+
+	_, err = lc.virsh(command, xmlFile.Name())
+
+	return err
+}
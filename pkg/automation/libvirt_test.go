@@ -0,0 +1,440 @@
+package automation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testLibvirtInstallConfig = `apiVersion: v1
+baseDomain: example.com
+metadata:
+  name: edge
+controlPlane:
+  name: master
+  replicas: %d
+compute:
+- name: worker
+  replicas: %d
+networking:
+  machineCIDR: %s
+platform:
+  libvirt:
+    URI: %s
+    network:
+      if: tt1
+`
+
+// creates the build directory of a libvirt site, with its install-config.yaml,
+// profile.env and final manifests, and a fake openshift-install writing the
+// ignition configs
+func newTestLibvirtSite(t *testing.T, installConfig string, profileEnv string) libvirtAutomatedDeployment {
+	t.Helper()
+
+	buildPath, err := ioutil.TempDir("", "kni-libvirt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(buildPath) })
+
+	sitePath := filepath.Join(buildPath, "edge.example.com")
+	files := map[string]string{
+		"automation/install-config.yaml":      installConfig,
+		"profile.env":                         profileEnv,
+		"final_manifests/install-config.yaml": installConfig,
+		"requirements/openshift-install": `#!/bin/sh
+for arg in "$@"; do
+    case "$arg" in
+    --dir=*) dir="${arg#--dir=}" ;;
+    esac
+done
+for role in bootstrap master worker; do
+    echo '{"ignition": {"version": "2.2.0"}}' > "$dir/$role.ign"
+done
+`,
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(sitePath, path)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		err = ioutil.WriteFile(fullPath, []byte(content), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return libvirtAutomatedDeployment{siteBuildPath: buildPath, siteName: "edge.example.com"}
+}
+
+func TestLibvirtPlanCluster(t *testing.T) {
+	os.Setenv("LIBVIRT_DEFAULT_URI", "test:///default")
+	defer os.Unsetenv("LIBVIRT_DEFAULT_URI")
+
+	lad := newTestLibvirtSite(t, fmt.Sprintf(testLibvirtInstallConfig, 3, 2, "192.168.126.0/24", `""`), "export TF_VAR_libvirt_master_memory=16384\n")
+
+	cluster, err := lad.planCluster()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cluster.URI != "test:///default" || cluster.DomainType != "test" {
+		t.Errorf("expected the test driver from LIBVIRT_DEFAULT_URI, got %s and domain type %s", cluster.URI, cluster.DomainType)
+	}
+	if cluster.Name != "edge" || cluster.Domain != "edge.example.com" || cluster.Bridge != "tt1" || cluster.Pool != "default" {
+		t.Errorf("unexpected cluster %+v", cluster)
+	}
+	if cluster.Gateway != "192.168.126.1" || cluster.Prefix != 24 || cluster.DHCPStart != "192.168.126.100" || cluster.DHCPEnd != "192.168.126.254" {
+		t.Errorf("unexpected network layout %s/%d, DHCP %s-%s", cluster.Gateway, cluster.Prefix, cluster.DHCPStart, cluster.DHCPEnd)
+	}
+
+	hosts := append(append([]libvirtHost{cluster.Bootstrap}, cluster.Masters...), cluster.Workers...)
+	want := []string{
+		"edge-bootstrap bootstrap 192.168.126.10 4096",
+		"edge-master-0 master 192.168.126.11 16384",
+		"edge-master-1 master 192.168.126.12 16384",
+		"edge-master-2 master 192.168.126.13 16384",
+		"edge-worker-0 worker 192.168.126.51 7168",
+		"edge-worker-1 worker 192.168.126.52 7168",
+	}
+	if len(hosts) != len(want) {
+		t.Fatalf("expected %d hosts, got %d", len(want), len(hosts))
+	}
+
+	macs := map[string]bool{}
+	for i, host := range hosts {
+		if got := fmt.Sprintf("%s %s %s %s", host.Name, host.Role, host.IP, host.Memory); got != want[i] {
+			t.Errorf("expected host %q, got %q", want[i], got)
+		}
+		if !strings.HasPrefix(host.MAC, "52:54:00:") || macs[host.MAC] {
+			t.Errorf("host %s has an invalid or duplicated MAC %s", host.Name, host.MAC)
+		}
+		macs[host.MAC] = true
+	}
+
+	// the MACs are stable, so the DHCP reservations survive redeployments
+	replanned, _ := lad.planCluster()
+	if replanned.Masters[1].MAC != cluster.Masters[1].MAC {
+		t.Errorf("MAC of %s changed between plans", cluster.Masters[1].Name)
+	}
+}
+
+func TestLibvirtPlanClusterErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		installConfig string
+		err           string
+	}{
+		{"no masters", fmt.Sprintf(testLibvirtInstallConfig, 0, 2, "192.168.126.0/24", "test:///default"), "between 1 and 39 masters, and at most 48 workers are supported"},
+		{"too many masters", fmt.Sprintf(testLibvirtInstallConfig, 40, 2, "192.168.126.0/24", "test:///default"), "between 1 and 39 masters, and at most 48 workers are supported"},
+		{"too many workers", fmt.Sprintf(testLibvirtInstallConfig, 3, 49, "192.168.126.0/24", "test:///default"), "between 1 and 39 masters, and at most 48 workers are supported"},
+		{"negative workers", fmt.Sprintf(testLibvirtInstallConfig, 3, -1, "192.168.126.0/24", "test:///default"), "between 1 and 39 masters, and at most 48 workers are supported"},
+		{"small machine CIDR", fmt.Sprintf(testLibvirtInstallConfig, 3, 2, "192.168.126.0/25", "test:///default"), "machine CIDR 192.168.126.0/25 is too small, at least a /24 is needed"},
+		{"IPv6 machine CIDR", fmt.Sprintf(testLibvirtInstallConfig, 3, 2, "fd00::/64", "test:///default"), "invalid IPv4 machine CIDR fd00::/64"},
+		{"no libvirt platform", "baseDomain: example.com\nmetadata:\n  name: edge\nplatform:\n  none: {}\n", "install-config.yaml has no libvirt platform"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newTestLibvirtSite(t, tc.installConfig, "").planCluster()
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// fake virsh keeping the networks, domains and volumes it manages as files of
+// the state directory, like a libvirt daemon would across virsh connections
+const fakeVirsh = `#!/bin/sh
+state="%s"
+shift 2 # --connect <uri>
+echo "$@" >> "$state/calls"
+command="$1"
+shift
+case "$command" in
+net-define|define)
+    name=$(sed -n 's|^  <name>\(.*\)</name>$|\1|p' "$1")
+    kind=net; [ "$command" = define ] && kind=dom
+    [ -n "$name" ] || { echo "error: no name in $1" >&2; exit 1; }
+    cp "$1" "$state/$kind.$name" ;;
+net-info)
+    [ -f "$state/net.$1" ] || { echo "error: network not found: $1" >&2; exit 1; } ;;
+net-start|net-autostart)
+    [ -f "$state/net.$1" ] || exit 1 ;;
+net-destroy) ;;
+net-undefine)
+    rm "$state/net.$1" ;;
+net-update) ;;
+dominfo|destroy)
+    [ -f "$state/dom.$1" ] || { echo "error: domain not found: $1" >&2; exit 1; } ;;
+start)
+    [ -f "$state/dom.$1" ] || exit 1
+    if [ "$1" = "$FAKE_VIRSH_FAIL_START" ]; then echo "error: failed to start $1" >&2; exit 1; fi ;;
+undefine)
+    rm "$state/dom.$1" ;;
+vol-create-as)
+    touch "$state/vol.$2" ;;
+vol-info|vol-upload|vol-path)
+    [ -f "$state/vol.$3" ] || { echo "error: volume not found: $3" >&2; exit 1; }
+    [ "$command" = vol-path ] && echo "/var/lib/libvirt/images/$3" ;;
+vol-delete)
+    rm "$state/vol.$3" ;;
+*)
+    echo "error: unexpected command $command" >&2; exit 1 ;;
+esac
+exit 0
+`
+
+// puts the fake virsh first in the PATH, returning its state directory
+func installFakeVirsh(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "kni-virsh-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	state := filepath.Join(dir, "state")
+	os.Mkdir(state, 0755)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "virsh"), []byte(fmt.Sprintf(fakeVirsh, state)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	t.Cleanup(func() { os.Setenv("PATH", path) })
+
+	return state
+}
+
+// the networks, domains and volumes managed by the fake virsh
+func fakeVirshObjects(t *testing.T, state string) []string {
+	t.Helper()
+
+	files, err := ioutil.ReadDir(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := []string{}
+	for _, file := range files {
+		if file.Name() != "calls" {
+			objects = append(objects, file.Name())
+		}
+	}
+	sort.Strings(objects)
+
+	return objects
+}
+
+func newTestLibvirtDeployment(t *testing.T, masters int) libvirtAutomatedDeployment {
+	t.Helper()
+
+	image, err := ioutil.TempFile("", "rhcos-*.qcow2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image.WriteString("qcow2")
+	image.Close()
+	t.Cleanup(func() { os.Remove(image.Name()) })
+
+	lad := newTestLibvirtSite(t, fmt.Sprintf(testLibvirtInstallConfig, masters, 1, "192.168.126.0/24", "test:///default"), fmt.Sprintf("export TF_VAR_libvirt_image=%s\n", image.Name()))
+
+	os.MkdirAll(filepath.Join(lad.siteBuildPath, lad.siteName, "libvirt_automation"), 0700)
+
+	return lad
+}
+
+func TestLibvirtDeployMastersAndDestroyCluster(t *testing.T) {
+	state := installFakeVirsh(t)
+	lad := newTestLibvirtDeployment(t, 2)
+
+	err := lad.DeployMasters()
+	if err != nil {
+		t.Fatalf("unexpected error deploying masters: %s", err)
+	}
+
+	want := []string{
+		"dom.edge-bootstrap", "dom.edge-master-0", "dom.edge-master-1",
+		"net.edge",
+		"vol.edge-base", "vol.edge-bootstrap", "vol.edge-bootstrap.ign", "vol.edge-master-0", "vol.edge-master-1", "vol.edge-master.ign",
+	}
+	if got := fakeVirshObjects(t, state); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v after deploying the masters, got %v", want, got)
+	}
+
+	network, _ := ioutil.ReadFile(filepath.Join(state, "net.edge"))
+	for _, want := range []string{
+		"<bridge name='tt1' stp='on' delay='0'/>",
+		"<host ip='192.168.126.10'><hostname>api.edge.example.com</hostname><hostname>api-int.edge.example.com</hostname></host>",
+		"target='etcd-1.edge.example.com' port='2380'",
+		"address=/.apps.edge.example.com/192.168.126.51",
+	} {
+		if !strings.Contains(string(network), want) {
+			t.Errorf("network XML has no %q", want)
+		}
+	}
+
+	domain, _ := ioutil.ReadFile(filepath.Join(state, "dom.edge-master-0"))
+	for _, want := range []string{
+		"<domain type='test'",
+		"<memory unit='MiB'>7168</memory>",
+		"<source pool='default' volume='edge-master-0'/>",
+		"file=/var/lib/libvirt/images/edge-master.ign",
+	} {
+		if !strings.Contains(string(domain), want) {
+			t.Errorf("domain XML has no %q", want)
+		}
+	}
+
+	err = lad.DestroyCluster()
+	if err != nil {
+		t.Fatalf("unexpected error destroying the cluster: %s", err)
+	}
+
+	if got := fakeVirshObjects(t, state); len(got) != 0 {
+		t.Errorf("expected nothing left after destroying the cluster, got %v", got)
+	}
+
+	_, err = os.Stat(filepath.Join(lad.siteBuildPath, lad.siteName, "libvirt_automation/ocp"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected the installer directory to be removed, got %v", err)
+	}
+}
+
+func TestLibvirtDeployMastersRollback(t *testing.T) {
+	state := installFakeVirsh(t)
+	os.Setenv("FAKE_VIRSH_FAIL_START", "edge-master-1")
+	defer os.Unsetenv("FAKE_VIRSH_FAIL_START")
+
+	lad := newTestLibvirtDeployment(t, 2)
+	lad.options.Rollback = true
+
+	err := lad.DeployMasters()
+	if err == nil || !strings.Contains(err.Error(), "failed to start edge-master-1") || !strings.Contains(err.Error(), "the deployment was rolled back") {
+		t.Fatalf("expected the failed start to be rolled back, got %v", err)
+	}
+
+	// the base image is kept for the next attempt, and so are the ignition volumes
+	want := []string{"vol.edge-base", "vol.edge-bootstrap.ign", "vol.edge-master.ign"}
+	if got := fakeVirshObjects(t, state); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v after the rollback, got %v", want, got)
+	}
+}
+
+func TestLibvirtDeployMastersExistingVMs(t *testing.T) {
+	state := installFakeVirsh(t)
+	lad := newTestLibvirtDeployment(t, 1)
+
+	err := lad.DeployMasters()
+	if err != nil {
+		t.Fatalf("unexpected error deploying masters: %s", err)
+	}
+
+	// a second master is added, and fails to start
+	sitePath := filepath.Join(lad.siteBuildPath, lad.siteName)
+	ioutil.WriteFile(filepath.Join(sitePath, "automation/install-config.yaml"), []byte(fmt.Sprintf(testLibvirtInstallConfig, 2, 1, "192.168.126.0/24", "test:///default")), 0644)
+
+	masterIgnition := filepath.Join(sitePath, "libvirt_automation/ocp/master.ign")
+	ioutil.WriteFile(masterIgnition, []byte("previous"), 0600)
+
+	os.Setenv("FAKE_VIRSH_FAIL_START", "edge-master-1")
+	defer os.Unsetenv("FAKE_VIRSH_FAIL_START")
+	lad.options.Rollback = true
+
+	err = lad.DeployMasters()
+	if err == nil || !strings.Contains(err.Error(), "the deployment was rolled back") {
+		t.Fatalf("expected the failed start to be rolled back, got %v", err)
+	}
+
+	// only the VM created by the failed attempt is removed, and the existing
+	// VMs keep the ignition configs they were created with
+	want := []string{
+		"dom.edge-bootstrap", "dom.edge-master-0",
+		"net.edge",
+		"vol.edge-base", "vol.edge-bootstrap", "vol.edge-bootstrap.ign", "vol.edge-master-0", "vol.edge-master.ign",
+	}
+	if got := fakeVirshObjects(t, state); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v after the rollback, got %v", want, got)
+	}
+
+	content, _ := ioutil.ReadFile(masterIgnition)
+	if string(content) != "previous" {
+		t.Errorf("expected the ignition configs to be reused, got %q", content)
+	}
+
+	// without them, the deployment is refused
+	os.RemoveAll(filepath.Join(sitePath, "libvirt_automation/ocp"))
+
+	err = lad.DeployMasters()
+	if err == nil || !strings.Contains(err.Error(), "VM(s) edge-bootstrap, edge-master-0 already exist, but not the ignition configs they were created with") {
+		t.Fatalf("expected the deployment to be refused, got %v", err)
+	}
+}
+
+// checks the rendered network and domain XML against the libvirt test driver.
+// Its state only lasts for a connection, so all the commands run in one virsh
+func TestLibvirtTestDriver(t *testing.T) {
+	// looked up before the fake virsh rendering the XML is put in the PATH
+	virsh, err := exec.LookPath("virsh")
+	if err != nil {
+		t.Skip("virsh not found")
+	}
+
+	lad := newTestLibvirtSite(t, fmt.Sprintf(testLibvirtInstallConfig, 1, 1, "192.168.126.0/24", "test:///default"), "")
+
+	cluster, err := lad.planCluster()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	networkXML, domainXML := renderTestLibvirtXML(t, cluster)
+
+	dir := filepath.Dir(networkXML)
+	out, err := exec.Command(virsh, "--connect", "test:///default", fmt.Sprintf(
+		"net-define %s; net-start %s; define %s; dominfo %s",
+		networkXML, cluster.Name, domainXML, cluster.Masters[0].Name,
+	)).CombinedOutput()
+	os.RemoveAll(dir)
+
+	if err != nil {
+		t.Fatalf("the test driver rejected the rendered XML: %s\n%s", err, out)
+	}
+}
+
+// writes the network XML and the domain XML of the first master to files
+func renderTestLibvirtXML(t *testing.T, cluster libvirtCluster) (string, string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "kni-libvirt-xml-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ensureNetwork and createHosts render the XML from the same data
+	state := installFakeVirsh(t)
+	err = cluster.ensureNetwork()
+	if err != nil {
+		t.Fatalf("unexpected error rendering the network: %s", err)
+	}
+	ioutil.WriteFile(filepath.Join(state, "vol.edge-master.ign"), nil, 0644)
+	ocpPath := filepath.Join(dir, "ocp")
+	os.MkdirAll(ocpPath, 0755)
+	ioutil.WriteFile(filepath.Join(ocpPath, "master.ign"), []byte("{}"), 0644)
+	err = cluster.createHosts(cluster.Masters[:1], ocpPath)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the domain: %s", err)
+	}
+
+	networkXML := filepath.Join(dir, "network.xml")
+	domainXML := filepath.Join(dir, "domain.xml")
+	os.Rename(filepath.Join(state, "net."+cluster.Name), networkXML)
+	os.Rename(filepath.Join(state, "dom."+cluster.Masters[0].Name), domainXML)
+
+	return networkXML, domainXML
+}
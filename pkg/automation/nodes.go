@@ -0,0 +1,201 @@
+package automation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// time between two checks of the nodes joining the cluster
+var nodeCheckInterval = 20 * time.Second
+
+// clusterClient : runs oc against a deployed cluster, with the kubeconfig
+// written by the installer
+type clusterClient struct {
+	ocPath     string
+	kubeconfig string
+}
+
+// the fields of the nodes and certificate signing requests used when scaling
+type clusterNode struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Status struct {
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+type clusterCSR struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Request []byte `json:"request"` // PEM, base64 encoded in the JSON
+	} `json:"spec"`
+	Status struct {
+		Conditions []interface{} `json:"conditions"`
+	} `json:"status"`
+}
+
+func (cc clusterClient) oc(args ...string) ([]byte, error) {
+	cmd := exec.Command(cc.ocPath, args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", cc.kubeconfig))
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		return nil, fmt.Errorf("oc %s: %s: %s", args[0], err, strings.TrimSpace(errb.String()))
+	}
+
+	return outb.Bytes(), nil
+}
+
+// Cordons and drains a node, and then deletes its Node object, so that its
+// workloads are moved before the host is removed. A missing node is an error,
+// as its host could still run workloads under another name
+func (cc clusterClient) removeNode(name string) error {
+	if _, err := cc.oc("get", "node", name); err != nil {
+		return fmt.Errorf("clusterClient: removeNode: node %s not found in the cluster, it can not be drained: %s", name, err)
+	}
+
+	log.Printf("clusterClient: removeNode: draining node %s...\n", name)
+
+	for _, args := range [][]string{
+		{"adm", "cordon", name},
+		{"adm", "drain", name, "--ignore-daemonsets", "--delete-local-data", "--force", "--timeout=10m"},
+		{"delete", "node", name},
+	} {
+		_, err := cc.oc(args...)
+
+		if err != nil {
+			return fmt.Errorf("clusterClient: removeNode: error removing node %s: %s", name, err)
+		}
+	}
+
+	log.Printf("clusterClient: removeNode: node %s removed from the cluster\n", name)
+
+	return nil
+}
+
+// Approves the certificate signing requests of the given nodes until they are
+// all Ready. Only the requests for the names of these nodes are approved, the
+// client ones first and then the serving ones, once the nodes have joined
+func (cc clusterClient) approveNodes(names []string, timeout time.Duration) error {
+	pendingNodes := map[string]bool{}
+	for _, name := range names {
+		pendingNodes[name] = true
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		out, err := cc.oc("get", "csr", "-o", "json")
+
+		if err != nil {
+			log.Printf("clusterClient: approveNodes: %s\n", err)
+		} else {
+			var csrs struct {
+				Items []clusterCSR `json:"items"`
+			}
+
+			err = json.Unmarshal(out, &csrs)
+
+			if err != nil {
+				return fmt.Errorf("clusterClient: approveNodes: error parsing certificate signing requests: %s", err)
+			}
+
+			for _, csr := range csrs.Items {
+				if len(csr.Status.Conditions) > 0 {
+					continue
+				}
+
+				nodeName := csrNodeName(csr.Spec.Request)
+
+				if !pendingNodes[nodeName] {
+					continue
+				}
+
+				log.Printf("clusterClient: approveNodes: approving certificate signing request %s of node %s\n", csr.Metadata.Name, nodeName)
+
+				_, err = cc.oc("adm", "certificate", "approve", csr.Metadata.Name)
+
+				if err != nil {
+					return fmt.Errorf("clusterClient: approveNodes: %s", err)
+				}
+			}
+		}
+
+		out, err = cc.oc("get", "nodes", "-o", "json")
+
+		if err == nil {
+			var nodes struct {
+				Items []clusterNode `json:"items"`
+			}
+
+			if json.Unmarshal(out, &nodes) == nil {
+				for _, node := range nodes.Items {
+					for _, condition := range node.Status.Conditions {
+						if condition.Type == "Ready" && condition.Status == "True" && pendingNodes[node.Metadata.Name] {
+							log.Printf("clusterClient: approveNodes: node %s is ready\n", node.Metadata.Name)
+							delete(pendingNodes, node.Metadata.Name)
+						}
+					}
+				}
+			}
+		}
+
+		if len(pendingNodes) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			notReady := []string{}
+			for _, name := range names {
+				if pendingNodes[name] {
+					notReady = append(notReady, name)
+				}
+			}
+
+			return fmt.Errorf("clusterClient: approveNodes: node(s) %s not ready after %s", strings.Join(notReady, ", "), timeout)
+		}
+
+		time.Sleep(nodeCheckInterval)
+	}
+}
+
+// Returns the node name a certificate signing request is for, from the
+// system:node:<name> common name of the request, or an empty string
+func csrNodeName(request []byte) string {
+	block, _ := pem.Decode(request)
+
+	if block == nil {
+		return ""
+	}
+
+	certificateRequest, err := x509.ParseCertificateRequest(block.Bytes)
+
+	if err != nil {
+		return ""
+	}
+
+	if !strings.HasPrefix(certificateRequest.Subject.CommonName, "system:node:") {
+		return ""
+	}
+
+	return strings.TrimPrefix(certificateRequest.Subject.CommonName, "system:node:")
+}
@@ -0,0 +1,221 @@
+package automation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fake oc: the nodes and certificate signing requests are read from
+// <state>/nodes.json and <state>/csr.json, a node exists if <state>/node.<name>
+// does, and the commands are appended to <state>/calls
+const fakeOc = `#!/bin/sh
+state="%s"
+echo "$@" >> "$state/calls"
+case "$1 $2" in
+"get csr")
+    cat "$state/csr.json" ;;
+"get nodes")
+    cat "$state/nodes.json" ;;
+"get node")
+    [ -f "$state/node.$3" ] || { echo "Error from server (NotFound): nodes \"$3\" not found" >&2; exit 1; } ;;
+"delete node")
+    rm "$state/node.$3" ;;
+esac
+exit 0
+`
+
+// writes a fake oc, returning the client running it and its state directory
+func newTestClusterClient(t *testing.T) (clusterClient, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	os.Mkdir(state, 0755)
+
+	ocPath := filepath.Join(dir, "oc")
+	err := ioutil.WriteFile(ocPath, []byte(fmt.Sprintf(fakeOc, state)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return clusterClient{ocPath: ocPath, kubeconfig: filepath.Join(dir, "kubeconfig")}, state
+}
+
+// the commands run by the fake oc
+func readOcCalls(t *testing.T, state string) []string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(filepath.Join(state, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+// a PEM certificate signing request with that common name
+func testCertificateRequest(t *testing.T, commonName string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName, Organization: []string{"system:nodes"}}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request})
+}
+
+func TestCSRNodeName(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		request []byte
+		want    string
+	}{
+		{"node", testCertificateRequest(t, "system:node:worker-2"), "worker-2"},
+		{"bootstrapper", testCertificateRequest(t, "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper"), ""},
+		{"not PEM", []byte("system:node:worker-2"), ""},
+		{"not a request", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: []byte("garbage")}), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := csrNodeName(tc.request); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// writes the certificate signing requests of the fake oc, by name, with the
+// node they are for, and whether they are approved already
+func writeTestCSRs(t *testing.T, state string, csrs map[string]struct {
+	node     string
+	approved bool
+}) {
+	t.Helper()
+
+	items := []clusterCSR{}
+	for name, csr := range csrs {
+		var item clusterCSR
+		item.Metadata.Name = name
+		item.Spec.Request = testCertificateRequest(t, "system:node:"+csr.node)
+		if csr.approved {
+			item.Status.Conditions = []interface{}{map[string]string{"type": "Approved"}}
+		}
+		items = append(items, item)
+	}
+
+	content, _ := json.Marshal(map[string]interface{}{"items": items})
+	ioutil.WriteFile(filepath.Join(state, "csr.json"), content, 0644)
+}
+
+// writes the nodes of the fake oc, with whether they are ready
+func writeTestNodes(t *testing.T, state string, nodes map[string]bool) {
+	t.Helper()
+
+	items := []map[string]interface{}{}
+	for name, ready := range nodes {
+		status := "False"
+		if ready {
+			status = "True"
+		}
+		items = append(items, map[string]interface{}{
+			"metadata": map[string]string{"name": name},
+			"status":   map[string]interface{}{"conditions": []map[string]string{{"type": "Ready", "status": status}}},
+		})
+	}
+
+	content, _ := json.Marshal(map[string]interface{}{"items": items})
+	ioutil.WriteFile(filepath.Join(state, "nodes.json"), content, 0644)
+}
+
+func TestApproveNodes(t *testing.T) {
+	cluster, state := newTestClusterClient(t)
+
+	writeTestCSRs(t, state, map[string]struct {
+		node     string
+		approved bool
+	}{
+		"csr-added":    {"worker-2", false},
+		"csr-approved": {"worker-2", true},
+		"csr-other":    {"worker-3", false},
+		"csr-master":   {"master-0", false},
+	})
+	writeTestNodes(t, state, map[string]bool{"worker-2": true, "worker-3": true, "master-0": true})
+
+	err := cluster.approveNodes([]string{"worker-2"}, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// only the pending request of the added node is approved
+	approved := []string{}
+	for _, call := range readOcCalls(t, state) {
+		if strings.HasPrefix(call, "adm certificate approve") {
+			approved = append(approved, call)
+		}
+	}
+	if fmt.Sprint(approved) != "[adm certificate approve csr-added]" {
+		t.Errorf("expected only csr-added to be approved, got %v", approved)
+	}
+}
+
+func TestApproveNodesTimeout(t *testing.T) {
+	interval := nodeCheckInterval
+	nodeCheckInterval = 10 * time.Millisecond
+	defer func() { nodeCheckInterval = interval }()
+
+	cluster, state := newTestClusterClient(t)
+
+	writeTestCSRs(t, state, map[string]struct {
+		node     string
+		approved bool
+	}{})
+	writeTestNodes(t, state, map[string]bool{"worker-2": true, "worker-3": false})
+
+	err := cluster.approveNodes([]string{"worker-2", "worker-3", "worker-4"}, 50*time.Millisecond)
+	if err == nil || err.Error() != "clusterClient: approveNodes: node(s) worker-3, worker-4 not ready after 50ms" {
+		t.Fatalf("expected the nodes that are not ready to be reported, got %v", err)
+	}
+}
+
+func TestRemoveNode(t *testing.T) {
+	cluster, state := newTestClusterClient(t)
+	ioutil.WriteFile(filepath.Join(state, "node.worker-1"), nil, 0644)
+
+	err := cluster.removeNode("worker-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"get node worker-1",
+		"adm cordon worker-1",
+		"adm drain worker-1 --ignore-daemonsets --delete-local-data --force --timeout=10m",
+		"delete node worker-1",
+	}
+	if got := readOcCalls(t, state); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// a node that is not found can not be drained
+	err = cluster.removeNode("worker-1")
+	if err == nil || !strings.Contains(err.Error(), "node worker-1 not found in the cluster, it can not be drained") {
+		t.Fatalf("expected the missing node to fail, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package automation
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sources profile.env with bash in the current environment, exporting what it
+// sets, and prints the environment before and after, separated by an empty entry
+const sourceProfileEnv = `env -0; printf '\0'; set -a; . "$1" >&2 || exit 1; env -0`
+
+// the variables of bash itself, that change when profile.env is sourced
+var shellVariables = map[string]bool{"_": true, "SHLVL": true, "PWD": true, "OLDPWD": true}
+
+// sources profile.env like the user would, returning the variables it sets or
+// changes, so quoting, expansions and comments are handled by the shell. A
+// missing file has no variables
+func parseProfileEnv(path string) (map[string]string, error) {
+	variables := map[string]string{}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return variables, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("bash", "-c", sourceProfileEnv, "bash", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	if err != nil {
+		return nil, fmt.Errorf("parseProfileEnv: error sourcing %s: %s: %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+
+	environments := strings.SplitN(stdout.String(), "\x00\x00", 2)
+	if len(environments) != 2 {
+		return nil, fmt.Errorf("parseProfileEnv: unexpected output sourcing %s", filepath.Base(path))
+	}
+
+	before := map[string]string{}
+	for _, entry := range strings.Split(environments[0], "\x00") {
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			before[parts[0]] = parts[1]
+		}
+	}
+
+	for _, entry := range strings.Split(environments[1], "\x00") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || shellVariables[parts[0]] {
+			continue
+		}
+		if value, ok := before[parts[0]]; !ok || value != parts[1] {
+			variables[parts[0]] = parts[1]
+		}
+	}
+
+	return variables, nil
+}
+
+// reads the variables set in profile.env on top of the defaults. The environment
+// takes precedence, as profile.env is usually sourced before deploying, and a
+// variable set on the command line is meant to override it
+func readProfileEnv(path string, defaults map[string]string) (map[string]string, error) {
+	settings := map[string]string{}
+	for key, value := range defaults {
+		settings[key] = value
+	}
+
+	variables, err := parseProfileEnv(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range variables {
+		settings[key] = value
+	}
+
+	for key := range settings {
+		if value, ok := os.LookupEnv(key); ok {
+			settings[key] = value
+		}
+	}
+
+	return settings, nil
+}
+
+// returns the environment with the variables of profile.env that it does not
+// set already, with the same precedence as readProfileEnv
+func profileEnvironment(path string) ([]string, error) {
+	variables, err := parseProfileEnv(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	environment := os.Environ()
+	for key, value := range variables {
+		if _, ok := os.LookupEnv(key); !ok {
+			environment = append(environment, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+
+	return environment, nil
+}
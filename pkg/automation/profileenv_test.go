@@ -0,0 +1,97 @@
+package automation
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// a profile.env as written by prepare_manifests, with what users add to it
+const testProfileEnv = `# generated by knictl
+export TF_VAR_libvirt_image="/var/lib/libvirt/images/rhcos qemu.qcow2"
+export TF_VAR_libvirt_pool='default' # storage pool
+TF_VAR_master_memory=8192
+export SETTINGS_DIR=$PROFILE_BASE/settings
+export LITERAL='$PROFILE_BASE'
+export MULTILINE="first
+second"
+export JOINED=one\
+two
+echo "sourced"
+`
+
+func writeTestProfileEnv(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "profile.env")
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestParseProfileEnv(t *testing.T) {
+	t.Setenv("PROFILE_BASE", "/opt/kni")
+	t.Setenv("UNCHANGED", "kept")
+
+	variables, err := parseProfileEnv(writeTestProfileEnv(t, testProfileEnv+"export UNCHANGED=kept\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]string{
+		"TF_VAR_libvirt_image": "/var/lib/libvirt/images/rhcos qemu.qcow2",
+		"TF_VAR_libvirt_pool":  "default",
+		"TF_VAR_master_memory": "8192",
+		"SETTINGS_DIR":         "/opt/kni/settings",
+		"LITERAL":              "$PROFILE_BASE",
+		"MULTILINE":            "first\nsecond",
+		"JOINED":               "onetwo",
+	}
+	if !reflect.DeepEqual(variables, want) {
+		t.Errorf("expected\n%v\ngot\n%v", want, variables)
+	}
+
+	// a missing profile.env has no variables
+	variables, err = parseProfileEnv(filepath.Join(t.TempDir(), "profile.env"))
+	if err != nil || len(variables) != 0 {
+		t.Errorf("expected no variables without profile.env, got %v %v", variables, err)
+	}
+
+	_, err = parseProfileEnv(writeTestProfileEnv(t, "export A=\"unterminated\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "parseProfileEnv: error sourcing profile.env: exit status") {
+		t.Errorf("expected the invalid profile.env to fail, got %v", err)
+	}
+}
+
+// the environment takes precedence over profile.env in both helpers
+func TestProfileEnvPrecedence(t *testing.T) {
+	path := writeTestProfileEnv(t, "export TF_VAR_libvirt_pool=profile\nexport TF_VAR_libvirt_image=/profile.qcow2\n")
+	t.Setenv("TF_VAR_libvirt_pool", "environment")
+
+	settings, err := readProfileEnv(path, map[string]string{"TF_VAR_libvirt_pool": "default", "TF_VAR_libvirt_disk_size": "32G"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"TF_VAR_libvirt_pool": "environment", "TF_VAR_libvirt_image": "/profile.qcow2", "TF_VAR_libvirt_disk_size": "32G"}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("expected settings\n%v\ngot\n%v", want, settings)
+	}
+
+	environment, err := profileEnvironment(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	values := map[string][]string{}
+	for _, entry := range environment {
+		parts := strings.SplitN(entry, "=", 2)
+		values[parts[0]] = append(values[parts[0]], parts[1])
+	}
+	if !reflect.DeepEqual(values["TF_VAR_libvirt_pool"], []string{"environment"}) || !reflect.DeepEqual(values["TF_VAR_libvirt_image"], []string{"/profile.qcow2"}) {
+		t.Errorf("expected the environment to take precedence once, got %v and %v", values["TF_VAR_libvirt_pool"], values["TF_VAR_libvirt_image"])
+	}
+}
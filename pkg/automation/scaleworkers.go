@@ -0,0 +1,232 @@
+package automation
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gerrit.akraino.org/kni/installer/pkg/bmc"
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// terraform resource created per worker by the workers target, named after the host
+const workerTerraformResourceType = "matchbox_group"
+
+// how long the added workers have to join the cluster
+const workerJoinTimeout = 45 * time.Minute
+
+// Scales the workers of a deployed cluster to the workers of the site config: the
+// workers that are not deployed yet are added and their certificate signing requests
+// approved, and the deployed workers removed from the site config are drained,
+// deleted from the cluster and powered off before their terraform resources are
+// destroyed
+func (bad baremetalAutomatedDeployment) ScaleWorkers() error {
+	sitePath := fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName)
+	automationRepoPath := fmt.Sprintf("%s/baremetal_automation", sitePath)
+
+	_, err := os.Stat(automationRepoPath)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: unable to access local automation repo at %s: %s", automationRepoPath, err)
+	}
+
+	err = checkAutomationSource(sitePath, automationRepoPath, bad.siteConfig.ProvisioningInfrastructure.Automation)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: %s", err)
+	}
+
+	// The deployed workers are the ones in the terraform state, before the
+	// configuration is generated again from the site config
+	deployed, err := terraformStateNames(fmt.Sprintf("%s/terraform/workers", automationRepoPath), workerTerraformResourceType)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: error reading the deployed workers, workers need to be deployed first: %s", err)
+	}
+
+	desired := []string{}
+	for _, worker := range bad.siteConfig.ProvisioningInfrastructure.Hosts.Workers {
+		desired = append(desired, worker.Name)
+	}
+
+	added, removed := diffHostNames(desired, deployed)
+
+	fmt.Printf("Workers deployed: %s\n", describeHostNames(deployed))
+	fmt.Printf("Workers to add: %s\n", describeHostNames(added))
+	fmt.Printf("Workers to remove: %s\n", describeHostNames(removed))
+
+	if len(added) == 0 && len(removed) == 0 && !bad.options.Plan {
+		log.Println("baremetalAutomatedDeployment: ScaleWorkers: the deployed workers already match the site config")
+		return nil
+	}
+
+	// The removed workers are only in the site config they were deployed with,
+	// copied to the automation repo, that has their BMC
+	deployedSiteConfig, err := siteconfig.Load(fmt.Sprintf("%s/cluster/site-config.yaml", automationRepoPath))
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: error reading the site config the workers were deployed with: %s", err)
+	}
+
+	err = bad.copySiteConfig(automationRepoPath)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: %s", err)
+	}
+
+	cluster := clusterClient{
+		ocPath:     fmt.Sprintf("%s/requirements/oc", automationRepoPath),
+		kubeconfig: fmt.Sprintf("%s/ocp/auth/kubeconfig", automationRepoPath),
+	}
+
+	// Move the workloads off the removed workers while they still run, and then
+	// power them off, so that they do not boot again from the bastion
+	if !bad.options.Plan {
+		for _, name := range removed {
+			err = cluster.removeNode(name)
+
+			if err != nil {
+				return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: %s", err)
+			}
+
+			err = bad.powerOffWorker(deployedSiteConfig, name)
+
+			if err != nil {
+				return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: %s", err)
+			}
+		}
+	}
+
+	// The bastion configuration lists the workers too, for the DHCP leases, the
+	// matchbox groups and the ingress backends
+	err = bad.runConfigGenerationScripts(automationRepoPath, "workers")
+
+	if err != nil {
+		return err
+	}
+
+	if !bad.options.Plan {
+		err = bad.runContainers(automationRepoPath)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = bad.runTerraform(automationRepoPath, "workers", terraformApply)
+
+	if err != nil {
+		return err
+	}
+
+	if bad.options.Plan || len(added) == 0 {
+		return nil
+	}
+
+	err = bad.validateMatchbox(automationRepoPath, "worker")
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: %s", err)
+	}
+
+	log.Printf("baremetalAutomatedDeployment: ScaleWorkers: waiting for worker(s) %s to join the cluster...\n", strings.Join(added, ", "))
+
+	err = cluster.approveNodes(added, workerJoinTimeout)
+
+	if err != nil {
+		return fmt.Errorf("baremetalAutomatedDeployment: ScaleWorkers: %s", err)
+	}
+
+	log.Println("baremetalAutomatedDeployment: ScaleWorkers: workers scaled")
+
+	return nil
+}
+
+// Powers off a worker through its BMC, as found in the site config it was
+// deployed with. Workers without a BMC address are left as they are
+func (bad baremetalAutomatedDeployment) powerOffWorker(siteConfig siteconfig.SiteConfig, name string) error {
+	var worker *siteconfig.Host
+
+	if siteConfig.ProvisioningInfrastructure != nil {
+		for i, host := range siteConfig.ProvisioningInfrastructure.Hosts.Workers {
+			if host.Name == name {
+				worker = &siteConfig.ProvisioningInfrastructure.Hosts.Workers[i]
+				break
+			}
+		}
+	}
+
+	if worker == nil {
+		return fmt.Errorf("powerOffWorker: worker %s not found in the site config it was deployed with", name)
+	}
+
+	if worker.BMC.Address == "" {
+		log.Printf("WARNING: worker %s has no BMC address, it needs to be powered off by hand\n", name)
+		return nil
+	}
+
+	provider, err := secrets.New(siteConfig.Secrets, secrets.Lookup{SitePath: fmt.Sprintf("%s/%s", bad.siteBuildPath, bad.siteName), GlobalPath: bad.siteBuildPath})
+
+	if err != nil {
+		return fmt.Errorf("powerOffWorker: error creating secret provider: %s", err)
+	}
+
+	hostBMC, err := bmc.New(worker.BMC, provider)
+
+	if err != nil {
+		return fmt.Errorf("powerOffWorker: error accessing the BMC of worker %s: %s", name, err)
+	}
+
+	err = hostBMC.PowerOff()
+
+	if err != nil {
+		return fmt.Errorf("powerOffWorker: error powering off worker %s: %s", name, err)
+	}
+
+	log.Printf("baremetalAutomatedDeployment: powerOffWorker: worker %s powered off\n", name)
+
+	return nil
+}
+
+// Returns the desired names that are not deployed, and the deployed names that
+// are not desired anymore, sorted
+func diffHostNames(desired []string, deployed []string) ([]string, []string) {
+	added := []string{}
+	removed := []string{}
+
+	deployedNames := map[string]bool{}
+	for _, name := range deployed {
+		deployedNames[name] = true
+	}
+
+	desiredNames := map[string]bool{}
+	for _, name := range desired {
+		desiredNames[name] = true
+		if !deployedNames[name] {
+			added = append(added, name)
+		}
+	}
+
+	for _, name := range deployed {
+		if !desiredNames[name] {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+func describeHostNames(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,93 @@
+package automation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+func TestDiffHostNames(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		desired  []string
+		deployed []string
+		added    []string
+		removed  []string
+	}{
+		{"unchanged", []string{"worker-1", "worker-0"}, []string{"worker-0", "worker-1"}, []string{}, []string{}},
+		{"added", []string{"worker-2", "worker-0", "worker-1"}, []string{"worker-0"}, []string{"worker-1", "worker-2"}, []string{}},
+		{"removed", []string{"worker-0"}, []string{"worker-1", "worker-0", "worker-2"}, []string{}, []string{"worker-1", "worker-2"}},
+		{"replaced", []string{"worker-3"}, []string{"worker-0"}, []string{"worker-3"}, []string{"worker-0"}},
+		{"first workers", []string{"worker-0"}, []string{}, []string{"worker-0"}, []string{}},
+		{"no workers left", nil, []string{"worker-0"}, []string{}, []string{"worker-0"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			added, removed := diffHostNames(tc.desired, tc.deployed)
+
+			if fmt.Sprint(added) != fmt.Sprint(tc.added) || fmt.Sprint(removed) != fmt.Sprint(tc.removed) {
+				t.Errorf("expected %v added and %v removed, got %v and %v", tc.added, tc.removed, added, removed)
+			}
+		})
+	}
+}
+
+// fake ipmitool, reporting the host as on and appending the commands to <dir>/calls
+const fakeIpmitool = `#!/bin/sh
+dir="%s"
+shift 9 # -I lanplus -H <host> -p <port> -U <username> -E
+echo "$IPMI_PASSWORD $@" >> "$dir/calls"
+[ "$*" = "chassis power status" ] && echo "Chassis Power is on"
+exit 0
+`
+
+func TestPowerOffWorker(t *testing.T) {
+	dir := t.TempDir()
+
+	err := ioutil.WriteFile(filepath.Join(dir, "ipmitool"), []byte(fmt.Sprintf(fakeIpmitool, dir)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	siteConfig := siteconfig.SiteConfig{
+		ProvisioningInfrastructure: &siteconfig.ProvisioningInfrastructure{
+			Hosts: siteconfig.Hosts{
+				Workers: []siteconfig.Host{
+					{Name: "worker-0"},
+					{Name: "worker-1", BMC: siteconfig.BMC{Address: "ipmi://10.0.0.11", Username: "admin", Password: "secret"}},
+				},
+			},
+		},
+	}
+
+	bad := baremetalAutomatedDeployment{siteBuildPath: dir, siteName: "edge"}
+
+	err = bad.powerOffWorker(siteConfig, "worker-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	calls, _ := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if want := "secret chassis power status\nsecret chassis power off\n"; string(calls) != want {
+		t.Errorf("expected ipmitool commands %q, got %q", want, calls)
+	}
+
+	// a worker without BMC is left as it is
+	err = bad.powerOffWorker(siteConfig, "worker-0")
+	if err != nil {
+		t.Errorf("unexpected error for a worker without BMC: %s", err)
+	}
+
+	err = bad.powerOffWorker(siteConfig, "worker-2")
+	if err == nil || !strings.Contains(err.Error(), "worker worker-2 not found in the site config it was deployed with") {
+		t.Errorf("expected an unknown worker to fail, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package automation
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// outcomes of the teardown steps, in the summary
+const (
+	teardownStepDone    = "done"
+	teardownStepFailed  = "failed"
+	teardownStepSkipped = "not run"
+)
+
+// a step of a cluster teardown, independent of the other steps so that they can
+// still run when it fails
+type teardownStep struct {
+	description string
+	run         func() error
+}
+
+// Runs the steps of a teardown in order, printing the outcome of every step in a
+// summary at the end. The steps after a failed one still run, unless failFast is
+// set, so that a partially deployed cluster is torn down as much as possible
+func runTeardown(caller string, steps []teardownStep, failFast bool) error {
+	outcomes := make([]string, len(steps))
+	failures := []string{}
+
+	for i, step := range steps {
+		if failFast && len(failures) > 0 {
+			outcomes[i] = teardownStepSkipped
+			continue
+		}
+
+		err := step.run()
+
+		if err != nil {
+			outcomes[i] = fmt.Sprintf("%s: %s", teardownStepFailed, err)
+			failures = append(failures, step.description)
+			if !failFast {
+				log.Printf("%s: %s failed, continuing with the next steps: %s\n", caller, step.description, err)
+			}
+			continue
+		}
+
+		outcomes[i] = teardownStepDone
+	}
+
+	fmt.Println("\nTeardown summary:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, step := range steps {
+		fmt.Fprintf(tw, "  %s\t%s\n", step.description, outcomes[i])
+	}
+	tw.Flush()
+	fmt.Println()
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s: %d of %d teardown steps failed: %s", caller, len(failures), len(steps), strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+// rollback : the teardown steps undoing the deployment steps started so far
+type rollback struct {
+	steps []teardownStep
+}
+
+// records the steps undoing a deployment step, before it starts
+func (r *rollback) record(steps ...teardownStep) {
+	r.steps = append(r.steps, steps...)
+}
+
+// Runs the recorded steps in reverse order, all of them even if some fail, and
+// returns the deployment error with the outcome of the rollback
+func (r *rollback) undo(caller string, deployErr error) error {
+	if len(r.steps) == 0 {
+		return deployErr
+	}
+
+	log.Printf("%s: %s\n", caller, deployErr)
+	log.Printf("%s: rolling back %d step(s)...\n", caller, len(r.steps))
+
+	steps := make([]teardownStep, len(r.steps))
+	for i, step := range r.steps {
+		steps[len(r.steps)-1-i] = step
+	}
+
+	err := runTeardown(caller, steps, false)
+
+	if err != nil {
+		return fmt.Errorf("%s, and the rollback failed: %s", deployErr, err)
+	}
+
+	return fmt.Errorf("%s, the deployment was rolled back", deployErr)
+}
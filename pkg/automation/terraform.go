@@ -0,0 +1,239 @@
+package automation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// terraformPlanDirectory : directory of a site where the terraform plans are saved
+// for review, relative to its build path
+const terraformPlanDirectory = "terraform_plans"
+
+// terraformPlan : the fields of `terraform show -json` used to summarize a plan
+type terraformPlan struct {
+	FormatVersion   string `json:"format_version"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// terraformPlanSummary : the addresses of the resources changed by a plan, by action
+type terraformPlanSummary struct {
+	Create  []string
+	Update  []string
+	Replace []string
+	Delete  []string
+}
+
+// Returns the name of the plan file of a terraform target and operation, that
+// is also how the reviewed plans are matched when applying them
+func terraformPlanFileName(targetType string, operation terraformOperation) string {
+	return fmt.Sprintf("%s-%s.tfplan", targetType, operation)
+}
+
+// Finds the reviewed plan of a terraform target and operation among the given plan files
+func findTerraformPlanFile(planFiles []string, targetType string, operation terraformOperation) (string, error) {
+	planFileName := terraformPlanFileName(targetType, operation)
+
+	for _, planFile := range planFiles {
+		if filepath.Base(planFile) == planFileName {
+			return filepath.Abs(planFile)
+		}
+	}
+
+	return "", fmt.Errorf("no reviewed plan %s for the %s terraform %s among the plan files given, create it with --plan", planFileName, targetType, operation)
+}
+
+// Fingerprints the inputs of a plan: the given content, and the path and content
+// of every file of the given directories, in order
+func fingerprintInputs(content []byte, directories ...string) (string, error) {
+	hash := sha256.New()
+	hash.Write(content)
+
+	for _, directory := range directories {
+		err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+
+			fileContent, err := ioutil.ReadFile(path)
+
+			if err != nil {
+				return err
+			}
+
+			relativePath, _ := filepath.Rel(directory, path)
+			fmt.Fprintf(hash, "\x00%s\x00%d\x00", relativePath, len(fileContent))
+			hash.Write(fileContent)
+
+			return nil
+		})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// Summarizes a saved plan from its JSON representation
+func summarizeTerraformPlan(terraformPath string, planFile string) (terraformPlanSummary, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("terraform", "show", "-json", planFile)
+	cmd.Dir = terraformPath
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	if err != nil {
+		return terraformPlanSummary{}, fmt.Errorf("summarizeTerraformPlan: error running terraform show: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseTerraformPlan(stdout.Bytes())
+}
+
+func parseTerraformPlan(planJSON []byte) (terraformPlanSummary, error) {
+	summary := terraformPlanSummary{}
+
+	var plan terraformPlan
+
+	err := json.Unmarshal(planJSON, &plan)
+
+	if err != nil {
+		return summary, fmt.Errorf("parseTerraformPlan: invalid terraform plan JSON: %s", err)
+	}
+
+	if plan.FormatVersion == "" {
+		return summary, fmt.Errorf("parseTerraformPlan: terraform plan JSON has no format_version, terraform 0.12 or newer is needed")
+	}
+
+	for _, resourceChange := range plan.ResourceChanges {
+		// replacements are a delete and a create, in either order
+		switch strings.Join(resourceChange.Change.Actions, ",") {
+		case "create":
+			summary.Create = append(summary.Create, resourceChange.Address)
+		case "update":
+			summary.Update = append(summary.Update, resourceChange.Address)
+		case "delete,create", "create,delete":
+			summary.Replace = append(summary.Replace, resourceChange.Address)
+		case "delete":
+			summary.Delete = append(summary.Delete, resourceChange.Address)
+		}
+	}
+
+	for _, addresses := range [][]string{summary.Create, summary.Update, summary.Replace, summary.Delete} {
+		sort.Strings(addresses)
+	}
+
+	return summary, nil
+}
+
+// String : one line per changed resource, prefixed like in the terraform plan
+// output, followed by the counts
+func (tps terraformPlanSummary) String() string {
+	var out strings.Builder
+
+	for _, changes := range []struct {
+		symbol    string
+		addresses []string
+	}{{"+", tps.Create}, {"~", tps.Update}, {"-/+", tps.Replace}, {"-", tps.Delete}} {
+		for _, address := range changes.addresses {
+			fmt.Fprintf(&out, "  %-3s %s\n", changes.symbol, address)
+		}
+	}
+
+	fmt.Fprintf(&out, "  %d to create, %d to update, %d to replace, %d to destroy\n", len(tps.Create), len(tps.Update), len(tps.Replace), len(tps.Delete))
+
+	return out.String()
+}
+
+// terraformState : the fields of `terraform show -json` on a state used to find
+// the deployed resources
+type terraformState struct {
+	Values *struct {
+		RootModule terraformStateModule `json:"root_module"`
+	} `json:"values"`
+}
+
+type terraformStateModule struct {
+	Resources []struct {
+		Address string                 `json:"address"`
+		Type    string                 `json:"type"`
+		Values  map[string]interface{} `json:"values"`
+	} `json:"resources"`
+	ChildModules []terraformStateModule `json:"child_modules"`
+}
+
+// Lists the names of the resources of a type in the state of a terraform
+// directory, sorted. An empty state has no resources
+func terraformStateNames(terraformPath string, resourceType string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("terraform", "show", "-json")
+	cmd.Dir = terraformPath
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	if err != nil {
+		return nil, fmt.Errorf("terraformStateNames: error running terraform show: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseTerraformStateNames(stdout.Bytes(), resourceType)
+}
+
+func parseTerraformStateNames(stateJSON []byte, resourceType string) ([]string, error) {
+	var state terraformState
+
+	err := json.Unmarshal(stateJSON, &state)
+
+	if err != nil {
+		return nil, fmt.Errorf("parseTerraformStateNames: invalid terraform state JSON: %s", err)
+	}
+
+	names := []string{}
+
+	if state.Values == nil {
+		return names, nil
+	}
+
+	modules := []terraformStateModule{state.Values.RootModule}
+
+	for len(modules) > 0 {
+		module := modules[0]
+		modules = append(modules[1:], module.ChildModules...)
+
+		for _, resource := range module.Resources {
+			if resource.Type != resourceType {
+				continue
+			}
+
+			name, ok := resource.Values["name"].(string)
+
+			if !ok || name == "" {
+				return nil, fmt.Errorf("parseTerraformStateNames: resource %s has no name", resource.Address)
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
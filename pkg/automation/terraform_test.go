@@ -0,0 +1,295 @@
+package automation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// terraform show -json output of the workers target, with the matchbox groups
+// in a child module
+const testWorkersState = `{
+  "format_version": "0.1",
+  "terraform_version": "0.12.24",
+  "values": {
+    "root_module": {
+      "resources": [
+        {"address": "null_resource.power", "type": "null_resource", "values": {"id": "1"}}
+      ],
+      "child_modules": [
+        {
+          "address": "module.workers",
+          "resources": [
+            {"address": "module.workers.matchbox_group.worker[1]", "type": "matchbox_group", "values": {"name": "worker-1"}},
+            {"address": "module.workers.matchbox_group.worker[0]", "type": "matchbox_group", "values": {"name": "worker-0"}},
+            {"address": "module.workers.matchbox_profile.worker[0]", "type": "matchbox_profile", "values": {"name": "worker-profile"}}
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func TestParseTerraformStateNames(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		state string
+		want  []string
+		err   string
+	}{
+		{"workers", testWorkersState, []string{"worker-0", "worker-1"}, ""},
+		{"empty state", `{"format_version": "0.1"}`, []string{}, ""},
+		{"no name", `{"values": {"root_module": {"resources": [{"address": "matchbox_group.worker[0]", "type": "matchbox_group", "values": {}}]}}}`, nil, "parseTerraformStateNames: resource matchbox_group.worker[0] has no name"},
+		{"invalid JSON", "No state.", nil, "parseTerraformStateNames: invalid terraform state JSON"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			names, err := parseTerraformStateNames([]byte(tc.state), "matchbox_group")
+
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, names)
+			}
+		})
+	}
+}
+
+// terraform show -json output of a plan, with a change of each kind, a no-op and
+// a read of a data source
+const testPlan = `{
+  "format_version": "0.1",
+  "terraform_version": "0.12.24",
+  "resource_changes": [
+    {"address": "module.masters.matchbox_group.master[1]", "change": {"actions": ["create"]}},
+    {"address": "module.masters.matchbox_group.master[0]", "change": {"actions": ["create"]}},
+    {"address": "module.masters.matchbox_profile.master[0]", "change": {"actions": ["update"]}},
+    {"address": "module.bootstrap.null_resource.power", "change": {"actions": ["delete", "create"]}},
+    {"address": "module.bootstrap.matchbox_profile.bootstrap", "change": {"actions": ["create", "delete"]}},
+    {"address": "module.bootstrap.matchbox_group.bootstrap", "change": {"actions": ["delete"]}},
+    {"address": "null_resource.dns", "change": {"actions": ["no-op"]}},
+    {"address": "data.template_file.ignition", "change": {"actions": ["read"]}}
+  ]
+}`
+
+func TestParseTerraformPlan(t *testing.T) {
+	summary, err := parseTerraformPlan([]byte(testPlan))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `  +   module.masters.matchbox_group.master[0]
+  +   module.masters.matchbox_group.master[1]
+  ~   module.masters.matchbox_profile.master[0]
+  -/+ module.bootstrap.matchbox_profile.bootstrap
+  -/+ module.bootstrap.null_resource.power
+  -   module.bootstrap.matchbox_group.bootstrap
+  2 to create, 1 to update, 2 to replace, 1 to destroy
+`
+	if summary.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, summary)
+	}
+
+	for _, tc := range []struct {
+		name string
+		plan string
+		err  string
+	}{
+		{"no format version", `{"resource_changes": []}`, "parseTerraformPlan: terraform plan JSON has no format_version, terraform 0.12 or newer is needed"},
+		{"invalid JSON", "This plan was saved to: cluster-apply.tfplan", "parseTerraformPlan: invalid terraform plan JSON"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseTerraformPlan([]byte(tc.plan))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// a plan without changes
+	summary, err = parseTerraformPlan([]byte(`{"format_version": "0.1"}`))
+	if err != nil || summary.String() != "  0 to create, 0 to update, 0 to replace, 0 to destroy\n" {
+		t.Errorf("unexpected summary of an empty plan: %q %v", summary, err)
+	}
+}
+
+func TestFindTerraformPlanFile(t *testing.T) {
+	planFiles := []string{"/plans/workers-apply.tfplan", "plans/cluster-destroy.tfplan", "/plans/cluster-apply.tfplan.bak"}
+
+	planFile, err := findTerraformPlanFile(planFiles, "workers", terraformApply)
+	if err != nil || planFile != "/plans/workers-apply.tfplan" {
+		t.Errorf("expected the workers plan, got %q %v", planFile, err)
+	}
+
+	// relative paths are made absolute, as terraform runs in its directory
+	planFile, err = findTerraformPlanFile(planFiles, "cluster", terraformDestroy)
+	wd, _ := os.Getwd()
+	if err != nil || planFile != filepath.Join(wd, "plans/cluster-destroy.tfplan") {
+		t.Errorf("expected the absolute cluster plan, got %q %v", planFile, err)
+	}
+
+	_, err = findTerraformPlanFile(planFiles, "cluster", terraformApply)
+	if err == nil || err.Error() != "no reviewed plan cluster-apply.tfplan for the cluster terraform apply among the plan files given, create it with --plan" {
+		t.Errorf("expected the missing plan to be reported, got %v", err)
+	}
+}
+
+func TestFingerprintInputs(t *testing.T) {
+	root := t.TempDir()
+	manifests := filepath.Join(root, "manifests")
+	automation := filepath.Join(root, "automation")
+
+	for path, content := range map[string]string{
+		"manifests/install-config.yaml":   "baseDomain: example.com",
+		"manifests/openshift/99_kni.yaml": "kind: ConfigMap",
+		"automation/site-config.yaml":     "config: {}",
+	} {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0700)
+		ioutil.WriteFile(filepath.Join(root, path), []byte(content), 0600)
+	}
+
+	fingerprint := func(content string, directories ...string) string {
+		t.Helper()
+
+		hash, err := fingerprintInputs([]byte(content), directories...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	planned := fingerprint("site", manifests, automation)
+	if len(planned) != 64 || fingerprint("site", manifests, automation) != planned {
+		t.Fatalf("expected a stable sha256 fingerprint, got %q", planned)
+	}
+
+	for name, change := range map[string]func() string{
+		"content": func() string {
+			return fingerprint("other site", manifests, automation)
+		},
+		"directory order": func() string {
+			return fingerprint("site", automation, manifests)
+		},
+		"file content": func() string {
+			ioutil.WriteFile(filepath.Join(manifests, "openshift/99_kni.yaml"), []byte("kind: Secret"), 0600)
+			defer ioutil.WriteFile(filepath.Join(manifests, "openshift/99_kni.yaml"), []byte("kind: ConfigMap"), 0600)
+			return fingerprint("site", manifests, automation)
+		},
+		"renamed file": func() string {
+			os.Rename(filepath.Join(manifests, "openshift/99_kni.yaml"), filepath.Join(manifests, "openshift/98_kni.yaml"))
+			defer os.Rename(filepath.Join(manifests, "openshift/98_kni.yaml"), filepath.Join(manifests, "openshift/99_kni.yaml"))
+			return fingerprint("site", manifests, automation)
+		},
+		"new file": func() string {
+			ioutil.WriteFile(filepath.Join(automation, "pull-secret.yaml"), nil, 0600)
+			defer os.Remove(filepath.Join(automation, "pull-secret.yaml"))
+			return fingerprint("site", manifests, automation)
+		},
+	} {
+		if change() == planned {
+			t.Errorf("expected a changed %s to change the fingerprint", name)
+		}
+	}
+
+	// the changes were reverted
+	if fingerprint("site", manifests, automation) != planned {
+		t.Errorf("expected the fingerprint of the reverted inputs to be the planned one")
+	}
+
+	_, err := fingerprintInputs(nil, filepath.Join(root, "missing"))
+	if err == nil {
+		t.Errorf("expected a missing directory to fail")
+	}
+}
+
+// fake terraform: logs its directory and arguments to <state>/calls, and shows
+// the test plan
+const fakeTerraform = `#!/bin/sh
+echo "$(basename $PWD): $*" >> %s/calls
+[ "$1" = show ] && cat <<'PLAN'
+%s
+PLAN
+exit 0
+`
+
+// puts the fake terraform first in the PATH, returning its state directory
+func installFakeTerraform(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	os.Mkdir(state, 0755)
+
+	err := ioutil.WriteFile(filepath.Join(dir, "terraform"), []byte(fmt.Sprintf(fakeTerraform, state, testPlan)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	t.Cleanup(func() { os.Setenv("PATH", path) })
+
+	return state
+}
+
+// the bootstrap removal is planned and applied from its reviewed plan like the
+// terraform targets, and only destroyed without review when no plan is used
+func TestDestroyBootstrapTerraform(t *testing.T) {
+	buildPath := t.TempDir()
+	automationRepoPath := filepath.Join(buildPath, "edge.example.com", "baremetal_automation")
+	os.MkdirAll(filepath.Join(automationRepoPath, "terraform", "cluster"), 0755)
+	planFile := filepath.Join(buildPath, "edge.example.com", terraformPlanDirectory, "bootstrap-destroy.tfplan")
+
+	for _, tc := range []struct {
+		name      string
+		plan      bool
+		planFiles []string
+		calls     []string
+		err       string
+	}{
+		{"plan", true, nil, []string{
+			fmt.Sprintf("cluster: plan -input=false -out=%s -destroy -target=module.bootstrap", planFile),
+			fmt.Sprintf("cluster: show -json %s", planFile),
+		}, ""},
+		{"reviewed plan", false, []string{"/plans/workers-apply.tfplan", planFile}, []string{
+			fmt.Sprintf("cluster: apply -input=false %s", planFile),
+		}, ""},
+		{"reviewed plan missing", false, []string{"/plans/workers-apply.tfplan"}, nil,
+			"baremetalAutomatedDeployment: destroyBootstrapTerraform: no reviewed plan bootstrap-destroy.tfplan for the bootstrap terraform destroy among the plan files given, create it with --plan"},
+		{"no plan", false, nil, []string{"cluster: destroy -target=module.bootstrap --auto-approve"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state := installFakeTerraform(t)
+
+			bad := baremetalAutomatedDeployment{siteBuildPath: buildPath, siteName: "edge.example.com"}
+			bad.options.Plan = tc.plan
+			bad.options.PlanFiles = tc.planFiles
+
+			err := bad.destroyBootstrapTerraform(automationRepoPath)
+
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			calls, _ := ioutil.ReadFile(filepath.Join(state, "calls"))
+			if got := strings.Split(strings.TrimSpace(string(calls)), "\n"); fmt.Sprint(got) != fmt.Sprint(tc.calls) {
+				t.Errorf("expected terraform to run\n%q\ngot\n%q", tc.calls, got)
+			}
+		})
+	}
+}
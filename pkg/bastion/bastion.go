@@ -0,0 +1,237 @@
+package bastion
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+// port of the matchbox HTTP endpoint on the bastion, where hosts chainload iPXE
+const matchboxHTTPPort = 8080
+
+// ConfigFiles : configuration files of the bastion services, by path relative to
+// the baremetal automation repo, where the service containers mount them from
+type ConfigFiles map[string]string
+
+// Paths : the paths of the files, sorted
+func (cf ConfigFiles) Paths() []string {
+	paths := []string{}
+	for path := range cf {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Write : writes the files under basePath, creating their directories
+func (cf ConfigFiles) Write(basePath string) error {
+	for _, path := range cf.Paths() {
+		fullPath := filepath.Join(basePath, path)
+
+		err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err != nil {
+			return fmt.Errorf("Bastion: Write: error creating directory for %s: %s", path, err)
+		}
+
+		err = ioutil.WriteFile(fullPath, []byte(cf[path]), 0644)
+		if err != nil {
+			return fmt.Errorf("Bastion: Write: error writing %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// a host of the site, with its role
+type bastionHost struct {
+	siteconfig.Host
+	Role string
+}
+
+// lists the masters and then the workers of the site
+func siteHosts(pi siteconfig.ProvisioningInfrastructure) []bastionHost {
+	hosts := []bastionHost{}
+	for _, host := range pi.Hosts.Masters {
+		hosts = append(hosts, bastionHost{Host: host, Role: "master"})
+	}
+	for _, host := range pi.Hosts.Workers {
+		hosts = append(hosts, bastionHost{Host: host, Role: "worker"})
+	}
+	return hosts
+}
+
+// clusterAddresses : the addresses of the cluster on the baremetal network,
+// served by the DNS and load balancer of the bastion
+type clusterAddresses struct {
+	APIVIP      string
+	IngressVIP  string
+	BootstrapIP string
+	DNSServer   string
+	Masters     []bastionHost
+	Workers     []bastionHost
+}
+
+// checks that every host has an address on the baremetal network, and that the
+// load balanced addresses do not collide with the hosts ones
+func planClusterAddresses(pi siteconfig.ProvisioningInfrastructure) (clusterAddresses, error) {
+	network := pi.Network
+	addresses := clusterAddresses{
+		APIVIP:      network.APIVIP,
+		IngressVIP:  network.IngressVIP,
+		BootstrapIP: network.BootstrapIP,
+		DNSServer:   network.BaremetalIP,
+	}
+
+	baremetalNetwork, err := parseIPv4CIDR("baremetalIpCidr", network.BaremetalIPCIDR)
+	if err != nil {
+		return addresses, err
+	}
+
+	if network.BaremetalIP == "" {
+		return addresses, fmt.Errorf("provisioningInfrastructure.network.baremetalIp is required, it is the DNS server and load balancer of the hosts")
+	}
+	if addresses.APIVIP == "" {
+		addresses.APIVIP = network.BaremetalIP
+	}
+	if addresses.IngressVIP == "" {
+		addresses.IngressVIP = network.BaremetalIP
+	}
+
+	// owner of each address, to report collisions
+	owners := map[string]string{}
+	claim := func(owner string, ip string) error {
+		err := checkIPInNetwork(owner, ip, baremetalNetwork)
+		if err != nil {
+			return err
+		}
+		if other, ok := owners[ip]; ok {
+			return fmt.Errorf("%s %s is also used by %s", owner, ip, other)
+		}
+		owners[ip] = owner
+		return nil
+	}
+
+	if len(pi.Hosts.Masters) == 0 {
+		return addresses, fmt.Errorf("provisioningInfrastructure.hosts.masters needs at least one host")
+	}
+
+	for _, host := range siteHosts(pi) {
+		owner := fmt.Sprintf("%s %s", host.Role, host.Name)
+		if host.IP == "" {
+			return addresses, fmt.Errorf("%s has no ip on the baremetal network", owner)
+		}
+		err = claim(owner, host.IP)
+		if err != nil {
+			return addresses, err
+		}
+
+		if host.Role == "master" {
+			addresses.Masters = append(addresses.Masters, host)
+		} else {
+			addresses.Workers = append(addresses.Workers, host)
+		}
+	}
+
+	for _, address := range []struct {
+		owner string
+		ip    string
+	}{{"bootstrapIp", addresses.BootstrapIP}, {"baremetalGWIP", network.BaremetalGatewayIP}} {
+		if address.ip == "" {
+			continue
+		}
+		err = claim(address.owner, address.ip)
+		if err != nil {
+			return addresses, err
+		}
+	}
+
+	// the VIPs can be shared by api and ingress, and be the bastion address,
+	// as they are served by the same load balancer
+	for _, address := range []struct {
+		owner string
+		ip    string
+	}{{"apiVip", addresses.APIVIP}, {"ingressVip", addresses.IngressVIP}, {"baremetalIp", network.BaremetalIP}} {
+		err = checkIPInNetwork(address.owner, address.ip, baremetalNetwork)
+		if err != nil {
+			return addresses, err
+		}
+		if other, ok := owners[address.ip]; ok {
+			return addresses, fmt.Errorf("%s %s is also used by %s", address.owner, address.ip, other)
+		}
+	}
+
+	return addresses, nil
+}
+
+// whether the provisioning and baremetal networks share the same bastion
+// interface and VLAN, for hosts with a single NIC
+func sharedInterface(network siteconfig.Network) bool {
+	return interfaceName(network.ProvisioningInterface, network.ProvisioningVLAN) == interfaceName(network.BaremetalInterface, network.BaremetalVLAN)
+}
+
+// returns the address of the bastion on the network the hosts PXE boot from,
+// where they reach TFTP and matchbox: the provisioning network, or the
+// baremetal one when both share the same bastion interface
+func bootServerAddress(network siteconfig.Network) (string, error) {
+	if sharedInterface(network) {
+		if network.BaremetalIP == "" {
+			return "", fmt.Errorf("provisioningInfrastructure.network.baremetalIp is required")
+		}
+		return network.BaremetalIP, nil
+	}
+
+	provisioningNetwork, err := parseIPv4CIDR("provisioningIpCidr", network.ProvisioningIPCIDR)
+	if err != nil {
+		return "", err
+	}
+
+	provisioningIP := network.ProvisioningIP
+	if provisioningIP == "" {
+		provisioningIP = utils.HostIP(provisioningNetwork, 1)
+	}
+
+	err = checkIPInNetwork("provisioningInfrastructure.network.provisioningIp", provisioningIP, provisioningNetwork)
+	if err != nil {
+		return "", err
+	}
+
+	return provisioningIP, nil
+}
+
+// name of the bastion interface for a network, with its VLAN if tagged
+func interfaceName(name string, vlan int) string {
+	if vlan == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s.%d", name, vlan)
+}
+
+// parses an IPv4 CIDR, as dnsmasq static leases and the automation only
+// support IPv4 networks
+func parseIPv4CIDR(field string, cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, fmt.Errorf("provisioningInfrastructure.network.%s is required", field)
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || network.IP.To4() == nil {
+		return nil, fmt.Errorf("provisioningInfrastructure.network.%s must be an IPv4 CIDR, got %q", field, cidr)
+	}
+
+	return network, nil
+}
+
+// checks that an address belongs to a network
+func checkIPInNetwork(field string, ip string, network *net.IPNet) error {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil || !network.Contains(parsedIP) {
+		return fmt.Errorf("%s %q is not in %s", field, ip, network)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package bastion
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the rendered configs")
+
+// compares a rendered config with testdata/<name>.golden, or writes it with -update
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+
+	goldenPath := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		err := ioutil.WriteFile(goldenPath, []byte(got), 0644)
+		if err != nil {
+			t.Fatalf("error updating %s: %s", goldenPath, err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("error reading %s, run the tests with -update to create it: %s", goldenPath, err)
+	}
+
+	if got != string(want) {
+		t.Errorf("%s differs from %s:\n--- got\n%s\n--- want\n%s", name, goldenPath, got, want)
+	}
+}
+
+// checks that err is set and contains want
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected an error containing %q, got none", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got %q", want, err)
+	}
+}
+
+// provisioning infrastructure of a site with three masters and two workers,
+// with separate interfaces for the provisioning and baremetal networks
+func testInfrastructure() siteconfig.ProvisioningInfrastructure {
+	host := func(name string, ip string, bootMAC string, sdnMAC string) siteconfig.Host {
+		return siteconfig.Host{Name: name, IP: ip, BootMACAddress: bootMAC, SdnMACAddress: sdnMAC}
+	}
+
+	return siteconfig.ProvisioningInfrastructure{
+		Network: siteconfig.Network{
+			ProvisioningInterface: "eno1",
+			BaremetalInterface:    "eno2",
+			ProvisioningIPCIDR:    "172.22.0.0/24",
+			BaremetalIPCIDR:       "192.168.111.0/24",
+			BaremetalIP:           "192.168.111.1",
+			BaremetalGatewayIP:    "192.168.111.254",
+			APIVIP:                "192.168.111.5",
+			IngressVIP:            "192.168.111.6",
+			BootstrapIP:           "192.168.111.10",
+		},
+		Hosts: siteconfig.Hosts{
+			Masters: []siteconfig.Host{
+				host("master-0", "192.168.111.11", "52:54:00:00:01:00", "52:54:00:00:02:00"),
+				host("master-1", "192.168.111.12", "52:54:00:00:01:01", "52:54:00:00:02:01"),
+				host("master-2", "192.168.111.13", "52:54:00:00:01:02", "52:54:00:00:02:02"),
+			},
+			Workers: []siteconfig.Host{
+				host("worker-0", "192.168.111.51", "52:54:00:00:01:10", "52:54:00:00:02:10"),
+				host("worker-1", "192.168.111.52", "52:54:00:00:01:11", "52:54:00:00:02:11"),
+			},
+		},
+	}
+}
+
+// same site, with hosts that have a single NIC for both networks, on a single
+// bastion interface
+func testSingleNICInfrastructure() siteconfig.ProvisioningInfrastructure {
+	pi := testInfrastructure()
+	pi.Network.BaremetalInterface = pi.Network.ProvisioningInterface
+
+	for _, hosts := range [][]siteconfig.Host{pi.Hosts.Masters, pi.Hosts.Workers} {
+		for i := range hosts {
+			hosts[i].SdnMACAddress = ""
+		}
+	}
+
+	return pi
+}
+
+func TestPlanClusterAddressesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(pi *siteconfig.ProvisioningInfrastructure)
+		err    string
+	}{
+		{"host without ip", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Hosts.Workers[0].IP = ""
+		}, "worker worker-0 has no ip on the baremetal network"},
+		{"apiVip equal to a host ip", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Network.APIVIP = "192.168.111.12"
+		}, "apiVip 192.168.111.12 is also used by master master-1"},
+		{"ingressVip equal to the bootstrap ip", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Network.IngressVIP = "192.168.111.10"
+		}, "ingressVip 192.168.111.10 is also used by bootstrapIp"},
+		{"host ip outside baremetalIpCidr", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Hosts.Masters[2].IP = "192.168.112.13"
+		}, `master master-2 "192.168.112.13" is not in 192.168.111.0/24`},
+		{"apiVip outside baremetalIpCidr", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Network.APIVIP = "10.0.0.5"
+		}, `apiVip "10.0.0.5" is not in 192.168.111.0/24`},
+		{"duplicated host ip", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Hosts.Workers[1].IP = pi.Hosts.Workers[0].IP
+		}, "worker worker-1 192.168.111.51 is also used by worker worker-0"},
+		{"no masters", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Hosts.Masters = nil
+		}, "provisioningInfrastructure.hosts.masters needs at least one host"},
+		{"no baremetalIp", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Network.BaremetalIP = ""
+		}, "provisioningInfrastructure.network.baremetalIp is required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pi := testInfrastructure()
+			tc.modify(&pi)
+
+			_, err := planClusterAddresses(pi)
+			checkError(t, err, tc.err)
+
+			// the generators report the same problems
+			_, err = GenerateCoreDNS(pi, testCluster)
+			checkError(t, err, "Bastion: GenerateCoreDNS: "+tc.err)
+
+			_, err = GenerateHAProxy(pi)
+			checkError(t, err, "Bastion: GenerateHAProxy: "+tc.err)
+		})
+	}
+}
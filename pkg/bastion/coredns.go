@@ -0,0 +1,129 @@
+package bastion
+
+import (
+	"fmt"
+	"regexp"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+// CoreDNS configuration paths, as mounted by the coredns container in /etc/coredns
+const (
+	CoreDNSDirectory  = "coredns"
+	CoreDNSConfigPath = "coredns/Corefile"
+)
+
+// host names that can be published as records of the cluster zone
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Cluster : the name and base domain of the cluster, from install-config.yaml
+type Cluster struct {
+	Name       string
+	BaseDomain string
+}
+
+// Zone : the DNS zone of the cluster
+func (c Cluster) Zone() string {
+	return fmt.Sprintf("%s.%s", c.Name, c.BaseDomain)
+}
+
+type dnsRecord struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+type coreDNSSettings struct {
+	Zone    string
+	Records []dnsRecord
+}
+
+const corefileTemplate = `# Generated by knictl from site-config.yaml, do not edit
+{{.Zone}} {
+    log
+    errors
+    file /etc/coredns/db.{{.Zone}}
+}
+
+. {
+    errors
+    cache 30
+    forward . /etc/resolv.conf
+}
+`
+
+const zoneTemplate = `; Generated by knictl from site-config.yaml, do not edit
+$ORIGIN {{.Zone}}.
+$TTL 300
+@ IN SOA ns1.{{.Zone}}. admin.{{.Zone}}. (
+    1      ; serial
+    3600   ; refresh
+    600    ; retry
+    604800 ; expire
+    300    ; minimum
+)
+@ IN NS ns1.{{.Zone}}.
+{{- range .Records}}
+{{.Name}} IN {{.Type}} {{.Value}}
+{{- end}}
+`
+
+// GenerateCoreDNS : renders the CoreDNS configuration serving the cluster zone:
+// the api, api-int and *.apps load balanced addresses, the etcd records and SRV
+// entries of the masters, and the names of the hosts. Other names are forwarded
+// to the resolvers of the bastion
+func GenerateCoreDNS(pi siteconfig.ProvisioningInfrastructure, cluster Cluster) (ConfigFiles, error) {
+	if cluster.Name == "" || cluster.BaseDomain == "" {
+		return nil, fmt.Errorf("Bastion: GenerateCoreDNS: the cluster name and base domain are required")
+	}
+
+	addresses, err := planClusterAddresses(pi)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateCoreDNS: %s", err)
+	}
+
+	settings := coreDNSSettings{Zone: cluster.Zone()}
+	settings.Records = []dnsRecord{
+		{Name: "ns1", Type: "A", Value: addresses.DNSServer},
+		{Name: "api", Type: "A", Value: addresses.APIVIP},
+		{Name: "api-int", Type: "A", Value: addresses.APIVIP},
+		{Name: "*.apps", Type: "A", Value: addresses.IngressVIP},
+	}
+
+	if addresses.BootstrapIP != "" {
+		settings.Records = append(settings.Records, dnsRecord{Name: "bootstrap", Type: "A", Value: addresses.BootstrapIP})
+	}
+
+	for _, host := range append(addresses.Masters, addresses.Workers...) {
+		if dnsLabel.MatchString(host.Name) {
+			settings.Records = append(settings.Records, dnsRecord{Name: host.Name, Type: "A", Value: host.IP})
+		}
+	}
+
+	// etcd members are found with SRV records of the etcd-<index> names
+	for i, master := range addresses.Masters {
+		settings.Records = append(settings.Records, dnsRecord{Name: fmt.Sprintf("etcd-%d", i), Type: "A", Value: master.IP})
+	}
+	for i := range addresses.Masters {
+		settings.Records = append(settings.Records, dnsRecord{
+			Name:  "_etcd-server-ssl._tcp",
+			Type:  "SRV",
+			Value: fmt.Sprintf("0 10 2380 etcd-%d.%s.", i, settings.Zone),
+		})
+	}
+
+	files := ConfigFiles{}
+
+	files[CoreDNSConfigPath], err = utils.RenderTemplate("Corefile", corefileTemplate, settings)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateCoreDNS: %s", err)
+	}
+
+	files[fmt.Sprintf("%s/db.%s", CoreDNSDirectory, settings.Zone)], err = utils.RenderTemplate("zone", zoneTemplate, settings)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateCoreDNS: %s", err)
+	}
+
+	return files, nil
+}
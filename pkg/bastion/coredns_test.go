@@ -0,0 +1,64 @@
+package bastion
+
+import (
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+var testCluster = Cluster{Name: "edge", BaseDomain: "example.com"}
+
+// a compact site: no workers, no bootstrap node and VIPs defaulting to the bastion address
+func testCompactInfrastructure() siteconfig.ProvisioningInfrastructure {
+	pi := testInfrastructure()
+	pi.Hosts.Workers = nil
+	pi.Network.BootstrapIP = ""
+	pi.Network.APIVIP = ""
+	pi.Network.IngressVIP = ""
+	return pi
+}
+
+func TestGenerateCoreDNS(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		pi      siteconfig.ProvisioningInfrastructure
+		records []string
+	}{
+		{"site", testInfrastructure(), []string{
+			"api IN A 192.168.111.5",
+			"api-int IN A 192.168.111.5",
+			"*.apps IN A 192.168.111.6",
+			"bootstrap IN A 192.168.111.10",
+			"worker-1 IN A 192.168.111.52",
+			"etcd-2 IN A 192.168.111.13",
+			"_etcd-server-ssl._tcp IN SRV 0 10 2380 etcd-0.edge.example.com.",
+			"_etcd-server-ssl._tcp IN SRV 0 10 2380 etcd-2.edge.example.com.",
+		}},
+		{"compact", testCompactInfrastructure(), []string{
+			"api IN A 192.168.111.1",
+			"*.apps IN A 192.168.111.1",
+			"_etcd-server-ssl._tcp IN SRV 0 10 2380 etcd-1.edge.example.com.",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := GenerateCoreDNS(tc.pi, testCluster)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			zone := files["coredns/db.edge.example.com"]
+			for _, record := range tc.records {
+				if !strings.Contains(zone, "\n"+record+"\n") {
+					t.Errorf("zone has no record %q", record)
+				}
+			}
+
+			checkGolden(t, "coredns-"+tc.name+"-Corefile", files[CoreDNSConfigPath])
+			checkGolden(t, "coredns-"+tc.name+"-zone", zone)
+		})
+	}
+
+	_, err := GenerateCoreDNS(testInfrastructure(), Cluster{Name: "edge"})
+	checkError(t, err, "the cluster name and base domain are required")
+}
@@ -0,0 +1,233 @@
+package bastion
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+// dnsmasq configuration paths, as mounted by the dnsmasq containers
+const (
+	DnsmasqProvisioningPath = "dnsmasq/prov/etc/dnsmasq.d/dnsmasq.conf"
+	DnsmasqBaremetalPath    = "dnsmasq/bm/etc/dnsmasq.d/dnsmasq.conf"
+)
+
+// addresses leased to PXE booting hosts when provisioningDhcpRange is not set
+const (
+	provisioningDHCPStartOffset = 10
+	provisioningDHCPEndOffset   = 100
+)
+
+type dnsmasqHost struct {
+	MAC  string
+	IP   string
+	Name string
+}
+
+type dnsmasqSettings struct {
+	Interface string
+	SingleNIC bool
+
+	// PXE boot, served from the provisioning network, or from the baremetal one
+	// when both share the same interface
+	DHCPStart  string
+	DHCPEnd    string
+	TFTPServer string
+	BootURL    string
+
+	// static leases of the baremetal network
+	Network   string
+	Netmask   string
+	Gateway   string
+	DNSServer string
+
+	Hosts []dnsmasqHost
+}
+
+// iPXE chainloading: BIOS and UEFI firmwares get an iPXE binary by TFTP, and
+// iPXE then gets its script from matchbox
+const dnsmasqPXETemplate = `{{define "pxe"}}dhcp-userclass=set:ipxe,iPXE
+dhcp-match=set:efi64,option:client-arch,7
+dhcp-match=set:efi64,option:client-arch,9
+dhcp-boot=tag:!ipxe,tag:!efi64,undionly.kpxe{{if .TFTPServer}},,{{.TFTPServer}}{{end}}
+dhcp-boot=tag:!ipxe,tag:efi64,ipxe.efi{{if .TFTPServer}},,{{.TFTPServer}}{{end}}
+dhcp-boot=tag:ipxe,{{.BootURL}}{{end}}`
+
+const dnsmasqProvisioningTemplate = dnsmasqPXETemplate + `# Generated by knictl from site-config.yaml, do not edit
+# Provisioning network{{if .SingleNIC}}, shared with the baremetal network: DHCP is served by the baremetal instance{{end}}
+port=0
+interface={{.Interface}}
+bind-interfaces
+except-interface=lo
+enable-tftp
+tftp-root=/var/lib/tftpboot
+{{- if not .SingleNIC}}
+log-dhcp
+dhcp-range={{.DHCPStart}},{{.DHCPEnd}},30m
+dhcp-no-override
+dhcp-ignore=tag:!known
+{{template "pxe" .}}
+{{- range .Hosts}}
+dhcp-host={{.MAC}},{{.Name}}
+{{- end}}
+{{- end}}
+`
+
+const dnsmasqBaremetalTemplate = dnsmasqPXETemplate + `# Generated by knictl from site-config.yaml, do not edit
+# Baremetal network
+port=0
+interface={{.Interface}}
+bind-interfaces
+except-interface=lo
+log-dhcp
+dhcp-range={{.Network}},static,{{.Netmask}},30m
+dhcp-ignore=tag:!known
+{{- if .Gateway}}
+dhcp-option=option:router,{{.Gateway}}
+{{- end}}
+dhcp-option=option:dns-server,{{.DNSServer}}
+{{- if .SingleNIC}}
+dhcp-no-override
+{{template "pxe" .}}
+{{- end}}
+{{- range .Hosts}}
+dhcp-host={{.MAC}},{{.IP}},{{.Name}}
+{{- end}}
+`
+
+// GenerateDnsmasq : renders the configuration of the dnsmasq instances serving
+// the provisioning and baremetal networks. The layout is inferred from the
+// interfaces: separate interfaces (dual-NIC or VLANs on the same NIC) get a
+// PXE instance on the provisioning network, and a single interface gets PXE
+// served with the static leases of the baremetal network
+func GenerateDnsmasq(pi siteconfig.ProvisioningInfrastructure) (ConfigFiles, error) {
+	network := pi.Network
+
+	if network.ProvisioningInterface == "" || network.BaremetalInterface == "" {
+		return nil, fmt.Errorf("Bastion: GenerateDnsmasq: provisioningInfrastructure.network.provisioningInterface and baremetalInterface are required")
+	}
+
+	provisioningInterface := interfaceName(network.ProvisioningInterface, network.ProvisioningVLAN)
+	baremetalInterface := interfaceName(network.BaremetalInterface, network.BaremetalVLAN)
+	singleNIC := sharedInterface(network)
+
+	// checks the host addresses, and the bastion and gateway ones
+	_, err := planClusterAddresses(pi)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s", err)
+	}
+
+	baremetalNetwork, err := parseIPv4CIDR("baremetalIpCidr", network.BaremetalIPCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s", err)
+	}
+
+	baremetal := dnsmasqSettings{
+		Interface: baremetalInterface,
+		SingleNIC: singleNIC,
+		Network:   baremetalNetwork.IP.String(),
+		Netmask:   net.IP(baremetalNetwork.Mask).String(),
+		Gateway:   network.BaremetalGatewayIP,
+		DNSServer: network.BaremetalIP,
+	}
+	provisioning := dnsmasqSettings{
+		Interface: provisioningInterface,
+		SingleNIC: singleNIC,
+	}
+
+	for _, host := range siteHosts(pi) {
+		field := fmt.Sprintf("%s %s", host.Role, host.Name)
+
+		// the sdn NIC is on the baremetal network, unless the hosts use the
+		// boot NIC for both networks
+		baremetalMAC := host.SdnMACAddress
+		if baremetalMAC == "" {
+			baremetalMAC = host.BootMACAddress
+		}
+		if singleNIC && !strings.EqualFold(baremetalMAC, host.BootMACAddress) {
+			return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s has a sdnMACAddress, but the provisioning and baremetal networks share interface %s", field, baremetalInterface)
+		}
+
+		baremetal.Hosts = append(baremetal.Hosts, dnsmasqHost{MAC: strings.ToLower(baremetalMAC), IP: host.IP, Name: host.Name})
+		provisioning.Hosts = append(provisioning.Hosts, dnsmasqHost{MAC: strings.ToLower(host.BootMACAddress), Name: host.Name})
+	}
+
+	bootServer, err := bootServerAddress(network)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s", err)
+	}
+
+	if singleNIC {
+		// the bastion serves TFTP and matchbox on the baremetal network
+		baremetal.TFTPServer = bootServer
+		baremetal.BootURL = fmt.Sprintf("http://%s:%d/boot.ipxe", bootServer, matchboxHTTPPort)
+	} else {
+		provisioningNetwork, err := parseIPv4CIDR("provisioningIpCidr", network.ProvisioningIPCIDR)
+		if err != nil {
+			return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s", err)
+		}
+
+		provisioning.DHCPStart, provisioning.DHCPEnd, err = provisioningDHCPRange(network.ProvisioningDHCPRange, provisioningNetwork, bootServer)
+		if err != nil {
+			return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s", err)
+		}
+		provisioning.BootURL = fmt.Sprintf("http://%s:%d/boot.ipxe", bootServer, matchboxHTTPPort)
+	}
+
+	files := ConfigFiles{}
+
+	for path, settings := range map[string]dnsmasqSettings{DnsmasqProvisioningPath: provisioning, DnsmasqBaremetalPath: baremetal} {
+		templateText := dnsmasqBaremetalTemplate
+		if path == DnsmasqProvisioningPath {
+			templateText = dnsmasqProvisioningTemplate
+		}
+
+		files[path], err = utils.RenderTemplate("dnsmasq", templateText, settings)
+		if err != nil {
+			return nil, fmt.Errorf("Bastion: GenerateDnsmasq: %s", err)
+		}
+	}
+
+	return files, nil
+}
+
+// returns the addresses leased on the provisioning network, that must not
+// include the bastion address
+func provisioningDHCPRange(dhcpRange string, provisioningNetwork *net.IPNet, provisioningIP string) (string, string, error) {
+	var start, end string
+
+	if dhcpRange != "" {
+		bounds := strings.Split(dhcpRange, ",")
+		if len(bounds) != 2 {
+			return "", "", fmt.Errorf("provisioningInfrastructure.network.provisioningDhcpRange must be '<first IP>,<last IP>', got %q", dhcpRange)
+		}
+		start, end = strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])
+	} else {
+		ones, bits := provisioningNetwork.Mask.Size()
+		if bits-ones < 8 {
+			return "", "", fmt.Errorf("provisioningInfrastructure.network.provisioningIpCidr %s is too small for the default DHCP range, set provisioningDhcpRange", provisioningNetwork)
+		}
+		start, end = utils.HostIP(provisioningNetwork, provisioningDHCPStartOffset), utils.HostIP(provisioningNetwork, provisioningDHCPEndOffset)
+	}
+
+	for _, ip := range []string{start, end} {
+		err := checkIPInNetwork("provisioning DHCP range address", ip, provisioningNetwork)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	startIP, endIP, bastionIP := net.ParseIP(start).To4(), net.ParseIP(end).To4(), net.ParseIP(provisioningIP).To4()
+	if bytes.Compare(startIP, endIP) > 0 {
+		return "", "", fmt.Errorf("provisioning DHCP range %s,%s ends before it starts", start, end)
+	}
+	if bytes.Compare(startIP, bastionIP) <= 0 && bytes.Compare(bastionIP, endIP) <= 0 {
+		return "", "", fmt.Errorf("provisioning DHCP range %s,%s includes the bastion address %s", start, end, provisioningIP)
+	}
+
+	return start, end, nil
+}
@@ -0,0 +1,78 @@
+package bastion
+
+import (
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+func TestGenerateDnsmasq(t *testing.T) {
+	vlans := testInfrastructure()
+	vlans.Network.BaremetalInterface = vlans.Network.ProvisioningInterface
+	vlans.Network.ProvisioningVLAN = 10
+	vlans.Network.BaremetalVLAN = 20
+	vlans.Network.ProvisioningIP = "172.22.0.3"
+	vlans.Network.ProvisioningDHCPRange = "172.22.0.20,172.22.0.50"
+
+	for _, tc := range []struct {
+		name string
+		pi   siteconfig.ProvisioningInfrastructure
+	}{
+		{"single-nic", testSingleNICInfrastructure()},
+		{"dual-nic", testInfrastructure()},
+		{"vlan", vlans},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := GenerateDnsmasq(tc.pi)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(files) != 2 {
+				t.Fatalf("expected the provisioning and baremetal configs, got %v", files.Paths())
+			}
+
+			checkGolden(t, "dnsmasq-"+tc.name+"-prov", files[DnsmasqProvisioningPath])
+			checkGolden(t, "dnsmasq-"+tc.name+"-bm", files[DnsmasqBaremetalPath])
+		})
+	}
+}
+
+func TestGenerateDnsmasqErrors(t *testing.T) {
+	sdnMACOnSharedInterface := testSingleNICInfrastructure()
+	sdnMACOnSharedInterface.Hosts.Workers[1].SdnMACAddress = "52:54:00:00:02:11"
+
+	// the same MAC for both networks is the single NIC
+	sameMACOnSharedInterface := testSingleNICInfrastructure()
+	sameMACOnSharedInterface.Hosts.Workers[1].SdnMACAddress = "52:54:00:00:01:11"
+
+	rangeWithBastion := testInfrastructure()
+	rangeWithBastion.Network.ProvisioningDHCPRange = "172.22.0.1,172.22.0.100"
+
+	rangeWithDefaultBastion := testInfrastructure()
+	rangeWithDefaultBastion.Network.ProvisioningIP = "172.22.0.50"
+
+	noInterface := testInfrastructure()
+	noInterface.Network.BaremetalInterface = ""
+
+	for _, tc := range []struct {
+		name string
+		pi   siteconfig.ProvisioningInfrastructure
+		err  string
+	}{
+		{"sdn MAC on shared interface", sdnMACOnSharedInterface, "worker worker-1 has a sdnMACAddress, but the provisioning and baremetal networks share interface eno1"},
+		{"DHCP range with the bastion address", rangeWithBastion, "provisioning DHCP range 172.22.0.1,172.22.0.100 includes the bastion address 172.22.0.1"},
+		{"default DHCP range with the bastion address", rangeWithDefaultBastion, "provisioning DHCP range 172.22.0.10,172.22.0.100 includes the bastion address 172.22.0.50"},
+		{"missing interface", noInterface, "provisioningInterface and baremetalInterface are required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GenerateDnsmasq(tc.pi)
+			checkError(t, err, tc.err)
+		})
+	}
+
+	_, err := GenerateDnsmasq(sameMACOnSharedInterface)
+	if err != nil {
+		t.Errorf("the boot MAC repeated as sdn MAC on a shared interface should be accepted, got %s", err)
+	}
+}
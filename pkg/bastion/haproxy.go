@@ -0,0 +1,93 @@
+package bastion
+
+import (
+	"fmt"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+// HAProxy configuration path, copied into the haproxy container image
+const HAProxyConfigPath = "haproxy/haproxy.cfg"
+
+type haproxyServer struct {
+	Name string
+	IP   string
+}
+
+type haproxyBackend struct {
+	Name    string
+	Address string
+	Port    int
+	Servers []haproxyServer
+}
+
+const haproxyTemplate = `# Generated by knictl from site-config.yaml, do not edit
+global
+    log 127.0.0.1 local0
+    maxconn 20000
+
+defaults
+    mode tcp
+    log global
+    option tcplog
+    option dontlognull
+    retries 3
+    timeout connect 10s
+    timeout client 1m
+    timeout server 1m
+    timeout check 10s
+{{range $backend := .}}
+frontend {{$backend.Name}}
+    bind {{$backend.Address}}:{{$backend.Port}}
+    default_backend {{$backend.Name}}
+
+backend {{$backend.Name}}
+    balance source
+{{- range $backend.Servers}}
+    server {{.Name}} {{.IP}}:{{$backend.Port}} check
+{{- end}}
+{{end}}`
+
+// GenerateHAProxy : renders the HAProxy configuration load balancing the api
+// and machine config server on the masters (and the bootstrap node while it is
+// set), and the ingress on the workers, or on the masters when there are none
+func GenerateHAProxy(pi siteconfig.ProvisioningInfrastructure) (ConfigFiles, error) {
+	addresses, err := planClusterAddresses(pi)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateHAProxy: %s", err)
+	}
+
+	controlPlane := []haproxyServer{}
+	if addresses.BootstrapIP != "" {
+		controlPlane = append(controlPlane, haproxyServer{Name: "bootstrap", IP: addresses.BootstrapIP})
+	}
+	for _, master := range addresses.Masters {
+		controlPlane = append(controlPlane, haproxyServer{Name: master.Name, IP: master.IP})
+	}
+
+	ingressHosts := addresses.Workers
+	if len(ingressHosts) == 0 {
+		ingressHosts = addresses.Masters
+	}
+	ingress := []haproxyServer{}
+	for _, host := range ingressHosts {
+		ingress = append(ingress, haproxyServer{Name: host.Name, IP: host.IP})
+	}
+
+	backends := []haproxyBackend{
+		{Name: "api", Address: addresses.APIVIP, Port: 6443, Servers: controlPlane},
+		{Name: "machine-config", Address: addresses.APIVIP, Port: 22623, Servers: controlPlane},
+		{Name: "ingress-http", Address: addresses.IngressVIP, Port: 80, Servers: ingress},
+		{Name: "ingress-https", Address: addresses.IngressVIP, Port: 443, Servers: ingress},
+	}
+
+	files := ConfigFiles{}
+
+	files[HAProxyConfigPath], err = utils.RenderTemplate("haproxy", haproxyTemplate, backends)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateHAProxy: %s", err)
+	}
+
+	return files, nil
+}
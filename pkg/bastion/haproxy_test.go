@@ -0,0 +1,47 @@
+package bastion
+
+import (
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+func TestGenerateHAProxy(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		pi    siteconfig.ProvisioningInfrastructure
+		lines []string
+	}{
+		{"site", testInfrastructure(), []string{
+			"    bind 192.168.111.5:6443",
+			"    server bootstrap 192.168.111.10:22623 check",
+			"    bind 192.168.111.6:443",
+			"    server worker-0 192.168.111.51:443 check",
+		}},
+		// the ingress runs on the masters without workers
+		{"compact", testCompactInfrastructure(), []string{
+			"    bind 192.168.111.1:80",
+			"    server master-2 192.168.111.13:80 check",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := GenerateHAProxy(tc.pi)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			config := files[HAProxyConfigPath]
+			for _, line := range tc.lines {
+				if !strings.Contains(config, "\n"+line+"\n") {
+					t.Errorf("haproxy.cfg has no line %q", line)
+				}
+			}
+			if tc.name == "compact" && strings.Contains(config, "bootstrap") {
+				t.Errorf("haproxy.cfg has a bootstrap server without bootstrapIp")
+			}
+
+			checkGolden(t, "haproxy-"+tc.name, config)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package bastion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// matchbox data paths, as mounted by the matchbox container in /var/lib/matchbox.
+// The profiles and groups of the hosts are created by terraform through the
+// matchbox API, and destroyed with the hosts, while knictl writes the ignition
+// configs of the roles
+const (
+	MatchboxDataDirectory     = "matchbox-data/var/lib/matchbox"
+	MatchboxProfilesDirectory = "matchbox-data/var/lib/matchbox/profiles"
+	MatchboxGroupsDirectory   = "matchbox-data/var/lib/matchbox/groups"
+	MatchboxIgnitionDirectory = "matchbox-data/var/lib/matchbox/ignition"
+)
+
+// MatchboxIgnitionRoles : the roles with an ignition config served by matchbox,
+// as <role>.ign
+var MatchboxIgnitionRoles = []string{"bootstrap", "master", "worker"}
+
+// RHCOS installer assets, downloaded to the matchbox assets directory
+const (
+	rhcosKernelAsset    = "rhcos-installer-kernel"
+	rhcosInitramfsAsset = "rhcos-installer-initramfs.img"
+	rhcosImageAsset     = "rhcos-metal-bios.raw.gz"
+)
+
+type matchboxBoot struct {
+	Kernel string   `json:"kernel"`
+	Initrd []string `json:"initrd"`
+	Args   []string `json:"args"`
+}
+
+type matchboxProfile struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Boot       matchboxBoot `json:"boot"`
+	IgnitionID string       `json:"ignition_id,omitempty"`
+}
+
+type matchboxGroup struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Profile  string            `json:"profile"`
+	Selector map[string]string `json:"selector"`
+}
+
+// GenerateMatchbox : renders the matchbox profile and group expected for each
+// host, that boot the RHCOS installer and install RHCOS with the ignition config
+// of the host role on its install disk. The kernel args of the hosts are
+// appended, and a host ignitionURL replaces the ignition config served by
+// matchbox. They are not written, as terraform owns the deployed ones, but are
+// what ValidateMatchbox checks them against
+func GenerateMatchbox(pi siteconfig.ProvisioningInfrastructure) (ConfigFiles, error) {
+	bootServer, err := bootServerAddress(pi.Network)
+	if err != nil {
+		return nil, fmt.Errorf("Bastion: GenerateMatchbox: %s", err)
+	}
+	matchboxURL := fmt.Sprintf("http://%s:%d", bootServer, matchboxHTTPPort)
+
+	files := ConfigFiles{}
+
+	for _, host := range siteHosts(pi) {
+		if !dnsLabel.MatchString(host.Name) {
+			return nil, fmt.Errorf("Bastion: GenerateMatchbox: %s name %q is used as matchbox id, it can only contain lowercase letters, digits and dashes", host.Role, host.Name)
+		}
+
+		mac, err := net.ParseMAC(host.BootMACAddress)
+		if err != nil {
+			return nil, fmt.Errorf("Bastion: GenerateMatchbox: %s %s has an invalid bootMACAddress %q", host.Role, host.Name, host.BootMACAddress)
+		}
+
+		// matchbox serves the ignition config of the profile, for the MAC
+		// address iPXE booted from
+		ignitionURL := host.IgnitionURL
+		if ignitionURL == "" {
+			ignitionURL = fmt.Sprintf("%s/ignition?mac=${mac:hexhyp}", matchboxURL)
+		}
+
+		profile := matchboxProfile{
+			ID:   host.Name,
+			Name: host.Name,
+			Boot: matchboxBoot{
+				Kernel: fmt.Sprintf("/assets/%s", rhcosKernelAsset),
+				Initrd: []string{fmt.Sprintf("/assets/%s", rhcosInitramfsAsset)},
+				Args: append([]string{
+					fmt.Sprintf("initrd=%s", rhcosInitramfsAsset),
+					"ip=dhcp",
+					"rd.neednet=1",
+					"console=tty0",
+					"console=ttyS0,115200n8",
+					"coreos.inst=yes",
+					fmt.Sprintf("coreos.inst.install_dev=%s", strings.TrimPrefix(host.InstallDisk, "/dev/")),
+					fmt.Sprintf("coreos.inst.image_url=%s/assets/%s", matchboxURL, rhcosImageAsset),
+					fmt.Sprintf("coreos.inst.ignition_url=%s", ignitionURL),
+				}, host.KernelArgs...),
+			},
+			IgnitionID: fmt.Sprintf("%s.ign", host.Role),
+		}
+
+		group := matchboxGroup{
+			ID:       host.Name,
+			Name:     host.Name,
+			Profile:  host.Name,
+			Selector: map[string]string{"mac": mac.String()},
+		}
+
+		for path, object := range map[string]interface{}{
+			fmt.Sprintf("%s/%s.json", MatchboxProfilesDirectory, host.Name): profile,
+			fmt.Sprintf("%s/%s.json", MatchboxGroupsDirectory, host.Name):   group,
+		} {
+			content, err := json.MarshalIndent(object, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("Bastion: GenerateMatchbox: error marshalling %s: %s", path, err)
+			}
+			files[path] = fmt.Sprintf("%s\n", content)
+		}
+	}
+
+	return files, nil
+}
+
+// the boot args the profile of a host needs, for its settings in the site config
+func hostBootArgs(host bastionHost) []string {
+	args := []string{}
+	if host.InstallDisk != "" {
+		args = append(args, fmt.Sprintf("coreos.inst.install_dev=%s", strings.TrimPrefix(host.InstallDisk, "/dev/")))
+	}
+	if host.IgnitionURL != "" {
+		args = append(args, fmt.Sprintf("coreos.inst.ignition_url=%s", host.IgnitionURL))
+	}
+	return append(args, host.KernelArgs...)
+}
+
+// whether a list of strings has that one
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// reads the JSON files of a directory of the matchbox data, by the id of the
+// object they hold
+func readMatchboxFiles(directory string) (map[string][]byte, error) {
+	contents := map[string][]byte{}
+
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		contents[strings.TrimSuffix(file.Name(), ".json")] = content
+	}
+
+	return contents, nil
+}
+
+// ValidateMatchbox : checks the matchbox data deployed under basePath, for the
+// hosts of the site with those roles, or all of them: every host needs a group
+// selecting its boot MAC address, with an existing profile that has the install
+// disk, ignition URL and kernel args of the host, and the ignition config it
+// references. All the hosts that can not boot are reported at once
+func ValidateMatchbox(pi siteconfig.ProvisioningInfrastructure, basePath string, roles ...string) error {
+	groupFiles, err := readMatchboxFiles(filepath.Join(basePath, MatchboxGroupsDirectory))
+	if err != nil {
+		return fmt.Errorf("Bastion: ValidateMatchbox: error reading matchbox groups: %s", err)
+	}
+
+	profileFiles, err := readMatchboxFiles(filepath.Join(basePath, MatchboxProfilesDirectory))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Bastion: ValidateMatchbox: error reading matchbox profiles: %s", err)
+	}
+
+	// groups by the MAC address they select
+	groups := map[string]matchboxGroup{}
+	for id, content := range groupFiles {
+		var group matchboxGroup
+		err = json.Unmarshal(content, &group)
+		if err != nil {
+			return fmt.Errorf("Bastion: ValidateMatchbox: invalid matchbox group %s: %s", id, err)
+		}
+
+		if mac, err := net.ParseMAC(group.Selector["mac"]); err == nil {
+			groups[mac.String()] = group
+		}
+	}
+
+	problems := []string{}
+	for _, host := range siteHosts(pi) {
+		if len(roles) > 0 && !containsString(roles, host.Role) {
+			continue
+		}
+
+		mac, err := net.ParseMAC(host.BootMACAddress)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: invalid bootMACAddress %q", host.Role, host.Name, host.BootMACAddress))
+			continue
+		}
+
+		group, ok := groups[mac.String()]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s: no group selects mac %s", host.Role, host.Name, mac))
+			continue
+		}
+
+		content, ok := profileFiles[group.Profile]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s: profile %q of group %s is missing", host.Role, host.Name, group.Profile, group.ID))
+			continue
+		}
+
+		var profile matchboxProfile
+		err = json.Unmarshal(content, &profile)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: invalid profile %s: %s", host.Role, host.Name, group.Profile, err))
+			continue
+		}
+
+		for _, arg := range hostBootArgs(host) {
+			if !containsString(profile.Boot.Args, arg) {
+				problems = append(problems, fmt.Sprintf("%s %s: profile %s does not boot with %s", host.Role, host.Name, group.Profile, arg))
+			}
+		}
+
+		if profile.IgnitionID != "" {
+			_, err = os.Stat(filepath.Join(basePath, MatchboxIgnitionDirectory, profile.IgnitionID))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s %s: ignition config %s of profile %s is missing", host.Role, host.Name, profile.IgnitionID, group.Profile))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Bastion: ValidateMatchbox: %d problem(s), the hosts can not boot from matchbox as configured:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package bastion
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// site with per-host install disks, kernel args and ignition URL
+func testMatchboxInfrastructure() siteconfig.ProvisioningInfrastructure {
+	pi := testInfrastructure()
+
+	for _, hosts := range [][]siteconfig.Host{pi.Hosts.Masters, pi.Hosts.Workers} {
+		for i := range hosts {
+			hosts[i].InstallDisk = "/dev/sda"
+		}
+	}
+	pi.Hosts.Masters[1].InstallDisk = "/dev/nvme0n1"
+	pi.Hosts.Workers[0].KernelArgs = []string{"nomodeset", "rd.driver.blacklist=nouveau"}
+	pi.Hosts.Workers[1].IgnitionURL = "http://172.22.0.100:8080/worker-1.ign"
+
+	return pi
+}
+
+// concatenates the rendered files, each one after its path
+func matchboxFilesContent(files ConfigFiles) string {
+	var content strings.Builder
+	for _, path := range files.Paths() {
+		fmt.Fprintf(&content, "# %s\n%s", path, files[path])
+	}
+	return content.String()
+}
+
+func TestGenerateMatchbox(t *testing.T) {
+	files, err := GenerateMatchbox(testMatchboxInfrastructure())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a profile and a group per host
+	if len(files) != 10 {
+		t.Fatalf("expected 10 files, got %v", files.Paths())
+	}
+
+	checkGolden(t, "matchbox-site", matchboxFilesContent(files))
+}
+
+func TestGenerateMatchboxErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(pi *siteconfig.ProvisioningInfrastructure)
+		err    string
+	}{
+		{"invalid MAC", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Hosts.Workers[1].BootMACAddress = "52:54:00:00:01"
+		}, `worker worker-1 has an invalid bootMACAddress "52:54:00:00:01"`},
+		{"invalid name", func(pi *siteconfig.ProvisioningInfrastructure) {
+			pi.Hosts.Masters[0].Name = "Master_0"
+		}, `master name "Master_0" is used as matchbox id, it can only contain lowercase letters, digits and dashes`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pi := testMatchboxInfrastructure()
+			tc.modify(&pi)
+
+			_, err := GenerateMatchbox(pi)
+			checkError(t, err, "Bastion: GenerateMatchbox: "+tc.err)
+		})
+	}
+}
+
+// writes the matchbox data that terraform deploys for the site, with the
+// ignition configs of the roles
+func writeTestMatchboxData(t *testing.T, pi siteconfig.ProvisioningInfrastructure) string {
+	t.Helper()
+
+	basePath := t.TempDir()
+
+	files, err := GenerateMatchbox(pi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range MatchboxIgnitionRoles {
+		files[fmt.Sprintf("%s/%s.ign", MatchboxIgnitionDirectory, role)] = "{}"
+	}
+
+	err = files.Write(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return basePath
+}
+
+func TestValidateMatchbox(t *testing.T) {
+	profilePath := func(name string) string {
+		return filepath.Join(MatchboxProfilesDirectory, name+".json")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		roles  []string
+		modify func(t *testing.T, basePath string)
+		err    string // expected problems, none if empty
+	}{
+		{"deployed", nil, func(t *testing.T, basePath string) {}, ""},
+		{"missing group", nil, func(t *testing.T, basePath string) {
+			os.Remove(filepath.Join(basePath, MatchboxGroupsDirectory, "worker-0.json"))
+		}, "1 problem(s), the hosts can not boot from matchbox as configured:\n  - worker worker-0: no group selects mac 52:54:00:00:01:10"},
+		{"missing group of another role", []string{"master"}, func(t *testing.T, basePath string) {
+			os.Remove(filepath.Join(basePath, MatchboxGroupsDirectory, "worker-0.json"))
+		}, ""},
+		{"missing profile", nil, func(t *testing.T, basePath string) {
+			os.Remove(filepath.Join(basePath, profilePath("master-2")))
+		}, `master master-2: profile "master-2" of group master-2 is missing`},
+		{"missing ignition", []string{"worker"}, func(t *testing.T, basePath string) {
+			os.Remove(filepath.Join(basePath, MatchboxIgnitionDirectory, "worker.ign"))
+		}, "2 problem(s), the hosts can not boot from matchbox as configured:\n  - worker worker-0: ignition config worker.ign of profile worker-0 is missing\n  - worker worker-1: ignition config worker.ign of profile worker-1 is missing"},
+		{"profile without the host settings", nil, func(t *testing.T, basePath string) {
+			path := filepath.Join(basePath, profilePath("worker-0"))
+			content, _ := ioutil.ReadFile(path)
+			content = []byte(strings.Replace(string(content), `"nomodeset",`, "", 1))
+			ioutil.WriteFile(path, content, 0644)
+
+			path = filepath.Join(basePath, profilePath("master-1"))
+			content, _ = ioutil.ReadFile(path)
+			content = []byte(strings.Replace(string(content), "install_dev=nvme0n1", "install_dev=sda", 1))
+			ioutil.WriteFile(path, content, 0644)
+		}, "2 problem(s), the hosts can not boot from matchbox as configured:\n  - master master-1: profile master-1 does not boot with coreos.inst.install_dev=nvme0n1\n  - worker worker-0: profile worker-0 does not boot with nomodeset"},
+		{"no groups", nil, func(t *testing.T, basePath string) {
+			os.RemoveAll(filepath.Join(basePath, MatchboxGroupsDirectory))
+		}, "error reading matchbox groups"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pi := testMatchboxInfrastructure()
+			basePath := writeTestMatchboxData(t, pi)
+			tc.modify(t, basePath)
+
+			err := ValidateMatchbox(pi, basePath, tc.roles...)
+
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			checkError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package bastion
+
+import (
+	"fmt"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/container"
+)
+
+// Service : a service of the bastion, run in a container created and started by
+// a script of the baremetal automation
+type Service struct {
+	Name        string
+	Container   string
+	Description string
+	Script      string // script of the automation repo, that creates and starts the container
+	Build       bool   // whether the script builds the container image before starting it
+}
+
+// Services : the bastion services, in the order they are started
+var Services = []Service{
+	{"dnsmasq-prov", "kni-dnsmasq-prov", "DHCP and TFTP on the provisioning network", "gen_config_prov.sh", false},
+	{"dnsmasq-bm", "kni-dnsmasq-bm", "DHCP on the baremetal network", "gen_config_bm.sh", false},
+	{"haproxy", "kni-haproxy", "load balancer of the api and ingress", "gen_haproxy.sh", true},
+	{"coredns", "kni-coredns", "DNS of the cluster", "gen_coredns.sh", false},
+	{"matchbox", "kni-matchbox", "iPXE and ignition configs of the hosts", "gen_matchbox.sh", false},
+}
+
+// StartCommands : the commands of the service script that create and start its
+// container, with the arguments of each one
+func (s Service) StartCommands() [][]string {
+	commands := [][]string{}
+	if s.Build {
+		commands = append(commands, []string{"build"})
+	}
+	return append(commands, []string{"start"})
+}
+
+// ScriptEnv : the environment of the service scripts, selecting the container
+// runtime they create and remove the containers with
+func ScriptEnv(runtime container.RuntimeInterface) []string {
+	return []string{fmt.Sprintf("CONTAINER_RUNTIME=%s", runtime.Name())}
+}
+
+// FindServices : the services with those names or container names, or all the
+// services if there is none
+func FindServices(names []string) ([]Service, error) {
+	if len(names) == 0 {
+		return Services, nil
+	}
+
+	services := []Service{}
+	for _, name := range names {
+		found := false
+		for _, service := range Services {
+			if name == service.Name || name == service.Container {
+				services = append(services, service)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			valid := []string{}
+			for _, service := range Services {
+				valid = append(valid, service.Name)
+			}
+			return nil, fmt.Errorf("Bastion: FindServices: unknown service %s, valid services are %s", name, strings.Join(valid, ", "))
+		}
+	}
+
+	return services, nil
+}
+
+// ServiceStatus : the state of the container of a service, and whether it is healthy
+type ServiceStatus struct {
+	Service
+	State   container.State
+	Healthy bool
+	Detail  string
+}
+
+// Status : the status of the services. A service is healthy when its container
+// runs, and its healthcheck, if it has one, does not fail
+func Status(runtime container.RuntimeInterface, services []Service) ([]ServiceStatus, error) {
+	statuses := []ServiceStatus{}
+
+	for _, service := range services {
+		state, err := runtime.Inspect(service.Container)
+
+		if err != nil {
+			return nil, fmt.Errorf("Bastion: Status: %s", err)
+		}
+
+		status := ServiceStatus{Service: service, State: state}
+
+		switch {
+		case !state.Exists:
+			status.Detail = "container not created"
+		case !state.Running:
+			status.Detail = fmt.Sprintf("container %s", state.Status)
+		case state.Health == container.HealthUnhealthy:
+			status.Detail = "healthcheck failing"
+		default:
+			status.Healthy = true
+			status.Detail = fmt.Sprintf("running since %s", state.StartedAt.Local().Format("2006-01-02 15:04:05"))
+			if state.Health != "" {
+				status.Detail = fmt.Sprintf("%s, %s", status.Detail, state.Health)
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// CheckServices : returns an error listing the services that are not healthy
+func CheckServices(runtime container.RuntimeInterface, services []Service) error {
+	statuses, err := Status(runtime, services)
+
+	if err != nil {
+		return err
+	}
+
+	problems := []string{}
+	for _, status := range statuses {
+		if !status.Healthy {
+			problems = append(problems, fmt.Sprintf("%s: %s", status.Name, status.Detail))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Bastion: CheckServices: services not healthy: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package bmc
+
+import (
+	"fmt"
+	"net/url"
+
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// PowerState : the power state of a host, as reported by its BMC
+type PowerState string
+
+// power states
+const (
+	PowerOn      PowerState = "on"
+	PowerOff     PowerState = "off"
+	PowerUnknown PowerState = "unknown"
+)
+
+// Credentials : the user the BMC is accessed with
+type Credentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type BMCInterface interface {
+	PowerOn() error                   // Power the host on, doing nothing if it is on
+	PowerOff() error                  // Power the host off immediately, doing nothing if it is off
+	PowerCycle() error                // Restart the host, or power it on if it is off
+	SetPXEBootOnce() error            // Boot from the network on the next boot only
+	PowerStatus() (PowerState, error) // Current power state of the host
+}
+
+var (
+	bmcConstructors map[string]func(*url.URL, Credentials, siteconfig.BMC) (BMCInterface, error)
+)
+
+func init() {
+	// Add new BMC drivers here, by address scheme
+	bmcConstructors = map[string]func(*url.URL, Credentials, siteconfig.BMC) (BMCInterface, error){}
+	bmcConstructors["ipmi"] = newIPMI
+	bmcConstructors["redfish"] = newRedfish
+	bmcConstructors["redfish+http"] = newRedfish
+	bmcConstructors["redfish+https"] = newRedfish
+}
+
+// New : returns the driver of a host BMC, from its address scheme. The credentials
+// are the ones of the BMC config, or the username and password keys of the
+// credentialsName secret, read from the secret provider of the site
+func New(config siteconfig.BMC, provider secrets.SecretProviderInterface) (BMCInterface, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("BMC: New: no BMC address")
+	}
+
+	address, err := url.Parse(config.Address)
+
+	if err != nil || address.Host == "" {
+		return nil, fmt.Errorf("BMC: New: invalid BMC address %q", config.Address)
+	}
+
+	constructor := bmcConstructors[address.Scheme]
+
+	if constructor == nil {
+		return nil, fmt.Errorf("BMC: New: unsupported BMC address scheme '%s'", address.Scheme)
+	}
+
+	credentials := Credentials{Username: config.Username, Password: config.Password}
+
+	if config.CredentialsName != "" {
+		credentials, err = readCredentials(config.CredentialsName, provider)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return constructor(address, credentials, config)
+}
+
+// reads the credentials secret, a YAML or JSON document with username and password keys
+func readCredentials(name string, provider secrets.SecretProviderInterface) (Credentials, error) {
+	var credentials Credentials
+
+	if provider == nil {
+		return credentials, fmt.Errorf("BMC: readCredentials: no secret provider to read credentials %s from", name)
+	}
+
+	content, err := provider.GetSecret(name)
+
+	if err != nil {
+		return credentials, fmt.Errorf("BMC: readCredentials: error reading credentials %s from %s: %s", name, provider.Description(), err)
+	}
+
+	err = yaml.Unmarshal([]byte(content), &credentials)
+
+	if err != nil || credentials.Username == "" {
+		return credentials, fmt.Errorf("BMC: readCredentials: credentials %s need username and password keys", name)
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,142 @@
+package bmc
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// ipmiBMC : drives a host with ipmitool over IPMI v2.0 (lanplus)
+type ipmiBMC struct {
+	host        string
+	port        string
+	credentials Credentials
+}
+
+func newIPMI(address *url.URL, credentials Credentials, config siteconfig.BMC) (BMCInterface, error) {
+	port := address.Port()
+	if port == "" {
+		port = "623"
+	}
+
+	return &ipmiBMC{
+		host:        address.Hostname(),
+		port:        port,
+		credentials: credentials,
+	}, nil
+}
+
+// runs an ipmitool command against the BMC. The password is passed in the
+// environment, so it does not show in the process list
+func (ib *ipmiBMC) ipmitool(args ...string) (string, error) {
+	cmd := exec.Command("ipmitool", append([]string{"-I", "lanplus", "-H", ib.host, "-p", ib.port, "-U", ib.credentials.Username, "-E"}, args...)...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("IPMI_PASSWORD=%s", ib.credentials.Password))
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		return "", fmt.Errorf("ipmitool %s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(errb.String()))
+	}
+
+	return strings.TrimSpace(outb.String()), nil
+}
+
+func (ib *ipmiBMC) PowerOn() error {
+	state, err := ib.PowerStatus()
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: PowerOn: %s", err)
+	}
+
+	if state == PowerOn {
+		return nil
+	}
+
+	_, err = ib.ipmitool("chassis", "power", "on")
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: PowerOn: %s", err)
+	}
+
+	return nil
+}
+
+func (ib *ipmiBMC) PowerOff() error {
+	state, err := ib.PowerStatus()
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: PowerOff: %s", err)
+	}
+
+	if state == PowerOff {
+		return nil
+	}
+
+	_, err = ib.ipmitool("chassis", "power", "off")
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: PowerOff: %s", err)
+	}
+
+	return nil
+}
+
+func (ib *ipmiBMC) PowerCycle() error {
+	state, err := ib.PowerStatus()
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: PowerCycle: %s", err)
+	}
+
+	// power cycle fails on hosts that are off
+	action := "cycle"
+	if state == PowerOff {
+		action = "on"
+	}
+
+	_, err = ib.ipmitool("chassis", "power", action)
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: PowerCycle: %s", err)
+	}
+
+	return nil
+}
+
+func (ib *ipmiBMC) SetPXEBootOnce() error {
+	// Without the persistent option, the boot device only applies to the next boot
+	_, err := ib.ipmitool("chassis", "bootdev", "pxe")
+
+	if err != nil {
+		return fmt.Errorf("ipmiBMC: SetPXEBootOnce: %s", err)
+	}
+
+	return nil
+}
+
+func (ib *ipmiBMC) PowerStatus() (PowerState, error) {
+	out, err := ib.ipmitool("chassis", "power", "status")
+
+	if err != nil {
+		return PowerUnknown, fmt.Errorf("ipmiBMC: PowerStatus: %s", err)
+	}
+
+	// "Chassis Power is on" or "Chassis Power is off"
+	switch {
+	case strings.HasSuffix(out, " on"):
+		return PowerOn, nil
+	case strings.HasSuffix(out, " off"):
+		return PowerOff, nil
+	}
+
+	return PowerUnknown, nil
+}
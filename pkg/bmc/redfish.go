@@ -0,0 +1,254 @@
+package bmc
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// root of the Redfish service, used to find the system when the address has no path
+const redfishSystemsPath = "/redfish/v1/Systems"
+
+// redfishBMC : drives a host through the Redfish ComputerSystem of its BMC
+type redfishBMC struct {
+	baseURL     string // scheme and host of the BMC
+	systemPath  string // path of the ComputerSystem, found on first use when not set
+	credentials Credentials
+	client      *http.Client
+}
+
+// the fields of a Redfish ComputerSystem used to manage its power
+type redfishSystem struct {
+	PowerState string `json:"PowerState"`
+	Actions    struct {
+		Reset struct {
+			Target string `json:"target"`
+		} `json:"#ComputerSystem.Reset"`
+	} `json:"Actions"`
+}
+
+// redfish:// and redfish+https:// use https, redfish+http:// plain http
+func newRedfish(address *url.URL, credentials Credentials, config siteconfig.BMC) (BMCInterface, error) {
+	scheme := "https"
+	if address.Scheme == "redfish+http" {
+		scheme = "http"
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		// BMCs commonly have self-signed certificates
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.DisableCertificateVerification},
+	}
+
+	return &redfishBMC{
+		baseURL:     fmt.Sprintf("%s://%s", scheme, address.Host),
+		systemPath:  strings.TrimSuffix(address.Path, "/"),
+		credentials: credentials,
+		client:      &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// sends a request to the BMC, decoding the JSON response into result if set
+func (rb *redfishBMC) request(method string, path string, body interface{}, result interface{}) error {
+	var requestBody io.Reader
+
+	if body != nil {
+		content, err := json.Marshal(body)
+
+		if err != nil {
+			return fmt.Errorf("error marshalling request: %s", err)
+		}
+
+		requestBody = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, rb.baseURL+path, requestBody)
+
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(rb.credentials.Username, rb.credentials.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := rb.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return fmt.Errorf("error reading %s %s response: %s", method, path, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(content)))
+	}
+
+	if result != nil && len(content) > 0 {
+		err = json.Unmarshal(content, result)
+
+		if err != nil {
+			return fmt.Errorf("error parsing %s %s response: %s", method, path, err)
+		}
+	}
+
+	return nil
+}
+
+// returns the ComputerSystem of the host. BMC addresses without a system path
+// are only accepted when the BMC manages a single system
+func (rb *redfishBMC) system() (redfishSystem, error) {
+	var system redfishSystem
+
+	if rb.systemPath == "" {
+		var systems struct {
+			Members []struct {
+				ID string `json:"@odata.id"`
+			} `json:"Members"`
+		}
+
+		err := rb.request(http.MethodGet, redfishSystemsPath, nil, &systems)
+
+		if err != nil {
+			return system, err
+		}
+
+		if len(systems.Members) != 1 {
+			return system, fmt.Errorf("BMC manages %d systems, set the system path in the BMC address", len(systems.Members))
+		}
+
+		rb.systemPath = systems.Members[0].ID
+	}
+
+	err := rb.request(http.MethodGet, rb.systemPath, nil, &system)
+
+	return system, err
+}
+
+func (rb *redfishBMC) reset(system redfishSystem, resetType string) error {
+	target := system.Actions.Reset.Target
+	if target == "" {
+		target = fmt.Sprintf("%s/Actions/ComputerSystem.Reset", rb.systemPath)
+	}
+
+	return rb.request(http.MethodPost, target, map[string]string{"ResetType": resetType}, nil)
+}
+
+func (rb *redfishBMC) PowerOn() error {
+	system, err := rb.system()
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: PowerOn: %s", err)
+	}
+
+	if system.PowerState == "On" {
+		return nil
+	}
+
+	err = rb.reset(system, "On")
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: PowerOn: %s", err)
+	}
+
+	return nil
+}
+
+func (rb *redfishBMC) PowerOff() error {
+	system, err := rb.system()
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: PowerOff: %s", err)
+	}
+
+	if system.PowerState == "Off" {
+		return nil
+	}
+
+	err = rb.reset(system, "ForceOff")
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: PowerOff: %s", err)
+	}
+
+	return nil
+}
+
+func (rb *redfishBMC) PowerCycle() error {
+	system, err := rb.system()
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: PowerCycle: %s", err)
+	}
+
+	resetType := "ForceRestart"
+	if system.PowerState == "Off" {
+		resetType = "On"
+	}
+
+	err = rb.reset(system, resetType)
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: PowerCycle: %s", err)
+	}
+
+	return nil
+}
+
+func (rb *redfishBMC) SetPXEBootOnce() error {
+	_, err := rb.system()
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: SetPXEBootOnce: %s", err)
+	}
+
+	boot := map[string]interface{}{
+		"Boot": map[string]string{
+			"BootSourceOverrideEnabled": "Once",
+			"BootSourceOverrideTarget":  "Pxe",
+		},
+	}
+
+	err = rb.request(http.MethodPatch, rb.systemPath, boot, nil)
+
+	if err != nil {
+		return fmt.Errorf("redfishBMC: SetPXEBootOnce: %s", err)
+	}
+
+	return nil
+}
+
+func (rb *redfishBMC) PowerStatus() (PowerState, error) {
+	system, err := rb.system()
+
+	if err != nil {
+		return PowerUnknown, fmt.Errorf("redfishBMC: PowerStatus: %s", err)
+	}
+
+	// PoweringOn and PoweringOff are reported as the state being reached
+	switch system.PowerState {
+	case "On", "PoweringOn":
+		return PowerOn, nil
+	case "Off", "PoweringOff":
+		return PowerOff, nil
+	}
+
+	return PowerUnknown, nil
+}
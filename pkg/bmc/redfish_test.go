@@ -0,0 +1,204 @@
+package bmc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// redfishMock : a Redfish service managing the given systems, recording the
+// resets and boot overrides it receives
+type redfishMock struct {
+	mu       sync.Mutex
+	systems  map[string]string // power state by system path
+	resets   []string          // "<system path> <ResetType>"
+	boots    []map[string]string
+	failWith int // status code returned to every request, when set
+}
+
+func newRedfishMock(systems map[string]string) (*redfishMock, *httptest.Server) {
+	mock := &redfishMock{systems: systems}
+	return mock, httptest.NewServer(mock)
+}
+
+func (rm *redfishMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if rm.failWith != 0 {
+		http.Error(w, `{"error": "internal error"}`, rm.failWith)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == redfishSystemsPath:
+		members := []map[string]string{}
+		for path := range rm.systems {
+			members = append(members, map[string]string{"@odata.id": path})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Members": members})
+
+	case r.Method == http.MethodGet && rm.systems[r.URL.Path] != "":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"PowerState": rm.systems[r.URL.Path],
+			"Actions": map[string]interface{}{
+				"#ComputerSystem.Reset": map[string]string{"target": r.URL.Path + "/Actions/ComputerSystem.Reset"},
+			},
+		})
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/Actions/ComputerSystem.Reset"):
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		rm.resets = append(rm.resets, fmt.Sprintf("%s %s", strings.TrimSuffix(r.URL.Path, "/Actions/ComputerSystem.Reset"), body["ResetType"]))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPatch && rm.systems[r.URL.Path] != "":
+		var body map[string]map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		rm.boots = append(rm.boots, body["Boot"])
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// returns the driver of a BMC served by the mock, with the system path if set
+func newTestRedfish(t *testing.T, server *httptest.Server, systemPath string) BMCInterface {
+	t.Helper()
+
+	address := strings.Replace(server.URL, "http://", "redfish+http://", 1) + systemPath
+	hostBMC, err := New(siteconfig.BMC{Address: address, Username: "admin", Password: "secret"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating the BMC driver: %s", err)
+	}
+
+	return hostBMC
+}
+
+func TestRedfishSystemDiscovery(t *testing.T) {
+	_, single := newRedfishMock(map[string]string{"/redfish/v1/Systems/1": "On"})
+	defer single.Close()
+
+	state, err := newTestRedfish(t, single, "").PowerStatus()
+	if err != nil || state != PowerOn {
+		t.Errorf("expected the single system to be found and on, got %s, %v", state, err)
+	}
+
+	_, several := newRedfishMock(map[string]string{"/redfish/v1/Systems/1": "On", "/redfish/v1/Systems/2": "Off"})
+	defer several.Close()
+
+	_, err = newTestRedfish(t, several, "").PowerStatus()
+	if err == nil || !strings.Contains(err.Error(), "BMC manages 2 systems, set the system path in the BMC address") {
+		t.Errorf("expected an error about the number of systems, got %v", err)
+	}
+
+	state, err = newTestRedfish(t, several, "/redfish/v1/Systems/2").PowerStatus()
+	if err != nil || state != PowerOff {
+		t.Errorf("expected the system of the address to be off, got %s, %v", state, err)
+	}
+}
+
+func TestRedfishPowerState(t *testing.T) {
+	for redfishState, want := range map[string]PowerState{
+		"On":          PowerOn,
+		"PoweringOn":  PowerOn,
+		"Off":         PowerOff,
+		"PoweringOff": PowerOff,
+		"Paused":      PowerUnknown,
+	} {
+		_, server := newRedfishMock(map[string]string{"/redfish/v1/Systems/1": redfishState})
+
+		state, err := newTestRedfish(t, server, "/redfish/v1/Systems/1").PowerStatus()
+		if err != nil || state != want {
+			t.Errorf("Redfish state %s: expected %s, got %s, %v", redfishState, want, state, err)
+		}
+
+		server.Close()
+	}
+}
+
+func TestRedfishReset(t *testing.T) {
+	for _, tc := range []struct {
+		action string
+		state  string
+		resets []string
+	}{
+		{"on", "Off", []string{"/redfish/v1/Systems/1 On"}},
+		{"on", "On", nil},
+		{"off", "On", []string{"/redfish/v1/Systems/1 ForceOff"}},
+		{"off", "Off", nil},
+		{"cycle", "On", []string{"/redfish/v1/Systems/1 ForceRestart"}},
+		{"cycle", "Off", []string{"/redfish/v1/Systems/1 On"}},
+	} {
+		t.Run(fmt.Sprintf("%s when %s", tc.action, tc.state), func(t *testing.T) {
+			mock, server := newRedfishMock(map[string]string{"/redfish/v1/Systems/1": tc.state})
+			defer server.Close()
+
+			hostBMC := newTestRedfish(t, server, "")
+
+			var err error
+			switch tc.action {
+			case "on":
+				err = hostBMC.PowerOn()
+			case "off":
+				err = hostBMC.PowerOff()
+			case "cycle":
+				err = hostBMC.PowerCycle()
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if fmt.Sprint(mock.resets) != fmt.Sprint(tc.resets) {
+				t.Errorf("expected resets %v, got %v", tc.resets, mock.resets)
+			}
+		})
+	}
+}
+
+func TestRedfishPXEBootOnce(t *testing.T) {
+	mock, server := newRedfishMock(map[string]string{"/redfish/v1/Systems/1": "Off"})
+	defer server.Close()
+
+	err := newTestRedfish(t, server, "").SetPXEBootOnce()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(mock.boots) != 1 || mock.boots[0]["BootSourceOverrideEnabled"] != "Once" || mock.boots[0]["BootSourceOverrideTarget"] != "Pxe" {
+		t.Errorf("expected a PATCH of the boot override to PXE once, got %v", mock.boots)
+	}
+}
+
+func TestRedfishErrorStatus(t *testing.T) {
+	mock, server := newRedfishMock(map[string]string{"/redfish/v1/Systems/1": "On"})
+	defer server.Close()
+
+	mock.failWith = http.StatusInternalServerError
+
+	err := newTestRedfish(t, server, "/redfish/v1/Systems/1").PowerOff()
+	if err == nil || !strings.Contains(err.Error(), "redfishBMC: PowerOff: GET /redfish/v1/Systems/1 returned 500 Internal Server Error") {
+		t.Errorf("expected the error status to be reported, got %v", err)
+	}
+
+	hostBMC, err := New(siteconfig.BMC{Address: strings.Replace(server.URL, "http://", "redfish+http://", 1), Username: "admin", Password: "wrong"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = hostBMC.PowerStatus()
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("expected the login to fail, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cliRuntime : drives containers with the docker CLI, or the podman one, that
+// takes the same commands
+type cliRuntime struct {
+	name       string
+	binaryPath string
+	// podman can leave the storage of a container behind, when it is removed
+	// while in use, and then refuses to create a container with the same name
+	removeStorage bool
+}
+
+func newPodman(binaryPath string) RuntimeInterface {
+	return &cliRuntime{name: "podman", binaryPath: binaryPath, removeStorage: true}
+}
+
+func newDocker(binaryPath string) RuntimeInterface {
+	return &cliRuntime{name: "docker", binaryPath: binaryPath}
+}
+
+// the fields of the inspect output used for the state. Podman reports the health
+// as Healthcheck, and as Health since 4.x, like docker
+type cliInspect struct {
+	Name  string `json:"Name"`
+	State struct {
+		Status    string    `json:"Status"`
+		Running   bool      `json:"Running"`
+		StartedAt time.Time `json:"StartedAt"`
+		Health    *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+		Healthcheck *struct {
+			Status string `json:"Status"`
+		} `json:"Healthcheck"`
+	} `json:"State"`
+	Config struct {
+		Image string `json:"Image"`
+	} `json:"Config"`
+	ImageName string `json:"ImageName"`
+}
+
+// runs a command of the runtime, returning its output
+func (cr *cliRuntime) run(args ...string) (string, error) {
+	cmd := exec.Command(cr.binaryPath, args...)
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %s: %s", cr.name, strings.Join(args, " "), err, strings.TrimSpace(errb.String()))
+	}
+
+	return outb.String(), nil
+}
+
+func (cr *cliRuntime) Name() string {
+	return cr.name
+}
+
+func (cr *cliRuntime) Start(name string) error {
+	state, err := cr.Inspect(name)
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Start: %s", err)
+	}
+
+	if !state.Exists {
+		return fmt.Errorf("cliRuntime: Start: container %s does not exist", name)
+	}
+
+	if state.Running {
+		return nil
+	}
+
+	_, err = cr.run("start", name)
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Start: %s", err)
+	}
+
+	return nil
+}
+
+func (cr *cliRuntime) Stop(name string) error {
+	state, err := cr.Inspect(name)
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Stop: %s", err)
+	}
+
+	if !state.Running {
+		return nil
+	}
+
+	_, err = cr.run("stop", name)
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Stop: %s", err)
+	}
+
+	return nil
+}
+
+func (cr *cliRuntime) Remove(name string) error {
+	state, err := cr.Inspect(name)
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Remove: %s", err)
+	}
+
+	if !state.Exists {
+		if cr.removeStorage {
+			// Nothing to clean up most of the time, so the error is ignored
+			cr.run("rm", "--force", "--storage", name)
+		}
+		return nil
+	}
+
+	if state.Running {
+		return fmt.Errorf("cliRuntime: Remove: container %s is running, it needs to be stopped first", name)
+	}
+
+	_, err = cr.run("rm", name)
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Remove: %s", err)
+	}
+
+	return nil
+}
+
+func (cr *cliRuntime) Inspect(name string) (State, error) {
+	state := State{Name: name}
+
+	out, err := cr.run("inspect", "--type", "container", name)
+
+	if err != nil {
+		message := strings.ToLower(err.Error())
+		if strings.Contains(message, "no such") || strings.Contains(message, "no container with name") {
+			return state, nil
+		}
+		return state, fmt.Errorf("cliRuntime: Inspect: %s", err)
+	}
+
+	var inspected []cliInspect
+	err = json.Unmarshal([]byte(out), &inspected)
+
+	if err != nil || len(inspected) != 1 {
+		return state, fmt.Errorf("cliRuntime: Inspect: unexpected %s inspect output for container %s", cr.name, name)
+	}
+
+	container := inspected[0]
+
+	state.Exists = true
+	state.Running = container.State.Running
+	state.Status = container.State.Status
+	state.StartedAt = container.State.StartedAt
+
+	state.Image = container.Config.Image
+	if container.ImageName != "" {
+		state.Image = container.ImageName
+	}
+
+	if container.State.Health != nil {
+		state.Health = container.State.Health.Status
+	} else if container.State.Healthcheck != nil {
+		state.Health = container.State.Healthcheck.Status
+	}
+
+	return state, nil
+}
+
+func (cr *cliRuntime) Logs(name string, tail int, follow bool, w io.Writer) error {
+	args := []string{"logs"}
+	if tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(tail))
+	}
+	if follow {
+		args = append(args, "--follow")
+	}
+	args = append(args, name)
+
+	cmd := exec.Command(cr.binaryPath, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	err := cmd.Run()
+
+	if err != nil {
+		return fmt.Errorf("cliRuntime: Logs: %s %s: %s", cr.name, strings.Join(args, " "), err)
+	}
+
+	return nil
+}
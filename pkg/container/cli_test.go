@@ -0,0 +1,291 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fake docker or podman CLI: the inspect output of a container is read from
+// <state>/<name>.json, and the commands are appended to <state>/calls
+const fakeCLI = `#!/bin/sh
+state=%s
+echo "$*" >> $state/calls
+case "$1" in
+  inspect)
+    for name; do :; done
+    if [ -f "$state/$name.json" ]; then cat "$state/$name.json"; exit 0; fi
+    if [ -f "$state/$name.error" ]; then cat "$state/$name.error" >&2; exit 125; fi
+    echo "%s" >&2; exit 125 ;;
+  logs)
+    echo "log line"; exit 0 ;;
+esac
+exit 0
+`
+
+// the not found message of each runtime, quoted for the fake CLI
+var fakeNotFound = map[string]string{
+	"podman": `Error: no container with name or ID \"$name\" found: no such container`,
+	"docker": `Error: No such container: $name`,
+}
+
+// installs a fake CLI with that name in a directory of its own, returning the
+// directory and its state directory
+func installFakeCLI(t *testing.T, name string) (string, string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "kni-container-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	state := filepath.Join(dir, "state")
+	os.Mkdir(state, 0755)
+
+	err = ioutil.WriteFile(filepath.Join(dir, name), []byte(fmt.Sprintf(fakeCLI, state, fakeNotFound[name])), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, state
+}
+
+// sets the PATH to those directories only
+func setPath(t *testing.T, dirs ...string) {
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", strings.Join(dirs, string(os.PathListSeparator)))
+	t.Cleanup(func() { os.Setenv("PATH", path) })
+}
+
+// the commands run by the fake CLI
+func fakeCalls(t *testing.T, state string) []string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(filepath.Join(state, "calls"))
+	if os.IsNotExist(err) {
+		return []string{}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestNew(t *testing.T) {
+	podmanDir, _ := installFakeCLI(t, "podman")
+	dockerDir, _ := installFakeCLI(t, "docker")
+	emptyDir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		path    []string
+		runtime string
+		want    string
+		err     string
+	}{
+		{"podman first", []string{dockerDir, podmanDir}, "", "podman", ""},
+		{"docker only", []string{dockerDir}, "", "docker", ""},
+		{"none", []string{emptyDir}, "", "", "no container runtime found, install one of podman, docker"},
+		{"requested docker", []string{podmanDir, dockerDir}, "docker", "docker", ""},
+		{"requested missing", []string{podmanDir}, "docker", "", "docker not found in the PATH"},
+		{"unsupported", []string{podmanDir}, "rkt", "", "unsupported container runtime 'rkt', valid runtimes are docker, podman"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setPath(t, test.path...)
+
+			runtime, err := New(test.runtime)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runtime.Name() != test.want {
+				t.Errorf("expected runtime %s, got %s", test.want, runtime.Name())
+			}
+		})
+	}
+}
+
+// inspect output of podman 1.x-3.x, with the health as Healthcheck
+const podmanInspect = `[{
+  "Name": "kni-haproxy",
+  "State": {
+    "Status": "running",
+    "Running": true,
+    "StartedAt": "2019-08-01T10:00:00.123456789Z",
+    "Healthcheck": {"Status": "unhealthy", "FailingStreak": 3}
+  },
+  "Config": {"Image": "haproxy"},
+  "ImageName": "localhost/kni-haproxy:latest"
+}]`
+
+// inspect output of docker, with the health as Health
+const dockerInspect = `[{
+  "Name": "/kni-haproxy",
+  "State": {
+    "Status": "running",
+    "Running": true,
+    "StartedAt": "2019-08-01T10:00:00.123456789Z",
+    "Health": {"Status": "healthy", "FailingStreak": 0}
+  },
+  "Config": {"Image": "kni-haproxy:latest"}
+}]`
+
+// inspect output of a stopped container without healthcheck
+const exitedInspect = `[{
+  "Name": "kni-coredns",
+  "State": {"Status": "exited", "Running": false, "StartedAt": "2019-08-01T10:00:00Z"},
+  "Config": {"Image": "coredns/coredns"}
+}]`
+
+func TestInspect(t *testing.T) {
+	startedAt := time.Date(2019, 8, 1, 10, 0, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		name    string
+		runtime string
+		inspect string // inspect output, or the error of the CLI if it starts with Error
+		want    State
+		err     string
+	}{
+		{
+			name:    "podman healthcheck",
+			runtime: "podman",
+			inspect: podmanInspect,
+			want:    State{Name: "c", Exists: true, Running: true, Status: "running", Health: HealthUnhealthy, Image: "localhost/kni-haproxy:latest", StartedAt: startedAt},
+		},
+		{
+			name:    "docker health",
+			runtime: "docker",
+			inspect: dockerInspect,
+			want:    State{Name: "c", Exists: true, Running: true, Status: "running", Health: HealthHealthy, Image: "kni-haproxy:latest", StartedAt: startedAt},
+		},
+		{
+			name:    "no healthcheck",
+			runtime: "docker",
+			inspect: exitedInspect,
+			want:    State{Name: "c", Exists: true, Status: "exited", Image: "coredns/coredns", StartedAt: time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "podman not found",
+			runtime: "podman",
+			want:    State{Name: "c"},
+		},
+		{
+			name:    "docker not found",
+			runtime: "docker",
+			want:    State{Name: "c"},
+		},
+		{
+			name:    "cli error",
+			runtime: "podman",
+			inspect: "Error: cannot connect to the storage",
+			err:     "cliRuntime: Inspect: podman inspect --type container c: exit status 125: Error: cannot connect to the storage",
+		},
+		{
+			name:    "unexpected output",
+			runtime: "docker",
+			inspect: "[]",
+			err:     "cliRuntime: Inspect: unexpected docker inspect output for container c",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, state := installFakeCLI(t, test.runtime)
+
+			if strings.HasPrefix(test.inspect, "Error") {
+				ioutil.WriteFile(filepath.Join(state, "c.error"), []byte(test.inspect), 0644)
+			} else if test.inspect != "" {
+				ioutil.WriteFile(filepath.Join(state, "c.json"), []byte(test.inspect), 0644)
+			}
+
+			runtime := runtimeConstructors[test.runtime](filepath.Join(dir, test.runtime))
+			got, err := runtime.Inspect("c")
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected state\n%+v\ngot\n%+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	running := `[{"State": {"Status": "running", "Running": true}}]`
+	stopped := `[{"State": {"Status": "exited", "Running": false}}]`
+
+	tests := []struct {
+		name    string
+		runtime string
+		inspect string // inspect output of the container, none if empty
+		run     func(RuntimeInterface) error
+		calls   []string
+		err     string
+	}{
+		{"start stopped", "podman", stopped, func(r RuntimeInterface) error { return r.Start("c") }, []string{"start c"}, ""},
+		{"start running", "docker", running, func(r RuntimeInterface) error { return r.Start("c") }, []string{}, ""},
+		{"start missing", "docker", "", func(r RuntimeInterface) error { return r.Start("c") }, []string{}, "cliRuntime: Start: container c does not exist"},
+		{"stop running", "docker", running, func(r RuntimeInterface) error { return r.Stop("c") }, []string{"stop c"}, ""},
+		{"stop stopped", "podman", stopped, func(r RuntimeInterface) error { return r.Stop("c") }, []string{}, ""},
+		{"stop missing", "podman", "", func(r RuntimeInterface) error { return r.Stop("c") }, []string{}, ""},
+		{"remove stopped", "docker", stopped, func(r RuntimeInterface) error { return r.Remove("c") }, []string{"rm c"}, ""},
+		{"remove running", "podman", running, func(r RuntimeInterface) error { return r.Remove("c") }, []string{}, "cliRuntime: Remove: container c is running, it needs to be stopped first"},
+		{"remove missing podman", "podman", "", func(r RuntimeInterface) error { return r.Remove("c") }, []string{"rm --force --storage c"}, ""},
+		{"remove missing docker", "docker", "", func(r RuntimeInterface) error { return r.Remove("c") }, []string{}, ""},
+		{"logs", "podman", "", func(r RuntimeInterface) error { return r.Logs("c", 100, true, ioutil.Discard) }, []string{"logs --tail 100 --follow c"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, state := installFakeCLI(t, test.runtime)
+
+			if test.inspect != "" {
+				ioutil.WriteFile(filepath.Join(state, "c.json"), []byte(test.inspect), 0644)
+			}
+
+			err := test.run(runtimeConstructors[test.runtime](filepath.Join(dir, test.runtime)))
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			// the inspect calls are left out
+			calls := []string{}
+			for _, call := range fakeCalls(t, state) {
+				if !strings.HasPrefix(call, "inspect ") {
+					calls = append(calls, call)
+				}
+			}
+			if !reflect.DeepEqual(calls, test.calls) {
+				t.Errorf("expected commands %q, got %q", test.calls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// health of a container with a healthcheck. Containers without one have no health
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthStarting  = "starting"
+)
+
+// State : the state of a container, as reported by its runtime
+type State struct {
+	Name      string
+	Exists    bool
+	Running   bool
+	Status    string // created, running, exited...
+	Health    string // empty when the container has no healthcheck
+	Image     string
+	StartedAt time.Time
+}
+
+type RuntimeInterface interface {
+	Name() string                                               // Name of the runtime, as passed to New
+	Start(name string) error                                    // Start a created or stopped container, doing nothing if it is running
+	Stop(name string) error                                     // Stop a container, doing nothing if it is stopped or does not exist
+	Remove(name string) error                                   // Remove a stopped container, doing nothing if it does not exist
+	Inspect(name string) (State, error)                         // State of a container, with Exists unset if there is none with that name
+	Logs(name string, tail int, follow bool, w io.Writer) error // Write the logs of a container, the last tail lines only if tail is positive
+}
+
+var (
+	runtimeConstructors map[string]func(binaryPath string) RuntimeInterface
+)
+
+func init() {
+	// Add new container runtimes here, in order of preference when detecting
+	// the runtime of the host
+	runtimeConstructors = map[string]func(string) RuntimeInterface{}
+	runtimeConstructors["podman"] = newPodman
+	runtimeConstructors["docker"] = newDocker
+}
+
+// order in which the runtimes are looked for, when none is requested
+var detectionOrder = []string{"podman", "docker"}
+
+// New : returns the container runtime with that name, or the first one of podman
+// and docker found in the PATH if name is empty
+func New(name string) (RuntimeInterface, error) {
+	if name == "" {
+		for _, runtimeName := range detectionOrder {
+			binaryPath, err := exec.LookPath(runtimeName)
+
+			if err == nil {
+				return runtimeConstructors[runtimeName](binaryPath), nil
+			}
+		}
+
+		return nil, fmt.Errorf("Container: New: no container runtime found, install one of %s", strings.Join(detectionOrder, ", "))
+	}
+
+	constructor := runtimeConstructors[name]
+
+	if constructor == nil {
+		return nil, fmt.Errorf("Container: New: unsupported container runtime '%s', valid runtimes are %s", name, strings.Join(Runtimes(), ", "))
+	}
+
+	binaryPath, err := exec.LookPath(name)
+
+	if err != nil {
+		return nil, fmt.Errorf("Container: New: %s not found in the PATH: %s", name, err)
+	}
+
+	return constructor(binaryPath), nil
+}
+
+// Runtimes : the names of the supported container runtimes, sorted
+func Runtimes() []string {
+	names := []string{}
+	for name := range runtimeConstructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,167 @@
+package hardening
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// machineConfigFile : a file to be written on the nodes by a MachineConfig
+type machineConfigFile struct {
+	source string // file with the contents, relative to the hardening sources path
+	path   string // path of the file in the nodes
+	mode   int
+}
+
+// machineConfigUnit : a systemd unit to be enabled on the nodes by a MachineConfig
+type machineConfigUnit struct {
+	source string // file with the unit contents, relative to the hardening sources path
+	name   string
+}
+
+// machineConfig : a MachineConfig to generate from readable source files
+type machineConfig struct {
+	name  string
+	roles []string // generated for each role, suffixing the name with it when there are several
+	files []machineConfigFile
+	units []machineConfigUnit
+}
+
+// profile : the settings applied by a hardening profile
+type profile struct {
+	machineConfigs []machineConfig
+	manifests      []string // plain manifests, relative to the hardening sources path
+}
+
+var (
+	profiles map[string]profile
+)
+
+func init() {
+	// Add new hardening profiles here
+	profiles = map[string]profile{}
+	profiles["akraino"] = profile{
+		machineConfigs: []machineConfig{
+			{
+				name:  "99-akraino-sec",
+				roles: []string{"master", "worker"},
+				files: []machineConfigFile{{source: "akraino-lynis-fixes.sh", path: "/root/akrainosec.sh", mode: 0755}},
+				units: []machineConfigUnit{{source: "akraino-sec.service", name: "akrainosec.service"}},
+			},
+			{
+				name:  "50-disable-secret-automount",
+				files: []machineConfigFile{{source: "akraino-containers-mounts.conf", path: "/etc/containers/mounts.conf", mode: 0644}},
+				roles: []string{"worker"},
+			},
+		},
+		manifests: []string{"akraino-kubehunter.yaml"},
+	}
+}
+
+// Generates the manifests for a hardening profile, using the sources in the given
+// path. Manifests are returned separated by "---", like a kustomize output
+func Generate(profileName string, sourcesPath string) (string, error) {
+	p, ok := profiles[profileName]
+	if !ok {
+		return "", fmt.Errorf("Hardening: Generate: unknown hardening profile '%s'", profileName)
+	}
+
+	generatedManifests := []string{}
+
+	for _, mc := range p.machineConfigs {
+		for _, role := range mc.roles {
+			mcObj, err := mc.generate(role, sourcesPath)
+			if err != nil {
+				return "", err
+			}
+
+			mcString, err := yaml.Marshal(mcObj)
+			if err != nil {
+				return "", fmt.Errorf("Hardening: Generate: error marshaling MachineConfig %s: %s", mc.name, err)
+			}
+			generatedManifests = append(generatedManifests, strings.TrimSpace(string(mcString)))
+		}
+	}
+
+	for _, manifest := range p.manifests {
+		manifestContent, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", sourcesPath, manifest))
+		if err != nil {
+			return "", fmt.Errorf("Hardening: Generate: error reading manifest source: %s", err)
+		}
+
+		for _, doc := range strings.Split(string(manifestContent), "---\n") {
+			if len(strings.TrimSpace(doc)) > 0 {
+				generatedManifests = append(generatedManifests, strings.TrimSpace(doc))
+			}
+		}
+	}
+
+	return strings.Join(generatedManifests, "\n---\n"), nil
+}
+
+// generates the MachineConfig object for a given role
+func (mc machineConfig) generate(role string, sourcesPath string) (map[string]interface{}, error) {
+	files := []interface{}{}
+	for _, file := range mc.files {
+		content, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", sourcesPath, file.source))
+		if err != nil {
+			return nil, fmt.Errorf("Hardening: generate: error reading MachineConfig file source: %s", err)
+		}
+
+		files = append(files, map[string]interface{}{
+			"filesystem": "root",
+			"path":       file.path,
+			"mode":       file.mode,
+			"contents": map[string]interface{}{
+				"source":       fmt.Sprintf("data:text/plain;charset=utf-8;base64,%s", base64.StdEncoding.EncodeToString(content)),
+				"verification": map[string]interface{}{},
+			},
+		})
+	}
+
+	units := []interface{}{}
+	for _, unit := range mc.units {
+		content, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", sourcesPath, unit.source))
+		if err != nil {
+			return nil, fmt.Errorf("Hardening: generate: error reading MachineConfig unit source: %s", err)
+		}
+
+		units = append(units, map[string]interface{}{
+			"name":     unit.name,
+			"enabled":  true,
+			"contents": string(content),
+		})
+	}
+
+	config := map[string]interface{}{
+		"ignition": map[string]interface{}{"version": "2.2.0"},
+	}
+	if len(files) > 0 {
+		config["storage"] = map[string]interface{}{"files": files}
+	}
+	if len(units) > 0 {
+		config["systemd"] = map[string]interface{}{"units": units}
+	}
+
+	name := mc.name
+	if len(mc.roles) > 1 {
+		name = fmt.Sprintf("%s-%s", mc.name, role)
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "machineconfiguration.openshift.io/v1",
+		"kind":       "MachineConfig",
+		"metadata": map[string]interface{}{
+			"name": name,
+			"labels": map[string]interface{}{
+				"machineconfiguration.openshift.io/role": role,
+			},
+		},
+		"spec": map[string]interface{}{
+			"config": config,
+		},
+	}, nil
+}
@@ -48,17 +48,27 @@ func GetGKVN(manifestObj map[interface{}]interface{}) string {
 	return GVKN
 }
 
+// returns all the objects in the items of a list, in order, including the ones
+// that share a gvkn
+func GetListItems(manifestObj map[interface{}]interface{}) []map[interface{}]interface{} {
+	parsedItems := []map[interface{}]interface{}{}
+
+	items, _ := manifestObj["items"].([]interface{})
+	for _, item := range items {
+		if parsedItem, ok := item.(map[interface{}]interface{}); ok {
+			parsedItems = append(parsedItems, parsedItem)
+		}
+	}
+
+	return parsedItems
+}
+
 // we have a list of items, we need to split and get their individual gvkn
 func GetNestedManifestsWithGVKN(manifestObj map[interface{}]interface{}) map[string]map[interface{}]interface{} {
-	var items []interface{}
-	var parsedItem map[interface{}]interface{}
-	var GVKN string
 	GVKNS := make(map[string]map[interface{}]interface{})
 
-	items = manifestObj["items"].([]interface{})
-	for _, item := range items {
-		parsedItem = item.(map[interface{}]interface{})
-		GVKN = GetGKVN(parsedItem)
+	for _, parsedItem := range GetListItems(manifestObj) {
+		GVKN := GetGKVN(parsedItem)
 		if len(GVKN) > 0 {
 			GVKNS[GVKN] = parsedItem
 		}
@@ -78,8 +88,10 @@ func NameFromGVKN(GVKN string) string {
 	return strings.ToLower(name)
 }
 
-// utility to merge manifests
-func MergeManifests(content string, siteBuildPath string) string {
+// utility to merge manifests. If provenance is not nil, the manifests modified or
+// added by the blueprint are annotated with the layer they come from. Manifests
+// generated by the installer and left unmodified are kept as they are
+func MergeManifests(content string, siteBuildPath string, provenance *Provenance) string {
 	manifests := strings.Split(content, "\n---\n")
 	kustomizeManifests := make(map[string]map[interface{}]interface{})
 
@@ -120,54 +132,56 @@ func MergeManifests(content string, siteBuildPath string) string {
 					log.Fatalf("Error parsing manifest: %s\n", err)
 				}
 
+				// the items of a list are compared one by one, and the list is
+				// rewritten as a whole when any of them was modified
 				GVKN := GetGKVN(manifestContentObj)
-				var walkedManifests map[string]map[interface{}]interface{}
-				if GVKN == "~G/v1/List|~N" {
-					walkedManifests = GetNestedManifestsWithGVKN(manifestContentObj)
-					for k, _ := range walkedManifests {
-						processedManifests[k] = ""
-					}
-				} else {
-					walkedManifests = make(map[string]map[interface{}]interface{})
-					walkedManifests[GVKN] = manifestContentObj
-					processedManifests[GVKN] = ""
+				isList := GVKN == "~G/v1/List|~N"
+				walkedManifests := []map[interface{}]interface{}{manifestContentObj}
+				if isList {
+					walkedManifests = GetListItems(manifestContentObj)
 				}
 
 				// now compare each content with the ones from kustomize
-				counter := 0
-				for k, v := range walkedManifests {
+				mergedManifests := []interface{}{}
+				modified := false
+				for _, v := range walkedManifests {
+					k := GetGKVN(v)
+					processedManifests[k] = ""
+
 					kustomizedContentObj, ok := kustomizeManifests[k]
-					if ok {
-						if !reflect.DeepEqual(kustomizedContentObj, v) {
-							// do a backup of the original file
-							if _, err := os.Stat(path); err == nil {
-								err = os.Rename(path, fmt.Sprintf("%s.orig", path))
-							}
-
-							kustomizedString, err := yaml.Marshal(kustomizedContentObj)
-							if err != nil {
-								log.Fatalf("Error marshaling kustomized content: %s\n", err)
-							}
-
-							if len(walkedManifests) == 1 {
-								// just rewrite with the original name
-								err = ioutil.WriteFile(path, kustomizedString, 0644)
-								if err != nil {
-									log.Fatalf("Error writing new manifest content: %s\n", err)
-								}
-							} else {
-								// rewrite with a prefix
-								newPath := fmt.Sprintf("%02d_%s", counter, path)
-								err = ioutil.WriteFile(newPath, kustomizedString, 0644)
-								if err != nil {
-									log.Fatalf("Error writing new manifest content: %s", err)
-								}
-								counter = counter + 1
-							}
-						}
+					if ok && !reflect.DeepEqual(kustomizedContentObj, v) {
+						modified = true
+						provenance.Annotate(kustomizedContentObj, LayerClusterMods)
+						mergedManifests = append(mergedManifests, kustomizedContentObj)
+					} else {
+						mergedManifests = append(mergedManifests, v)
 					}
 				}
 
+				if modified {
+					// do a backup of the original file
+					err = os.Rename(path, fmt.Sprintf("%s.orig", path))
+					if err != nil {
+						log.Fatalf("Error backing up manifest: %s\n", err)
+					}
+
+					var mergedContentObj interface{} = mergedManifests[0]
+					if isList {
+						manifestContentObj["items"] = mergedManifests
+						mergedContentObj = manifestContentObj
+					}
+
+					kustomizedString, err := yaml.Marshal(mergedContentObj)
+					if err != nil {
+						log.Fatalf("Error marshaling kustomized content: %s\n", err)
+					}
+
+					// just rewrite with the original name
+					err = ioutil.WriteFile(path, kustomizedString, 0600)
+					if err != nil {
+						log.Fatalf("Error writing new manifest content: %s\n", err)
+					}
+				}
 			}
 		} else {
 			log.Fatalf("Error walking on manifests directory: %s\n", err)
@@ -187,11 +201,12 @@ func MergeManifests(content string, siteBuildPath string) string {
 			newPath := fmt.Sprintf("%s/blueprint/base/00_cluster/manifests/%s", siteBuildPath, manifestName)
 
 			// marshal the file to write
+			provenance.Annotate(v, LayerClusterMods)
 			kustomizedString, err := yaml.Marshal(v)
 			if err != nil {
 				log.Fatalf("Error marshing manifest: %s\n", err)
 			}
-			err = ioutil.WriteFile(newPath, kustomizedString, 0644)
+			err = ioutil.WriteFile(newPath, kustomizedString, 0600)
 			if err != nil {
 				log.Fatalf("Error writing manifest: %s\n", err)
 			}
@@ -210,7 +225,7 @@ func MergeManifests(content string, siteBuildPath string) string {
 
 		fmt.Fprintf(&builder, "*** Manifest generation finished. You can run now: %s/requirements/openshift-install create cluster --dir=%s/final_manifests to create the site cluster ***\n", siteBuildPath, siteBuildPath)
 		fmt.Fprintf(&builder, "If using UPI you can generate ignition files with: %s/requirements/openshift-install create ignition-configs --dir=%s/final_manifests\n", siteBuildPath, siteBuildPath)
-		fmt.Fprintf(&builder, "If you are using baremetal automation you can deploy masters and workers with: ./knictl deploy_masters <site_name>, ./knictl deploy_workers <site_name>. Destroy the bootstrap VM once the deploy_workers command is initiated with: virsh destroy <bootstrap_vm_name>. You could destroy the cluster with: ./knictl destroy_cluster <site_name>\n")
+		fmt.Fprintf(&builder, "If you are using baremetal automation you can deploy masters and workers with: ./knictl deploy_masters <site_name>, ./knictl deploy_workers <site_name>. The deploy_workers command waits for the bootstrap to complete and removes the bootstrap node, unless --keep_bootstrap is given. You could destroy the cluster with: ./knictl destroy_cluster <site_name>\n")
 		fmt.Fprintf(&builder, "A profile.env file has been generated inside %s/profile.env, you can source it before starting the openshift-install command\n", siteBuildPath)
 		fmt.Fprintf(&builder, "In order to destroy the cluster you can run:  %s/requirements/openshift-install destroy cluster --dir %s/final_manifests", siteBuildPath, siteBuildPath)
 
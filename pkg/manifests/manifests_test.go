@@ -0,0 +1,256 @@
+package manifests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+var testProvenance = &Provenance{
+	BlueprintRepo: "github.com/akraino-edge-stack/kni-blueprint-pae",
+	BlueprintRef:  "master",
+	SiteCommit:    "0123456789",
+	KnictlVersion: "v1.0.0",
+}
+
+// manifests generated by the installer, by path relative to the 00_cluster layer
+var testInstallerManifests = map[string]string{
+	"manifests/cluster-config.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cluster-config-v1
+  namespace: kube-system
+data:
+  install-config: |
+    baseDomain: example.com
+`,
+	"openshift/99_kubeadmin-password-secret.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: kubeadmin
+  namespace: kube-system
+data:
+  kubeadmin: cGFzc3dvcmQ=
+`,
+	"openshift/99_openshift-machineconfig.yaml": `apiVersion: v1
+kind: List
+items:
+- apiVersion: machineconfiguration.openshift.io/v1
+  kind: MachineConfig
+  metadata:
+    name: 99-master-ssh
+  spec:
+    config: {}
+- apiVersion: machineconfiguration.openshift.io/v1
+  kind: MachineConfig
+  metadata:
+    name: 99-worker-ssh
+  spec:
+    config: {}
+`,
+}
+
+// kustomize output of the 00_cluster and 01_cluster-mods layers: the secret and
+// the master machine config are modified, and a namespace is added
+const testKustomizeOutput = `apiVersion: v1
+data:
+  install-config: |
+    baseDomain: example.com
+kind: ConfigMap
+metadata:
+  name: cluster-config-v1
+  namespace: kube-system
+---
+apiVersion: v1
+data:
+  kubeadmin: b3RoZXI=
+kind: Secret
+metadata:
+  name: kubeadmin
+  namespace: kube-system
+---
+apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 99-master-ssh
+spec:
+  config:
+    ignition:
+      version: 2.2.0
+---
+apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 99-worker-ssh
+spec:
+  config: {}
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: blueprint
+`
+
+// a site build path with the installer manifests in the 00_cluster layer
+func testSiteBuildPath(t *testing.T) string {
+	t.Helper()
+
+	siteBuildPath := t.TempDir()
+	for path, content := range testInstallerManifests {
+		fullPath := filepath.Join(siteBuildPath, "blueprint/base/00_cluster", path)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		err := ioutil.WriteFile(fullPath, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return siteBuildPath
+}
+
+func readManifest(t *testing.T, path string) map[interface{}]interface{} {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifestObj map[interface{}]interface{}
+	err = yaml.Unmarshal(content, &manifestObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manifestObj
+}
+
+func sourceLayer(manifestObj map[interface{}]interface{}) string {
+	return GetProvenance(manifestObj)[AnnotationSourceLayer]
+}
+
+func TestMergeManifestsWithProvenance(t *testing.T) {
+	siteBuildPath := testSiteBuildPath(t)
+
+	result := MergeManifests(testKustomizeOutput, siteBuildPath, testProvenance)
+	if !strings.Contains(result, "Manifest generation finished") {
+		t.Errorf("unexpected result %q", result)
+	}
+	finalManifests := filepath.Join(siteBuildPath, "final_manifests")
+
+	// the unmodified installer manifest is kept byte for byte
+	content, _ := ioutil.ReadFile(filepath.Join(finalManifests, "manifests/cluster-config.yaml"))
+	if string(content) != testInstallerManifests["manifests/cluster-config.yaml"] {
+		t.Errorf("expected the unmodified manifest to be kept, got\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(finalManifests, "manifests/cluster-config.yaml.orig")); !os.IsNotExist(err) {
+		t.Errorf("expected no backup of the unmodified manifest")
+	}
+
+	secret := readManifest(t, filepath.Join(finalManifests, "openshift/99_kubeadmin-password-secret.yaml"))
+	want := map[string]string{
+		AnnotationSourceLayer:   LayerClusterMods,
+		AnnotationBlueprintRepo: testProvenance.BlueprintRepo,
+		AnnotationBlueprintRef:  testProvenance.BlueprintRef,
+		AnnotationSiteCommit:    testProvenance.SiteCommit,
+		AnnotationKnictlVersion: testProvenance.KnictlVersion,
+	}
+	if !reflect.DeepEqual(GetProvenance(secret), want) {
+		t.Errorf("expected the modified manifest to be annotated with\n%v\ngot\n%v", want, GetProvenance(secret))
+	}
+	if secret["data"].(map[interface{}]interface{})["kubeadmin"] != "b3RoZXI=" {
+		t.Errorf("expected the modified content, got %v", secret["data"])
+	}
+	original, _ := ioutil.ReadFile(filepath.Join(finalManifests, "openshift/99_kubeadmin-password-secret.yaml.orig"))
+	if string(original) != testInstallerManifests["openshift/99_kubeadmin-password-secret.yaml"] {
+		t.Errorf("expected a backup of the installer manifest, got\n%s", original)
+	}
+
+	// the list keeps all its items, and only the modified one is annotated
+	items := GetListItems(readManifest(t, filepath.Join(finalManifests, "openshift/99_openshift-machineconfig.yaml")))
+	if len(items) != 2 {
+		t.Fatalf("expected the list to keep its 2 items, got %v", items)
+	}
+	if GetGKVN(items[0]) != "machineconfiguration.openshift.io/v1/MachineConfig|99-master-ssh" || sourceLayer(items[0]) != LayerClusterMods {
+		t.Errorf("expected the modified master machine config to be annotated, got %v", items[0])
+	}
+	if GetGKVN(items[1]) != "machineconfiguration.openshift.io/v1/MachineConfig|99-worker-ssh" || len(GetProvenance(items[1])) != 0 {
+		t.Errorf("expected the unmodified worker machine config without annotations, got %v", items[1])
+	}
+	files, _ := filepath.Glob(filepath.Join(finalManifests, "openshift/*"))
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	wantFiles := []string{"99_kubeadmin-password-secret.yaml", "99_kubeadmin-password-secret.yaml.orig", "99_openshift-machineconfig.yaml", "99_openshift-machineconfig.yaml.orig"}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("expected the files %v, got %v", wantFiles, files)
+	}
+
+	// the blueprint manifest is added and annotated
+	added := readManifest(t, filepath.Join(finalManifests, "manifests/99_0000_namespace-blueprint.yaml"))
+	if sourceLayer(added) != LayerClusterMods {
+		t.Errorf("expected the added manifest to be annotated, got %v", added)
+	}
+}
+
+func TestMergeManifestsWithoutProvenance(t *testing.T) {
+	siteBuildPath := testSiteBuildPath(t)
+
+	MergeManifests(testKustomizeOutput, siteBuildPath, nil)
+
+	filepath.Walk(filepath.Join(siteBuildPath, "final_manifests"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			content, _ := ioutil.ReadFile(path)
+			if strings.Contains(string(content), "kni.akraino.org/") {
+				t.Errorf("expected no annotation in %s, got\n%s", path, content)
+			}
+		}
+		return nil
+	})
+}
+
+// every item of a list is annotated, even the ones sharing a kind and name
+func TestAnnotateList(t *testing.T) {
+	var list map[interface{}]interface{}
+	yaml.Unmarshal([]byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+    namespace: first
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+    namespace: second
+- apiVersion: v1
+  kind: ConfigMap
+- not an object
+`), &list)
+
+	testProvenance.Annotate(list, LayerServices)
+
+	items := GetListItems(list)
+	if len(items) != 3 {
+		t.Fatalf("expected the 3 objects of the list, got %v", items)
+	}
+	for i, item := range items {
+		if sourceLayer(item) != LayerServices {
+			t.Errorf("expected item %d to be annotated, got %v", i, item)
+		}
+	}
+	if _, ok := list["metadata"]; ok {
+		t.Errorf("expected the list itself not to be annotated")
+	}
+
+	annotated := string(testProvenance.AnnotateContent([]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: first\n---\nkey: value\n"), LayerServices))
+	if strings.Count(annotated, AnnotationSourceLayer+": "+LayerServices) != 1 || !strings.Contains(annotated, "---\nkey: value\n") {
+		t.Errorf("expected only the object with a kind to be annotated, got\n%s", annotated)
+	}
+}
@@ -0,0 +1,193 @@
+package manifests
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// layers of a site, used as the source of each rendered manifest
+const (
+	LayerCluster       = "00_cluster"
+	LayerClusterMods   = "01_cluster-mods"
+	LayerClusterAddons = "02_cluster-addons"
+	LayerServices      = "03_services"
+)
+
+// annotations used to record the provenance of a manifest
+const (
+	AnnotationSourceLayer   = "kni.akraino.org/source-layer"
+	AnnotationBlueprintRepo = "kni.akraino.org/blueprint-repo"
+	AnnotationBlueprintRef  = "kni.akraino.org/blueprint-ref"
+	AnnotationSiteCommit    = "kni.akraino.org/site-commit"
+	AnnotationKnictlVersion = "kni.akraino.org/knictl-version"
+)
+
+// ProvenanceAnnotations lists all the provenance annotations, in display order
+var ProvenanceAnnotations = []string{
+	AnnotationSourceLayer,
+	AnnotationBlueprintRepo,
+	AnnotationBlueprintRef,
+	AnnotationSiteCommit,
+	AnnotationKnictlVersion,
+}
+
+// Provenance : Structure that contains the origin of the manifests rendered for a site
+type Provenance struct {
+	BlueprintRepo string
+	BlueprintRef  string
+	SiteCommit    string
+	KnictlVersion string
+}
+
+// annotates a single manifest with the provenance for the given layer
+func (p *Provenance) Annotate(manifestObj map[interface{}]interface{}, layer string) {
+	// nil provenance means that annotations are disabled
+	if p == nil || manifestObj == nil {
+		return
+	}
+
+	// objects without kind are not kubernetes objects, leave them untouched
+	if _, ok := manifestObj["kind"]; !ok {
+		return
+	}
+
+	// lists get all their items annotated instead
+	if GetGKVN(manifestObj) == "~G/v1/List|~N" {
+		for _, item := range GetListItems(manifestObj) {
+			p.Annotate(item, layer)
+		}
+		return
+	}
+
+	metadata, ok := manifestObj["metadata"].(map[interface{}]interface{})
+	if !ok {
+		metadata = make(map[interface{}]interface{})
+		manifestObj["metadata"] = metadata
+	}
+	annotations, ok := metadata["annotations"].(map[interface{}]interface{})
+	if !ok {
+		annotations = make(map[interface{}]interface{})
+		metadata["annotations"] = annotations
+	}
+
+	annotations[AnnotationSourceLayer] = layer
+	annotations[AnnotationBlueprintRepo] = p.BlueprintRepo
+	annotations[AnnotationBlueprintRef] = p.BlueprintRef
+	annotations[AnnotationSiteCommit] = p.SiteCommit
+	annotations[AnnotationKnictlVersion] = p.KnictlVersion
+}
+
+// annotates all the manifests contained in a kustomize output
+func (p *Provenance) AnnotateContent(content []byte, layer string) []byte {
+	if p == nil || len(content) == 0 {
+		return content
+	}
+
+	var builder strings.Builder
+	for _, manifest := range strings.Split(string(content), "\n---\n") {
+		var manifestObj map[interface{}]interface{}
+		err := yaml.Unmarshal([]byte(manifest), &manifestObj)
+		if err != nil {
+			log.Fatalf("Error parsing manifest: %s\n", err)
+		}
+		if manifestObj == nil {
+			continue
+		}
+		p.Annotate(manifestObj, layer)
+
+		annotatedString, err := yaml.Marshal(manifestObj)
+		if err != nil {
+			log.Fatalf("Error marshaling annotated content: %s\n", err)
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("---\n")
+		}
+		builder.Write(annotatedString)
+	}
+
+	return []byte(builder.String())
+}
+
+// retrieves the provenance annotations of a manifest
+func GetProvenance(manifestObj map[interface{}]interface{}) map[string]string {
+	provenance := make(map[string]string)
+
+	metadata, ok := manifestObj["metadata"].(map[interface{}]interface{})
+	if !ok {
+		return provenance
+	}
+	annotations, ok := metadata["annotations"].(map[interface{}]interface{})
+	if !ok {
+		return provenance
+	}
+
+	for _, annotation := range ProvenanceAnnotations {
+		if value, ok := annotations[annotation]; ok {
+			provenance[annotation] = fmt.Sprintf("%v", value)
+		}
+	}
+	return provenance
+}
+
+// searches a manifests directory for an object with the given kind and name,
+// returning the object and the file that contains it
+func FindManifest(manifestsPath string, kind string, name string) (map[interface{}]interface{}, string) {
+	var foundObj map[interface{}]interface{}
+	var foundPath string
+
+	filepath.Walk(manifestsPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || foundObj != nil {
+			return nil
+		}
+		if info.IsDir() || !(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+			return nil
+		}
+
+		manifestContent, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var manifestContentObj map[interface{}]interface{}
+		err = yaml.Unmarshal(manifestContent, &manifestContentObj)
+		if err != nil || manifestContentObj == nil {
+			// not a manifest we can inspect, skip it
+			return nil
+		}
+
+		candidates := []map[interface{}]interface{}{manifestContentObj}
+		if GetGKVN(manifestContentObj) == "~G/v1/List|~N" {
+			candidates = GetListItems(manifestContentObj)
+		}
+
+		for _, candidate := range candidates {
+			if manifestMatches(candidate, kind, name) {
+				foundObj = candidate
+				foundPath = path
+				break
+			}
+		}
+		return nil
+	})
+
+	return foundObj, foundPath
+}
+
+// checks if a manifest has the given kind (case insensitive) and name
+func manifestMatches(manifestObj map[interface{}]interface{}, kind string, name string) bool {
+	manifestKind, ok := manifestObj["kind"].(string)
+	if !ok || !strings.EqualFold(manifestKind, kind) {
+		return false
+	}
+	metadata, ok := manifestObj["metadata"].(map[interface{}]interface{})
+	if !ok {
+		return false
+	}
+	manifestName, ok := metadata["name"].(string)
+	return ok && manifestName == name
+}
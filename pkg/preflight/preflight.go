@@ -0,0 +1,334 @@
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"gerrit.akraino.org/kni/installer/pkg/bastion"
+	"gerrit.akraino.org/kni/installer/pkg/bmc"
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// statuses of the checks
+const (
+	StatusPass = "PASS"
+	StatusWarn = "WARN" // does not block the deployment, but needs a look
+	StatusFail = "FAIL"
+)
+
+// Result : the outcome of a check on a target, a host or the bastion
+type Result struct {
+	Check  string
+	Target string
+	Status string
+	Detail string
+}
+
+// Report : the results of all the checks, in the order they ran
+type Report struct {
+	Results []Result
+}
+
+func (r *Report) add(check string, target string, status string, detail string) {
+	r.Results = append(r.Results, Result{Check: check, Target: target, Status: status, Detail: detail})
+}
+
+// Failed : the number of failed checks
+func (r Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Print : writes the report as a table, followed by the totals
+func (r Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tTARGET\tRESULT\tDETAIL")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Check, result.Target, result.Status, result.Detail)
+	}
+	tw.Flush()
+
+	warnings := 0
+	for _, result := range r.Results {
+		if result.Status == StatusWarn {
+			warnings++
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d checks, %d failed, %d warnings\n", len(r.Results), r.Failed(), warnings)
+}
+
+// Run : checks the provisioning infrastructure of a site before deploying it. The
+// validation error of the site config, if any, and its unknown settings are
+// reported along with the checks of the hosts, the addresses and the bastion
+// interfaces, and the BMC of every host is logged into with the credentials read
+// from the secret provider
+func Run(siteConfig siteconfig.SiteConfig, validationErr error, provider secrets.SecretProviderInterface) Report {
+	report := Report{}
+
+	if validationErr != nil {
+		problems := []string{validationErr.Error()}
+		if ve, ok := validationErr.(siteconfig.ValidationError); ok {
+			problems = ve.Problems
+		}
+		for _, problem := range problems {
+			report.add("site config", "site-config.yaml", StatusFail, problem)
+		}
+	} else {
+		report.add("site config", "site-config.yaml", StatusPass, "")
+	}
+	for _, warning := range siteConfig.Warnings() {
+		report.add("site config", "site-config.yaml", StatusWarn, warning)
+	}
+
+	pi := siteConfig.ProvisioningInfrastructure
+	if pi == nil {
+		report.add("provisioning infrastructure", "site-config.yaml", StatusFail, "no provisioningInfrastructure block, the site can not be deployed by the baremetal automation")
+		return report
+	}
+
+	hosts := append(append([]siteconfig.Host{}, pi.Hosts.Masters...), pi.Hosts.Workers...)
+
+	checkInterfaces(&report, pi.Network)
+	checkAddresses(&report, pi.Network, hosts)
+	checkMACs(&report, hosts)
+	checkBastionConfig(&report, *pi)
+	checkBMCs(&report, hosts, provider)
+
+	return report
+}
+
+// checks that the bastion interfaces exist, and that the bastion addresses are set on them
+func checkInterfaces(report *Report, network siteconfig.Network) {
+	for _, bastionInterface := range []struct {
+		field string
+		name  string
+		vlan  int
+		ip    string
+	}{
+		{"provisioningInterface", network.ProvisioningInterface, network.ProvisioningVLAN, network.ProvisioningIP},
+		{"baremetalInterface", network.BaremetalInterface, network.BaremetalVLAN, network.BaremetalIP},
+	} {
+		if bastionInterface.name == "" {
+			report.add("bastion interface", bastionInterface.field, StatusFail, "not set")
+			continue
+		}
+
+		iface, err := net.InterfaceByName(bastionInterface.name)
+		if err != nil {
+			report.add("bastion interface", bastionInterface.name, StatusFail, fmt.Sprintf("%s not found on this host", bastionInterface.field))
+			continue
+		}
+
+		if iface.Flags&net.FlagUp == 0 {
+			report.add("bastion interface", bastionInterface.name, StatusWarn, "interface is down")
+			continue
+		}
+
+		// the address is set on the VLAN interface, when there is one
+		addressInterface := iface
+		if bastionInterface.vlan != 0 {
+			vlanName := fmt.Sprintf("%s.%d", bastionInterface.name, bastionInterface.vlan)
+			addressInterface, err = net.InterfaceByName(vlanName)
+			if err != nil {
+				report.add("bastion interface", bastionInterface.name, StatusWarn, fmt.Sprintf("VLAN interface %s not found", vlanName))
+				continue
+			}
+		}
+
+		if bastionInterface.ip != "" && !interfaceHasIP(addressInterface, bastionInterface.ip) {
+			report.add("bastion interface", addressInterface.Name, StatusWarn, fmt.Sprintf("%s is not set on the interface", bastionInterface.ip))
+			continue
+		}
+
+		report.add("bastion interface", addressInterface.Name, StatusPass, "")
+	}
+}
+
+func interfaceHasIP(iface *net.Interface, ip string) bool {
+	addresses, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+
+	for _, address := range addresses {
+		if ipNet, ok := address.(*net.IPNet); ok && ipNet.IP.Equal(net.ParseIP(ip)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// an address of the baremetal network to check
+type preflightAddress struct {
+	target    string
+	ip        string
+	required  bool
+	shareable bool // the bastion address and the VIPs, that default to it
+}
+
+// checks that the VIPs, the bootstrap and gateway addresses and the host
+// addresses are on the baremetal network, and not used twice
+func checkAddresses(report *Report, network siteconfig.Network, hosts []siteconfig.Host) {
+	_, baremetalNetwork, err := net.ParseCIDR(network.BaremetalIPCIDR)
+	if err != nil {
+		report.add("address", "baremetalIpCidr", StatusFail, fmt.Sprintf("invalid baremetal CIDR %q", network.BaremetalIPCIDR))
+		return
+	}
+
+	addresses := []preflightAddress{
+		{"baremetalIp", network.BaremetalIP, true, true},
+		{"baremetalGWIP", network.BaremetalGatewayIP, false, false},
+		{"apiVip", network.APIVIP, false, true},
+		{"ingressVip", network.IngressVIP, false, true},
+		{"bootstrapIp", network.BootstrapIP, false, false},
+	}
+	for _, host := range hosts {
+		addresses = append(addresses, preflightAddress{host.Name, host.IP, true, false})
+	}
+
+	for _, a := range addresses {
+		if a.ip == "" {
+			if a.required {
+				report.add("address", a.target, StatusFail, "no ip on the baremetal network")
+			}
+			continue
+		}
+
+		ip := net.ParseIP(a.ip)
+		if ip == nil || !baremetalNetwork.Contains(ip) {
+			report.add("address", a.target, StatusFail, fmt.Sprintf("%s is not in %s", a.ip, network.BaremetalIPCIDR))
+			continue
+		}
+
+		collisions := []string{}
+		for _, other := range addresses {
+			if other.target != a.target && other.ip == a.ip && !(a.shareable && other.shareable) {
+				collisions = append(collisions, other.target)
+			}
+		}
+		if len(collisions) > 0 {
+			report.add("address", a.target, StatusFail, fmt.Sprintf("%s is also used by %s", a.ip, strings.Join(collisions, ", ")))
+			continue
+		}
+
+		report.add("address", a.target, StatusPass, a.ip)
+	}
+}
+
+// checks the format and uniqueness of the MAC addresses of the hosts
+func checkMACs(report *Report, hosts []siteconfig.Host) {
+	// a single NIC host can use the same MAC for boot and sdn, so each host
+	// counts once per MAC
+	users := map[string][]string{}
+	for _, host := range hosts {
+		hostMACs := map[string]bool{}
+		for _, mac := range []string{host.BootMACAddress, host.SdnMACAddress} {
+			if parsedMAC, err := net.ParseMAC(mac); err == nil && !hostMACs[parsedMAC.String()] {
+				hostMACs[parsedMAC.String()] = true
+				users[parsedMAC.String()] = append(users[parsedMAC.String()], host.Name)
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		problems := []string{}
+		for _, mac := range []struct {
+			field   string
+			address string
+		}{{"bootMACAddress", host.BootMACAddress}, {"sdnMACAddress", host.SdnMACAddress}} {
+			if mac.address == "" {
+				if mac.field == "bootMACAddress" {
+					problems = append(problems, "no bootMACAddress")
+				}
+				continue
+			}
+
+			parsedMAC, err := net.ParseMAC(mac.address)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("invalid %s %q", mac.field, mac.address))
+				continue
+			}
+
+			if len(users[parsedMAC.String()]) > 1 {
+				problems = append(problems, fmt.Sprintf("%s %s is used by %d hosts: %s", mac.field, parsedMAC, len(users[parsedMAC.String()]), strings.Join(users[parsedMAC.String()], ", ")))
+			}
+		}
+
+		if len(problems) > 0 {
+			report.add("mac", host.Name, StatusFail, strings.Join(problems, "; "))
+		} else {
+			report.add("mac", host.Name, StatusPass, host.BootMACAddress)
+		}
+	}
+}
+
+// checks that the bastion services can be configured, as the deployment does
+func checkBastionConfig(report *Report, pi siteconfig.ProvisioningInfrastructure) {
+	for _, generator := range []struct {
+		service  string
+		generate func(siteconfig.ProvisioningInfrastructure) (bastion.ConfigFiles, error)
+	}{
+		{"dnsmasq", bastion.GenerateDnsmasq},
+		{"haproxy", bastion.GenerateHAProxy},
+		{"matchbox", bastion.GenerateMatchbox},
+	} {
+		_, err := generator.generate(pi)
+		if err != nil {
+			report.add("bastion config", generator.service, StatusFail, err.Error())
+		} else {
+			report.add("bastion config", generator.service, StatusPass, "")
+		}
+	}
+}
+
+// logs into the BMC of every host by reading its power state, in parallel as
+// unreachable BMCs take a while to time out
+func checkBMCs(report *Report, hosts []siteconfig.Host, provider secrets.SecretProviderInterface) {
+	results := make([]Result, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host siteconfig.Host) {
+			defer wg.Done()
+
+			result := Result{Check: "bmc", Target: host.Name}
+
+			if host.BMC.Address == "" {
+				result.Status = StatusWarn
+				result.Detail = "no BMC address, the host can not be power managed"
+				results[i] = result
+				return
+			}
+
+			hostBMC, err := bmc.New(host.BMC, provider)
+			if err == nil {
+				var state bmc.PowerState
+				state, err = hostBMC.PowerStatus()
+				result.Detail = fmt.Sprintf("%s, powered %s", host.BMC.Address, state)
+			}
+
+			result.Status = StatusPass
+			if err != nil {
+				result.Status = StatusFail
+				result.Detail = err.Error()
+			}
+			results[i] = result
+		}(i, host)
+	}
+	wg.Wait()
+
+	report.Results = append(report.Results, results...)
+}
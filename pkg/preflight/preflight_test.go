@@ -0,0 +1,68 @@
+package preflight
+
+import (
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+func TestCheckMACs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		hosts  []siteconfig.Host
+		detail []string
+	}{
+		{
+			"single NIC host",
+			[]siteconfig.Host{
+				{Name: "h1", BootMACAddress: "52:54:00:00:00:01", SdnMACAddress: "52:54:00:00:00:01"},
+				{Name: "h2", BootMACAddress: "52:54:00:00:00:02"},
+			},
+			[]string{"52:54:00:00:00:01", "52:54:00:00:00:02"},
+		},
+		{
+			"MAC shared by hosts",
+			[]siteconfig.Host{
+				{Name: "h1", BootMACAddress: "52:54:00:00:00:01", SdnMACAddress: "52:54:00:00:00:01"},
+				{Name: "h2", BootMACAddress: "52:54:00:00:00:02", SdnMACAddress: "52:54:00:00:00:01"},
+			},
+			[]string{
+				"bootMACAddress 52:54:00:00:00:01 is used by 2 hosts: h1, h2; sdnMACAddress 52:54:00:00:00:01 is used by 2 hosts: h1, h2",
+				"sdnMACAddress 52:54:00:00:00:01 is used by 2 hosts: h1, h2",
+			},
+		},
+		{
+			"host listed twice",
+			[]siteconfig.Host{
+				{Name: "h1", BootMACAddress: "52:54:00:00:00:01"},
+				{Name: "h1", BootMACAddress: "52:54:00:00:00:01"},
+			},
+			[]string{
+				"bootMACAddress 52:54:00:00:00:01 is used by 2 hosts: h1, h1",
+				"bootMACAddress 52:54:00:00:00:01 is used by 2 hosts: h1, h1",
+			},
+		},
+		{
+			"missing and invalid MACs",
+			[]siteconfig.Host{
+				{Name: "h1", SdnMACAddress: "52:54:00"},
+			},
+			[]string{`no bootMACAddress; invalid sdnMACAddress "52:54:00"`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := &Report{}
+			checkMACs(report, tc.hosts)
+
+			if len(report.Results) != len(tc.detail) {
+				t.Fatalf("expected %d results, got %+v", len(tc.detail), report.Results)
+			}
+
+			for i, result := range report.Results {
+				if result.Detail != tc.detail[i] {
+					t.Errorf("expected %s detail %q, got %q", result.Target, tc.detail[i], result.Detail)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package pullsecret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PullSecret : the registry credentials used by the cluster to pull images, in
+// the same format as a docker config.json
+type PullSecret struct {
+	Auths map[string]map[string]interface{} `json:"auths"`
+}
+
+// parses a pull secret, rejecting anything but an auths block, and validates it
+func Parse(content string) (PullSecret, error) {
+	var pullSecret PullSecret
+
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&pullSecret)
+	if err != nil {
+		return pullSecret, fmt.Errorf("PullSecret: Parse: invalid pull secret JSON: %s", err)
+	}
+
+	// only one JSON document is expected, extra content usually comes from a bad copy
+	if decoder.More() {
+		return pullSecret, fmt.Errorf("PullSecret: Parse: unexpected content after the pull secret JSON")
+	}
+
+	// the decoder keeps the last credentials of a registry listed twice
+	duplicates := duplicateRegistries(content)
+	if len(duplicates) > 0 {
+		return pullSecret, fmt.Errorf("PullSecret: Parse: registries listed more than once in auths: %s", strings.Join(duplicates, ", "))
+	}
+
+	err = pullSecret.Validate()
+	if err != nil {
+		return pullSecret, err
+	}
+
+	return pullSecret, nil
+}
+
+// returns the registries listed more than once in the auths of a pull secret
+// that decodes, in order
+func duplicateRegistries(content string) []string {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	listed := map[string]int{}
+	duplicates := []string{}
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return duplicates
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		// field names are matched without case when decoding
+		if name, _ := key.(string); !strings.EqualFold(name, "auths") {
+			var value json.RawMessage
+			if decoder.Decode(&value) != nil {
+				break
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+			continue
+		}
+		for decoder.More() {
+			registry, err := decoder.Token()
+			var value json.RawMessage
+			if err != nil || decoder.Decode(&value) != nil {
+				return duplicates
+			}
+
+			name, _ := registry.(string)
+			listed[name] = listed[name] + 1
+			if listed[name] == 2 {
+				duplicates = append(duplicates, name)
+			}
+		}
+		decoder.Token()
+	}
+
+	sort.Strings(duplicates)
+
+	return duplicates
+}
+
+// returns the registries of the pull secret, in order
+func (ps PullSecret) Registries() []string {
+	registries := make([]string, 0, len(ps.Auths))
+	for registry := range ps.Auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// checks that every registry has an auth in base64 user:pass form, reporting all
+// the problems found
+func (ps PullSecret) Validate() error {
+	if len(ps.Auths) == 0 {
+		return fmt.Errorf("PullSecret: Validate: pull secret does not contain any registry in auths")
+	}
+
+	problems := []string{}
+	for _, registry := range ps.Registries() {
+		if strings.TrimSpace(registry) == "" {
+			problems = append(problems, "empty registry name")
+			continue
+		}
+
+		auth, ok := ps.Auths[registry]["auth"].(string)
+		if !ok || auth == "" {
+			problems = append(problems, fmt.Sprintf("%s: auth is missing or is not a string", registry))
+			continue
+		}
+
+		decodedAuth, err := base64.StdEncoding.DecodeString(auth)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: auth is not valid base64: %s", registry, err))
+			continue
+		}
+
+		parts := strings.SplitN(string(decodedAuth), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			problems = append(problems, fmt.Sprintf("%s: auth must be the base64 encoding of user:password", registry))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("PullSecret: Validate: invalid registry credentials:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	return nil
+}
+
+// adds the credentials of a registry, replacing the existing ones if any
+func (ps *PullSecret) Add(registry string, username string, password string, email string) error {
+	if registry == "" || username == "" || password == "" {
+		return fmt.Errorf("PullSecret: Add: registry, username and password are required")
+	}
+	if strings.Contains(username, ":") {
+		return fmt.Errorf("PullSecret: Add: username cannot contain ':'")
+	}
+
+	if ps.Auths == nil {
+		ps.Auths = map[string]map[string]interface{}{}
+	}
+
+	auth := map[string]interface{}{
+		"auth": base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password))),
+	}
+	if email != "" {
+		auth["email"] = email
+	}
+	ps.Auths[registry] = auth
+
+	return nil
+}
+
+// returns the pull secret as compact JSON in a single line, as expected by
+// install-config.yaml
+func (ps PullSecret) Marshal() (string, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	// keep registry names and passwords untouched
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(ps)
+	if err != nil {
+		return "", fmt.Errorf("PullSecret: Marshal: error encoding pull secret: %s", err)
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
+}
@@ -0,0 +1,175 @@
+package pullsecret
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func encodeAuth(credentials string) string {
+	return base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+func TestParse(t *testing.T) {
+	userAuth := encodeAuth("user:pass")
+	otherAuth := encodeAuth("other:secret:with:colons")
+
+	for _, tc := range []struct {
+		name       string
+		content    string
+		registries []string
+		err        string
+	}{
+		{"valid", `{"auths": {"quay.io": {"auth": "` + userAuth + `", "email": "user@example.com"}, "registry.example.com:5000": {"auth": "` + otherAuth + `"}}}`, []string{"quay.io", "registry.example.com:5000"}, ""},
+		{"trailing newline", `{"auths": {"quay.io": {"auth": "` + userAuth + `"}}}` + "\n", []string{"quay.io"}, ""},
+		{"not JSON", `auths: {}`, nil, "PullSecret: Parse: invalid pull secret JSON: invalid character 'a'"},
+		{"truncated", `{"auths": {"quay.io": {"auth": "` + userAuth + `"}}`, nil, "PullSecret: Parse: invalid pull secret JSON: unexpected EOF"},
+		{"unknown field", `{"auths": {}, "credsStore": "secretservice"}`, nil, `PullSecret: Parse: invalid pull secret JSON: json: unknown field "credsStore"`},
+		{"extra content", `{"auths": {"quay.io": {"auth": "` + userAuth + `"}}} {"auths": {}}`, nil, "PullSecret: Parse: unexpected content after the pull secret JSON"},
+		{"duplicate registry", `{"auths": {"quay.io": {"auth": "` + userAuth + `"}, "cloud.openshift.com": {"auth": "` + userAuth + `"}, "quay.io": {"auth": "` + otherAuth + `"}, "quay.io": {"auth": "` + otherAuth + `"}}}`, nil, "PullSecret: Parse: registries listed more than once in auths: quay.io"},
+		{"duplicate registry in other auths", `{"auths": {"quay.io": {"auth": "` + userAuth + `"}}, "Auths": {"quay.io": {"auth": "` + otherAuth + `"}}}`, nil, "PullSecret: Parse: registries listed more than once in auths: quay.io"},
+		{"no registry", `{"auths": {}}`, nil, "PullSecret: Validate: pull secret does not contain any registry in auths"},
+		{"no auths", `{}`, nil, "PullSecret: Validate: pull secret does not contain any registry in auths"},
+		{"invalid registry", `{"auths": {"quay.io": {"auth": "not base64!"}}}`, nil, "quay.io: auth is not valid base64"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pullSecret, err := Parse(tc.content)
+
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error with %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(pullSecret.Registries(), tc.registries) {
+				t.Errorf("expected registries %v, got %v", tc.registries, pullSecret.Registries())
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		auths    map[string]map[string]interface{}
+		problems []string
+	}{
+		{"valid", map[string]map[string]interface{}{"quay.io": {"auth": encodeAuth("user:pass")}}, nil},
+		{"password with colons", map[string]map[string]interface{}{"quay.io": {"auth": encodeAuth("user:pa:ss")}}, nil},
+		{"no registry", nil, []string{"pull secret does not contain any registry in auths"}},
+		{"empty registry name", map[string]map[string]interface{}{" ": {"auth": encodeAuth("user:pass")}}, []string{"empty registry name"}},
+		{"missing auth", map[string]map[string]interface{}{"quay.io": {"email": "user@example.com"}}, []string{"quay.io: auth is missing or is not a string"}},
+		{"auth not a string", map[string]map[string]interface{}{"quay.io": {"auth": 42.0}}, []string{"quay.io: auth is missing or is not a string"}},
+		{"not base64", map[string]map[string]interface{}{"quay.io": {"auth": "user:pass"}}, []string{"quay.io: auth is not valid base64: illegal base64 data at input byte 4"}},
+		{"not user:pass", map[string]map[string]interface{}{"quay.io": {"auth": encodeAuth("token")}}, []string{"quay.io: auth must be the base64 encoding of user:password"}},
+		{"empty user", map[string]map[string]interface{}{"quay.io": {"auth": encodeAuth(":pass")}}, []string{"quay.io: auth must be the base64 encoding of user:password"}},
+		{"empty password", map[string]map[string]interface{}{"quay.io": {"auth": encodeAuth("user:")}}, []string{"quay.io: auth must be the base64 encoding of user:password"}},
+		{"all problems", map[string]map[string]interface{}{
+			"registry.example.com": {"auth": encodeAuth("token")},
+			"quay.io":              {"auth": "%%%"},
+			"cloud.openshift.com":  {"auth": encodeAuth("user:pass")},
+		}, []string{"quay.io: auth is not valid base64: illegal base64 data at input byte 0", "registry.example.com: auth must be the base64 encoding of user:password"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := PullSecret{Auths: tc.auths}.Validate()
+
+			if len(tc.problems) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			want := "PullSecret: Validate: invalid registry credentials:\n  - " + strings.Join(tc.problems, "\n  - ")
+			if tc.auths == nil {
+				want = "PullSecret: Validate: " + tc.problems[0]
+			}
+			if err == nil || err.Error() != want {
+				t.Errorf("expected\n%s\ngot\n%v", want, err)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		registry string
+		username string
+		password string
+		email    string
+		want     map[string]interface{}
+		err      string
+	}{
+		{"new registry", "registry.example.com:5000", "user", "p&ss<word>", "", map[string]interface{}{"auth": encodeAuth("user:p&ss<word>")}, ""},
+		{"with email", "registry.example.com:5000", "user", "pass", "user@example.com", map[string]interface{}{"auth": encodeAuth("user:pass"), "email": "user@example.com"}, ""},
+		{"existing registry is replaced", "quay.io", "other", "secret", "", map[string]interface{}{"auth": encodeAuth("other:secret")}, ""},
+		{"missing password", "quay.io", "user", "", "", nil, "PullSecret: Add: registry, username and password are required"},
+		{"missing registry", "", "user", "pass", "", nil, "PullSecret: Add: registry, username and password are required"},
+		{"colon in username", "quay.io", "us:er", "pass", "", nil, "PullSecret: Add: username cannot contain ':'"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pullSecret := PullSecret{Auths: map[string]map[string]interface{}{"quay.io": {"auth": encodeAuth("user:pass"), "email": "user@example.com"}}}
+
+			err := pullSecret.Add(tc.registry, tc.username, tc.password, tc.email)
+
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				if len(pullSecret.Auths) != 1 || pullSecret.Auths["quay.io"]["auth"] != encodeAuth("user:pass") {
+					t.Errorf("expected the pull secret to be unchanged, got %v", pullSecret.Auths)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(pullSecret.Auths[tc.registry], tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, pullSecret.Auths[tc.registry])
+			}
+			if err := pullSecret.Validate(); err != nil {
+				t.Errorf("expected the added credentials to be valid, got %s", err)
+			}
+		})
+	}
+
+	// the auths are created on an empty pull secret
+	var pullSecret PullSecret
+	if err := pullSecret.Add("quay.io", "user", "pass", ""); err != nil || len(pullSecret.Auths) != 1 {
+		t.Errorf("expected the registry to be added, got %v %v", pullSecret.Auths, err)
+	}
+}
+
+// the pull secret is marshalled in a single line, without escaping, and parses back
+func TestMarshal(t *testing.T) {
+	pullSecret := PullSecret{}
+	pullSecret.Add("registry.example.com:5000", "user", "p&ss<word>", "")
+	pullSecret.Add("quay.io", "user", "pass", "user@example.com")
+
+	content, err := pullSecret.Marshal()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `{"auths":{"quay.io":{"auth":"` + encodeAuth("user:pass") + `","email":"user@example.com"},"registry.example.com:5000":{"auth":"` + encodeAuth("user:p&ss<word>") + `"}}}`
+	if content != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, content)
+	}
+
+	parsed, err := Parse(content)
+	if err != nil || !reflect.DeepEqual(parsed, pullSecret) {
+		t.Errorf("expected the marshalled pull secret to parse back, got %v %v", parsed, err)
+	}
+
+	// registry names and emails are not escaped
+	pullSecret = PullSecret{Auths: map[string]map[string]interface{}{"registry.example.com/<ns>&x": {"auth": encodeAuth("user:pass")}}}
+	content, _ = pullSecret.Marshal()
+	if !strings.Contains(content, `"registry.example.com/<ns>&x"`) || strings.Contains(content, "\n") {
+		t.Errorf("expected a single unescaped line, got %q", content)
+	}
+}
@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envNameReplacer = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// envSecretProvider reads each secret from an environment variable. The variable
+// name is the secret name upper-cased, with any other character than letters and
+// digits replaced by "_", and prefixed: pull-secret.json is read from KNI_PULL_SECRET_JSON
+type envSecretProvider struct {
+	prefix string
+}
+
+func newEnv(config Config, lookup Lookup) (SecretProviderInterface, error) {
+	prefix := config.Env.Prefix
+	if prefix == "" {
+		prefix = "KNI_"
+	}
+
+	return envSecretProvider{prefix: prefix}, nil
+}
+
+func (esp envSecretProvider) variableName(name string) string {
+	return fmt.Sprintf("%s%s", esp.prefix, envNameReplacer.ReplaceAllString(strings.ToUpper(name), "_"))
+}
+
+func (esp envSecretProvider) GetSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(esp.variableName(name))
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (esp envSecretProvider) Source(name string) (string, error) {
+	variableName := esp.variableName(name)
+
+	if _, ok := os.LookupEnv(variableName); !ok {
+		return "", ErrNotFound
+	}
+
+	return fmt.Sprintf("environment variable %s", variableName), nil
+}
+
+func (esp envSecretProvider) Description() string {
+	return fmt.Sprintf("environment variables prefixed with %s", esp.prefix)
+}
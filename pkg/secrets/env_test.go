@@ -0,0 +1,46 @@
+package secrets
+
+import (
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	t.Setenv("KNI_PULL_SECRET_JSON", `{"auths":{}}`)
+	t.Setenv("TENANT_A_ID_RSA_PUB", "ssh-rsa AAAA tenant-a")
+
+	for _, tc := range []struct {
+		name     string
+		prefix   string
+		secret   string
+		value    string
+		variable string
+	}{
+		{"default prefix", "", "pull-secret.json", `{"auths":{}}`, "KNI_PULL_SECRET_JSON"},
+		{"site prefix", "TENANT_A_", "id_rsa.pub", "ssh-rsa AAAA tenant-a", "TENANT_A_ID_RSA_PUB"},
+		{"missing", "", "id_rsa.pub", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := New(Config{Provider: "env", Env: EnvConfig{Prefix: tc.prefix}}, Lookup{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value, err := provider.GetSecret(tc.secret)
+			source, sourceErr := provider.Source(tc.secret)
+
+			if tc.variable == "" {
+				if err != ErrNotFound || sourceErr != ErrNotFound {
+					t.Errorf("expected %s to be not found, got %v and %v", tc.secret, err, sourceErr)
+				}
+				return
+			}
+
+			if err != nil || value != tc.value {
+				t.Errorf("expected %q, got %q, %v", tc.value, value, err)
+			}
+			if sourceErr != nil || source != "environment variable "+tc.variable {
+				t.Errorf("unexpected source %q, %v", source, sourceErr)
+			}
+		})
+	}
+}
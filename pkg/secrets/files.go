@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// filesSecretProvider reads each secret from a file named after it, taking the
+// first one found in its paths
+type filesSecretProvider struct {
+	paths []string
+}
+
+// an explicit path in the site is used alone. Otherwise secrets are looked up in
+// the site credentials directory, then in the credential set selected by the
+// site, and finally in the global secrets path
+func newFiles(config Config, lookup Lookup) (SecretProviderInterface, error) {
+	if config.Files.Path != "" {
+		return filesSecretProvider{paths: []string{config.Files.Path}}, nil
+	}
+
+	paths := []string{}
+	if lookup.SitePath != "" {
+		paths = append(paths, filepath.Join(lookup.SitePath, CredentialsDirectory))
+	}
+
+	if config.CredentialSet != "" {
+		if lookup.GlobalPath == "" {
+			return nil, fmt.Errorf("Secrets: newFiles: credential set %s selected, but there is no global secrets path", config.CredentialSet)
+		}
+
+		// a missing set is an error, falling back to the global secrets could deploy the site with another tenant credentials
+		setPath := filepath.Join(lookup.GlobalPath, CredentialsDirectory, filepath.Base(config.CredentialSet))
+		info, err := os.Stat(setPath)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("Secrets: newFiles: credential set %s not found, expected a directory at %s", config.CredentialSet, setPath)
+		}
+		paths = append(paths, setPath)
+	}
+
+	if lookup.GlobalPath != "" {
+		paths = append(paths, lookup.GlobalPath)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("Secrets: newFiles: secrets path not provided")
+	}
+
+	return filesSecretProvider{paths: paths}, nil
+}
+
+func (fsp filesSecretProvider) Source(name string) (string, error) {
+	for _, path := range fsp.paths {
+		// secret names cannot escape the secrets directories
+		secretPath := filepath.Join(path, filepath.Base(name))
+
+		_, err := os.Stat(secretPath)
+		if err == nil {
+			return secretPath, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("filesSecretProvider: Source: error checking secret %s: %s", name, err)
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+func (fsp filesSecretProvider) GetSecret(name string) (string, error) {
+	secretPath, err := fsp.Source(name)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(secretPath)
+	if err != nil {
+		return "", fmt.Errorf("filesSecretProvider: GetSecret: error reading secret %s: %s", name, err)
+	}
+
+	return string(content), nil
+}
+
+func (fsp filesSecretProvider) Description() string {
+	return fmt.Sprintf("files in %s", strings.Join(fsp.paths, ", then "))
+}
@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writes the secret files, named by their path relative to root
+func writeTestSecrets(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+
+		err := ioutil.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFilesLookupOrder(t *testing.T) {
+	root := t.TempDir()
+	lookup := Lookup{SitePath: filepath.Join(root, "build/edge"), GlobalPath: filepath.Join(root, "kni")}
+
+	writeTestSecrets(t, root, map[string]string{
+		"build/edge/credentials/pull-secret.json":    "site",
+		"kni/credentials/tenant-a/id_rsa.pub":        "tenant-a",
+		"kni/credentials/tenant-a/dockerconfig.json": "tenant-a",
+		"kni/pull-secret.json":                       "global",
+		"kni/id_rsa.pub":                             "global",
+		"kni/dockerconfig.json":                      "global",
+		"kni/kubeconfighub.json":                     "global",
+		"other/pull-secret.json":                     "other",
+	})
+
+	for _, tc := range []struct {
+		name   string
+		config Config
+		want   map[string]string
+	}{
+		{"site then global", Config{}, map[string]string{"pull-secret.json": "site", "id_rsa.pub": "global", "kubeconfighub.json": "global"}},
+		{"credential set", Config{CredentialSet: "tenant-a"}, map[string]string{"pull-secret.json": "site", "id_rsa.pub": "tenant-a", "dockerconfig.json": "tenant-a", "kubeconfighub.json": "global"}},
+		{"explicit path", Config{Files: FilesConfig{Path: filepath.Join(root, "other")}}, map[string]string{"pull-secret.json": "other", "id_rsa.pub": ""}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := New(tc.config, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range tc.want {
+				value, err := provider.GetSecret(name)
+				if want == "" {
+					if err != ErrNotFound {
+						t.Errorf("expected %s to be not found, got %q, %v", name, value, err)
+					}
+					continue
+				}
+				if err != nil || value != want {
+					t.Errorf("expected %s from %s, got %q, %v", name, want, value, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFilesErrors(t *testing.T) {
+	root := t.TempDir()
+	writeTestSecrets(t, root, map[string]string{"kni/pull-secret.json": "global", "secret": "outside"})
+
+	// a missing credential set never falls back to the global secrets
+	_, err := New(Config{CredentialSet: "tenant-b"}, Lookup{GlobalPath: filepath.Join(root, "kni")})
+	if err == nil || !strings.Contains(err.Error(), "credential set tenant-b not found, expected a directory at "+filepath.Join(root, "kni/credentials/tenant-b")) {
+		t.Errorf("expected a missing credential set to fail, got %v", err)
+	}
+
+	_, err = New(Config{}, Lookup{})
+	if err == nil || !strings.Contains(err.Error(), "secrets path not provided") {
+		t.Errorf("expected no path to fail, got %v", err)
+	}
+
+	provider, err := New(Config{}, Lookup{GlobalPath: filepath.Join(root, "kni")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = provider.GetSecret("../secret")
+	if err != ErrNotFound {
+		t.Errorf("expected secret names not to escape the secrets directories, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// kubernetesSecretProvider reads the secrets as keys of a Kubernetes Secret,
+// using the oc or kubectl binary
+type kubernetesSecretProvider struct {
+	name       string
+	namespace  string
+	kubeconfig string
+	binary     string
+	keys       map[string]string // keys of the Secret, fetched on first use
+}
+
+func newKubernetes(config Config, lookup Lookup) (SecretProviderInterface, error) {
+	if config.Kubernetes.Name == "" {
+		return nil, fmt.Errorf("Secrets: newKubernetes: kubernetes secret name not provided")
+	}
+
+	namespace := config.Kubernetes.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	binary := config.Kubernetes.Binary
+	if binary == "" {
+		binary = "oc"
+	}
+
+	return &kubernetesSecretProvider{
+		name:       config.Kubernetes.Name,
+		namespace:  namespace,
+		kubeconfig: config.Kubernetes.Kubeconfig,
+		binary:     binary,
+	}, nil
+}
+
+// reads the whole Secret from the cluster
+func (ksp *kubernetesSecretProvider) fetch() error {
+	cmd := exec.Command(ksp.binary, "get", "secret", ksp.name, "-n", ksp.namespace, "-o", "json")
+	cmd.Env = os.Environ()
+	if ksp.kubeconfig != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("KUBECONFIG=%s", ksp.kubeconfig))
+	}
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		if strings.Contains(errb.String(), "NotFound") {
+			ksp.keys = map[string]string{}
+			return nil
+		}
+		return fmt.Errorf("%s - %s", err, strings.TrimSpace(errb.String()))
+	}
+
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+
+	err = json.Unmarshal(outb.Bytes(), &secret)
+
+	if err != nil {
+		return fmt.Errorf("error parsing secret: %s", err)
+	}
+
+	keys := map[string]string{}
+	for key, encodedValue := range secret.Data {
+		value, err := base64.StdEncoding.DecodeString(encodedValue)
+
+		if err != nil {
+			return fmt.Errorf("error decoding key %s: %s", key, err)
+		}
+
+		keys[key] = string(value)
+	}
+
+	ksp.keys = keys
+
+	return nil
+}
+
+func (ksp *kubernetesSecretProvider) GetSecret(name string) (string, error) {
+	if ksp.keys == nil {
+		err := ksp.fetch()
+
+		if err != nil {
+			return "", fmt.Errorf("kubernetesSecretProvider: GetSecret: error reading secret %s/%s: %s", ksp.namespace, ksp.name, err)
+		}
+	}
+
+	value, ok := ksp.keys[name]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (ksp *kubernetesSecretProvider) Source(name string) (string, error) {
+	_, err := ksp.GetSecret(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("key %s of kubernetes secret %s/%s", name, ksp.namespace, ksp.name), nil
+}
+
+func (ksp *kubernetesSecretProvider) Description() string {
+	return fmt.Sprintf("kubernetes secret %s/%s", ksp.namespace, ksp.name)
+}
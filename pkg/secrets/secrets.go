@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CredentialsDirectory : directory holding the credentials of a site inside its
+// build path, and the named credential sets inside the global secrets path
+const CredentialsDirectory = "credentials"
+
+// ErrNotFound is returned by providers when a secret does not exist, so callers
+// can tell optional secrets apart from actual errors
+var ErrNotFound = errors.New("secret not found")
+
+// FilesConfig : settings for the local files provider
+type FilesConfig struct {
+	Path string `yaml:"path,omitempty"` // directory holding one file per secret
+}
+
+// EnvConfig : settings for the environment variables provider
+type EnvConfig struct {
+	Prefix string `yaml:"prefix,omitempty"` // prefix of the variables, KNI_ by default
+}
+
+// VaultConfig : settings for the HashiCorp Vault KV provider. The token is never
+// read from the site, it is taken from the VAULT_TOKEN environment variable
+type VaultConfig struct {
+	Address   string `yaml:"address,omitempty"`   // VAULT_ADDR is used if not set
+	Mount     string `yaml:"mount,omitempty"`     // KV engine mount, secret by default
+	Path      string `yaml:"path"`                // path of the secret holding the site keys
+	KVVersion int    `yaml:"kvVersion,omitempty"` // 1 or 2, 2 by default
+}
+
+// KubernetesConfig : settings for the Kubernetes Secret provider
+type KubernetesConfig struct {
+	Name       string `yaml:"name"`                 // name of the Secret holding the site keys
+	Namespace  string `yaml:"namespace,omitempty"`  // default by default
+	Kubeconfig string `yaml:"kubeconfig,omitempty"` // KUBECONFIG is used if not set
+	Binary     string `yaml:"binary,omitempty"`     // oc or kubectl binary, oc by default
+}
+
+// Names : the names of the secrets used by a site, as known by the provider
+type Names struct {
+	PullSecret    string `yaml:"pullSecret,omitempty"`
+	SSHPublicKey  string `yaml:"sshPublicKey,omitempty"`
+	DockerConfig  string `yaml:"dockerConfig,omitempty"`
+	KubeconfigHub string `yaml:"kubeconfigHub,omitempty"`
+	GithubSecret  string `yaml:"githubSecret,omitempty"`
+}
+
+// Config : the secrets block of a site-config.yaml, that selects the provider
+// and references the secrets by name
+type Config struct {
+	Provider      string           `yaml:"provider,omitempty"`      // files (default), env, vault or kubernetes
+	CredentialSet string           `yaml:"credentialSet,omitempty"` // named set of files in credentials/<name> of the global secrets path
+	Files         FilesConfig      `yaml:"files,omitempty"`
+	Env           EnvConfig        `yaml:"env,omitempty"`
+	Vault         VaultConfig      `yaml:"vault,omitempty"`
+	Kubernetes    KubernetesConfig `yaml:"kubernetes,omitempty"`
+	Names         Names            `yaml:"names,omitempty"`
+}
+
+// Lookup : the local directories where the files provider looks for secrets
+type Lookup struct {
+	SitePath   string // build path of the site, whose credentials directory is checked first
+	GlobalPath string // secrets shared by all the sites, $HOME/.kni by default
+}
+
+type SecretProviderInterface interface {
+	GetSecret(name string) (string, error) // Retrieve a secret by name, returning ErrNotFound if missing
+	Source(name string) (string, error)    // Where a secret is read from, returning ErrNotFound if missing
+	Description() string                   // Human readable description of where secrets come from
+}
+
+var (
+	secretProviderConstructors map[string]func(Config, Lookup) (SecretProviderInterface, error)
+)
+
+func init() {
+	// Add new secret providers here
+	secretProviderConstructors = map[string]func(Config, Lookup) (SecretProviderInterface, error){}
+	secretProviderConstructors["files"] = newFiles
+	secretProviderConstructors["env"] = newEnv
+	secretProviderConstructors["vault"] = newVault
+	secretProviderConstructors["kubernetes"] = newKubernetes
+}
+
+// Generates a new secret provider. lookup holds the directories used by the
+// files provider when the site does not set a path
+func New(config Config, lookup Lookup) (SecretProviderInterface, error) {
+	providerType := config.Provider
+	if providerType == "" {
+		providerType = "files"
+	}
+
+	constructor := secretProviderConstructors[providerType]
+
+	if constructor == nil {
+		return nil, fmt.Errorf("Secrets: New: unknown secret provider '%s'", providerType)
+	}
+
+	return constructor(config, lookup)
+}
+
+// returns the secret names, defaulting to the names of the files in $HOME/.kni
+func (c Config) SecretNames() Names {
+	names := c.Names
+	if names.PullSecret == "" {
+		names.PullSecret = "pull-secret.json"
+	}
+	if names.SSHPublicKey == "" {
+		names.SSHPublicKey = "id_rsa.pub"
+	}
+	if names.DockerConfig == "" {
+		names.DockerConfig = "dockerconfig.json"
+	}
+	if names.KubeconfigHub == "" {
+		names.KubeconfigHub = "kubeconfighub.json"
+	}
+	if names.GithubSecret == "" {
+		names.GithubSecret = "githubsecret.json"
+	}
+	return names
+}
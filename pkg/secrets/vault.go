@@ -0,0 +1,174 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultSecretProvider reads the secrets as keys of a secret stored in a
+// HashiCorp Vault KV engine, using its HTTP API
+type vaultSecretProvider struct {
+	address   string
+	token     string
+	mount     string
+	path      string
+	kvVersion int
+	client    *http.Client
+	keys      map[string]interface{} // keys of the secret, fetched on first use
+}
+
+func newVault(config Config, lookup Lookup) (SecretProviderInterface, error) {
+	address := config.Vault.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+
+	if address == "" {
+		return nil, fmt.Errorf("Secrets: newVault: vault address not provided, set it in site-config.yaml or in VAULT_ADDR")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+
+	if token == "" {
+		return nil, fmt.Errorf("Secrets: newVault: VAULT_TOKEN environment variable not set")
+	}
+
+	if config.Vault.Path == "" {
+		return nil, fmt.Errorf("Secrets: newVault: vault secret path not provided")
+	}
+
+	mount := config.Vault.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+
+	kvVersion := config.Vault.KVVersion
+	if kvVersion == 0 {
+		kvVersion = 2
+	}
+
+	if kvVersion != 1 && kvVersion != 2 {
+		return nil, fmt.Errorf("Secrets: newVault: unsupported KV engine version %d", kvVersion)
+	}
+
+	return &vaultSecretProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		mount:     strings.Trim(mount, "/"),
+		path:      strings.Trim(config.Vault.Path, "/"),
+		kvVersion: kvVersion,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// reads the whole secret from vault
+func (vsp *vaultSecretProvider) fetch() error {
+	url := fmt.Sprintf("%s/v1/%s/%s", vsp.address, vsp.mount, vsp.path)
+	if vsp.kvVersion == 2 {
+		url = fmt.Sprintf("%s/v1/%s/data/%s", vsp.address, vsp.mount, vsp.path)
+	}
+
+	request, err := http.NewRequest("GET", url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("X-Vault-Token", vsp.token)
+
+	response, err := vsp.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	// a missing secret usually comes from a wrong mount, path or KV version, it is
+	// not reported as a missing key, that optional secrets would silently skip
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("vault secret does not exist at %s, check the mount, path and kvVersion of the site secrets", url)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	// KV version 1 returns the keys in data, version 2 in data.data
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	err = json.Unmarshal(body, &secret)
+
+	if err != nil {
+		return fmt.Errorf("error parsing vault response: %s", err)
+	}
+
+	keys := secret.Data
+	if vsp.kvVersion == 2 {
+		keys, _ = secret.Data["data"].(map[string]interface{})
+	}
+
+	if keys == nil {
+		keys = map[string]interface{}{}
+	}
+
+	vsp.keys = keys
+
+	return nil
+}
+
+func (vsp *vaultSecretProvider) GetSecret(name string) (string, error) {
+	if vsp.keys == nil {
+		err := vsp.fetch()
+
+		if err != nil {
+			return "", fmt.Errorf("vaultSecretProvider: GetSecret: error reading %s/%s: %s", vsp.mount, vsp.path, err)
+		}
+	}
+
+	value, ok := vsp.keys[name]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	if stringValue, ok := value.(string); ok {
+		return stringValue, nil
+	}
+
+	// structured values, such as a pull secret stored as JSON, are returned as JSON
+	jsonValue, err := json.Marshal(value)
+
+	if err != nil {
+		return "", fmt.Errorf("vaultSecretProvider: GetSecret: error encoding secret %s: %s", name, err)
+	}
+
+	return string(jsonValue), nil
+}
+
+func (vsp *vaultSecretProvider) Source(name string) (string, error) {
+	_, err := vsp.GetSecret(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("key %s of vault secret %s/%s", name, vsp.mount, vsp.path), nil
+}
+
+func (vsp *vaultSecretProvider) Description() string {
+	return fmt.Sprintf("vault secret %s/%s at %s", vsp.mount, vsp.path, vsp.address)
+}
@@ -0,0 +1,235 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testVaultKeys = map[string]interface{}{
+	"pull-secret.json": map[string]interface{}{"auths": map[string]interface{}{"quay.io": map[string]interface{}{"auth": "dXNlcjpwYXNz"}}},
+	"id_rsa.pub":       "ssh-rsa AAAAB3NzaC1yc2E edge",
+}
+
+// fake Vault serving the secret edge with the test keys, from a KV version 1
+// engine mounted at kv1 and a version 2 engine mounted at secret
+func newFakeVault(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/kv1/edge":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": testVaultKeys})
+		case "/v1/secret/data/edge":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": testVaultKeys, "metadata": map[string]interface{}{"version": 1}}})
+		case "/v1/secret/data/broken":
+			w.Write([]byte("{"))
+		default:
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestVault(t *testing.T, address string, mount string, path string, kvVersion int) SecretProviderInterface {
+	t.Helper()
+
+	t.Setenv("VAULT_TOKEN", "root")
+
+	provider, err := New(Config{Provider: "vault", Vault: VaultConfig{Address: address, Mount: mount, Path: path, KVVersion: kvVersion}}, Lookup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+// checks the keys of the edge secret, the JSON values being returned as JSON
+func checkVaultSecrets(t *testing.T, provider SecretProviderInterface) {
+	t.Helper()
+
+	pullSecret, err := provider.GetSecret("pull-secret.json")
+	if err != nil || pullSecret != `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"}}}` {
+		t.Errorf("expected the pull secret as JSON, got %q, %v", pullSecret, err)
+	}
+
+	sshKey, err := provider.GetSecret("id_rsa.pub")
+	if err != nil || sshKey != "ssh-rsa AAAAB3NzaC1yc2E edge" {
+		t.Errorf("expected the SSH key, got %q, %v", sshKey, err)
+	}
+
+	_, err = provider.GetSecret("githubsecret.json")
+	if err != ErrNotFound {
+		t.Errorf("expected a missing key to be not found, got %v", err)
+	}
+}
+
+func TestVault(t *testing.T) {
+	server := newFakeVault(t)
+
+	for _, tc := range []struct {
+		name      string
+		mount     string
+		kvVersion int
+	}{
+		{"KV version 1", "kv1", 1},
+		{"KV version 2", "", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newTestVault(t, server.URL+"/", tc.mount, "/edge/", tc.kvVersion)
+			checkVaultSecrets(t, provider)
+
+			source, err := provider.Source("id_rsa.pub")
+			mount := map[string]string{"kv1": "kv1", "": "secret"}[tc.mount]
+			if err != nil || source != fmt.Sprintf("key id_rsa.pub of vault secret %s/edge", mount) {
+				t.Errorf("unexpected source %q, %v", source, err)
+			}
+		})
+	}
+}
+
+func TestVaultErrors(t *testing.T) {
+	server := newFakeVault(t)
+
+	// a secret that does not exist is a configuration error, not a missing key
+	for _, tc := range []struct {
+		name      string
+		mount     string
+		path      string
+		kvVersion int
+		url       string
+	}{
+		{"wrong path", "", "other-site", 2, "/v1/secret/data/other-site"},
+		{"wrong mount", "kv", "edge", 2, "/v1/kv/data/edge"},
+		{"wrong KV version", "secret", "edge", 1, "/v1/secret/edge"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newTestVault(t, server.URL, tc.mount, tc.path, tc.kvVersion).GetSecret("id_rsa.pub")
+			want := fmt.Sprintf("vault secret does not exist at %s%s, check the mount, path and kvVersion of the site secrets", server.URL, tc.url)
+			if err == nil || err == ErrNotFound || !strings.HasSuffix(err.Error(), want) {
+				t.Errorf("expected an error ending with %q, got %v", want, err)
+			}
+		})
+	}
+
+	_, err := newTestVault(t, server.URL, "", "broken", 2).GetSecret("id_rsa.pub")
+	if err == nil || !strings.Contains(err.Error(), "error reading secret/broken: error parsing vault response") {
+		t.Errorf("expected an invalid response to fail, got %v", err)
+	}
+
+	provider := newTestVault(t, server.URL, "", "edge", 2)
+	provider.(*vaultSecretProvider).token = "other"
+	_, err = provider.GetSecret("id_rsa.pub")
+	if err == nil || !strings.Contains(err.Error(), "vault returned 403 Forbidden: {\"errors\":[\"permission denied\"]}") {
+		t.Errorf("expected a denied read to fail, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		config VaultConfig
+		token  string
+		err    string
+	}{
+		{"no address", VaultConfig{Path: "edge"}, "root", "vault address not provided"},
+		{"no token", VaultConfig{Address: server.URL, Path: "edge"}, "", "VAULT_TOKEN environment variable not set"},
+		{"no path", VaultConfig{Address: server.URL}, "root", "vault secret path not provided"},
+		{"unsupported version", VaultConfig{Address: server.URL, Path: "edge", KVVersion: 3}, "root", "unsupported KV engine version 3"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("VAULT_ADDR", "")
+			t.Setenv("VAULT_TOKEN", tc.token)
+
+			_, err := New(Config{Provider: "vault", Vault: tc.config}, Lookup{})
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// runs the provider against a Vault dev server, that mounts a KV version 2
+// engine at secret. A version 1 engine is mounted at kv1
+func TestVaultDevServer(t *testing.T) {
+	vault, err := exec.LookPath("vault")
+	if err != nil {
+		t.Skip("vault not found")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenAddress := listener.Addr().String()
+	listener.Close()
+
+	server := exec.Command(vault, "server", "-dev", "-dev-root-token-id=root", "-dev-listen-address="+listenAddress)
+	err = server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		server.Process.Kill()
+		server.Wait()
+	}()
+
+	address := "http://" + listenAddress
+	request := func(method string, path string, body interface{}) error {
+		content, _ := json.Marshal(body)
+		request, _ := http.NewRequest(method, address+path, bytes.NewReader(content))
+		request.Header.Set("X-Vault-Token", "root")
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		if response.StatusCode >= 300 {
+			return errors.New(response.Status)
+		}
+		return nil
+	}
+
+	for start := time.Now(); request("GET", "/v1/sys/health", nil) != nil; time.Sleep(100 * time.Millisecond) {
+		if time.Since(start) > 30*time.Second {
+			t.Fatal("the vault dev server did not start")
+		}
+	}
+
+	for _, step := range []struct {
+		path string
+		body interface{}
+	}{
+		{"/v1/sys/mounts/kv1", map[string]interface{}{"type": "kv", "options": map[string]string{"version": "1"}}},
+		{"/v1/kv1/edge", testVaultKeys},
+		{"/v1/secret/data/edge", map[string]interface{}{"data": testVaultKeys}},
+	} {
+		err := request("POST", step.path, step.body)
+		if err != nil {
+			t.Fatalf("error writing %s: %s", step.path, err)
+		}
+	}
+
+	checkVaultSecrets(t, newTestVault(t, address, "kv1", "edge", 1))
+	checkVaultSecrets(t, newTestVault(t, address, "secret", "edge", 2))
+
+	_, err = newTestVault(t, address, "secret", "other-site", 2).GetSecret("id_rsa.pub")
+	if err == nil || !strings.Contains(err.Error(), "vault secret does not exist at "+address+"/v1/secret/data/other-site") {
+		t.Errorf("expected a missing secret to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package site
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"gerrit.akraino.org/kni/installer/pkg/bastion"
+	"gerrit.akraino.org/kni/installer/pkg/container"
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+// returns the container runtime with that name, detecting it if empty
+func bastionRuntime(runtimeName string) container.RuntimeInterface {
+	runtime, err := container.New(runtimeName)
+	if err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
+	return runtime
+}
+
+// returns the bastion services with those names, or all of them
+func bastionServices(serviceNames []string) []bastion.Service {
+	services, err := bastion.FindServices(serviceNames)
+	if err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
+	return services
+}
+
+// shows the state of the bastion service containers, failing if any of them is
+// not running and healthy
+func (s Site) BastionStatus(runtimeName string) {
+	runtime := bastionRuntime(runtimeName)
+
+	statuses, err := bastion.Status(runtime, bastion.Services)
+	if err != nil {
+		log.Fatalf("Error reading the status of the bastion services: %s\n", err)
+	}
+
+	fmt.Printf("Bastion services of site %s, with %s:\n\n", s.siteName, runtime.Name())
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tCONTAINER\tSTATUS\tDETAIL")
+
+	unhealthy := 0
+	for _, status := range statuses {
+		result := "healthy"
+		if !status.Healthy {
+			result = "unhealthy"
+			unhealthy++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", status.Name, status.Container, result, status.Detail)
+	}
+	tw.Flush()
+
+	if unhealthy > 0 {
+		log.Fatalf("%d bastion service(s) not healthy, see knictl bastion logs %s <service>\n", unhealthy, s.siteName)
+	}
+}
+
+// starts the containers of the bastion services, failing if any of them is not
+// healthy afterwards. Stopped containers are started again, and the missing ones
+// are created with that runtime by the scripts of the baremetal automation, like
+// deploy_masters does, from the configuration it generated
+func (s Site) BastionStart(runtimeName string, serviceNames []string) {
+	runtime := bastionRuntime(runtimeName)
+	automationRepoPath := fmt.Sprintf("%s/%s/baremetal_automation", s.buildPath, s.siteName)
+
+	services := bastionServices(serviceNames)
+	for _, service := range services {
+		state, err := runtime.Inspect(service.Container)
+		if err != nil {
+			log.Fatalf("Error starting bastion service %s: %s\n", service.Name, err)
+		}
+
+		if state.Exists {
+			err = runtime.Start(service.Container)
+			if err != nil {
+				log.Fatalf("Error starting bastion service %s: %s\n", service.Name, err)
+			}
+			log.Printf("Bastion service %s started\n", service.Name)
+			continue
+		}
+
+		if _, err := os.Stat(automationRepoPath); err != nil {
+			log.Fatalf("Error, container %s of bastion service %s does not exist, and there is no baremetal automation to create it at %s, run deploy_masters first\n", service.Container, service.Name, automationRepoPath)
+		}
+
+		for _, args := range service.StartCommands() {
+			log.Printf("Running %s %s to create bastion service %s\n", service.Script, args[0], service.Name)
+			utils.ExecuteCommand(automationRepoPath, bastion.ScriptEnv(runtime), true, true, fmt.Sprintf("%s/scripts/%s", automationRepoPath, service.Script), args...)
+		}
+		log.Printf("Bastion service %s created and started\n", service.Name)
+	}
+
+	// a container can exit right after starting, when its configuration is wrong
+	err := bastion.CheckServices(runtime, services)
+	if err != nil {
+		log.Fatalf("Error: %s, see knictl bastion logs %s <service>\n", err, s.siteName)
+	}
+}
+
+// stops the containers of the bastion services, in reverse start order, and
+// removes them if remove is set, so that they can be created again. The other
+// services are still stopped when one of them fails
+func (s Site) BastionStop(runtimeName string, serviceNames []string, remove bool) {
+	runtime := bastionRuntime(runtimeName)
+
+	failed := 0
+	services := bastionServices(serviceNames)
+	for i := len(services) - 1; i >= 0; i-- {
+		service := services[i]
+
+		err := runtime.Stop(service.Container)
+		if err == nil && remove {
+			err = runtime.Remove(service.Container)
+		}
+		if err != nil {
+			log.Printf("Error stopping bastion service %s: %s\n", service.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("Bastion service %s stopped\n", service.Name)
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d bastion service(s) could not be stopped\n", failed)
+	}
+}
+
+// writes the logs of a bastion service, the last tail lines only if tail is
+// positive, following them if follow is set
+func (s Site) BastionLogs(runtimeName string, serviceName string, tail int, follow bool) {
+	runtime := bastionRuntime(runtimeName)
+
+	service := bastionServices([]string{serviceName})[0]
+
+	err := runtime.Logs(service.Container, tail, follow, os.Stdout)
+	if err != nil {
+		log.Fatalf("Error reading the logs of bastion service %s: %s\n", service.Name, err)
+	}
+}
@@ -0,0 +1,159 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"gerrit.akraino.org/kni/installer/pkg/pullsecret"
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+// returns the site config and the secret provider of the site. Local files are
+// looked up in the site credentials directory before the global ones
+func (s Site) getSecretProvider() (siteconfig.SiteConfig, secrets.SecretProviderInterface) {
+	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+	siteConfig, err := siteconfig.Load(fmt.Sprintf("%s/site/00_install-config/site-config.yaml", siteBuildPath))
+	if err != nil {
+		log.Fatalf("Error reading site config: %s\n", err)
+	}
+
+	provider, err := secrets.New(siteConfig.Secrets, secrets.Lookup{SitePath: siteBuildPath, GlobalPath: s.buildPath})
+	if err != nil {
+		log.Fatalf("Error creating secret provider: %s\n", err)
+	}
+
+	return siteConfig, provider
+}
+
+// shows where each credential used by the site is read from
+func (s Site) ShowCredentials() {
+	siteConfig, provider := s.getSecretProvider()
+
+	fmt.Printf("Credentials of site %s are read from %s\n\n", s.siteName, provider.Description())
+
+	names := siteConfig.Secrets.SecretNames()
+	credentials := []struct {
+		description string
+		name        string
+	}{
+		{"pull secret", names.PullSecret},
+		{"ssh public key", names.SSHPublicKey},
+		{"docker config", names.DockerConfig},
+		{"hub kubeconfig", names.KubeconfigHub},
+		{"github secret", names.GithubSecret},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CREDENTIAL\tNAME\tSOURCE")
+	for _, credential := range credentials {
+		source, err := provider.Source(credential.name)
+		if err == secrets.ErrNotFound {
+			source = "not found"
+		} else if err != nil {
+			source = fmt.Sprintf("error: %s", err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", credential.description, credential.name, source)
+	}
+	w.Flush()
+}
+
+// returns the pull secret file of the site, that mirror registry credentials
+// are merged into. Only available for sites using the files provider. Unless the
+// site sets its own files path, the pull secret resolved from a credential set
+// or the global secrets is first copied to the site credentials directory, so
+// that the other sites sharing it are left untouched
+func (s Site) GetPullSecretPath() string {
+	siteConfig, provider := s.getSecretProvider()
+
+	if siteConfig.Secrets.Provider != "" && siteConfig.Secrets.Provider != "files" {
+		log.Fatalf("Error, the pull secret of site %s is read from %s, registry credentials need to be merged there\n", s.siteName, provider.Description())
+	}
+
+	names := siteConfig.Secrets.SecretNames()
+	pullSecretPath, err := provider.Source(names.PullSecret)
+	if err != nil {
+		log.Fatalf("Error, no pull secret %s found in %s: %s\n", names.PullSecret, provider.Description(), err)
+	}
+
+	if siteConfig.Secrets.Files.Path != "" {
+		return pullSecretPath
+	}
+
+	sitePullSecretPath := filepath.Join(s.buildPath, s.siteName, secrets.CredentialsDirectory, filepath.Base(names.PullSecret))
+	if pullSecretPath != sitePullSecretPath {
+		content, err := ioutil.ReadFile(pullSecretPath)
+		if err != nil {
+			log.Fatalf("Error reading pull secret: %s\n", err)
+		}
+
+		err = utils.WriteSecretFile(sitePullSecretPath, content)
+		if err != nil {
+			log.Fatalf("Error writing pull secret: %s\n", err)
+		}
+
+		log.Printf("Copied pull secret %s to %s, the credentials of site %s are merged there\n", pullSecretPath, sitePullSecretPath, s.siteName)
+	}
+
+	return sitePullSecretPath
+}
+
+// parses and validates the pull secret of the site, so a malformed one is caught
+// before the installer runs
+func (s Site) ValidatePullSecret() {
+	siteConfig, provider := s.getSecretProvider()
+
+	names := siteConfig.Secrets.SecretNames()
+	content, err := provider.GetSecret(names.PullSecret)
+	if err != nil {
+		log.Fatalf("Error, no valid pull secret %s found in %s: %s\n", names.PullSecret, provider.Description(), err)
+	}
+
+	ps, err := pullsecret.Parse(content)
+	if err != nil {
+		log.Fatalf("Error, invalid pull secret %s in %s: %s\n", names.PullSecret, provider.Description(), err)
+	}
+
+	log.Printf("Pull secret %s is valid, with credentials for %s\n", names.PullSecret, strings.Join(ps.Registries(), ", "))
+}
+
+// merges the credentials of a registry, usually a mirror, into a pull secret file
+func AddPullSecretAuth(pullSecretPath string, registry string, username string, password string, email string) {
+	content, err := ioutil.ReadFile(pullSecretPath)
+	if err != nil {
+		log.Fatalf("Error reading pull secret: %s\n", err)
+	}
+
+	ps, err := pullsecret.Parse(string(content))
+	if err != nil {
+		log.Fatalf("Error, invalid pull secret %s: %s\n", pullSecretPath, err)
+	}
+
+	_, replaced := ps.Auths[registry]
+	err = ps.Add(registry, username, password, email)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	mergedContent, err := ps.Marshal()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	err = utils.WriteSecretFile(pullSecretPath, []byte(mergedContent))
+	if err != nil {
+		log.Fatalf("Error writing pull secret: %s\n", err)
+	}
+
+	if replaced {
+		log.Printf("Replaced credentials for %s in %s\n", registry, pullSecretPath)
+	} else {
+		log.Printf("Added credentials for %s to %s\n", registry, pullSecretPath)
+	}
+}
@@ -0,0 +1,115 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/bmc"
+	"gerrit.akraino.org/kni/installer/pkg/preflight"
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+// power actions of the host power command
+var hostPowerActions = []string{"on", "off", "cycle", "status"}
+
+// returns a host of the provisioning infrastructure of the site, by name
+func findHost(siteConfig siteconfig.SiteConfig, hostName string) (siteconfig.Host, error) {
+	if siteConfig.ProvisioningInfrastructure == nil {
+		return siteconfig.Host{}, fmt.Errorf("site config has no provisioningInfrastructure hosts")
+	}
+
+	hosts := siteConfig.ProvisioningInfrastructure.Hosts
+	for _, host := range append(append([]siteconfig.Host{}, hosts.Masters...), hosts.Workers...) {
+		if host.Name == hostName {
+			return host, nil
+		}
+	}
+
+	return siteconfig.Host{}, fmt.Errorf("host %s not found in the site config", hostName)
+}
+
+// runs a power action on a host of the site through its BMC. If pxe is set, the
+// host boots from the network once, when it is powered on or cycled
+func (s Site) HostPower(hostName string, action string, pxe bool) {
+	validAction := false
+	for _, hostPowerAction := range hostPowerActions {
+		validAction = validAction || action == hostPowerAction
+	}
+	if !validAction {
+		log.Fatalf("Error: invalid power action %s, valid actions are on, off, cycle and status\n", action)
+	}
+	if pxe && action != "on" && action != "cycle" {
+		log.Fatalln("Error: PXE boot can only be requested when powering on or cycling a host")
+	}
+
+	siteConfig, provider := s.getSecretProvider()
+
+	host, err := findHost(siteConfig, hostName)
+	if err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
+	hostBMC, err := bmc.New(host.BMC, provider)
+	if err != nil {
+		log.Fatalf("Error accessing the BMC of host %s: %s\n", hostName, err)
+	}
+
+	if pxe {
+		err = hostBMC.SetPXEBootOnce()
+		if err != nil {
+			log.Fatalf("Error setting PXE boot on host %s: %s\n", hostName, err)
+		}
+	}
+
+	switch action {
+	case "on":
+		err = hostBMC.PowerOn()
+	case "off":
+		err = hostBMC.PowerOff()
+	case "cycle":
+		err = hostBMC.PowerCycle()
+	}
+	if err != nil {
+		log.Fatalf("Error powering %s host %s: %s\n", action, hostName, err)
+	}
+
+	state, err := hostBMC.PowerStatus()
+	if err != nil {
+		log.Fatalf("Error reading the power state of host %s: %s\n", hostName, err)
+	}
+
+	fmt.Printf("Host %s is powered %s\n", hostName, state)
+}
+
+// checks the provisioning infrastructure of the site before deploying it, printing
+// a report of all the checks. The site config problems are reported with the other
+// checks, instead of stopping them
+func (s Site) Preflight() {
+	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/site/00_install-config/site-config.yaml", siteBuildPath))
+	if err != nil {
+		log.Fatalf("Error reading site config: %s\n", err)
+	}
+
+	siteConfig, validationErr := siteconfig.Parse(content)
+	if _, ok := validationErr.(siteconfig.ValidationError); validationErr != nil && !ok {
+		log.Fatalf("Error parsing site config: %s\n", validationErr)
+	}
+
+	provider, err := secrets.New(siteConfig.Secrets, secrets.Lookup{SitePath: siteBuildPath, GlobalPath: s.buildPath})
+	if err != nil {
+		log.Printf("WARNING: error creating secret provider, BMC credentials can not be read: %s\n", err)
+		provider = nil
+	}
+
+	report := preflight.Run(siteConfig, validationErr, provider)
+	report.Print(os.Stdout)
+
+	if report.Failed() > 0 {
+		log.Fatalf("Preflight of site %s failed, %d check(s) failed\n", s.siteName, report.Failed())
+	}
+}
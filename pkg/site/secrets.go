@@ -0,0 +1,255 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// value used to replace the secrets that are redacted in place
+const redactedValue = "REDACTED"
+
+// directories of a site, relative to its build path, that can hold credentials
+var secretDirectories = []string{
+	"automation",
+	"generated_assets",
+	"final_manifests",
+	"baremetal_automation/cluster",
+	"baremetal_automation/ocp",
+	"libvirt_automation/ocp",
+	"terraform_plans",
+}
+
+// files that hold cluster credentials, still needed to operate the cluster
+var credentialFiles = []string{
+	"profile.env",
+	"kubeconfig",
+	"kubeadmin-password",
+}
+
+// once installation is done, removes or redacts the secrets stored in the site
+// build directory. If an encryption key file is given, secrets are encrypted at
+// rest instead, so they can be restored later
+func (s Site) ScrubSecrets(encryptionKeyFile string, removeCredentials bool) {
+	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+	if _, err := os.Stat(siteBuildPath); err != nil {
+		log.Fatalf("Error: site build directory %s does not exist\n", siteBuildPath)
+	}
+
+	var key []byte
+	if len(encryptionKeyFile) > 0 {
+		var err error
+		key, err = utils.ReadEncryptionKey(encryptionKeyFile)
+		if err != nil {
+			log.Fatalf("Error reading encryption key: %s\n", err)
+		}
+	}
+
+	// collect all the candidate files
+	files := []string{fmt.Sprintf("%s/profile.env", siteBuildPath)}
+	for _, secretDirectory := range secretDirectories {
+		filepath.Walk(fmt.Sprintf("%s/%s", siteBuildPath, secretDirectory), func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() && !strings.HasSuffix(path, utils.EncryptedSuffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+
+	scrubbed := 0
+	for _, path := range files {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		action := scrubActionForFile(path)
+		if action == "" {
+			continue
+		}
+
+		// with a key everything is encrypted, as redaction cannot be undone
+		if key != nil {
+			err := utils.EncryptFile(path, key)
+			if err != nil {
+				log.Fatalf("Error encrypting %s: %s\n", path, err)
+			}
+			log.Printf("Encrypted %s\n", path)
+			scrubbed = scrubbed + 1
+			continue
+		}
+
+		switch action {
+		case "keep":
+			if removeCredentials {
+				err := os.Remove(path)
+				if err != nil {
+					log.Fatalf("Error removing %s: %s\n", path, err)
+				}
+				log.Printf("Removed %s\n", path)
+				scrubbed = scrubbed + 1
+			} else {
+				log.Printf("Keeping credentials file %s, restricted to the owner\n", path)
+				os.Chmod(path, 0600)
+			}
+		case "remove":
+			err := os.Remove(path)
+			if err != nil {
+				log.Fatalf("Error removing %s: %s\n", path, err)
+			}
+			log.Printf("Removed %s\n", path)
+			scrubbed = scrubbed + 1
+		case "redact":
+			err := redactSecretManifest(path)
+			if err != nil {
+				log.Fatalf("Error redacting %s: %s\n", path, err)
+			}
+			log.Printf("Redacted %s\n", path)
+			scrubbed = scrubbed + 1
+		}
+	}
+
+	log.Printf("Scrubbed %d files holding secrets for site %s\n", scrubbed, s.siteName)
+}
+
+// decrypts the secrets previously encrypted at rest by ScrubSecrets
+func (s Site) RestoreSecrets(encryptionKeyFile string) {
+	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+	if _, err := os.Stat(siteBuildPath); err != nil {
+		log.Fatalf("Error: site build directory %s does not exist\n", siteBuildPath)
+	}
+
+	key, err := utils.ReadEncryptionKey(encryptionKeyFile)
+	if err != nil {
+		log.Fatalf("Error reading encryption key: %s\n", err)
+	}
+
+	restored := 0
+	err = filepath.Walk(siteBuildPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && strings.HasSuffix(path, utils.EncryptedSuffix) {
+			err = utils.DecryptFile(path, key)
+			if err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+			log.Printf("Restored %s\n", strings.TrimSuffix(path, utils.EncryptedSuffix))
+			restored = restored + 1
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error restoring secrets: %s\n", err)
+	}
+
+	log.Printf("Restored %d files holding secrets for site %s\n", restored, s.siteName)
+}
+
+// decides what to do with a file when scrubbing: keep credentials, remove
+// files fully made of secrets or redact manifests. Returns empty if the file
+// does not hold secrets
+func scrubActionForFile(path string) string {
+	fileName := filepath.Base(path)
+	for _, credentialFile := range credentialFiles {
+		if fileName == credentialFile {
+			return "keep"
+		}
+	}
+
+	if strings.HasSuffix(fileName, ".ign") || strings.HasSuffix(fileName, ".tfplan") || fileName == ".openshift_install_state.json" {
+		return "remove"
+	}
+
+	if strings.HasSuffix(fileName, ".yaml") || strings.HasSuffix(fileName, ".yml") || strings.HasSuffix(fileName, ".orig") {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		var manifestObj map[interface{}]interface{}
+		err = yaml.Unmarshal(content, &manifestObj)
+		if err != nil {
+			return ""
+		}
+		if hasSecrets(manifestObj) {
+			return "redact"
+		}
+	}
+
+	return ""
+}
+
+// checks if a manifest is an install-config with a pull secret, or holds a Secret
+func hasSecrets(manifestObj map[interface{}]interface{}) bool {
+	if pullSecret, ok := manifestObj["pullSecret"].(string); ok && pullSecret != redactedValue {
+		return true
+	}
+
+	if kind, ok := manifestObj["kind"].(string); ok && kind == "Secret" {
+		return true
+	}
+
+	if items, ok := manifestObj["items"].([]interface{}); ok {
+		for _, item := range items {
+			if itemObj, ok := item.(map[interface{}]interface{}); ok && hasSecrets(itemObj) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// redacts the secrets of an install-config or Secret manifest in place
+func redactSecretManifest(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var manifestObj map[interface{}]interface{}
+	err = yaml.Unmarshal(content, &manifestObj)
+	if err != nil {
+		return err
+	}
+	redactSecrets(manifestObj)
+
+	redactedContent, err := yaml.Marshal(manifestObj)
+	if err != nil {
+		return err
+	}
+	return utils.WriteSecretFile(path, redactedContent)
+}
+
+func redactSecrets(manifestObj map[interface{}]interface{}) {
+	if _, ok := manifestObj["pullSecret"]; ok {
+		manifestObj["pullSecret"] = redactedValue
+	}
+
+	if kind, ok := manifestObj["kind"].(string); ok && kind == "Secret" {
+		// data needs to stay valid base64, so it is emptied
+		if data, ok := manifestObj["data"].(map[interface{}]interface{}); ok {
+			for dataKey := range data {
+				data[dataKey] = ""
+			}
+		}
+		if stringData, ok := manifestObj["stringData"].(map[interface{}]interface{}); ok {
+			for dataKey := range stringData {
+				stringData[dataKey] = redactedValue
+			}
+		}
+	}
+
+	if items, ok := manifestObj["items"].([]interface{}); ok {
+		for _, item := range items {
+			if itemObj, ok := item.(map[interface{}]interface{}); ok {
+				redactSecrets(itemObj)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package site
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/utils"
+)
+
+const testPullSecretManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: pull-secret
+data:
+  .dockerconfigjson: eyJhdXRocyI6e319
+`
+
+const testInstallConfig = `apiVersion: v1
+metadata:
+  name: cluster
+pullSecret: '{"auths":{}}'
+`
+
+// files of a site build directory, by path relative to it
+var testSiteFiles = map[string]string{
+	"profile.env":                                  "export KUBECONFIG=/tmp/kubeconfig\n",
+	"final_manifests/pull-secret.yaml":             testPullSecretManifest,
+	"final_manifests/configmap.yaml":               "apiVersion: v1\nkind: ConfigMap\ndata:\n  key: value\n",
+	"baremetal_automation/ocp/install-config.yaml": testInstallConfig,
+	"baremetal_automation/ocp/bootstrap.ign":       "{}",
+	"baremetal_automation/ocp/auth/kubeconfig":     "kubeconfig",
+	"terraform_plans/workers.tfplan":               "plan",
+	"site/00_install-config/install-config.yaml":   testInstallConfig,
+}
+
+// a site build directory with testSiteFiles, returning the site and its build path
+func testSite(t *testing.T) (Site, string) {
+	t.Helper()
+
+	buildPath := t.TempDir()
+	for path, content := range testSiteFiles {
+		err := utils.WriteSecretFile(filepath.Join(buildPath, "testsite", path), []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewWithName("testsite", buildPath), filepath.Join(buildPath, "testsite")
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestScrubSecrets(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		removeCredentials bool
+		removed           []string
+	}{
+		{"keep credentials", false, []string{"baremetal_automation/ocp/bootstrap.ign", "terraform_plans/workers.tfplan"}},
+		{"remove credentials", true, []string{"baremetal_automation/ocp/bootstrap.ign", "terraform_plans/workers.tfplan", "profile.env", "baremetal_automation/ocp/auth/kubeconfig"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, siteBuildPath := testSite(t)
+
+			s.ScrubSecrets("", tc.removeCredentials)
+
+			for _, path := range tc.removed {
+				if _, err := os.Stat(filepath.Join(siteBuildPath, path)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed", path)
+				}
+			}
+			if !tc.removeCredentials && readTestFile(t, filepath.Join(siteBuildPath, "profile.env")) != testSiteFiles["profile.env"] {
+				t.Errorf("expected profile.env to be kept")
+			}
+
+			secret := readTestFile(t, filepath.Join(siteBuildPath, "final_manifests/pull-secret.yaml"))
+			if strings.Contains(secret, "eyJhdXRocyI6e319") || !strings.Contains(secret, "name: pull-secret") {
+				t.Errorf("expected the secret data to be emptied, got\n%s", secret)
+			}
+			installConfig := readTestFile(t, filepath.Join(siteBuildPath, "baremetal_automation/ocp/install-config.yaml"))
+			if !strings.Contains(installConfig, "pullSecret: REDACTED") {
+				t.Errorf("expected the pull secret to be redacted, got\n%s", installConfig)
+			}
+
+			// files without secrets, and the site layer, are left as they are
+			for _, path := range []string{"final_manifests/configmap.yaml", "site/00_install-config/install-config.yaml"} {
+				if readTestFile(t, filepath.Join(siteBuildPath, path)) != testSiteFiles[path] {
+					t.Errorf("expected %s to be unchanged", path)
+				}
+			}
+		})
+	}
+}
+
+// with a key the secrets are encrypted instead, and restored as they were
+func TestScrubAndRestoreSecrets(t *testing.T) {
+	s, siteBuildPath := testSite(t)
+	keyPath := filepath.Join(t.TempDir(), "key")
+	ioutil.WriteFile(keyPath, []byte("operator secret\n"), 0600)
+
+	s.ScrubSecrets(keyPath, false)
+
+	encrypted := []string{
+		"profile.env",
+		"final_manifests/pull-secret.yaml",
+		"baremetal_automation/ocp/install-config.yaml",
+		"baremetal_automation/ocp/bootstrap.ign",
+		"baremetal_automation/ocp/auth/kubeconfig",
+		"terraform_plans/workers.tfplan",
+	}
+	for _, path := range encrypted {
+		if _, err := os.Stat(filepath.Join(siteBuildPath, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be encrypted", path)
+		}
+		if content := readTestFile(t, filepath.Join(siteBuildPath, path+utils.EncryptedSuffix)); strings.Contains(content, testSiteFiles[path]) {
+			t.Errorf("expected %s to be encrypted, got %q", path, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(siteBuildPath, "final_manifests/configmap.yaml"+utils.EncryptedSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expected the file without secrets not to be encrypted")
+	}
+
+	s.RestoreSecrets(keyPath)
+
+	for path, content := range testSiteFiles {
+		if readTestFile(t, filepath.Join(siteBuildPath, path)) != content {
+			t.Errorf("expected %s to be restored", path)
+		}
+		if _, err := os.Stat(filepath.Join(siteBuildPath, path+utils.EncryptedSuffix)); !os.IsNotExist(err) {
+			t.Errorf("expected the encrypted %s to be removed", path)
+		}
+	}
+}
@@ -8,14 +8,20 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gerrit.akraino.org/kni/installer/pkg/automation"
+	"gerrit.akraino.org/kni/installer/pkg/hardening"
 	"gerrit.akraino.org/kni/installer/pkg/manifests"
 	"gerrit.akraino.org/kni/installer/pkg/requirements"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
 	"gerrit.akraino.org/kni/installer/pkg/utils"
+	"gerrit.akraino.org/kni/installer/pkg/version"
+	"gerrit.akraino.org/kni/installer/pkg/wait"
 	getter "github.com/hashicorp/go-getter"
 	"github.com/otiai10/copy"
 	"gopkg.in/yaml.v2"
@@ -173,6 +179,11 @@ func (s Site) FetchRequirements(individualRequirements []string) {
 		// binary versions set in the blueprint profile's "requirements.yaml"
 		parsedRequirements[binaryName] = binarySource
 
+		// the baremetal automation source is fetched by the automation itself
+		if binaryName == automation.BaremetalAutomationRequirement {
+			continue
+		}
+
 		// if we have individual requirements list, check if we have the requirement on it. Otherwise, skip
 		if len(individualRequirements) > 0 {
 			foundReq := false
@@ -212,29 +223,38 @@ func (s Site) WriteEnvFile() {
 	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
 	installYaml := fmt.Sprintf("%s/site/00_install-config/site-config.yaml", siteBuildPath)
 
-	var configFileObj map[interface{}]interface{}
-	b, err := ioutil.ReadFile(installYaml)
+	siteConfig, err := siteconfig.Load(installYaml)
 	if err != nil {
 		log.Fatalf("Error reading site config file: %s\n", err)
 	}
 
-	// parse the yaml and check for key value
-	err = yaml.Unmarshal(b, &configFileObj)
-	if err != nil {
-		log.Printf("Error parsing manifest: %s\n", err)
+	for _, key := range siteConfig.Config.Keys() {
+		envContents = fmt.Sprintf("%sexport %s=%s\n", envContents, key, siteConfig.Config[key])
 	}
 
-	envVars, ok := configFileObj["config"].(map[interface{}]interface{})
-	if ok {
-		for index, element := range envVars {
-			envContents = fmt.Sprintf("%sexport %s=%s\n", envContents, string(index.(string)), string(element.(string)))
-		}
+	// write a profile.env in the siteBuildPath, it may contain credentials
+	err = utils.WriteSecretFile(fmt.Sprintf("%s/profile.env", siteBuildPath), []byte(envContents))
+	if err != nil {
+		log.Fatalf("Error writing profile.env file: %s\n", err)
 	}
+}
 
-	// write a profile.env in the siteBuildPath
-	err = ioutil.WriteFile(fmt.Sprintf("%s/profile.env", siteBuildPath), []byte(envContents), 0644)
+// validates the site-config.yaml of the site, reporting every problem found
+func (s Site) ValidateSiteConfig() {
+	siteConfigPath := fmt.Sprintf("%s/%s/site/00_install-config/site-config.yaml", s.buildPath, s.siteName)
+
+	siteConfig, err := siteconfig.Load(siteConfigPath)
 	if err != nil {
-		log.Fatalf("Error writing profile.env file: %s\n", err)
+		log.Fatalln(err)
+	}
+
+	log.Printf("Site config %s for site %s is valid\n", siteConfigPath, s.siteName)
+	for _, warning := range siteConfig.Warnings() {
+		log.Printf("WARNING: %s\n", warning)
+	}
+	if siteConfig.ProvisioningInfrastructure != nil {
+		hosts := siteConfig.ProvisioningInfrastructure.Hosts
+		log.Printf("Provisioning infrastructure defines %d masters and %d workers\n", len(hosts.Masters), len(hosts.Workers))
 	}
 }
 
@@ -305,7 +325,7 @@ func (s Site) DownloadRepo(sitePath string, profileLayerPath string, profileRef
 					newKustomization = strings.Replace(newKustomization, fmt.Sprintf("?ref=%s", profileRef), "", -1)
 				}
 
-				err = ioutil.WriteFile(path, []byte(newKustomization), 0)
+				err = ioutil.WriteFile(path, []byte(newKustomization), info.Mode().Perm())
 				if err != nil {
 					log.Fatalf("Error writing modified kustomization file: %s\n", err)
 				}
@@ -320,9 +340,40 @@ func (s Site) DownloadRepo(sitePath string, profileLayerPath string, profileRef
 	})
 }
 
+// retrieves the provenance of the manifests rendered for a site
+func (s Site) GetProvenance() *manifests.Provenance {
+	sitePath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+	_, profileLayerPath, profileRef := s.GetProfileFromSite()
+
+	// the blueprint repo is recorded without the ref, that has its own annotation
+	blueprintRepo := profileLayerPath
+	if pos := strings.LastIndex(blueprintRepo, "?ref="); pos != -1 {
+		blueprintRepo = blueprintRepo[0:pos]
+	}
+
+	// site commit is only available when the site has been cloned from git,
+	// so run the command directly to not fail or log on local copies
+	siteCommit := "unknown"
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = fmt.Sprintf("%s/site", sitePath)
+	out, err := cmd.Output()
+	if err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		siteCommit = strings.TrimSpace(string(out))
+	}
+
+	return &manifests.Provenance{
+		BlueprintRepo: blueprintRepo,
+		BlueprintRef:  profileRef,
+		SiteCommit:    siteCommit,
+		KnictlVersion: version.Get(),
+	}
+}
+
 // using the downloaded site content, prepares the manifests for it, and also runs
-// host preparation finalization scripts for site automation (if any)
-func (s Site) PrepareManifests() {
+// host preparation finalization scripts for site automation (if any). If annotate
+// is set, every manifest gets annotated with its provenance. If a hardening profile
+// is given, its manifests are merged into the final manifests
+func (s Site) PrepareManifests(annotate bool, hardeningProfile string) {
 	sitePath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
 	log.Printf("Preparing manifests for %s\n", s.siteName)
 
@@ -335,9 +386,10 @@ func (s Site) PrepareManifests() {
 	s.DownloadRepo(sitePath, profileLayerPath, profileRef)
 
 	// create automation sub-directory to store a copy of anything that might be
-	// needed in the case of potential automation
+	// needed in the case of potential automation. As it will hold the final
+	// install-config.yaml, it is only accessible by the owner
 	automationPath := fmt.Sprintf("%s/automation", sitePath)
-	os.Mkdir(automationPath, 0755)
+	os.Mkdir(automationPath, 0700)
 
 	// copy 00_install-config directory contents into automation sub-directory
 	installConfigDirPath := fmt.Sprintf("%s/blueprint/sites/site/00_install-config", sitePath)
@@ -350,19 +402,19 @@ func (s Site) PrepareManifests() {
 	// generate openshift-install manifests based on phase 00_install-config
 	assetsPath := fmt.Sprintf("%s/generated_assets", sitePath)
 	os.RemoveAll(assetsPath)
-	os.Mkdir(assetsPath, 0755)
+	os.Mkdir(assetsPath, 0700)
 
-	out := utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), installConfigDirPath)
+	out := utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), installConfigDirPath, s.buildPath, s.siteName)
 	// check if we have any content and write to the target file
 	if len(out) > 0 {
-		err := ioutil.WriteFile(fmt.Sprintf("%s/install-config.yaml", assetsPath), out, 0644)
+		err := utils.WriteSecretFile(fmt.Sprintf("%s/install-config.yaml", assetsPath), out)
 		if err != nil {
 			log.Fatalf("Error writing final install-config file: %s\n", err)
 		}
 
 		// create a copy of final install-config.yaml in any site automation sub-directories
 		// in case automation is later needed
-		err = ioutil.WriteFile(fmt.Sprintf("%s/install-config.yaml", automationPath), out, 0644)
+		err = utils.WriteSecretFile(fmt.Sprintf("%s/install-config.yaml", automationPath), out)
 		if err != nil {
 			log.Fatalf("Error writing final install-config file to automation assets directory: %s\n", err)
 		}
@@ -406,10 +458,34 @@ func (s Site) PrepareManifests() {
 	os.Rename(fmt.Sprintf("%s/generated_assets/", sitePath), fmt.Sprintf("%s/blueprint/base/00_cluster/", sitePath))
 
 	// apply kustomize on cluster-mods
-	out = utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/01_cluster-mods", sitePath))
+	out = utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/01_cluster-mods", sitePath), s.buildPath, s.siteName)
 	if len(out) > 0 {
+		// add the hardening manifests, so they are merged as any other manifest
+		if len(hardeningProfile) > 0 {
+			log.Printf("Adding %s hardening manifests\n", hardeningProfile)
+			hardeningContent, err := hardening.Generate(hardeningProfile, utils.GetInstallationPath("utils"))
+			if err != nil {
+				log.Fatalf("Error generating hardening manifests: %s\n", err)
+			}
+			out = []byte(fmt.Sprintf("%s\n---\n%s\n", strings.TrimRight(string(out), "\n"), hardeningContent))
+		}
+
 		// now apply modifications on the manifests
-		resultStr := manifests.MergeManifests(string(out), sitePath)
+		var provenance *manifests.Provenance
+		if annotate {
+			provenance = s.GetProvenance()
+		}
+		resultStr := manifests.MergeManifests(string(out), sitePath, provenance)
+
+		// final manifests contain the pull secret, and will contain cluster credentials
+		err = utils.SecureDirectory(automationPath)
+		if err != nil {
+			log.Fatalf("Error restricting permissions of automation directory: %s\n", err)
+		}
+		err = utils.SecureDirectory(fmt.Sprintf("%s/final_manifests", sitePath))
+		if err != nil {
+			log.Fatalf("Error restricting permissions of final manifests: %s\n", err)
+		}
 
 		// Now that we have finalized our manifests, call automation finalization (if any)
 		err = s.finalizeHostForAutomation(profileName)
@@ -426,14 +502,15 @@ func (s Site) PrepareManifests() {
 
 }
 
-// using the site contents, applies the workloads on it
-func (s Site) ApplyWorkloads(kubeconfigFile string, retryCount int, delay int) {
+// using the site contents, applies the workloads on it. If annotate is set,
+// every workload gets annotated with its provenance
+func (s Site) ApplyWorkloads(kubeconfigFile string, retryCount int, delay int, annotate bool) {
 	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
 
 	// if we have kubeconfig, validate that exists
 	if len(kubeconfigFile) > 0 {
 		if _, err := os.Stat(kubeconfigFile); err != nil {
-			log.Fatalln("Error: kubeconfig file %s does not exist\n", kubeconfigFile)
+			log.Fatalf("Error: kubeconfig file %s does not exist\n", kubeconfigFile)
 		}
 	}
 	binariesPath := fmt.Sprintf("%s/requirements", siteBuildPath)
@@ -442,45 +519,225 @@ func (s Site) ApplyWorkloads(kubeconfigFile string, retryCount int, delay int) {
 	_, profileLayerPath, profileRef := s.GetProfileFromSite()
 	s.DownloadRepo(siteBuildPath, profileLayerPath, profileRef)
 
+	var provenance *manifests.Provenance
+	if annotate {
+		provenance = s.GetProvenance()
+	}
+
 	log.Printf("Applying workloads from %s/blueprint/sites/site/02_cluster-addons\n", siteBuildPath)
-	out := utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/02_cluster-addons", siteBuildPath))
+	out := utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/02_cluster-addons", siteBuildPath), s.buildPath, s.siteName)
 	if string(out) != "" {
 		// now we can apply it
+		out = provenance.AnnotateContent(out, manifests.LayerClusterAddons)
 		utils.ApplyOc(fmt.Sprintf("%s/oc", binariesPath), out, kubeconfigFile, retryCount, delay)
 	} else {
 		log.Printf("No manifests found for %s/blueprint/sites/site/02_cluster-addons\n", siteBuildPath)
 	}
 	log.Printf("Applying workloads from %s/blueprint/sites/site/03_services\n", siteBuildPath)
-	out = utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/03_services", siteBuildPath))
+	out = utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/03_services", siteBuildPath), s.buildPath, s.siteName)
 	if string(out) != "" {
 		// now we can apply it
+		out = provenance.AnnotateContent(out, manifests.LayerServices)
 		utils.ApplyOc(fmt.Sprintf("%s/oc", binariesPath), out, kubeconfigFile, retryCount, delay)
 	} else {
 		log.Printf("No manifests found for %s/blueprint/sites/site/03_services\n", siteBuildPath)
 	}
 }
 
-func (s Site) AutomateMastersDeployment() {
+// shows where a rendered object comes from, given as kind/name. The object is
+// searched in the final manifests first, and then in the cluster
+func (s Site) ExplainObject(object string, namespace string, kubeconfigFile string) {
+	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+
+	objectBits := strings.SplitN(object, "/", 2)
+	if len(objectBits) != 2 || objectBits[0] == "" || objectBits[1] == "" {
+		log.Fatalf("Error: object %s needs to be specified as <kind>/<name>\n", object)
+	}
+	kind, name := objectBits[0], objectBits[1]
+
+	location := ""
+	manifestObj, manifestPath := manifests.FindManifest(fmt.Sprintf("%s/final_manifests", siteBuildPath), kind, name)
+	if manifestObj != nil {
+		location = manifestPath
+	} else {
+		// not rendered by prepare_manifests, so check for it on the cluster
+		if len(kubeconfigFile) > 0 {
+			if _, err := os.Stat(kubeconfigFile); err != nil {
+				log.Fatalf("Error: %s not found in final manifests, and kubeconfig file %s does not exist\n", object, kubeconfigFile)
+			}
+		}
+
+		var envVars []string
+		if len(kubeconfigFile) > 0 {
+			envVars = []string{fmt.Sprintf("KUBECONFIG=%s", kubeconfigFile)}
+		}
+		args := []string{"get", object, "-o", "yaml"}
+		if len(namespace) > 0 {
+			args = append(args, "-n", namespace)
+		}
+		out, _ := utils.ExecuteCommand("", envVars, true, false, fmt.Sprintf("%s/requirements/oc", siteBuildPath), args...)
+
+		err := yaml.Unmarshal(out, &manifestObj)
+		if err != nil {
+			log.Fatalf("Error parsing object from the cluster: %s\n", err)
+		}
+		location = "cluster"
+	}
+
+	fmt.Printf("Object:   %s\n", object)
+	fmt.Printf("Location: %s\n", location)
+
+	provenance := manifests.GetProvenance(manifestObj)
+	if len(provenance) == 0 {
+		// installer manifests are only annotated when the blueprint modifies them
+		if location != "cluster" {
+			fmt.Printf("No provenance annotations found, the object was generated by openshift-install in the %s layer and left unmodified, or annotations were disabled\n", manifests.LayerCluster)
+			return
+		}
+		fmt.Println("No provenance annotations found, the object was not rendered by knictl or annotations were disabled")
+		return
+	}
+	for _, annotation := range manifests.ProvenanceAnnotations {
+		if value, ok := provenance[annotation]; ok {
+			fmt.Printf("%s: %s\n", annotation, value)
+		}
+	}
+}
+
+// waits for an installation milestone of the site. The installer state and the
+// default kubeconfig are taken from the directory where the site automation ran
+// the installer. A "local" kubeconfig uses the oc defaults
+func (s Site) WaitFor(milestoneName string, kubeconfigFile string, timeout time.Duration, interval time.Duration, jsonOutput bool) {
+	siteBuildPath := fmt.Sprintf("%s/%s", s.buildPath, s.siteName)
+
+	milestone, err := wait.ParseMilestone(milestoneName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	profileName, profileLayerPath, profileRef := s.GetProfileFromSite()
+	profileType, err := s.getProfileType(profileName)
+	if err != nil {
+		log.Fatalf("Error acquiring site profile type: %s\n", err)
+	}
+	installerDir := fmt.Sprintf("%s/%s", siteBuildPath, automation.InstallerDirectory(profileType))
+
+	if kubeconfigFile == "" {
+		kubeconfigFile = fmt.Sprintf("%s/auth/kubeconfig", installerDir)
+	} else if kubeconfigFile == "local" {
+		kubeconfigFile = ""
+	}
+	if len(kubeconfigFile) > 0 && (milestone == wait.WorkersReady || milestone == wait.WorkloadsReady) {
+		if _, err := os.Stat(kubeconfigFile); err != nil {
+			log.Fatalf("Error: kubeconfig file %s does not exist\n", kubeconfigFile)
+		}
+	}
+
+	binariesPath := fmt.Sprintf("%s/requirements", siteBuildPath)
+	params := wait.Params{
+		InstallerPath: fmt.Sprintf("%s/openshift-install", binariesPath),
+		InstallerDir:  installerDir,
+		OcPath:        fmt.Sprintf("%s/oc", binariesPath),
+		Kubeconfig:    kubeconfigFile,
+		Timeout:       timeout,
+		Interval:      interval,
+		JSON:          jsonOutput,
+	}
+
+	switch milestone {
+	case wait.WorkersReady:
+		// the number of workers requested, 3 when not set as in the installer
+		var installConfig struct {
+			Compute []struct {
+				Replicas *int `yaml:"replicas"`
+			} `yaml:"compute"`
+		}
+
+		installConfigFile, err := ioutil.ReadFile(fmt.Sprintf("%s/automation/install-config.yaml", siteBuildPath))
+		if err != nil {
+			log.Fatalf("Error reading install-config.yaml, run prepare_manifests first: %s\n", err)
+		}
+		err = yaml.Unmarshal(installConfigFile, &installConfig)
+		if err != nil {
+			log.Fatalf("Error parsing install-config.yaml: %s\n", err)
+		}
+
+		params.Workers = 3
+		if len(installConfig.Compute) > 0 && installConfig.Compute[0].Replicas != nil {
+			params.Workers = *installConfig.Compute[0].Replicas
+		}
+	case wait.WorkloadsReady:
+		// the workloads are rendered as apply_workloads does
+		s.DownloadRepo(siteBuildPath, profileLayerPath, profileRef)
+
+		for _, layer := range []string{manifests.LayerClusterAddons, manifests.LayerServices} {
+			out := utils.ApplyKustomize(fmt.Sprintf("%s/kustomize", binariesPath), fmt.Sprintf("%s/blueprint/sites/site/%s", siteBuildPath, layer), s.buildPath, s.siteName)
+
+			objects, err := wait.ParseObjects(out)
+			if err != nil {
+				log.Fatalf("Error listing the workloads of %s: %s\n", layer, err)
+			}
+			params.Workloads = append(params.Workloads, objects...)
+		}
+	}
+
+	err = wait.For(milestone, params)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func (s Site) AutomateMastersDeployment(options automation.DeploymentOptions) {
 	// Run the automated deployment
-	err := s.automateDeployment("masters")
+	err := s.automateDeployment("masters", options)
 
 	if err != nil {
 		log.Fatalf("Site: AutomateMastersDeployment: Error attempting to run automated deployment: %s\n", err)
 	}
 }
 
-func (s Site) AutomateWorkersDeployment() {
+func (s Site) AutomateWorkersDeployment(options automation.DeploymentOptions) {
 	// Run the automated deployment
-	err := s.automateDeployment("workers")
+	err := s.automateDeployment("workers", options)
 
 	if err != nil {
 		log.Fatalf("Site: AutomateWorkersDeployment: Error attempting to run automated deployment: %s\n", err)
 	}
 }
 
-func (s Site) AutomateClusterDestroy() {
+// adds and removes workers of a deployed site, to match its site config. If a site
+// repository is given, the site layer is downloaded again from it first
+func (s Site) AutomateWorkersScaling(siteRepo string, options automation.DeploymentOptions) {
+	if siteRepo != "" {
+		repoSite := New(siteRepo, s.buildPath)
+		if repoSite.siteName != s.siteName {
+			log.Fatalf("Site: AutomateWorkersScaling: site repository %s is for site '%s', not '%s'\n", siteRepo, repoSite.siteName, s.siteName)
+		}
+		repoSite.DownloadSite()
+	}
+
+	automatedDeployment, err := s.getAutomatedDeployment(options)
+
+	if err != nil {
+		log.Fatalf("Site: AutomateWorkersScaling: Error attempting to acquire automated deploy object: %s\n", err)
+	}
+
+	workerScaling, ok := automatedDeployment.(automation.WorkerScalingInterface)
+
+	if !ok {
+		log.Fatalf("Site: AutomateWorkersScaling: scaling workers is not supported for site '%s'\n", s.siteName)
+	}
+
+	err = workerScaling.ScaleWorkers()
+
+	if err != nil {
+		log.Fatalf("Site: AutomateWorkersScaling: Error attempting to scale workers: %s\n", err)
+	}
+}
+
+func (s Site) AutomateClusterDestroy(options automation.DeploymentOptions) {
 	// Get an automated deployment object
-	automatedDeployment, err := s.getAutomatedDeployment()
+	automatedDeployment, err := s.getAutomatedDeployment(options)
 
 	if err != nil {
 		log.Fatalf("Site: AutomateClusterDestroy: Error attempting to acquire automated deploy object: %s\n", err)
@@ -494,9 +751,9 @@ func (s Site) AutomateClusterDestroy() {
 	}
 }
 
-func (s Site) automateDeployment(deploymentType string) error {
+func (s Site) automateDeployment(deploymentType string, options automation.DeploymentOptions) error {
 	// Get an automated deployment object
-	automatedDeployment, err := s.getAutomatedDeployment()
+	automatedDeployment, err := s.getAutomatedDeployment(options)
 
 	if err != nil {
 		return err
@@ -514,7 +771,7 @@ func (s Site) automateDeployment(deploymentType string) error {
 }
 
 // Returns an AutomatedDeploymentInterface for use with automation operations
-func (s Site) getAutomatedDeployment() (automation.AutomatedDeploymentInterface, error) {
+func (s Site) getAutomatedDeployment(options automation.DeploymentOptions) (automation.AutomatedDeploymentInterface, error) {
 	// Get profile name
 	profileName, _, _ := s.GetProfileFromSite()
 
@@ -533,6 +790,7 @@ func (s Site) getAutomatedDeployment() (automation.AutomatedDeploymentInterface,
 		SiteBuildPath: s.buildPath,
 		SiteName:      s.siteName,
 		SiteRepo:      s.siteRepo,
+		Options:       options,
 	}
 
 	automatedDeployment, err := automation.New(automatedDeploymentParams)
@@ -667,7 +925,7 @@ func (s Site) prepareHostForAutomation(profileName string, requirements map[stri
 	os.RemoveAll(automationDestination)
 
 	// Get an automated deployment object
-	automatedDeployment, err := s.getAutomatedDeployment()
+	automatedDeployment, err := s.getAutomatedDeployment(automation.DeploymentOptions{})
 
 	if err != nil {
 		// If automation isn't supported for this profile type, it's not a fatal error in
@@ -697,7 +955,7 @@ func (s Site) finalizeHostForAutomation(profileName string) error {
 	}
 
 	// Get an automated deployment object
-	automatedDeployment, err := s.getAutomatedDeployment()
+	automatedDeployment, err := s.getAutomatedDeployment(automation.DeploymentOptions{})
 
 	if err != nil {
 		// If automation isn't supported for this profile type, it's not a fatal error in
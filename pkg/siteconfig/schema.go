@@ -0,0 +1,179 @@
+package siteconfig
+
+// Schema : JSON schema of the SiteConfig object, for editors and external
+// validation. It must be kept in sync with the SiteConfig types, that are the
+// reference used by knictl
+const Schema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://kni.akraino.org/schemas/v1alpha1/siteconfig.json",
+  "title": "SiteConfig",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "apiVersion": {"const": "kni.akraino.org/v1alpha1"},
+    "kind": {"const": "SiteConfig"},
+    "metadata": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "name": {"type": "string"}
+      }
+    },
+    "config": {
+      "type": "object",
+      "propertyNames": {"pattern": "^[A-Za-z_][A-Za-z0-9_]*$"},
+      "additionalProperties": {"type": ["string", "number", "boolean", "null"]},
+      "properties": {
+        "virtualizedInstall": {"enum": ["true", "false", true, false]},
+        "releaseImageOverride": {"type": "string", "pattern": ":"}
+      }
+    },
+    "fieldSpecs": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["path"],
+        "properties": {
+          "kind": {"type": "string"},
+          "name": {"type": "string"},
+          "path": {"type": "string", "minLength": 1},
+          "value": {"type": "string"}
+        }
+      }
+    },
+    "secrets": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "provider": {"enum": ["files", "env", "vault", "kubernetes"]},
+        "credentialSet": {"type": "string", "pattern": "^[^./\\\\][^/\\\\]*$"},
+        "files": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "path": {"type": "string"}
+          }
+        },
+        "env": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "prefix": {"type": "string"}
+          }
+        },
+        "vault": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "address": {"type": "string"},
+            "mount": {"type": "string"},
+            "path": {"type": "string"},
+            "kvVersion": {"enum": [1, 2]}
+          }
+        },
+        "kubernetes": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "name": {"type": "string"},
+            "namespace": {"type": "string"},
+            "kubeconfig": {"type": "string"},
+            "binary": {"type": "string"}
+          }
+        },
+        "names": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "pullSecret": {"type": "string"},
+            "sshPublicKey": {"type": "string"},
+            "dockerConfig": {"type": "string"},
+            "kubeconfigHub": {"type": "string"},
+            "githubSecret": {"type": "string"}
+          }
+        }
+      }
+    },
+    "ssh": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "keyType": {"enum": ["ed25519", "rsa"]},
+        "comment": {"type": "string"},
+        "keyPath": {"type": "string"},
+        "sources": {"type": "array", "items": {"enum": ["sshDirectory", "agent", "generate"]}},
+        "authorizedKeys": {"type": "array", "items": {"type": "string", "pattern": "^[a-z0-9-@.]+ [A-Za-z0-9+/=]+( .*)?$"}}
+      }
+    },
+    "provisioningInfrastructure": {
+      "type": "object",
+      "properties": {
+        "hosts": {
+          "type": "object",
+          "properties": {
+            "defaultBootInterface": {"type": "string"},
+            "defaultSdnInterface": {"type": "string"},
+            "masters": {"type": "array", "items": {"$ref": "#/definitions/host"}},
+            "workers": {"type": "array", "items": {"$ref": "#/definitions/host"}}
+          }
+        },
+        "automation": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "source": {"type": "string"},
+            "ref": {"type": "string"}
+          }
+        },
+        "network": {
+          "type": "object",
+          "properties": {
+            "provisioningInterface": {"type": "string"},
+            "baremetalInterface": {"type": "string"},
+            "provisioningVlan": {"type": "integer", "minimum": 1, "maximum": 4094},
+            "baremetalVlan": {"type": "integer", "minimum": 1, "maximum": 4094},
+            "provisioningIpCidr": {"type": "string"},
+            "baremetalIpCidr": {"type": "string"},
+            "provisioningIp": {"type": "string"},
+            "baremetalIp": {"type": "string"},
+            "baremetalGWIP": {"type": "string"},
+            "apiVip": {"type": "string"},
+            "ingressVip": {"type": "string"},
+            "bootstrapIp": {"type": "string"},
+            "provisioningDhcpRange": {"type": "string", "pattern": "^[^,]+,[^,]+$"}
+          }
+        }
+      }
+    }
+  },
+  "definitions": {
+    "host": {
+      "type": "object",
+      "required": ["name", "bootMACAddress"],
+      "properties": {
+        "name": {"type": "string", "minLength": 1},
+        "bootMACAddress": {"type": "string"},
+        "sdnMACAddress": {"type": "string"},
+        "bootInterface": {"type": "string"},
+        "sdnInterface": {"type": "string"},
+        "ip": {"type": "string"},
+        "installDisk": {"type": "string"},
+        "kernelArgs": {"type": "array", "items": {"type": "string"}},
+        "ignitionURL": {"type": "string", "pattern": "^https?://"},
+        "bmc": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "address": {"type": "string"},
+            "username": {"type": "string"},
+            "password": {"type": "string"},
+            "credentialsName": {"type": "string"},
+            "disableCertificateVerification": {"type": "boolean"}
+          }
+        }
+      }
+    }
+  }
+}
+`
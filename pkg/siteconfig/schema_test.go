@@ -0,0 +1,130 @@
+package siteconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// the parts of a JSON schema node compared with the types
+type schemaNode struct {
+	Ref                  string                `json:"$ref"`
+	Properties           map[string]schemaNode `json:"properties"`
+	Items                *schemaNode           `json:"items"`
+	AdditionalProperties interface{}           `json:"additionalProperties"`
+	Required             []string              `json:"required"`
+}
+
+// lists where the schema node of a struct and its fields differ: it needs a
+// property for each field, and none for fields that do not exist. Structs with
+// an inline Extra accept any property, the others none
+func schemaProblems(path string, structType reflect.Type, node schemaNode, definitions map[string]schemaNode) []string {
+	problems := []string{}
+
+	if node.Ref != "" {
+		node = definitions[strings.TrimPrefix(node.Ref, "#/definitions/")]
+	}
+
+	fields := map[string]reflect.Type{}
+	inline := false
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if strings.Contains(field.Tag.Get("yaml"), "inline") {
+			inline = true
+			continue
+		}
+		fields[tag[0]] = field.Type
+	}
+
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties := []string{}
+	for name := range node.Properties {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+
+	if strings.Join(names, ",") != strings.Join(properties, ",") {
+		problems = append(problems, fmt.Sprintf("%s: the schema properties %v are not the fields %v", path, properties, names))
+	}
+
+	if additional, ok := node.AdditionalProperties.(bool); inline == (ok && !additional) {
+		problems = append(problems, fmt.Sprintf("%s: the schema additionalProperties is %v, but the struct has inline fields: %v", path, node.AdditionalProperties, inline))
+	}
+
+	for _, required := range node.Required {
+		if _, ok := fields[required]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: required property %s is not a field", path, required))
+		}
+	}
+
+	for name, fieldType := range fields {
+		property, ok := node.Properties[name]
+		if !ok {
+			continue
+		}
+
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+			if property.Items == nil {
+				problems = append(problems, fmt.Sprintf("%s.%s: the schema has no items for the list", path, name))
+				continue
+			}
+			fieldType, property = fieldType.Elem(), *property.Items
+		}
+		if fieldType.Kind() == reflect.Struct {
+			problems = append(problems, schemaProblems(path+"."+name, fieldType, property, definitions)...)
+		}
+	}
+
+	sort.Strings(problems)
+
+	return problems
+}
+
+func TestSchema(t *testing.T) {
+	var schema struct {
+		schemaNode
+		Definitions map[string]schemaNode `json:"definitions"`
+	}
+
+	err := json.Unmarshal([]byte(Schema), &schema)
+	if err != nil {
+		t.Fatalf("invalid schema: %s", err)
+	}
+
+	problems := schemaProblems("SiteConfig", reflect.TypeOf(SiteConfig{}), schema.schemaNode, schema.Definitions)
+	if len(problems) > 0 {
+		t.Errorf("the schema is not in sync with the SiteConfig types:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+// the fields missing from the schema, the properties that are not fields and
+// the extra settings are found
+func TestSchemaProblems(t *testing.T) {
+	var node schemaNode
+	json.Unmarshal([]byte(`{"additionalProperties": false, "required": ["mac"], "properties": {"name": {}, "bootMACAddress": {}, "sdnMACAddress": {}, "bootInterface": {}, "sdnInterface": {}, "ip": {}, "installDisk": {}, "kernelArgs": {}, "ignitionURL": {}, "bmc": {"properties": {"address": {}}}, "disk": {}}}`), &node)
+
+	want := []string{
+		"Host.bmc: the schema properties [address] are not the fields [address credentialsName disableCertificateVerification password username]",
+		"Host.bmc: the schema additionalProperties is <nil>, but the struct has inline fields: false",
+		"Host: required property mac is not a field",
+		"Host: the schema additionalProperties is false, but the struct has inline fields: true",
+		"Host: the schema properties [bmc bootInterface bootMACAddress disk ignitionURL installDisk ip kernelArgs name sdnInterface sdnMACAddress] are not the fields [bmc bootInterface bootMACAddress ignitionURL installDisk ip kernelArgs name sdnInterface sdnMACAddress]",
+	}
+	sort.Strings(want)
+
+	if problems := schemaProblems("Host", reflect.TypeOf(Host{}), node, nil); strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(problems, "\n"))
+	}
+}
@@ -0,0 +1,244 @@
+package siteconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/sshkey"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	APIVersion = "kni.akraino.org/v1alpha1"
+	Kind       = "SiteConfig"
+)
+
+// Metadata : metadata of the SiteConfig object
+type Metadata struct {
+	Name string `yaml:"name"`
+}
+
+// FieldSpec : a value to inject at a given field path of the matching resources.
+// Path is a list of fields separated by "/", and value can reference
+// substitution variables as $(NAME)
+type FieldSpec struct {
+	Kind  string `yaml:"kind,omitempty"`
+	Name  string `yaml:"name,omitempty"`
+	Path  string `yaml:"path"`
+	Value string `yaml:"value"`
+}
+
+// ConfigValues : the config block of a site. Values are exported as env vars in
+// profile.env, so any scalar is accepted and kept as a string
+type ConfigValues map[string]string
+
+// SSH : how the ssh public key of the site is obtained when it is missing, and
+// the additional keys authorized to access the nodes
+type SSH struct {
+	KeyType        string   `yaml:"keyType,omitempty"`        // ed25519 or rsa, ed25519 by default
+	Comment        string   `yaml:"comment,omitempty"`        // comment of generated keys, user@hostname by default
+	KeyPath        string   `yaml:"keyPath,omitempty"`        // private key path of generated keys, next to the pull secret by default
+	Sources        []string `yaml:"sources,omitempty"`        // where to look for a key, in order: sshDirectory, agent or generate, all of them by default
+	AuthorizedKeys []string `yaml:"authorizedKeys,omitempty"` // public keys injected with the site key
+}
+
+// BMC : the baseboard management controller of a host
+type BMC struct {
+	Address                        string `yaml:"address,omitempty"` // ipmi://host[:port] or redfish[+http|+https]://host[:port]/system/path
+	Username                       string `yaml:"username,omitempty"`
+	Password                       string `yaml:"password,omitempty"`
+	CredentialsName                string `yaml:"credentialsName,omitempty"`                // secret holding username and password keys, instead of username and password
+	DisableCertificateVerification bool   `yaml:"disableCertificateVerification,omitempty"` // accept self-signed redfish certificates
+}
+
+// Host : a baremetal host of the site. Settings that are only consumed by the
+// baremetal automation scripts are kept in Extra
+type Host struct {
+	Name           string                 `yaml:"name"`
+	BootMACAddress string                 `yaml:"bootMACAddress"`
+	SdnMACAddress  string                 `yaml:"sdnMACAddress,omitempty"`
+	BootInterface  string                 `yaml:"bootInterface,omitempty"` // defaults to hosts.defaultBootInterface
+	SdnInterface   string                 `yaml:"sdnInterface,omitempty"`  // defaults to hosts.defaultSdnInterface
+	IP             string                 `yaml:"ip,omitempty"`            // address on the baremetal network
+	InstallDisk    string                 `yaml:"installDisk,omitempty"`   // defaults to /dev/sda
+	KernelArgs     []string               `yaml:"kernelArgs,omitempty"`
+	IgnitionURL    string                 `yaml:"ignitionURL,omitempty"` // defaults to the ignition config of its role, served by matchbox
+	BMC            BMC                    `yaml:"bmc,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// Hosts : the host inventory of the site. Settings that are only consumed by the
+// baremetal automation scripts are kept in Extra
+type Hosts struct {
+	DefaultBootInterface string                 `yaml:"defaultBootInterface,omitempty"`
+	DefaultSdnInterface  string                 `yaml:"defaultSdnInterface,omitempty"`
+	Masters              []Host                 `yaml:"masters,omitempty"`
+	Workers              []Host                 `yaml:"workers,omitempty"`
+	Extra                map[string]interface{} `yaml:",inline"`
+}
+
+// Network : the provisioning and baremetal networks of the site. Settings that are
+// only consumed by the baremetal automation scripts are kept in Extra
+type Network struct {
+	ProvisioningInterface string                 `yaml:"provisioningInterface,omitempty"` // bastion interface on the provisioning network
+	BaremetalInterface    string                 `yaml:"baremetalInterface,omitempty"`    // bastion interface on the baremetal network
+	ProvisioningVLAN      int                    `yaml:"provisioningVlan,omitempty"`      // VLAN of the provisioning network on provisioningInterface, untagged by default
+	BaremetalVLAN         int                    `yaml:"baremetalVlan,omitempty"`         // VLAN of the baremetal network on baremetalInterface, untagged by default
+	ProvisioningIPCIDR    string                 `yaml:"provisioningIpCidr,omitempty"`
+	BaremetalIPCIDR       string                 `yaml:"baremetalIpCidr,omitempty"`
+	ProvisioningIP        string                 `yaml:"provisioningIp,omitempty"`        // bastion address on the provisioning network, the first one of the CIDR by default
+	BaremetalIP           string                 `yaml:"baremetalIp,omitempty"`           // bastion address on the baremetal network
+	BaremetalGatewayIP    string                 `yaml:"baremetalGWIP,omitempty"`         // default route of the hosts
+	APIVIP                string                 `yaml:"apiVip,omitempty"`                // load balanced api and api-int address, baremetalIp by default
+	IngressVIP            string                 `yaml:"ingressVip,omitempty"`            // load balanced *.apps address, baremetalIp by default
+	BootstrapIP           string                 `yaml:"bootstrapIp,omitempty"`           // bootstrap node address, an api backend during the installation
+	ProvisioningDHCPRange string                 `yaml:"provisioningDhcpRange,omitempty"` // first,last addresses leased to PXE booting hosts
+	Extra                 map[string]interface{} `yaml:",inline"`
+}
+
+// AutomationSource : where the baremetal automation scripts are fetched from
+type AutomationSource struct {
+	Source string `yaml:"source,omitempty"` // go-getter source, or a local directory for development
+	Ref    string `yaml:"ref,omitempty"`    // git branch, tag or commit of a remote source
+}
+
+// IsLocal : whether the source is a local directory, copied instead of fetched
+func (as AutomationSource) IsLocal() bool {
+	return strings.HasPrefix(as.Source, "/") || strings.HasPrefix(as.Source, "./") ||
+		strings.HasPrefix(as.Source, "../") || strings.HasPrefix(as.Source, "file://")
+}
+
+// ProvisioningInfrastructure : settings for the automated deployment of baremetal
+// sites. Settings that are only consumed by the baremetal automation scripts are
+// kept in Extra
+type ProvisioningInfrastructure struct {
+	Hosts      Hosts                  `yaml:"hosts,omitempty"`
+	Network    Network                `yaml:"network,omitempty"`
+	Automation AutomationSource       `yaml:"automation,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+// SiteConfig : the kni.akraino.org/v1alpha1 SiteConfig object of a site
+type SiteConfig struct {
+	APIVersion                 string                      `yaml:"apiVersion"`
+	Kind                       string                      `yaml:"kind"`
+	Metadata                   Metadata                    `yaml:"metadata"`
+	Config                     ConfigValues                `yaml:"config"`
+	FieldSpecs                 []FieldSpec                 `yaml:"fieldSpecs,omitempty"`
+	Secrets                    secrets.Config              `yaml:"secrets,omitempty"`
+	SSH                        SSH                         `yaml:"ssh,omitempty"`
+	ProvisioningInfrastructure *ProvisioningInfrastructure `yaml:"provisioningInfrastructure,omitempty"`
+}
+
+// accepts any scalar as config value, rejecting lists and maps
+func (cv *ConfigValues) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var rawValues map[string]interface{}
+	err := unmarshal(&rawValues)
+	if err != nil {
+		return err
+	}
+
+	values := ConfigValues{}
+	for key, rawValue := range rawValues {
+		switch rawValue.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return fmt.Errorf("config.%s: value must be a scalar, not a list or map", key)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprintf("%v", rawValue)
+		}
+	}
+	*cv = values
+
+	return nil
+}
+
+// returns the config keys in order, so generated files are stable
+func (cv ConfigValues) Keys() []string {
+	keys := make([]string, 0, len(cv))
+	for key := range cv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parses a SiteConfig, rejecting unknown fields, and then defaults and validates it
+func Parse(content []byte) (SiteConfig, error) {
+	var siteConfig SiteConfig
+
+	err := yaml.UnmarshalStrict(content, &siteConfig)
+	if err != nil {
+		// yaml errors mention go types, point to typos instead
+		message := strings.Replace(err.Error(), "in type siteconfig.", "in ", -1)
+		return siteConfig, fmt.Errorf("%s (check for typos in field names)", message)
+	}
+
+	siteConfig.Default()
+
+	err = siteConfig.Validate()
+	if err != nil {
+		return siteConfig, err
+	}
+
+	return siteConfig, nil
+}
+
+// reads and parses a SiteConfig file
+func Load(path string) (SiteConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SiteConfig{}, fmt.Errorf("SiteConfig: Load: error reading %s: %s", path, err)
+	}
+
+	siteConfig, err := Parse(content)
+	if err != nil {
+		return siteConfig, fmt.Errorf("SiteConfig: Load: invalid %s: %s", path, err)
+	}
+
+	return siteConfig, nil
+}
+
+// fills the settings that have not been set with their default values
+func (sc *SiteConfig) Default() {
+	if sc.APIVersion == "" {
+		sc.APIVersion = APIVersion
+	}
+	if sc.Kind == "" {
+		sc.Kind = Kind
+	}
+	if sc.Config == nil {
+		sc.Config = ConfigValues{}
+	}
+	if sc.Secrets.Provider == "" {
+		sc.Secrets.Provider = "files"
+	}
+	if sc.SSH.KeyType == "" {
+		sc.SSH.KeyType = sshkey.KeyTypeEd25519
+	}
+	if len(sc.SSH.Sources) == 0 {
+		// an existing key of the user is reused before generating one
+		sc.SSH.Sources = []string{"sshDirectory", "agent", "generate"}
+	}
+
+	if sc.ProvisioningInfrastructure != nil {
+		hosts := &sc.ProvisioningInfrastructure.Hosts
+		for _, hostList := range [][]Host{hosts.Masters, hosts.Workers} {
+			for i := range hostList {
+				if hostList[i].BootInterface == "" {
+					hostList[i].BootInterface = hosts.DefaultBootInterface
+				}
+				if hostList[i].SdnInterface == "" {
+					hostList[i].SdnInterface = hosts.DefaultSdnInterface
+				}
+				if hostList[i].InstallDisk == "" {
+					hostList[i].InstallDisk = "/dev/sda"
+				}
+			}
+		}
+	}
+}
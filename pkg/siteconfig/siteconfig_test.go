@@ -0,0 +1,136 @@
+package siteconfig
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testSiteConfig = `apiVersion: kni.akraino.org/v1alpha1
+kind: SiteConfig
+metadata:
+  name: edge
+config:
+  clusterName: edge
+provisioningInfrastructure:
+  %s
+`
+
+func TestParseExtraFields(t *testing.T) {
+	siteConfig, err := Parse([]byte(strings.Replace(testSiteConfig, "%s", `hosts:
+    defaultBootInterface: eno1
+    defaultSdnInterface: eno2
+    ipmiRetries: 3
+    masters:
+    - name: master-0
+      bootMACAddress: 52:54:00:00:00:01
+      ip: 192.168.111.11
+      rootDeviceHints:
+        model: SSD
+  network:
+    baremetalIpCidr: 192.168.111.0/24
+    baremetalIp: 192.168.111.2
+    dns: 8.8.8.8
+  ntpServers:
+  - pool.ntp.org`, 1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pi := siteConfig.ProvisioningInfrastructure
+	for _, tc := range []struct {
+		name  string
+		extra map[string]interface{}
+		key   string
+		want  string
+	}{
+		{"provisioningInfrastructure", pi.Extra, "ntpServers", "[pool.ntp.org]"},
+		{"hosts", pi.Hosts.Extra, "ipmiRetries", "3"},
+		{"host", pi.Hosts.Masters[0].Extra, "rootDeviceHints", "map[model:SSD]"},
+		{"network", pi.Network.Extra, "dns", "8.8.8.8"},
+	} {
+		if got := fmt.Sprint(tc.extra[tc.key]); got != tc.want {
+			t.Errorf("expected %s extra %s to be %s, got %s", tc.name, tc.key, tc.want, got)
+		}
+	}
+}
+
+func TestParseUnknownFields(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"misspelled top level field", strings.Replace(testSiteConfig, "provisioningInfrastructure:\n  %s", "provisioningInfrastucture: {}", 1), "field provisioningInfrastucture not found"},
+		{"misspelled bmc field", strings.Replace(testSiteConfig, "%s", "hosts:\n    masters:\n    - name: master-0\n      bootMACAddress: 52:54:00:00:00:01\n      ip: 192.168.111.11\n      bmc:\n        adress: ipmi://10.0.0.1", 1), "field adress not found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	siteConfig, err := Parse([]byte(strings.Replace(testSiteConfig, "%s", `hosts:
+    defaultBootInterface: eno1
+    defaultBootInterfce: eno1
+    masters:
+    - name: master-0
+      bootMACAddress: 52:54:00:00:00:01
+      rootDeviceHints:
+        model: SSD
+      osProfile:
+        pxe: bios
+    workers:
+    - name: worker-0
+      bootMACAddress: 52:54:00:00:00:02
+      installdisk: /dev/sdb
+      ignitionUrl: http://172.22.0.1/worker.ign
+      customSetting: true
+  network:
+    dns: 8.8.8.8
+    bootstrapIP: 192.168.111.10
+  ntpServers:
+  - pool.ntp.org
+  automaton:
+    ref: v1`, 1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"provisioningInfrastructure.automaton: unknown setting, it is only passed to the automation scripts, did you mean automation?",
+		"provisioningInfrastructure.hosts.defaultBootInterfce: unknown setting, it is only passed to the automation scripts, did you mean defaultBootInterface?",
+		"provisioningInfrastructure.network.bootstrapIP: unknown setting, it is only passed to the automation scripts, did you mean bootstrapIp?",
+		"provisioningInfrastructure.hosts.workers[0].customSetting: unknown setting, it is only passed to the automation scripts",
+		"provisioningInfrastructure.hosts.workers[0].ignitionUrl: unknown setting, it is only passed to the automation scripts, did you mean ignitionURL?",
+		"provisioningInfrastructure.hosts.workers[0].installdisk: unknown setting, it is only passed to the automation scripts, did you mean installDisk?",
+	}
+	if got := siteConfig.Warnings(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	if warnings := (SiteConfig{}).Warnings(); len(warnings) != 0 {
+		t.Errorf("expected no warnings without provisioning infrastructure, got %v", warnings)
+	}
+}
+
+// an existing ed25519 key of the user is reused by default, before generating one
+func TestDefaultSSH(t *testing.T) {
+	var siteConfig SiteConfig
+	siteConfig.Default()
+
+	if siteConfig.SSH.KeyType != "ed25519" || strings.Join(siteConfig.SSH.Sources, ",") != "sshDirectory,agent,generate" {
+		t.Errorf("unexpected ssh defaults %+v", siteConfig.SSH)
+	}
+
+	siteConfig = SiteConfig{SSH: SSH{KeyType: "rsa", Sources: []string{"generate"}}}
+	siteConfig.Default()
+
+	if siteConfig.SSH.KeyType != "rsa" || strings.Join(siteConfig.SSH.Sources, ",") != "generate" {
+		t.Errorf("expected the ssh settings to be kept, got %+v", siteConfig.SSH)
+	}
+}
@@ -0,0 +1,252 @@
+package siteconfig
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/pullsecret"
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gopkg.in/yaml.v2"
+)
+
+// references to substitution variables inside a value, as $(NAME), or as the
+// placeholders used by the blueprints, replaced as whole words
+var variableReference = regexp.MustCompile(`\$\(([A-Za-z0-9_.-]+)\)|\b(PULL_SECRET|SSH_PUB_KEY|DOCKERCONFIGJSON|KUBECONFIGHUB|GITHUBUSER|GITHUBTOKEN)\b`)
+
+// builds the substitution variables for a site: the secrets retrieved from the
+// site secret provider, and every key of the config block. lookup holds the
+// directories used by the files provider when the site does not set a path
+func (sc SiteConfig) Variables(lookup secrets.Lookup) (map[string]string, error) {
+	variables := make(map[string]string)
+
+	provider, err := secrets.New(sc.Secrets, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("SiteConfig: Variables: error creating secret provider: %s", err)
+	}
+
+	names := sc.Secrets.SecretNames()
+	secretNames := map[string]string{
+		"PULL_SECRET":      names.PullSecret,
+		"SSH_PUB_KEY":      names.SSHPublicKey,
+		"DOCKERCONFIGJSON": names.DockerConfig,
+		"KUBECONFIGHUB":    names.KubeconfigHub,
+	}
+	for variable, secretName := range secretNames {
+		value, err := provider.GetSecret(secretName)
+		if err == secrets.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SiteConfig: Variables: error retrieving %s: %s", secretName, err)
+		}
+		variables[variable] = strings.TrimSpace(value)
+	}
+
+	// the pull secret is validated and injected as compact JSON, so extra newlines
+	// or spaces in its source cannot break install-config.yaml
+	if pullSecretContent, ok := variables["PULL_SECRET"]; ok {
+		ps, err := pullsecret.Parse(pullSecretContent)
+		if err != nil {
+			return nil, fmt.Errorf("SiteConfig: Variables: invalid pull secret %s: %s", names.PullSecret, err)
+		}
+		variables["PULL_SECRET"], err = ps.Marshal()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// additional authorized keys are injected with the site key, one per line
+	authorizedKeys := []string{}
+	if siteKey, ok := variables["SSH_PUB_KEY"]; ok && siteKey != "" {
+		authorizedKeys = append(authorizedKeys, siteKey)
+	}
+	for _, authorizedKey := range sc.SSH.AuthorizedKeys {
+		authorizedKey = strings.TrimSpace(authorizedKey)
+		if !contains(authorizedKeys, authorizedKey) {
+			authorizedKeys = append(authorizedKeys, authorizedKey)
+		}
+	}
+	if len(authorizedKeys) > 0 {
+		variables["SSH_PUB_KEY"] = strings.Join(authorizedKeys, "\n")
+	}
+
+	// github secret holds user and token keys
+	githubContent, err := provider.GetSecret(names.GithubSecret)
+	if err == nil {
+		var githubSecret map[string]string
+		err = yaml.Unmarshal([]byte(githubContent), &githubSecret)
+		if err != nil {
+			return nil, fmt.Errorf("SiteConfig: Variables: error parsing %s: %s", names.GithubSecret, err)
+		}
+		variables["GITHUBUSER"] = githubSecret["user"]
+		variables["GITHUBTOKEN"] = githubSecret["token"]
+	} else if err != secrets.ErrNotFound {
+		return nil, fmt.Errorf("SiteConfig: Variables: error retrieving %s: %s", names.GithubSecret, err)
+	}
+
+	for key, value := range sc.Config {
+		variables[key] = value
+	}
+
+	return variables, nil
+}
+
+// transforms the resources read from in, writing them to out. Legacy placeholders
+// and references to variables as $(NAME) are substituted in every value, and
+// the field specs of the SiteConfig are applied
+func (sc SiteConfig) Transform(variables map[string]string, in io.Reader, out io.Writer) error {
+	decoder := yaml.NewDecoder(in)
+	first := true
+
+	for {
+		var resource yaml.MapSlice
+		err := decoder.Decode(&resource)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("SiteConfig: Transform: error parsing resources: %s", err)
+		}
+		if resource == nil {
+			continue
+		}
+
+		transformed := substitute(resource, variables).(yaml.MapSlice)
+
+		for _, fieldSpec := range sc.FieldSpecs {
+			if !fieldSpec.matches(transformed) {
+				continue
+			}
+			value := substitute(fieldSpec.Value, variables)
+			transformed, err = setField(transformed, strings.Split(strings.Trim(fieldSpec.Path, "/"), "/"), value)
+			if err != nil {
+				return fmt.Errorf("SiteConfig: Transform: error setting field %s: %s", fieldSpec.Path, err)
+			}
+		}
+
+		transformedString, err := yaml.Marshal(transformed)
+		if err != nil {
+			return fmt.Errorf("SiteConfig: Transform: error marshaling resource: %s", err)
+		}
+
+		if !first {
+			_, err = io.WriteString(out, "---\n")
+			if err != nil {
+				return err
+			}
+		}
+		first = false
+
+		_, err = out.Write(transformedString)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walks a parsed value, substituting variables in every string scalar
+func substitute(value interface{}, variables map[string]string) interface{} {
+	switch typedValue := value.(type) {
+	case yaml.MapSlice:
+		for i := range typedValue {
+			typedValue[i].Value = substitute(typedValue[i].Value, variables)
+		}
+		return typedValue
+	case []interface{}:
+		for i := range typedValue {
+			typedValue[i] = substitute(typedValue[i], variables)
+		}
+		return typedValue
+	case string:
+		// replacement happens on the parsed value, so the result is always valid YAML
+		// whatever characters the variables contain
+		return variableReference.ReplaceAllStringFunc(typedValue, func(reference string) string {
+			submatches := variableReference.FindStringSubmatch(reference)
+			name := submatches[1]
+			if name == "" {
+				name = submatches[2]
+			}
+			if variable, ok := variables[name]; ok {
+				return variable
+			}
+			// unknown variables are left untouched
+			return reference
+		})
+	}
+
+	return value
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if a resource matches the kind and name of a field spec
+func (fs FieldSpec) matches(resource yaml.MapSlice) bool {
+	if fs.Kind != "" && fmt.Sprintf("%v", getField(resource, "kind")) != fs.Kind {
+		return false
+	}
+	if fs.Name != "" {
+		metadata, ok := getField(resource, "metadata").(yaml.MapSlice)
+		if !ok || fmt.Sprintf("%v", getField(metadata, "name")) != fs.Name {
+			return false
+		}
+	}
+	return true
+}
+
+func getField(resource yaml.MapSlice, key string) interface{} {
+	for _, item := range resource {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// sets the value at the given field path, creating the intermediate fields
+func setField(resource yaml.MapSlice, path []string, value interface{}) (yaml.MapSlice, error) {
+	if len(path) == 0 || path[0] == "" {
+		return resource, fmt.Errorf("empty field path")
+	}
+
+	for i := range resource {
+		if resource[i].Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			resource[i].Value = value
+			return resource, nil
+		}
+
+		child, ok := resource[i].Value.(yaml.MapSlice)
+		if !ok && resource[i].Value != nil {
+			return resource, fmt.Errorf("field %s is not a map", path[0])
+		}
+		child, err := setField(child, path[1:], value)
+		if err != nil {
+			return resource, err
+		}
+		resource[i].Value = child
+		return resource, nil
+	}
+
+	// field not found, so create it
+	if len(path) == 1 {
+		return append(resource, yaml.MapItem{Key: path[0], Value: value}), nil
+	}
+	child, err := setField(yaml.MapSlice{}, path[1:], value)
+	if err != nil {
+		return resource, err
+	}
+	return append(resource, yaml.MapItem{Key: path[0], Value: child}), nil
+}
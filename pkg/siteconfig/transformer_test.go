@@ -0,0 +1,174 @@
+package siteconfig
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gopkg.in/yaml.v2"
+)
+
+// resources as kustomize passes them to the transformer on stdin
+const testResources = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  pullSecret: PULL_SECRET
+  file: PULL_SECRET_FILE
+  sshKey: SSH_PUB_KEY
+  clusterName: $(CLUSTER_NAME)
+  unknown: $(UNKNOWN)
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+stringData:
+  token: GITHUBTOKEN
+`
+
+func TestTransform(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		resources  string
+		variables  map[string]string
+		fieldSpecs []FieldSpec
+		want       string
+		err        string
+	}{
+		{
+			name:      "placeholders",
+			resources: testResources,
+			variables: map[string]string{"PULL_SECRET": `{"auths":{}}`, "SSH_PUB_KEY": "ssh-ed25519 AAAA", "CLUSTER_NAME": "edge", "GITHUBTOKEN": "token"},
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  pullSecret: '{"auths":{}}'
+  file: PULL_SECRET_FILE
+  sshKey: ssh-ed25519 AAAA
+  clusterName: edge
+  unknown: $(UNKNOWN)
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+stringData:
+  token: token
+`,
+		},
+		{
+			name:      "word boundaries",
+			resources: "kind: ConfigMap\ndata:\n  a: MY_PULL_SECRET\n  b: PULL_SECRETS\n  c: pull_secret\n  d: '[PULL_SECRET]'\n  e: PULL_SECRET-x\n  f: $(PULL_SECRET)$(PULL_SECRET)\n",
+			variables: map[string]string{"PULL_SECRET": "ps"},
+			want:      "kind: ConfigMap\ndata:\n  a: MY_PULL_SECRET\n  b: PULL_SECRETS\n  c: pull_secret\n  d: '[ps]'\n  e: ps-x\n  f: psps\n",
+		},
+		{
+			name:       "field path injection",
+			resources:  "kind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  replaced: old\n---\nkind: ConfigMap\nmetadata:\n  name: other\n---\nkind: Secret\nmetadata:\n  name: settings\n",
+			variables:  map[string]string{"DNS": "192.168.111.1"},
+			fieldSpecs: []FieldSpec{{Kind: "ConfigMap", Name: "settings", Path: "/data/replaced", Value: "new"}, {Kind: "ConfigMap", Path: "spec/dns/server", Value: "$(DNS)"}},
+			want:       "kind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  replaced: new\nspec:\n  dns:\n    server: 192.168.111.1\n---\nkind: ConfigMap\nmetadata:\n  name: other\nspec:\n  dns:\n    server: 192.168.111.1\n---\nkind: Secret\nmetadata:\n  name: settings\n",
+		},
+		{
+			name:       "field path through a scalar",
+			resources:  "kind: ConfigMap\ndata: value\n",
+			fieldSpecs: []FieldSpec{{Path: "data/key", Value: "x"}},
+			err:        "SiteConfig: Transform: error setting field data/key: field data is not a map",
+		},
+		{
+			name:      "invalid resources",
+			resources: "kind: [ConfigMap\n",
+			err:       "SiteConfig: Transform: error parsing resources",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := SiteConfig{FieldSpecs: tc.fieldSpecs}.Transform(tc.variables, strings.NewReader(tc.resources), &out)
+
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("expected\n%s\ngot\n%s", tc.want, out.String())
+			}
+		})
+	}
+}
+
+// values that would break YAML if they were substituted in the text are kept
+// intact, as the substitution happens on the parsed values
+func TestTransformYAMLSafe(t *testing.T) {
+	for _, value := range []string{
+		"a | b",
+		"|\n  literal",
+		`with "double" and 'single' quotes`,
+		"first line\nsecond: line\n- third",
+		"# not a comment",
+		"{\"auths\": {\"quay.io\": {\"auth\": \"dXNlcjpwYXNz\"}}}",
+	} {
+		for _, fieldSpec := range [][]FieldSpec{nil, {{Kind: "ConfigMap", Path: "data/injected", Value: "prefix $(VALUE)"}}} {
+			var out bytes.Buffer
+			err := SiteConfig{FieldSpecs: fieldSpec}.Transform(map[string]string{"VALUE": value}, strings.NewReader("kind: ConfigMap\ndata:\n  value: $(VALUE)\n  legacy: PULL_SECRET\n"), &out)
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %s", value, err)
+			}
+
+			var resource struct {
+				Data map[string]string `yaml:"data"`
+			}
+			err = yaml.Unmarshal(out.Bytes(), &resource)
+			if err != nil {
+				t.Fatalf("invalid output for %q: %s\n%s", value, err, out.String())
+			}
+
+			if resource.Data["value"] != value {
+				t.Errorf("expected %q, got %q", value, resource.Data["value"])
+			}
+			if fieldSpec != nil && resource.Data["injected"] != "prefix "+value {
+				t.Errorf("expected %q injected, got %q", "prefix "+value, resource.Data["injected"])
+			}
+			if resource.Data["legacy"] != "PULL_SECRET" {
+				t.Errorf("expected the placeholder without variable to be kept, got %q", resource.Data["legacy"])
+			}
+		}
+	}
+}
+
+// the keys of the config block are substitution variables, next to the secrets
+func TestVariablesConfig(t *testing.T) {
+	root := t.TempDir()
+
+	sc := SiteConfig{
+		Config: ConfigValues{"CLUSTER_NAME": "edge", "NTP_SERVERS": "0.pool.ntp.org|1.pool.ntp.org"},
+	}
+
+	variables, err := sc.Variables(secrets.Lookup{SitePath: filepath.Join(root, "site"), GlobalPath: filepath.Join(root, "kni")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var out bytes.Buffer
+	err = sc.Transform(variables, strings.NewReader("kind: ConfigMap\ndata:\n  name: $(CLUSTER_NAME).example.com\n  ntp: $(NTP_SERVERS)\n  pullSecret: PULL_SECRET\n"), &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// no pull secret in the lookup directories, so its placeholder is kept
+	want := "kind: ConfigMap\ndata:\n  name: edge.example.com\n  ntp: 0.pool.ntp.org|1.pool.ntp.org\n  pullSecret: PULL_SECRET\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
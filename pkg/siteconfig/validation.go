@@ -0,0 +1,364 @@
+package siteconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gerrit.akraino.org/kni/installer/pkg/sshkey"
+)
+
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BMC address schemes supported by the bmc package, redfish using https by default
+var bmcSchemes = map[string]bool{"ipmi": true, "redfish": true, "redfish+http": true, "redfish+https": true}
+
+// ValidationError : all the problems found in a SiteConfig, one per line
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("%d validation error(s):\n  - %s", len(ve.Problems), strings.Join(ve.Problems, "\n  - "))
+}
+
+func (ve *ValidationError) add(field string, format string, args ...interface{}) {
+	ve.Problems = append(ve.Problems, fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// checks the SiteConfig, returning a ValidationError listing every problem found
+func (sc SiteConfig) Validate() error {
+	ve := &ValidationError{}
+
+	if sc.APIVersion != APIVersion {
+		ve.add("apiVersion", "must be %s, got %q", APIVersion, sc.APIVersion)
+	}
+	if sc.Kind != Kind {
+		ve.add("kind", "must be %s, got %q", Kind, sc.Kind)
+	}
+
+	for _, key := range sc.Config.Keys() {
+		if !envVarName.MatchString(key) {
+			ve.add(fmt.Sprintf("config.%s", key), "is exported as an env var, so it can only contain letters, digits and underscores")
+		}
+	}
+	if virtualizedInstall, ok := sc.Config["virtualizedInstall"]; ok && virtualizedInstall != "true" && virtualizedInstall != "false" {
+		ve.add("config.virtualizedInstall", "must be true or false, got %q", virtualizedInstall)
+	}
+	if releaseImageOverride, ok := sc.Config["releaseImageOverride"]; ok && !strings.ContainsAny(releaseImageOverride[strings.LastIndex(releaseImageOverride, "/")+1:], ":@") {
+		ve.add("config.releaseImageOverride", "must be an image with a tag or digest, got %q", releaseImageOverride)
+	}
+
+	for i, fieldSpec := range sc.FieldSpecs {
+		if strings.Trim(fieldSpec.Path, "/") == "" {
+			ve.add(fmt.Sprintf("fieldSpecs[%d].path", i), "is required")
+		}
+	}
+
+	switch sc.Secrets.Provider {
+	case "files", "env":
+	case "vault":
+		if sc.Secrets.Vault.Path == "" {
+			ve.add("secrets.vault.path", "is required for the vault provider")
+		}
+	case "kubernetes":
+		if sc.Secrets.Kubernetes.Name == "" {
+			ve.add("secrets.kubernetes.name", "is required for the kubernetes provider")
+		}
+	default:
+		ve.add("secrets.provider", "must be one of files, env, vault or kubernetes, got %q", sc.Secrets.Provider)
+	}
+	if sc.Secrets.CredentialSet != "" {
+		if sc.Secrets.Provider != "files" {
+			ve.add("secrets.credentialSet", "is only supported by the files provider")
+		}
+		if strings.ContainsAny(sc.Secrets.CredentialSet, "/\\") || strings.HasPrefix(sc.Secrets.CredentialSet, ".") {
+			ve.add("secrets.credentialSet", "must be a directory name, got %q", sc.Secrets.CredentialSet)
+		}
+	}
+
+	if sc.SSH.KeyType != sshkey.KeyTypeEd25519 && sc.SSH.KeyType != sshkey.KeyTypeRSA {
+		ve.add("ssh.keyType", "must be ed25519 or rsa, got %q", sc.SSH.KeyType)
+	}
+	for i, source := range sc.SSH.Sources {
+		if source != "sshDirectory" && source != "agent" && source != "generate" {
+			ve.add(fmt.Sprintf("ssh.sources[%d]", i), "must be one of sshDirectory, agent or generate, got %q", source)
+		}
+	}
+	for i, authorizedKey := range sc.SSH.AuthorizedKeys {
+		if _, err := sshkey.ParseAuthorizedKey(authorizedKey); err != nil {
+			ve.add(fmt.Sprintf("ssh.authorizedKeys[%d]", i), "invalid public key: %s", err)
+		}
+	}
+
+	if sc.ProvisioningInfrastructure != nil {
+		sc.ProvisioningInfrastructure.validate(ve)
+	}
+
+	if len(ve.Problems) > 0 {
+		return *ve
+	}
+	return nil
+}
+
+func (pi ProvisioningInfrastructure) validate(ve *ValidationError) {
+	if pi.Automation.Ref != "" {
+		if pi.Automation.Source == "" {
+			ve.add("provisioningInfrastructure.automation.ref", "needs a source")
+		} else if pi.Automation.IsLocal() {
+			ve.add("provisioningInfrastructure.automation.ref", "is not supported for local directories, check it out in %s instead", pi.Automation.Source)
+		} else if strings.Contains(pi.Automation.Source, "ref=") {
+			ve.add("provisioningInfrastructure.automation.ref", "is also set in the source %q", pi.Automation.Source)
+		}
+	}
+
+	network := pi.Network
+	for _, field := range []string{"provisioningIpCidr", "baremetalIpCidr"} {
+		cidr := network.ProvisioningIPCIDR
+		if field == "baremetalIpCidr" {
+			cidr = network.BaremetalIPCIDR
+		}
+		if cidr == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			ve.add(fmt.Sprintf("provisioningInfrastructure.network.%s", field), "invalid CIDR %q", cidr)
+		}
+	}
+
+	for _, vlan := range []struct {
+		field string
+		id    int
+	}{{"provisioningVlan", network.ProvisioningVLAN}, {"baremetalVlan", network.BaremetalVLAN}} {
+		if vlan.id < 0 || vlan.id > 4094 {
+			ve.add(fmt.Sprintf("provisioningInfrastructure.network.%s", vlan.field), "must be a VLAN id between 1 and 4094, got %d", vlan.id)
+		}
+	}
+	for _, address := range []struct {
+		field string
+		ip    string
+	}{{"provisioningIp", network.ProvisioningIP}, {"baremetalIp", network.BaremetalIP}, {"baremetalGWIP", network.BaremetalGatewayIP},
+		{"apiVip", network.APIVIP}, {"ingressVip", network.IngressVIP}, {"bootstrapIp", network.BootstrapIP}} {
+		if address.ip != "" && net.ParseIP(address.ip) == nil {
+			ve.add(fmt.Sprintf("provisioningInfrastructure.network.%s", address.field), "invalid IP address %q", address.ip)
+		}
+	}
+	if network.ProvisioningDHCPRange != "" {
+		bounds := strings.Split(network.ProvisioningDHCPRange, ",")
+		if len(bounds) != 2 || net.ParseIP(strings.TrimSpace(bounds[0])) == nil || net.ParseIP(strings.TrimSpace(bounds[1])) == nil {
+			ve.add("provisioningInfrastructure.network.provisioningDhcpRange", "must be '<first IP>,<last IP>', got %q", network.ProvisioningDHCPRange)
+		}
+	}
+
+	names := map[string]string{}
+	macs := map[string]string{}
+	ips := map[string]string{}
+
+	for _, role := range []string{"masters", "workers"} {
+		hostList := pi.Hosts.Masters
+		if role == "workers" {
+			hostList = pi.Hosts.Workers
+		}
+		for i, host := range hostList {
+			field := fmt.Sprintf("provisioningInfrastructure.hosts.%s[%d]", role, i)
+
+			if host.Name == "" {
+				ve.add(fmt.Sprintf("%s.name", field), "is required")
+			} else if other, ok := names[host.Name]; ok {
+				ve.add(fmt.Sprintf("%s.name", field), "%s is also used by %s", host.Name, other)
+			} else {
+				names[host.Name] = field
+			}
+
+			for _, macField := range []string{"bootMACAddress", "sdnMACAddress"} {
+				mac := host.BootMACAddress
+				if macField == "sdnMACAddress" {
+					mac = host.SdnMACAddress
+				}
+				if mac == "" {
+					if macField == "bootMACAddress" {
+						ve.add(fmt.Sprintf("%s.%s", field, macField), "is required")
+					}
+					continue
+				}
+				parsedMAC, err := net.ParseMAC(mac)
+				if err != nil {
+					ve.add(fmt.Sprintf("%s.%s", field, macField), "invalid MAC address %q", mac)
+					continue
+				}
+				if other, ok := macs[parsedMAC.String()]; ok {
+					ve.add(fmt.Sprintf("%s.%s", field, macField), "%s is also used by %s", mac, other)
+				} else {
+					macs[parsedMAC.String()] = fmt.Sprintf("%s.%s", field, macField)
+				}
+			}
+
+			if host.IP != "" {
+				if net.ParseIP(host.IP) == nil {
+					ve.add(fmt.Sprintf("%s.ip", field), "invalid IP address %q", host.IP)
+				} else if other, ok := ips[host.IP]; ok {
+					ve.add(fmt.Sprintf("%s.ip", field), "%s is also used by %s", host.IP, other)
+				} else {
+					ips[host.IP] = field
+				}
+			}
+
+			if host.IgnitionURL != "" {
+				ignitionURL, err := url.Parse(host.IgnitionURL)
+				if err != nil || (ignitionURL.Scheme != "http" && ignitionURL.Scheme != "https") || ignitionURL.Host == "" {
+					ve.add(fmt.Sprintf("%s.ignitionURL", field), "invalid ignition URL %q, expected http:// or https://", host.IgnitionURL)
+				}
+			}
+
+			if host.BMC.Address != "" {
+				bmcURL, err := url.Parse(host.BMC.Address)
+				if err != nil || bmcURL.Host == "" || !bmcSchemes[bmcURL.Scheme] {
+					ve.add(fmt.Sprintf("%s.bmc.address", field), "invalid BMC address %q, expected ipmi://host or redfish://host/path", host.BMC.Address)
+				}
+			}
+			if host.BMC.CredentialsName != "" && (host.BMC.Username != "" || host.BMC.Password != "") {
+				ve.add(fmt.Sprintf("%s.bmc", field), "set either credentialsName or username and password, not both")
+			}
+		}
+	}
+}
+
+// AutomationSettings : the settings read by the baremetal automation scripts
+// from each block of provisioningInfrastructure, that knictl keeps in Extra
+// without using them. Other keys are reported by Warnings, as they are likely typos
+var AutomationSettings = map[string][]string{
+	"provisioningInfrastructure": {"ntpServers"},
+	"hosts":                      {},
+	"host":                       {"osProfile", "rootDeviceHints"},
+	"network":                    {"dns"},
+}
+
+// Warnings : the keys of provisioningInfrastructure that neither knictl nor the
+// automation scripts know about. They are kept for the scripts, but most likely
+// are typos, that the strict parsing can not reject
+func (sc SiteConfig) Warnings() []string {
+	warnings := []string{}
+
+	pi := sc.ProvisioningInfrastructure
+	if pi == nil {
+		return warnings
+	}
+
+	check := func(field string, block string, fields interface{}, extra map[string]interface{}) {
+		for _, key := range sortedKeys(extra) {
+			if containsString(AutomationSettings[block], key) {
+				continue
+			}
+
+			warning := fmt.Sprintf("%s.%s: unknown setting, it is only passed to the automation scripts", field, key)
+			if suggestion := closestField(key, yamlFieldNames(fields)); suggestion != "" {
+				warning = fmt.Sprintf("%s, did you mean %s?", warning, suggestion)
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+
+	check("provisioningInfrastructure", "provisioningInfrastructure", *pi, pi.Extra)
+	check("provisioningInfrastructure.hosts", "hosts", pi.Hosts, pi.Hosts.Extra)
+	check("provisioningInfrastructure.network", "network", pi.Network, pi.Network.Extra)
+
+	for _, role := range []string{"masters", "workers"} {
+		hostList := pi.Hosts.Masters
+		if role == "workers" {
+			hostList = pi.Hosts.Workers
+		}
+		for i, host := range hostList {
+			check(fmt.Sprintf("provisioningInfrastructure.hosts.%s[%d]", role, i), "host", host, host.Extra)
+		}
+	}
+
+	return warnings
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// the YAML names of the fields of a struct, without the inline ones
+func yamlFieldNames(value interface{}) []string {
+	names := []string{}
+
+	structType := reflect.TypeOf(value)
+	for i := 0; i < structType.NumField(); i++ {
+		name := strings.Split(structType.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// the field the key is most likely a typo of: the same name in another case, or
+// a name at most two edits away. Empty if there is none
+func closestField(key string, fields []string) string {
+	closest := ""
+	closestDistance := 3
+
+	for _, field := range fields {
+		if strings.EqualFold(key, field) {
+			return field
+		}
+		if distance := editDistance(strings.ToLower(key), strings.ToLower(field)); distance < closestDistance {
+			closest, closestDistance = field, distance
+		}
+	}
+
+	return closest
+}
+
+// the Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
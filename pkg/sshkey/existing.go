@@ -0,0 +1,122 @@
+package sshkey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	agentRequestIdentities = 11
+	agentIdentitiesAnswer  = 12
+)
+
+// public keys looked up in ~/.ssh, in order of preference
+var sshDirectoryKeys = []string{"id_ed25519.pub", "id_rsa.pub", "id_ecdsa.pub"}
+
+// Returns the first public key of the given type found in the .ssh directory
+// of the user, or an empty string if there is none
+func FindInSSHDirectory(keyType string) (string, error) {
+	for _, keyFile := range sshDirectoryKeys {
+		content, err := ioutil.ReadFile(fmt.Sprintf("%s/.ssh/%s", os.Getenv("HOME"), keyFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("SSHKey: FindInSSHDirectory: error reading %s: %s", keyFile, err)
+		}
+
+		authorizedKey := strings.TrimSpace(string(content))
+		foundType, err := ParseAuthorizedKey(authorizedKey)
+		if err != nil {
+			return "", fmt.Errorf("SSHKey: FindInSSHDirectory: invalid public key %s: %s", keyFile, err)
+		}
+		if foundType == keyType {
+			return authorizedKey, nil
+		}
+	}
+
+	return "", nil
+}
+
+// Returns the first public key of the given type held by the ssh agent set in
+// SSH_AUTH_SOCK, or an empty string if there is none
+func FindInAgent(keyType string) (string, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return "", nil
+	}
+
+	conn, err := net.DialTimeout("unix", socket, 10*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("SSHKey: FindInAgent: error connecting to ssh agent: %s", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	authorizedKey, err := queryAgent(conn, keyType)
+	if err != nil {
+		return "", fmt.Errorf("SSHKey: FindInAgent: %s", err)
+	}
+
+	return authorizedKey, nil
+}
+
+// asks the ssh agent at the other end of the connection for its identities,
+// returning the first one of the given type
+func queryAgent(conn io.ReadWriter, keyType string) (string, error) {
+	// agent messages are a length-prefixed type and payload
+	_, err := conn.Write([]byte{0, 0, 0, 1, agentRequestIdentities})
+	if err != nil {
+		return "", fmt.Errorf("error querying ssh agent: %s", err)
+	}
+
+	var length uint32
+	err = binary.Read(conn, binary.BigEndian, &length)
+	if err != nil {
+		return "", fmt.Errorf("error reading ssh agent answer: %s", err)
+	}
+	if length == 0 || length > 256*1024 {
+		return "", fmt.Errorf("invalid ssh agent answer length %d", length)
+	}
+
+	answer := make([]byte, length)
+	_, err = io.ReadFull(conn, answer)
+	if err != nil {
+		return "", fmt.Errorf("error reading ssh agent answer: %s", err)
+	}
+	if answer[0] != agentIdentitiesAnswer || len(answer) < 5 {
+		return "", fmt.Errorf("unexpected ssh agent answer type %d", answer[0])
+	}
+
+	keyCount := binary.BigEndian.Uint32(answer[1:5])
+	data := answer[5:]
+	for i := uint32(0); i < keyCount; i++ {
+		var blob, comment []byte
+		blob, data, err = readString(data)
+		if err == nil {
+			comment, data, err = readString(data)
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid ssh agent identity: %s", err)
+		}
+
+		wireKeyType, _, err := readString(blob)
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(wireKeyType, []byte(wireKeyTypes[keyType])) {
+			continue
+		}
+
+		return formatAuthorizedKey(string(wireKeyType), blob, string(comment)), nil
+	}
+
+	return "", nil
+}
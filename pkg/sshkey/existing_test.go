@@ -0,0 +1,239 @@
+package sshkey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// an identity held by the fake agent
+type agentIdentity struct {
+	authorizedKey string
+}
+
+// answers one request identities message on the connection, with the
+// identities or with a raw answer when it is set
+func serveAgent(t *testing.T, conn io.ReadWriteCloser, identities []agentIdentity, raw []byte) {
+	defer conn.Close()
+
+	request := make([]byte, 5)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		t.Errorf("fake agent: error reading request: %s", err)
+		return
+	}
+	if !bytes.Equal(request, []byte{0, 0, 0, 1, agentRequestIdentities}) {
+		t.Errorf("fake agent: unexpected request %v", request)
+		return
+	}
+
+	if raw == nil {
+		var answer bytes.Buffer
+		answer.WriteByte(agentIdentitiesAnswer)
+		binary.Write(&answer, binary.BigEndian, uint32(len(identities)))
+		for _, identity := range identities {
+			fields := strings.SplitN(identity.authorizedKey, " ", 3)
+			comment := ""
+			if len(fields) == 3 {
+				comment = fields[2]
+			}
+			writeString(&answer, authorizedKeyBlob(t, identity.authorizedKey))
+			writeString(&answer, []byte(comment))
+		}
+		raw = answer.Bytes()
+	}
+
+	var message bytes.Buffer
+	writeString(&message, raw)
+	conn.Write(message.Bytes())
+}
+
+// a connected pair of unix sockets, as the agent socket gives
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := []net.Conn{}
+	for i, fd := range fds {
+		file := os.NewFile(uintptr(fd), "agent")
+		conn, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+		t.Cleanup(func() { conns[i].Close() })
+	}
+
+	return conns[0], conns[1]
+}
+
+func TestQueryAgent(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := Generate(KeyTypeRSA, "rsa@example.com", filepath.Join(dir, "id_rsa"))
+	ed25519Key, _ := Generate(KeyTypeEd25519, "ed25519@example.com", filepath.Join(dir, "id_ed25519"))
+	otherKey, _ := Generate(KeyTypeEd25519, "", filepath.Join(dir, "id_other"))
+
+	for _, tc := range []struct {
+		name       string
+		identities []agentIdentity
+		raw        []byte // raw answer instead of the identities
+		keyType    string
+		want       string
+		err        string
+	}{
+		{"first of the type", []agentIdentity{{rsaKey}, {ed25519Key}, {otherKey}}, nil, KeyTypeEd25519, ed25519Key, ""},
+		{"rsa", []agentIdentity{{ed25519Key}, {rsaKey}}, nil, KeyTypeRSA, rsaKey, ""},
+		{"without comment", []agentIdentity{{otherKey}}, nil, KeyTypeEd25519, otherKey, ""},
+		{"none of the type", []agentIdentity{{rsaKey}}, nil, KeyTypeEd25519, "", ""},
+		{"no identities", nil, nil, KeyTypeEd25519, "", ""},
+		{"failure", nil, []byte{5}, KeyTypeEd25519, "", "unexpected ssh agent answer type 5"},
+		{"truncated identity", nil, []byte{agentIdentitiesAnswer, 0, 0, 0, 1, 0, 0, 0, 9, 1}, KeyTypeEd25519, "", "invalid ssh agent identity: truncated data"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, agent := socketPair(t)
+			go serveAgent(t, agent, tc.identities, tc.raw)
+
+			client.SetDeadline(time.Now().Add(10 * time.Second))
+			authorizedKey, err := queryAgent(client, tc.keyType)
+
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if authorizedKey != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, authorizedKey)
+			}
+		})
+	}
+}
+
+func TestFindInAgent(t *testing.T) {
+	dir := t.TempDir()
+	ed25519Key, _ := Generate(KeyTypeEd25519, "ed25519@example.com", filepath.Join(dir, "id_ed25519"))
+
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			serveAgent(t, conn, []agentIdentity{{ed25519Key}}, nil)
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	authorizedKey, err := FindInAgent(KeyTypeEd25519)
+	if err != nil || authorizedKey != ed25519Key {
+		t.Errorf("expected %q, got %q %v", ed25519Key, authorizedKey, err)
+	}
+
+	// no agent
+	t.Setenv("SSH_AUTH_SOCK", "")
+	authorizedKey, err = FindInAgent(KeyTypeEd25519)
+	if err != nil || authorizedKey != "" {
+		t.Errorf("expected no key without agent, got %q %v", authorizedKey, err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(dir, "missing.sock"))
+	_, err = FindInAgent(KeyTypeEd25519)
+	if err == nil || !strings.HasPrefix(err.Error(), "SSHKey: FindInAgent: error connecting to ssh agent") {
+		t.Errorf("expected the missing agent to be reported, got %v", err)
+	}
+}
+
+// the generated keys are loaded in a real ssh-agent, and found back
+func TestFindInSSHAgent(t *testing.T) {
+	sshAgent, err := exec.LookPath("ssh-agent")
+	if err != nil {
+		t.Skip("ssh-agent not found")
+	}
+	sshAdd, err := exec.LookPath("ssh-add")
+	if err != nil {
+		t.Skip("ssh-add not found")
+	}
+
+	dir := t.TempDir()
+	ed25519Key, _ := Generate(KeyTypeEd25519, "ed25519@example.com", filepath.Join(dir, "id_ed25519"))
+
+	socket := filepath.Join(dir, "agent.sock")
+	agent := exec.Command(sshAgent, "-D", "-a", socket)
+	if err := agent.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		agent.Process.Kill()
+		agent.Wait()
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	out, err := exec.Command(sshAdd, filepath.Join(dir, "id_ed25519")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ssh-add can not load the generated key: %s: %s", err, out)
+	}
+
+	authorizedKey, err := FindInAgent(KeyTypeEd25519)
+	if err != nil || authorizedKey != ed25519Key {
+		t.Errorf("expected %q, got %q %v", ed25519Key, authorizedKey, err)
+	}
+
+	authorizedKey, err = FindInAgent(KeyTypeRSA)
+	if err != nil || authorizedKey != "" {
+		t.Errorf("expected no rsa key, got %q %v", authorizedKey, err)
+	}
+}
+
+func TestFindInSSHDirectory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	authorizedKey, err := FindInSSHDirectory(KeyTypeEd25519)
+	if err != nil || authorizedKey != "" {
+		t.Errorf("expected no key without .ssh directory, got %q %v", authorizedKey, err)
+	}
+
+	rsaKey, _ := Generate(KeyTypeRSA, "", filepath.Join(home, ".ssh", "id_rsa"))
+
+	authorizedKey, err = FindInSSHDirectory(KeyTypeEd25519)
+	if err != nil || authorizedKey != "" {
+		t.Errorf("expected no ed25519 key, got %q %v", authorizedKey, err)
+	}
+	authorizedKey, err = FindInSSHDirectory(KeyTypeRSA)
+	if err != nil || authorizedKey != rsaKey {
+		t.Errorf("expected %q, got %q %v", rsaKey, authorizedKey, err)
+	}
+
+	ioutil.WriteFile(filepath.Join(home, ".ssh", "id_ed25519.pub"), []byte("ssh-ed25519 invalid\n"), 0644)
+	_, err = FindInSSHDirectory(KeyTypeRSA)
+	if err == nil || !strings.Contains(err.Error(), "invalid public key id_ed25519.pub") {
+		t.Errorf("expected the invalid key to be reported, got %v", err)
+	}
+}
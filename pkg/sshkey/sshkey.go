@@ -0,0 +1,200 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeRSA     = "rsa"
+
+	rsaBits = 3072
+)
+
+// names of the key types in the SSH wire format, and in authorized keys
+var wireKeyTypes = map[string]string{
+	KeyTypeEd25519: "ssh-ed25519",
+	KeyTypeRSA:     "ssh-rsa",
+}
+
+// Generates a keypair of the given type, writing the private key to
+// privateKeyPath and the public key next to it, with a .pub suffix. Existing
+// keys are never overwritten. Returns the public key in authorized keys format
+func Generate(keyType string, comment string, privateKeyPath string) (string, error) {
+	for _, path := range []string{privateKeyPath, fmt.Sprintf("%s.pub", privateKeyPath)} {
+		if _, err := os.Stat(path); err == nil {
+			return "", fmt.Errorf("SSHKey: Generate: %s already exists, refusing to overwrite it", path)
+		}
+	}
+
+	var publicKeyBlob []byte
+	var privateKeyPEM *pem.Block
+
+	switch keyType {
+	case KeyTypeEd25519:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", fmt.Errorf("SSHKey: Generate: error generating ed25519 key: %s", err)
+		}
+		publicKeyBlob = ed25519PublicKeyBlob(publicKey)
+		privateKeyPEM, err = ed25519PrivateKeyPEM(publicKey, privateKey, comment)
+		if err != nil {
+			return "", err
+		}
+	case KeyTypeRSA:
+		privateKey, err := rsa.GenerateKey(rand.Reader, rsaBits)
+		if err != nil {
+			return "", fmt.Errorf("SSHKey: Generate: error generating rsa key: %s", err)
+		}
+		publicKeyBlob = rsaPublicKeyBlob(&privateKey.PublicKey)
+		// OpenSSH reads PKCS#1 keys as well as its own format
+		privateKeyPEM = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+	default:
+		return "", fmt.Errorf("SSHKey: Generate: unsupported key type '%s', use ed25519 or rsa", keyType)
+	}
+
+	authorizedKey := formatAuthorizedKey(wireKeyTypes[keyType], publicKeyBlob, comment)
+
+	err := os.MkdirAll(filepath.Dir(privateKeyPath), 0700)
+	if err != nil {
+		return "", fmt.Errorf("SSHKey: Generate: error creating key directory: %s", err)
+	}
+
+	err = ioutil.WriteFile(privateKeyPath, pem.EncodeToMemory(privateKeyPEM), 0600)
+	if err != nil {
+		return "", fmt.Errorf("SSHKey: Generate: error writing private key: %s", err)
+	}
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s.pub", privateKeyPath), []byte(fmt.Sprintf("%s\n", authorizedKey)), 0644)
+	if err != nil {
+		return "", fmt.Errorf("SSHKey: Generate: error writing public key: %s", err)
+	}
+
+	return authorizedKey, nil
+}
+
+// Parses a public key in authorized keys format, returning its key type as used
+// by Generate (ed25519, rsa), or the wire name for other types such as ecdsa
+func ParseAuthorizedKey(authorizedKey string) (string, error) {
+	fields := strings.Fields(authorizedKey)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("SSHKey: ParseAuthorizedKey: expected '<type> <base64 key> [comment]'")
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("SSHKey: ParseAuthorizedKey: key is not valid base64: %s", err)
+	}
+
+	// the key blob starts with its type, that must match the declared one
+	blobType, _, err := readString(blob)
+	if err != nil || string(blobType) != fields[0] {
+		return "", fmt.Errorf("SSHKey: ParseAuthorizedKey: key data does not match key type %s", fields[0])
+	}
+
+	for keyType, wireKeyType := range wireKeyTypes {
+		if wireKeyType == fields[0] {
+			return keyType, nil
+		}
+	}
+	return fields[0], nil
+}
+
+// builds the authorized keys line for a public key blob
+func formatAuthorizedKey(wireKeyType string, blob []byte, comment string) string {
+	authorizedKey := fmt.Sprintf("%s %s", wireKeyType, base64.StdEncoding.EncodeToString(blob))
+	if comment != "" {
+		authorizedKey = fmt.Sprintf("%s %s", authorizedKey, comment)
+	}
+	return authorizedKey
+}
+
+func ed25519PublicKeyBlob(publicKey ed25519.PublicKey) []byte {
+	var blob bytes.Buffer
+	writeString(&blob, []byte(wireKeyTypes[KeyTypeEd25519]))
+	writeString(&blob, publicKey)
+	return blob.Bytes()
+}
+
+func rsaPublicKeyBlob(publicKey *rsa.PublicKey) []byte {
+	var blob bytes.Buffer
+	writeString(&blob, []byte(wireKeyTypes[KeyTypeRSA]))
+	writeMPInt(&blob, big.NewInt(int64(publicKey.E)))
+	writeMPInt(&blob, publicKey.N)
+	return blob.Bytes()
+}
+
+// encodes an unencrypted ed25519 key in the openssh-key-v1 format, the only one
+// that OpenSSH supports for ed25519
+func ed25519PrivateKeyPEM(publicKey ed25519.PublicKey, privateKey ed25519.PrivateKey, comment string) (*pem.Block, error) {
+	checkBytes := make([]byte, 4)
+	_, err := rand.Read(checkBytes)
+	if err != nil {
+		return nil, fmt.Errorf("SSHKey: ed25519PrivateKeyPEM: error generating check bytes: %s", err)
+	}
+
+	var private bytes.Buffer
+	// the same random check value twice, used by OpenSSH to detect a wrong passphrase
+	private.Write(checkBytes)
+	private.Write(checkBytes)
+	writeString(&private, []byte(wireKeyTypes[KeyTypeEd25519]))
+	writeString(&private, publicKey)
+	writeString(&private, privateKey)
+	writeString(&private, []byte(comment))
+	// pad to the cipher block size, 8 for no cipher, with 1, 2, 3...
+	for i := byte(1); private.Len()%8 != 0; i++ {
+		private.WriteByte(i)
+	}
+
+	var key bytes.Buffer
+	key.WriteString("openssh-key-v1\x00")
+	writeString(&key, []byte("none")) // cipher
+	writeString(&key, []byte("none")) // kdf
+	writeString(&key, []byte{})       // kdf options
+	binary.Write(&key, binary.BigEndian, uint32(1))
+	writeString(&key, ed25519PublicKeyBlob(publicKey))
+	writeString(&key, private.Bytes())
+
+	return &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: key.Bytes()}, nil
+}
+
+// writes a length-prefixed string, as defined in RFC 4251
+func writeString(buffer *bytes.Buffer, value []byte) {
+	binary.Write(buffer, binary.BigEndian, uint32(len(value)))
+	buffer.Write(value)
+}
+
+// writes a positive multiple precision integer, as defined in RFC 4251
+func writeMPInt(buffer *bytes.Buffer, value *big.Int) {
+	valueBytes := value.Bytes()
+	// a leading zero keeps the number positive when the high bit is set
+	if len(valueBytes) > 0 && valueBytes[0]&0x80 != 0 {
+		valueBytes = append([]byte{0}, valueBytes...)
+	}
+	writeString(buffer, valueBytes)
+}
+
+// reads a length-prefixed string, returning it and the remaining data
+func readString(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("truncated data")
+	}
+	length := binary.BigEndian.Uint32(data)
+	if uint64(len(data)-4) < uint64(length) {
+		return nil, nil, fmt.Errorf("truncated data")
+	}
+	return data[4 : 4+length], data[4+length:], nil
+}
@@ -0,0 +1,275 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the key blob of an authorized key line
+func authorizedKeyBlob(t *testing.T, authorizedKey string) []byte {
+	t.Helper()
+
+	fields := strings.Fields(authorizedKey)
+	if len(fields) < 2 {
+		t.Fatalf("invalid authorized key %q", authorizedKey)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		t.Fatalf("invalid authorized key %q: %s", authorizedKey, err)
+	}
+	return blob
+}
+
+// reads the length-prefixed strings of data, failing if there are fewer
+func readStrings(t *testing.T, data []byte, count int) ([][]byte, []byte) {
+	t.Helper()
+
+	values := [][]byte{}
+	for i := 0; i < count; i++ {
+		var value []byte
+		var err error
+		value, data, err = readString(data)
+		if err != nil {
+			t.Fatalf("reading string %d: %s", i, err)
+		}
+		values = append(values, value)
+	}
+	return values, data
+}
+
+// parses an unencrypted openssh-key-v1 private key back, as OpenSSH does,
+// returning the ed25519 key and its comment
+func parseOpenSSHPrivateKey(t *testing.T, block *pem.Block, publicBlob []byte) (ed25519.PrivateKey, string) {
+	t.Helper()
+
+	if block.Type != "OPENSSH PRIVATE KEY" {
+		t.Fatalf("unexpected PEM type %s", block.Type)
+	}
+
+	magic := []byte("openssh-key-v1\x00")
+	if !bytes.HasPrefix(block.Bytes, magic) {
+		t.Fatalf("missing openssh-key-v1 magic")
+	}
+
+	header, data := readStrings(t, block.Bytes[len(magic):], 3)
+	if string(header[0]) != "none" || string(header[1]) != "none" || len(header[2]) != 0 {
+		t.Fatalf("expected an unencrypted key, got cipher %q kdf %q", header[0], header[1])
+	}
+
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != 1 {
+		t.Fatalf("expected one key")
+	}
+
+	keys, data := readStrings(t, data[4:], 2)
+	if len(data) != 0 {
+		t.Errorf("unexpected %d bytes after the private keys", len(data))
+	}
+	if !bytes.Equal(keys[0], publicBlob) {
+		t.Errorf("the public key of the private key file is not the authorized key")
+	}
+
+	private := keys[1]
+	if len(private)%8 != 0 || len(private) < 8 {
+		t.Fatalf("private section of %d bytes is not padded to the block size", len(private))
+	}
+	if !bytes.Equal(private[:4], private[4:8]) {
+		t.Errorf("the check values differ, OpenSSH would report a wrong passphrase")
+	}
+
+	fields, padding := readStrings(t, private[8:], 4)
+	if string(fields[0]) != "ssh-ed25519" {
+		t.Errorf("unexpected private key type %q", fields[0])
+	}
+	if len(fields[1]) != ed25519.PublicKeySize || len(fields[2]) != ed25519.PrivateKeySize || !bytes.Equal(fields[2][32:], fields[1]) {
+		t.Fatalf("invalid ed25519 key sizes %d and %d", len(fields[1]), len(fields[2]))
+	}
+	for i, b := range padding {
+		if b != byte(i+1) {
+			t.Errorf("invalid padding %v", padding)
+			break
+		}
+	}
+
+	return ed25519.PrivateKey(fields[2]), string(fields[3])
+}
+
+// checks the public key of the private key file with ssh-keygen, when it is installed
+func checkWithSSHKeygen(t *testing.T, privateKeyPath string, authorizedKey string) {
+	t.Helper()
+
+	sshKeygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Log("ssh-keygen not found, the key is not checked with OpenSSH")
+		return
+	}
+
+	out, err := exec.Command(sshKeygen, "-y", "-f", privateKeyPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ssh-keygen can not read the private key: %s: %s", err, out)
+	}
+
+	// ssh-keygen prints the comment of openssh-key-v1 keys only
+	fields := strings.Fields(authorizedKey)
+	if got := strings.Fields(string(out)); len(got) < 2 || got[0] != fields[0] || got[1] != fields[1] {
+		t.Errorf("ssh-keygen derives %q from the private key, expected %q", out, authorizedKey)
+	}
+}
+
+func TestGenerateEd25519(t *testing.T) {
+	privateKeyPath := filepath.Join(t.TempDir(), "keys", "id_ed25519")
+
+	authorizedKey, err := Generate(KeyTypeEd25519, "admin@example.com", privateKeyPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !strings.HasPrefix(authorizedKey, "ssh-ed25519 ") || !strings.HasSuffix(authorizedKey, " admin@example.com") {
+		t.Errorf("unexpected authorized key %q", authorizedKey)
+	}
+	keyType, err := ParseAuthorizedKey(authorizedKey)
+	if err != nil || keyType != KeyTypeEd25519 {
+		t.Errorf("expected an ed25519 authorized key, got %q %v", keyType, err)
+	}
+
+	publicKeyContent, _ := ioutil.ReadFile(privateKeyPath + ".pub")
+	if string(publicKeyContent) != authorizedKey+"\n" {
+		t.Errorf("expected the public key file to hold %q, got %q", authorizedKey, publicKeyContent)
+	}
+
+	content, err := ioutil.ReadFile(privateKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(privateKeyPath)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the private key to be 0600, got %o", info.Mode().Perm())
+	}
+
+	block, rest := pem.Decode(content)
+	if block == nil || len(bytes.TrimSpace(rest)) != 0 {
+		t.Fatalf("expected a single PEM block, got %q", content)
+	}
+
+	publicBlob := authorizedKeyBlob(t, authorizedKey)
+	privateKey, comment := parseOpenSSHPrivateKey(t, block, publicBlob)
+	if comment != "admin@example.com" {
+		t.Errorf("expected the comment in the private key, got %q", comment)
+	}
+
+	// the private key signs for the authorized public key
+	publicKey, _ := readStrings(t, publicBlob, 2)
+	signature := ed25519.Sign(privateKey, []byte("message"))
+	if !ed25519.Verify(ed25519.PublicKey(publicKey[1]), []byte("message"), signature) {
+		t.Errorf("the private key does not match the authorized key")
+	}
+
+	checkWithSSHKeygen(t, privateKeyPath, authorizedKey)
+}
+
+func TestGenerateRSA(t *testing.T) {
+	privateKeyPath := filepath.Join(t.TempDir(), "id_rsa")
+
+	authorizedKey, err := Generate(KeyTypeRSA, "", privateKeyPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(strings.Fields(authorizedKey)) != 2 || !strings.HasPrefix(authorizedKey, "ssh-rsa ") {
+		t.Errorf("expected an rsa authorized key without comment, got %q", authorizedKey)
+	}
+
+	content, _ := ioutil.ReadFile(privateKeyPath)
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		t.Fatalf("expected a PKCS#1 PEM block, got %q", content)
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("invalid PKCS#1 private key: %s", err)
+	}
+	if privateKey.N.BitLen() != rsaBits {
+		t.Errorf("expected a %d bits key, got %d", rsaBits, privateKey.N.BitLen())
+	}
+
+	// the authorized key holds the exponent and modulus of the private key
+	fields, rest := readStrings(t, authorizedKeyBlob(t, authorizedKey), 3)
+	if string(fields[0]) != "ssh-rsa" || len(rest) != 0 {
+		t.Fatalf("unexpected public key blob type %q", fields[0])
+	}
+	publicKey := rsa.PublicKey{E: int(new(big.Int).SetBytes(fields[1]).Int64()), N: new(big.Int).SetBytes(fields[2])}
+	if !privateKey.PublicKey.Equal(&publicKey) {
+		t.Errorf("the private key does not match the authorized key")
+	}
+	// positive multiple precision integers with the high bit set start with a zero
+	if fields[2][0] != 0 {
+		t.Errorf("expected a leading zero in the modulus")
+	}
+
+	checkWithSSHKeygen(t, privateKeyPath, authorizedKey)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Generate("dsa", "", filepath.Join(dir, "id_dsa"))
+	if err == nil || err.Error() != "SSHKey: Generate: unsupported key type 'dsa', use ed25519 or rsa" {
+		t.Errorf("expected the key type to be rejected, got %v", err)
+	}
+
+	// existing keys are kept
+	ioutil.WriteFile(filepath.Join(dir, "id_ed25519.pub"), []byte("existing"), 0644)
+	_, err = Generate(KeyTypeEd25519, "", filepath.Join(dir, "id_ed25519"))
+	if err == nil || !strings.Contains(err.Error(), "id_ed25519.pub already exists, refusing to overwrite it") {
+		t.Errorf("expected the existing key to be kept, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "id_ed25519")); !os.IsNotExist(err) {
+		t.Errorf("expected no private key to be written")
+	}
+}
+
+func TestParseAuthorizedKey(t *testing.T) {
+	ed25519Key, _ := Generate(KeyTypeEd25519, "", filepath.Join(t.TempDir(), "id_ed25519"))
+	ecdsaBlob := bytes.Buffer{}
+	writeString(&ecdsaBlob, []byte("ecdsa-sha2-nistp256"))
+	ecdsaKey := "ecdsa-sha2-nistp256 " + base64.StdEncoding.EncodeToString(ecdsaBlob.Bytes())
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		want string
+		err  string
+	}{
+		{"ed25519", ed25519Key, KeyTypeEd25519, ""},
+		{"other type", ecdsaKey, "ecdsa-sha2-nistp256", ""},
+		{"no key", "ssh-ed25519", "", "expected '<type> <base64 key> [comment]'"},
+		{"not base64", "ssh-ed25519 !!!", "", "key is not valid base64"},
+		{"mismatched type", strings.Replace(ed25519Key, "ssh-ed25519", "ssh-rsa", 1), "", "key data does not match key type ssh-rsa"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			keyType, err := ParseAuthorizedKey(tc.key)
+
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil || keyType != tc.want {
+				t.Errorf("expected %q, got %q %v", tc.want, keyType, err)
+			}
+		})
+	}
+}
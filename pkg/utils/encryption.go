@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// suffix used for the files encrypted at rest
+const EncryptedSuffix = ".enc"
+
+const (
+	// magic starting the files encrypted at rest, with the version of their layout
+	encryptedMagic = "KNI-ENC1"
+
+	// PBKDF2-HMAC-SHA256 iterations used to derive the key of each file, and the
+	// most accepted when decrypting, so a forged header can not stall the restore
+	encryptionKeyIterations    = 600000
+	maxEncryptionKeyIterations = 10000000
+
+	encryptionSaltSize = 16
+	encryptionKeySize  = 32
+)
+
+// utility to read an operator provided key file. The key file content is not
+// used as is: an AES-256 key is derived from it for each file, with a salt
+// stored in the file
+func ReadEncryptionKey(keyPath string) ([]byte, error) {
+	keyContent, err := ioutil.ReadFile(keyPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption key file %s: %s", keyPath, err)
+	}
+
+	trimmedKey := strings.TrimSpace(string(keyContent))
+	if len(trimmedKey) == 0 {
+		return nil, fmt.Errorf("encryption key file %s is empty", keyPath)
+	}
+
+	return []byte(trimmedKey), nil
+}
+
+// derives a key with PBKDF2 (RFC 8018) using HMAC-SHA256
+func pbkdf2SHA256(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	derivedKey := []byte{}
+
+	for block := uint32(1); len(derivedKey) < keyLength; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		derivedKey = append(derivedKey, t...)
+	}
+
+	return derivedKey[:keyLength]
+}
+
+// header of an encrypted file: the magic, then the key derivation iterations and salt
+func encryptionHeader(iterations uint32, salt []byte) []byte {
+	var header bytes.Buffer
+
+	header.WriteString(encryptedMagic)
+	binary.Write(&header, binary.BigEndian, iterations)
+	header.Write(salt)
+
+	return header.Bytes()
+}
+
+// utility to encrypt a file at rest with AES-GCM, with a key derived from the
+// key file content and a random salt. The encrypted content is written next to
+// the original file with the .enc suffix, and the original file is removed.
+// The header holding the salt is authenticated with the content
+func EncryptFile(path string, keyContent []byte) error {
+	plainContent, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, encryptionSaltSize)
+	_, err = io.ReadFull(rand.Reader, salt)
+
+	if err != nil {
+		return err
+	}
+
+	header := encryptionHeader(encryptionKeyIterations, salt)
+
+	gcm, err := newGCM(pbkdf2SHA256(keyContent, salt, encryptionKeyIterations, encryptionKeySize))
+
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+
+	if err != nil {
+		return err
+	}
+
+	// the nonce follows the header, before the sealed content
+	prefix := append(append([]byte{}, header...), nonce...)
+	encryptedContent := gcm.Seal(prefix, nonce, plainContent, header)
+
+	err = WriteSecretFile(fmt.Sprintf("%s%s", path, EncryptedSuffix), encryptedContent)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// utility to decrypt a file encrypted with EncryptFile. The decrypted content is
+// written without the .enc suffix, and the encrypted file is removed
+func DecryptFile(path string, keyContent []byte) error {
+	if !strings.HasSuffix(path, EncryptedSuffix) {
+		return fmt.Errorf("file %s is not an encrypted file", path)
+	}
+
+	encryptedContent, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	headerSize := len(encryptedMagic) + 4 + encryptionSaltSize
+	if len(encryptedContent) < headerSize || !bytes.HasPrefix(encryptedContent, []byte(encryptedMagic)) {
+		return fmt.Errorf("file %s is not an encrypted file, or was encrypted by an unsupported version", path)
+	}
+
+	header := encryptedContent[:headerSize]
+	iterations := binary.BigEndian.Uint32(header[len(encryptedMagic):])
+	salt := header[len(encryptedMagic)+4:]
+
+	if iterations == 0 || iterations > maxEncryptionKeyIterations {
+		return fmt.Errorf("file %s has an invalid key derivation count %d", path, iterations)
+	}
+
+	gcm, err := newGCM(pbkdf2SHA256(keyContent, salt, int(iterations), encryptionKeySize))
+
+	if err != nil {
+		return err
+	}
+
+	if len(encryptedContent) < headerSize+gcm.NonceSize() {
+		return fmt.Errorf("file %s is too short to be an encrypted file", path)
+	}
+
+	nonce := encryptedContent[headerSize : headerSize+gcm.NonceSize()]
+	plainContent, err := gcm.Open(nil, nonce, encryptedContent[headerSize+gcm.NonceSize():], header)
+
+	if err != nil {
+		return errors.New("error decrypting file, the encryption key may be wrong or the file was modified")
+	}
+
+	err = WriteSecretFile(strings.TrimSuffix(path, EncryptedSuffix), plainContent)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// PBKDF2-HMAC-SHA256 test vectors of RFC 7914
+func TestPBKDF2SHA256(t *testing.T) {
+	for _, tc := range []struct {
+		password   string
+		salt       string
+		iterations int
+		want       string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	} {
+		derivedKey := pbkdf2SHA256([]byte(tc.password), []byte(tc.salt), tc.iterations, 64)
+		if hex.EncodeToString(derivedKey) != tc.want {
+			t.Errorf("%s/%s/%d: expected %s, got %x", tc.password, tc.salt, tc.iterations, tc.want, derivedKey)
+		}
+	}
+
+	if derivedKey := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 20); hex.EncodeToString(derivedKey) != "55ac046e56e3089fec1691c22544b605f9418521" {
+		t.Errorf("expected a truncated key, got %x", derivedKey)
+	}
+}
+
+func TestReadEncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+
+	ioutil.WriteFile(filepath.Join(dir, "key"), []byte("  operator secret\n"), 0600)
+	key, err := ReadEncryptionKey(filepath.Join(dir, "key"))
+	if err != nil || string(key) != "operator secret" {
+		t.Errorf("expected the trimmed key, got %q %v", key, err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "empty"), []byte("\n"), 0600)
+	_, err = ReadEncryptionKey(filepath.Join(dir, "empty"))
+	if err == nil || !strings.HasSuffix(err.Error(), "empty is empty") {
+		t.Errorf("expected the empty key to be rejected, got %v", err)
+	}
+
+	_, err = ReadEncryptionKey(filepath.Join(dir, "missing"))
+	if err == nil || !strings.HasPrefix(err.Error(), "error reading encryption key file") {
+		t.Errorf("expected the missing key to be reported, got %v", err)
+	}
+}
+
+// writes content in a file of a new directory, returning its path
+func writeTestSecret(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secrets", "kubeconfig")
+	err := WriteSecretFile(path, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func checkMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != want {
+		t.Errorf("expected %s to be %o, got %o", path, want, info.Mode().Perm())
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	key := []byte("operator secret")
+	path := writeTestSecret(t, "apiVersion: v1\nkind: Config\n")
+
+	err := EncryptFile(path, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the plain file to be removed")
+	}
+	checkMode(t, path+EncryptedSuffix, 0600)
+
+	encryptedContent, _ := ioutil.ReadFile(path + EncryptedSuffix)
+	if !bytes.HasPrefix(encryptedContent, []byte(encryptedMagic)) || binary.BigEndian.Uint32(encryptedContent[len(encryptedMagic):]) != encryptionKeyIterations {
+		t.Errorf("expected the header to hold the magic and the iterations, got %q", encryptedContent[:len(encryptedMagic)+4])
+	}
+	if bytes.Contains(encryptedContent, []byte("kind: Config")) {
+		t.Errorf("the encrypted file holds the plain content")
+	}
+
+	err = DecryptFile(path+EncryptedSuffix, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "apiVersion: v1\nkind: Config\n" {
+		t.Errorf("expected the plain content back, got %q", content)
+	}
+	if _, err := os.Stat(path + EncryptedSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the encrypted file to be removed")
+	}
+	checkMode(t, path, 0600)
+
+	// each file gets its own salt, so the same content encrypts differently
+	otherPath := writeTestSecret(t, "apiVersion: v1\nkind: Config\n")
+	EncryptFile(path, key)
+	EncryptFile(otherPath, key)
+	first, _ := ioutil.ReadFile(path + EncryptedSuffix)
+	second, _ := ioutil.ReadFile(otherPath + EncryptedSuffix)
+	saltEnd := len(encryptedMagic) + 4 + encryptionSaltSize
+	if bytes.Equal(first[:saltEnd], second[:saltEnd]) {
+		t.Errorf("expected a different salt for each file")
+	}
+}
+
+// the header and the content are authenticated, a file that was modified, or
+// decrypted with another key, is left as it is
+func TestDecryptFileErrors(t *testing.T) {
+	key := []byte("operator secret")
+	path := writeTestSecret(t, "secret")
+	EncryptFile(path, key)
+	encryptedContent, _ := ioutil.ReadFile(path + EncryptedSuffix)
+
+	modified := func(offset int) []byte {
+		content := append([]byte{}, encryptedContent...)
+		content[offset] ^= 1
+		return content
+	}
+	tooManyIterations := append([]byte{}, encryptedContent...)
+	binary.BigEndian.PutUint32(tooManyIterations[len(encryptedMagic):], maxEncryptionKeyIterations+1)
+	saltOffset := len(encryptedMagic) + 4
+
+	for _, tc := range []struct {
+		name    string
+		content []byte
+		key     string
+		err     string
+	}{
+		{"wrong key", encryptedContent, "other secret", "error decrypting file, the encryption key may be wrong or the file was modified"},
+		{"modified salt", modified(saltOffset), string(key), "error decrypting file, the encryption key may be wrong or the file was modified"},
+		{"modified iterations", modified(saltOffset - 1), string(key), "error decrypting file, the encryption key may be wrong or the file was modified"},
+		{"modified nonce", modified(saltOffset + encryptionSaltSize), string(key), "error decrypting file, the encryption key may be wrong or the file was modified"},
+		{"modified content", modified(len(encryptedContent) - 1), string(key), "error decrypting file, the encryption key may be wrong or the file was modified"},
+		{"too many iterations", tooManyIterations, string(key), "has an invalid key derivation count 10000001"},
+		{"unsupported version", append([]byte("KNI-ENC0"), encryptedContent[len(encryptedMagic):]...), string(key), "was encrypted by an unsupported version"},
+		{"truncated", encryptedContent[:saltOffset+encryptionSaltSize+4], string(key), "is too short to be an encrypted file"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			encryptedPath := filepath.Join(t.TempDir(), "kubeconfig"+EncryptedSuffix)
+			ioutil.WriteFile(encryptedPath, tc.content, 0600)
+
+			err := DecryptFile(encryptedPath, []byte(tc.key))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+			}
+			if _, err := os.Stat(encryptedPath); err != nil {
+				t.Errorf("expected the encrypted file to be kept: %s", err)
+			}
+			if _, err := os.Stat(strings.TrimSuffix(encryptedPath, EncryptedSuffix)); !os.IsNotExist(err) {
+				t.Errorf("expected no plain file to be written")
+			}
+		})
+	}
+
+	err := DecryptFile(path, key)
+	if err == nil || !strings.HasSuffix(err.Error(), "is not an encrypted file") {
+		t.Errorf("expected the file without suffix to be rejected, got %v", err)
+	}
+}
+
+func TestWriteSecretFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site")
+	path := filepath.Join(dir, "final_manifests", "pull-secret.yaml")
+
+	err := WriteSecretFile(path, []byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkMode(t, path, 0600)
+	checkMode(t, filepath.Dir(path), 0700)
+
+	// existing files and directories are restricted too
+	os.Chmod(filepath.Dir(path), 0755)
+	os.Chmod(path, 0644)
+	err = WriteSecretFile(path, []byte("other secret"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkMode(t, path, 0600)
+	checkMode(t, filepath.Dir(path), 0700)
+	if content, _ := ioutil.ReadFile(path); string(content) != "other secret" {
+		t.Errorf("expected the content to be replaced, got %q", content)
+	}
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"text/template"
+)
+
+// utility to render a text template, named after what it renders for the errors
+func RenderTemplate(name string, templateText string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s template: %s", name, err)
+	}
+
+	var out bytes.Buffer
+
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering %s template: %s", name, err)
+	}
+
+	return out.String(), nil
+}
+
+// utility to return the IPv4 address at the given offset of a network
+func HostIP(network *net.IPNet, offset int) string {
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, base+uint32(offset))
+	return ip.String()
+}
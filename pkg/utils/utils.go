@@ -10,21 +10,45 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+	"gerrit.akraino.org/kni/installer/pkg/sshkey"
 )
 
 // utility to validate pre-requisites for deploying
 func ValidateRequirements(buildPath string, siteName string) {
-	// check for pull-secret.json
-	if _, err := os.Stat(fmt.Sprintf("%s/pull-secret.json", buildPath)); os.IsNotExist(err) {
-		log.Fatalf("Error, no valid pull-secret.json found in %s\n", buildPath)
+	// secrets are retrieved from the provider configured for the site
+	siteConfigPath := fmt.Sprintf("%s/%s/site/00_install-config/site-config.yaml", buildPath, siteName)
+	sc, err := siteconfig.Load(siteConfigPath)
+	if err != nil {
+		log.Fatalf("Error reading site config: %s\n", err)
+	}
+	provider, err := secrets.New(sc.Secrets, secrets.Lookup{SitePath: fmt.Sprintf("%s/%s", buildPath, siteName), GlobalPath: buildPath})
+	if err != nil {
+		log.Fatalf("Error creating secret provider: %s\n", err)
 	}
+	names := sc.Secrets.SecretNames()
 
-	// check for ssh key , and generate if it does not exist
-	if _, err := os.Stat(fmt.Sprintf("%s/id_rsa.pub", buildPath)); os.IsNotExist(err) {
-		log.Printf("No SSH public key (id_rsa.pub) found in %s. Generating keypair.\n", buildPath)
+	// check for pull secret
+	pullSecretSource, err := provider.Source(names.PullSecret)
+	if err != nil {
+		log.Fatalf("Error, no valid pull secret %s found in %s: %s\n", names.PullSecret, provider.Description(), err)
+	}
 
-		var envVars []string
-		ExecuteCommand("", envVars, true, true, "/bin/bash", "-c", fmt.Sprintf("ssh-keygen -b 2048 -q -N '' -f %s/id_rsa -C user@example.com", buildPath))
+	// check for ssh key, and reuse or generate one if it does not exist and secrets are local files.
+	// It is stored next to the pull secret, so it belongs to the same credentials
+	localFiles := sc.Secrets.Provider == "" || sc.Secrets.Provider == "files"
+	if _, err := provider.GetSecret(names.SSHPublicKey); err == secrets.ErrNotFound && localFiles {
+		secretsPath := filepath.Dir(pullSecretSource)
+		log.Printf("No SSH public key (%s) found in %s. Looking for a %s key in %s.\n", names.SSHPublicKey, provider.Description(), sc.SSH.KeyType, strings.Join(sc.SSH.Sources, ", "))
+
+		err = obtainSSHPublicKey(sc.SSH, secretsPath, names.SSHPublicKey)
+		if err != nil {
+			log.Fatalf("Error, cannot obtain a SSH public key: %s\n", err)
+		}
+	} else if err != nil {
+		log.Fatalf("Error, no valid SSH public key %s found in %s: %s\n", names.SSHPublicKey, provider.Description(), err)
 	}
 
 	// check if requirements folder exist
@@ -35,22 +59,121 @@ func ValidateRequirements(buildPath string, siteName string) {
 
 }
 
-// utility to apply kustomize on a given directory
-func ApplyKustomize(kustomizeBinary string, kustomizePath string) []byte {
-	// retrieve executable path to inject env var
+// looks for a public key in the sources configured for the site, in order, and
+// stores it in the secrets path with the given name
+func obtainSSHPublicKey(config siteconfig.SSH, secretsPath string, publicKeyName string) error {
+	publicKeyPath := filepath.Join(secretsPath, filepath.Base(publicKeyName))
+
+	for _, source := range config.Sources {
+		var publicKey string
+		var err error
+
+		switch source {
+		case "sshDirectory":
+			publicKey, err = sshkey.FindInSSHDirectory(config.KeyType)
+		case "agent":
+			publicKey, err = sshkey.FindInAgent(config.KeyType)
+		case "generate":
+			privateKeyPath := config.KeyPath
+			if privateKeyPath == "" {
+				privateKeyPath = strings.TrimSuffix(publicKeyPath, ".pub")
+			}
+
+			comment := config.Comment
+			if comment == "" {
+				hostname, _ := os.Hostname()
+				comment = fmt.Sprintf("%s@%s", os.Getenv("USER"), hostname)
+			}
+
+			publicKey, err = sshkey.Generate(config.KeyType, comment, privateKeyPath)
+			if err == nil {
+				log.Printf("Generated %s keypair in %s\n", config.KeyType, privateKeyPath)
+			}
+			// generated keys are already in place, unless a custom path is used
+			if err == nil && fmt.Sprintf("%s.pub", privateKeyPath) == publicKeyPath {
+				return nil
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+		if publicKey == "" {
+			log.Printf("No %s key found in %s\n", config.KeyType, source)
+			continue
+		}
+
+		log.Printf("Using SSH public key from %s: %s\n", source, publicKey)
+		return ioutil.WriteFile(publicKeyPath, []byte(fmt.Sprintf("%s\n", publicKey)), 0644)
+	}
+
+	return fmt.Errorf("no %s key found in %s", config.KeyType, strings.Join(config.Sources, ", "))
+}
+
+// utility to retrieve a directory shipped along with knictl (plugins, utils),
+// that sits next to the directory of the running binary
+func GetInstallationPath(directory string) string {
 	ex, err := os.Executable()
 	if err != nil {
 		log.Fatal("Error retrieving the current running path")
 	}
-	pluginPath := filepath.Dir(ex)
-	pluginPath, err = filepath.Abs(filepath.Join(pluginPath, "../plugins"))
+	installationPath, err := filepath.Abs(filepath.Join(filepath.Dir(ex), "..", directory))
+	if err != nil {
+		log.Fatalf("failed get %s path: %v", directory, err)
+	}
+	return installationPath
+}
+
+// SiteConfigPluginPath : the SiteConfig exec plugin executable, relative to the
+// plugins directory shipped with knictl
+const SiteConfigPluginPath = "kustomize/plugin/kni.akraino.org/v1alpha1/siteconfig/SiteConfig"
+
+// checks that the SiteConfig exec plugin is built, under the plugins directory
+func checkSiteConfigPlugin(pluginPath string) error {
+	siteConfigPlugin := filepath.Join(pluginPath, SiteConfigPluginPath)
+
+	info, err := os.Stat(siteConfigPlugin)
 	if err != nil {
-		log.Fatalf("failed get plugin path: %v", err)
+		return fmt.Errorf("the SiteConfig kustomize plugin is not built: %s", err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("the SiteConfig kustomize plugin %s is not an executable", siteConfigPlugin)
 	}
-	envVars := []string{fmt.Sprintf("XDG_CONFIG_HOME=%s", pluginPath)}
-	out, _ := ExecuteCommand("", envVars, true, false, kustomizeBinary, "build", "--enable_alpha_plugins", "--reorder", "none", kustomizePath)
 
-	return out
+	return nil
+}
+
+// utility to apply kustomize on a given directory
+func ApplyKustomize(kustomizeBinary string, kustomizePath string, buildPath string, siteName string) []byte {
+	// retrieve plugins path to inject env var, and let the plugins find the site and global credentials
+	pluginPath := GetInstallationPath("plugins")
+
+	envVars := []string{
+		fmt.Sprintf("XDG_CONFIG_HOME=%s", pluginPath),
+		fmt.Sprintf("KNI_BUILD_PATH=%s", buildPath),
+		fmt.Sprintf("KNI_SITE_BUILD_PATH=%s/%s", buildPath, siteName),
+	}
+
+	cmd := exec.Command(kustomizeBinary, "build", "--enable_alpha_plugins", "--reorder", "none", kustomizePath)
+	cmd.Env = append(os.Environ(), envVars...)
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		// kustomize only reports a missing exec plugin as a generic load error,
+		// so a missing SiteConfig plugin is pointed out for the layers using it
+		hint := ""
+		if pluginErr := checkSiteConfigPlugin(pluginPath); pluginErr != nil {
+			hint = fmt.Sprintf("\nNote: %s, build it with make build if %s uses the SiteConfig transformer", pluginErr, kustomizePath)
+		}
+		log.Fatalf("Error applying kustomize on %s: %s - %s%s\n", kustomizePath, err, strings.TrimSpace(errb.String()), hint)
+	}
+
+	return outb.Bytes()
 }
 
 // utility to apply OC for a given output
@@ -118,23 +241,37 @@ func ExecuteCommand(directory string, envVars []string, failFatal bool, showOutp
 	return outb.Bytes(), errb.Bytes()
 }
 
+// utility to copy a file, keeping the permissions of the source
 func CopyFile(sourcePath string, destinationPath string) error {
+	sourceInfo, err := os.Stat(sourcePath)
+
+	if err != nil {
+		return err
+	}
+
 	sourceContents, err := ioutil.ReadFile(sourcePath)
 
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(destinationPath, sourceContents, 0)
+	err = ioutil.WriteFile(destinationPath, sourceContents, sourceInfo.Mode().Perm())
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return os.Chmod(destinationPath, sourceInfo.Mode().Perm())
 }
 
+// utility to replace text in a file, keeping its permissions
 func ReplaceFileText(sourcePath string, oldText string, newText string) error {
+	sourceInfo, err := os.Stat(sourcePath)
+
+	if err != nil {
+		return err
+	}
+
 	read, err := ioutil.ReadFile(sourcePath)
 
 	if err != nil {
@@ -143,7 +280,7 @@ func ReplaceFileText(sourcePath string, oldText string, newText string) error {
 
 	newContents := strings.Replace(string(read), oldText, newText, -1)
 
-	err = ioutil.WriteFile(sourcePath, []byte(newContents), 0)
+	err = ioutil.WriteFile(sourcePath, []byte(newContents), sourceInfo.Mode().Perm())
 
 	if err != nil {
 		return err
@@ -151,3 +288,46 @@ func ReplaceFileText(sourcePath string, oldText string, newText string) error {
 
 	return nil
 }
+
+// utility to write a file holding credentials. The file is only readable by
+// the owner, and it is created inside a directory only accessible by the owner
+func WriteSecretFile(path string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(filepath.Dir(path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path, content, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	// WriteFile does not change the permissions of existing files
+	return os.Chmod(path, 0600)
+}
+
+// utility to restrict a directory holding credentials to its owner. The directory
+// and its subdirectories are set to 0700, and the files inside to 0600
+func SecureDirectory(path string) error {
+	return filepath.Walk(path, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return os.Chmod(walkPath, 0700)
+		} else if info.Mode().IsRegular() {
+			return os.Chmod(walkPath, 0600)
+		}
+
+		return nil
+	})
+}
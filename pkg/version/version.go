@@ -0,0 +1,13 @@
+package version
+
+// Version of knictl. It is injected at build time with
+// -ldflags "-X gerrit.akraino.org/kni/installer/pkg/version.Version=<version>"
+var Version = "unknown"
+
+// returns the knictl version, defaulting when it has not been injected
+func Get() string {
+	if Version == "" {
+		return "unknown"
+	}
+	return Version
+}
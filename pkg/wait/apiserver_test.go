@@ -0,0 +1,293 @@
+package wait
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a resource served by the fake API server
+type fakeResource struct {
+	group      string
+	version    string
+	kind       string
+	plural     string
+	shortNames []string
+	namespaced bool
+	list       string // recorded list in testdata/oc, not listable if empty
+}
+
+func (fr fakeResource) groupVersion() string {
+	if fr.group == "" {
+		return fr.version
+	}
+	return fmt.Sprintf("%s/%s", fr.group, fr.version)
+}
+
+// the recorded object with that name, as named by the fake oc
+func (fr fakeResource) recorded(name string) string {
+	resource := strings.ToLower(fr.kind)
+	if fr.group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, fr.group)
+	}
+	return fmt.Sprintf("%s_%s", resource, name)
+}
+
+var fakeResources = []fakeResource{
+	{"", "v1", "Node", "nodes", []string{"no"}, false, "nodes"},
+	{"", "v1", "ConfigMap", "configmaps", []string{"cm"}, true, ""},
+	{"certificates.k8s.io", "v1", "CertificateSigningRequest", "certificatesigningrequests", []string{"csr"}, false, "csr"},
+	{"apps", "v1", "Deployment", "deployments", []string{"deploy"}, true, ""},
+	{"apps", "v1", "StatefulSet", "statefulsets", []string{"sts"}, true, ""},
+	{"apps", "v1", "DaemonSet", "daemonsets", []string{"ds"}, true, ""},
+	{"batch", "v1", "Job", "jobs", nil, true, ""},
+	{"operators.coreos.com", "v1alpha1", "Subscription", "subscriptions", []string{"sub", "subs"}, true, ""},
+	{"operators.coreos.com", "v1alpha1", "ClusterServiceVersion", "clusterserviceversions", []string{"csv", "csvs"}, true, ""},
+}
+
+// serves the objects recorded in testdata/oc as a Kubernetes API server, with
+// the discovery documents oc needs to map the resources it is asked for
+func newFakeAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	recorded, err := filepath.Abs("testdata/oc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeJSON := func(w http.ResponseWriter, code int, object interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(object)
+	}
+	notFound := func(w http.ResponseWriter, message string) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"kind": "Status", "apiVersion": "v1", "metadata": map[string]interface{}{},
+			"status": "Failure", "message": message, "reason": "NotFound", "code": http.StatusNotFound,
+		})
+	}
+
+	groups := map[string]map[string]interface{}{}
+	groupNames := []string{}
+	for _, resource := range fakeResources {
+		if resource.group == "" || groups[resource.group] != nil {
+			continue
+		}
+		version := map[string]string{"groupVersion": resource.groupVersion(), "version": resource.version}
+		groups[resource.group] = map[string]interface{}{"name": resource.group, "versions": []interface{}{version}, "preferredVersion": version}
+		groupNames = append(groupNames, resource.group)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(r.URL.Path, "/")
+
+		switch path {
+		case "version":
+			writeJSON(w, http.StatusOK, map[string]string{"major": "1", "minor": "16", "gitVersion": "v1.16.2"})
+			return
+		case "api":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "APIVersions", "versions": []string{"v1"}, "serverAddressByClientCIDRs": []interface{}{}})
+			return
+		case "apis":
+			list := []interface{}{}
+			for _, name := range groupNames {
+				list = append(list, groups[name])
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "APIGroupList", "apiVersion": "v1", "groups": list})
+			return
+		}
+
+		// /api/v1/... or /apis/<group>/<version>/...
+		parts := strings.Split(path, "/")
+		var groupVersion string
+		switch {
+		case parts[0] == "api" && len(parts) >= 2:
+			groupVersion, parts = parts[1], parts[2:]
+		case parts[0] == "apis" && len(parts) >= 3:
+			groupVersion, parts = parts[1]+"/"+parts[2], parts[3:]
+		default:
+			notFound(w, fmt.Sprintf("the server could not find the requested resource %s", r.URL.Path))
+			return
+		}
+
+		if len(parts) == 0 {
+			resources := []interface{}{}
+			for _, resource := range fakeResources {
+				if resource.groupVersion() == groupVersion {
+					resources = append(resources, map[string]interface{}{
+						"name": resource.plural, "singularName": strings.ToLower(resource.kind), "namespaced": resource.namespaced,
+						"kind": resource.kind, "shortNames": resource.shortNames, "verbs": []string{"get", "list"},
+					})
+				}
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "APIResourceList", "apiVersion": "v1", "groupVersion": groupVersion, "resources": resources})
+			return
+		}
+
+		namespace := ""
+		if parts[0] == "namespaces" && len(parts) >= 3 {
+			namespace, parts = parts[1], parts[2:]
+		}
+
+		for _, resource := range fakeResources {
+			if resource.groupVersion() != groupVersion || resource.plural != parts[0] {
+				continue
+			}
+
+			file := resource.list
+			if len(parts) > 1 {
+				file = resource.recorded(parts[1])
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(recorded, file+".json"))
+			if file == "" || err != nil {
+				notFound(w, fmt.Sprintf("%s %q not found", resource.plural, strings.Join(parts[1:], "/")))
+				return
+			}
+
+			// the recorded objects are completed as the API server returns them
+			var object map[string]interface{}
+			if err := json.Unmarshal(content, &object); err != nil {
+				t.Errorf("invalid recorded object %s: %s", file, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			complete := func(item map[string]interface{}) {
+				item["apiVersion"], item["kind"] = groupVersion, resource.kind
+				if metadata, ok := item["metadata"].(map[string]interface{}); ok && namespace != "" {
+					metadata["namespace"] = namespace
+				}
+			}
+			if len(parts) > 1 {
+				complete(object)
+			} else {
+				for _, item := range object["items"].([]interface{}) {
+					complete(item.(map[string]interface{}))
+				}
+				object["apiVersion"], object["kind"], object["metadata"] = groupVersion, resource.kind+"List", map[string]interface{}{}
+			}
+
+			writeJSON(w, http.StatusOK, object)
+			return
+		}
+
+		notFound(w, fmt.Sprintf("the server could not find the requested resource %s", r.URL.Path))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// writes a kubeconfig for the server
+func writeFakeKubeconfig(t *testing.T, server string) string {
+	t.Helper()
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := ioutil.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+current-context: fake
+users:
+- name: fake
+  user:
+    token: fake
+`, server)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kubeconfig
+}
+
+// runs the checks with oc, as installed in the site requirements, against the
+// fake API server, so the resources they ask for and the output they parse are
+// the ones of the real client
+func TestChecksAPIServer(t *testing.T) {
+	oc, err := exec.LookPath("oc")
+	if err != nil {
+		t.Skip("oc not found")
+	}
+
+	// oc caches the discovery documents in the home directory
+	t.Setenv("HOME", t.TempDir())
+
+	server := newFakeAPIServer(t)
+	params := Params{OcPath: oc, Kubeconfig: writeFakeKubeconfig(t, server.URL)}
+
+	params.Workers = 3
+	done, message, err := params.checkWorkers()
+	if err != nil || done || message != "2 of 3 workers ready, not ready: worker-2, 2 pending certificate signing request(s)" {
+		t.Errorf("unexpected workers check: %v %q %v", done, message, err)
+	}
+
+	params.Workloads = []Object{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "demo", Name: "web"},
+		{APIVersion: "apps/v1", Kind: "StatefulSet", Namespace: "demo", Name: "db"},
+		{APIVersion: "batch/v1", Kind: "Job", Namespace: "demo", Name: "setup"},
+		{APIVersion: "operators.coreos.com/v1alpha1", Kind: "ClusterServiceVersion", Namespace: "openshift-sriov", Name: "sriov.v4.2.0"},
+		{APIVersion: "apps/v1", Kind: "DaemonSet", Namespace: "demo", Name: "agent"},
+		{APIVersion: "operators.coreos.com/v1alpha1", Kind: "Subscription", Namespace: "openshift-sriov", Name: "sriov"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "demo", Name: "missing"},
+	}
+	done, message, err = params.checkWorkloads()
+	if err != nil || done {
+		t.Fatalf("expected the workloads not to be ready, got %v %v", done, err)
+	}
+
+	for _, want := range []string{
+		"4 of 7 workloads ready, not ready: ",
+		"demo/DaemonSet/agent (4 of 5 pods ready)",
+		`openshift-sriov/Subscription/sriov (state "UpgradePending")`,
+		`demo/ConfigMap/missing (oc get configmap/missing -n demo: exit status 1 - Error from server (NotFound): configmaps "missing" not found)`,
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("expected %q in %q", want, message)
+		}
+	}
+}
+
+// the fake API server answers as oc expects it, checked without oc
+func TestFakeAPIServer(t *testing.T) {
+	server := newFakeAPIServer(t)
+
+	for _, tc := range []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/apis", http.StatusOK, `{"groupVersion":"certificates.k8s.io/v1","version":"v1"}`},
+		{"/apis/apps/v1", http.StatusOK, `"name":"daemonsets","namespaced":true,"shortNames":["ds"]`},
+		{"/api/v1/nodes", http.StatusOK, `"kind":"NodeList"`},
+		{"/apis/certificates.k8s.io/v1/certificatesigningrequests", http.StatusOK, `"apiVersion":"certificates.k8s.io/v1","kind":"CertificateSigningRequest","metadata":{"name":"csr-pending-0"}`},
+		{"/apis/apps/v1/namespaces/demo/deployments/web", http.StatusOK, `"metadata":{"generation":2,"name":"web","namespace":"demo"}`},
+		{"/api/v1/namespaces/demo/configmaps/missing", http.StatusNotFound, `"message":"configmaps \"missing\" not found"`},
+		{"/apis/example.com/v1", http.StatusOK, `"resources":[]`},
+	} {
+		response, err := http.Get(server.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode != tc.code || !strings.Contains(string(body), tc.want) {
+			t.Errorf("%s: expected %d with %s, got %d %s", tc.path, tc.code, tc.want, response.StatusCode, body)
+		}
+	}
+}
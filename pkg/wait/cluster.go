@@ -0,0 +1,291 @@
+package wait
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Object : an object applied to the cluster, identified as in the manifests
+type Object struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// String : the object as kind/name, prefixed by its namespace
+func (o Object) String() string {
+	if o.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", o.Namespace, o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s/%s", o.Kind, o.Name)
+}
+
+// the kind.group form understood by oc, so kinds with the same name in
+// different groups are not mixed up
+func (o Object) resource() string {
+	resource := strings.ToLower(o.Kind)
+	if slash := strings.Index(o.APIVersion, "/"); slash > 0 {
+		resource = fmt.Sprintf("%s.%s", resource, o.APIVersion[:slash])
+	}
+	return fmt.Sprintf("%s/%s", resource, o.Name)
+}
+
+// ParseObjects : lists the objects of a multi-document YAML, as rendered by kustomize
+func ParseObjects(content []byte) ([]Object, error) {
+	objects := []Object{}
+
+	for _, manifest := range strings.Split(string(content), "\n---\n") {
+		var manifestObj struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+
+		err := yaml.Unmarshal([]byte(manifest), &manifestObj)
+		if err != nil {
+			return nil, fmt.Errorf("Wait: ParseObjects: error parsing manifest: %s", err)
+		}
+		if manifestObj.Kind == "" {
+			continue
+		}
+		if manifestObj.Metadata.Name == "" {
+			return nil, fmt.Errorf("Wait: ParseObjects: %s manifest without a name", manifestObj.Kind)
+		}
+
+		objects = append(objects, Object{
+			APIVersion: manifestObj.APIVersion,
+			Kind:       manifestObj.Kind,
+			Namespace:  manifestObj.Metadata.Namespace,
+			Name:       manifestObj.Metadata.Name,
+		})
+	}
+
+	return objects, nil
+}
+
+// the fields of the cluster objects used to check their readiness
+type clusterCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+type clusterObject struct {
+	Metadata struct {
+		Name       string            `json:"name"`
+		Labels     map[string]string `json:"labels"`
+		Generation int64             `json:"generation"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas    *int32 `json:"replicas"`
+		Completions *int32 `json:"completions"`
+	} `json:"spec"`
+	Status struct {
+		Conditions             []clusterCondition `json:"conditions"`
+		ObservedGeneration     int64              `json:"observedGeneration"`
+		ReadyReplicas          int32              `json:"readyReplicas"`
+		UpdatedReplicas        int32              `json:"updatedReplicas"`
+		AvailableReplicas      int32              `json:"availableReplicas"`
+		DesiredNumberScheduled int32              `json:"desiredNumberScheduled"`
+		NumberReady            int32              `json:"numberReady"`
+		UpdatedNumberScheduled int32              `json:"updatedNumberScheduled"`
+		Succeeded              int32              `json:"succeeded"`
+		Phase                  string             `json:"phase"`
+		State                  string             `json:"state"`
+	} `json:"status"`
+}
+
+type clusterObjectList struct {
+	Items []clusterObject `json:"items"`
+}
+
+// whether a condition of the object has the given status
+func (co clusterObject) condition(conditionType string, status string) bool {
+	for _, condition := range co.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == status
+		}
+	}
+	return false
+}
+
+// runs oc get with the kubeconfig, decoding the JSON output
+func (p Params) ocGet(result interface{}, args ...string) error {
+	cmd := exec.Command(p.OcPath, append(append([]string{"get"}, args...), "-o", "json", "--request-timeout=30s")...)
+	cmd.Env = os.Environ()
+	if p.Kubeconfig != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("KUBECONFIG=%s", p.Kubeconfig))
+	}
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+
+	err := cmd.Run()
+
+	if err != nil {
+		return fmt.Errorf("oc get %s: %s - %s", strings.Join(args, " "), err, strings.TrimSpace(errb.String()))
+	}
+
+	err = json.Unmarshal(outb.Bytes(), result)
+
+	if err != nil {
+		return fmt.Errorf("oc get %s: error parsing output: %s", strings.Join(args, " "), err)
+	}
+
+	return nil
+}
+
+// checks that the requested workers joined the cluster and are ready. Workers
+// of user provisioned clusters only join once their certificate signing requests
+// are approved, so the pending ones are reported too
+func (p Params) checkWorkers() (bool, string, error) {
+	if p.Workers == 0 {
+		return true, "", nil
+	}
+
+	var nodes clusterObjectList
+
+	err := p.ocGet(&nodes, "nodes")
+
+	if err != nil {
+		return false, "", err
+	}
+
+	ready := 0
+	notReady := []string{}
+	for _, node := range nodes.Items {
+		// masters of compact clusters are also labelled as workers
+		_, worker := node.Metadata.Labels["node-role.kubernetes.io/worker"]
+		_, master := node.Metadata.Labels["node-role.kubernetes.io/master"]
+		if !worker || master {
+			continue
+		}
+
+		if node.condition("Ready", "True") {
+			ready++
+		} else {
+			notReady = append(notReady, node.Metadata.Name)
+		}
+	}
+
+	if ready >= p.Workers {
+		return true, "", nil
+	}
+
+	message := fmt.Sprintf("%d of %d workers ready", ready, p.Workers)
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		message = fmt.Sprintf("%s, not ready: %s", message, strings.Join(notReady, ", "))
+	}
+
+	var csrs clusterObjectList
+
+	if err := p.ocGet(&csrs, "csr"); err == nil {
+		pending := 0
+		for _, csr := range csrs.Items {
+			if len(csr.Status.Conditions) == 0 {
+				pending++
+			}
+		}
+		if pending > 0 {
+			message = fmt.Sprintf("%s, %d pending certificate signing request(s)", message, pending)
+		}
+	}
+
+	return false, message, nil
+}
+
+// checks that every workload applied by apply_workloads exists and, for the
+// kinds that roll out pods or install operators, that it is ready
+func (p Params) checkWorkloads() (bool, string, error) {
+	if len(p.Workloads) == 0 {
+		return true, "", nil
+	}
+
+	notReady := []string{}
+	for _, workload := range p.Workloads {
+		args := []string{workload.resource()}
+		if workload.Namespace != "" {
+			args = append(args, "-n", workload.Namespace)
+		}
+
+		var object clusterObject
+
+		err := p.ocGet(&object, args...)
+
+		if err != nil {
+			notReady = append(notReady, fmt.Sprintf("%s (%s)", workload, err))
+			continue
+		}
+
+		if reason := workloadNotReady(workload.Kind, object); reason != "" {
+			notReady = append(notReady, fmt.Sprintf("%s (%s)", workload, reason))
+		}
+	}
+
+	if len(notReady) == 0 {
+		return true, "", nil
+	}
+
+	return false, fmt.Sprintf("%d of %d workloads ready, not ready: %s", len(p.Workloads)-len(notReady), len(p.Workloads), strings.Join(notReady, "; ")), nil
+}
+
+// returns why a workload is not ready yet, or an empty string when it is
+func workloadNotReady(kind string, object clusterObject) string {
+	replicas := int32(1)
+	if object.Spec.Replicas != nil {
+		replicas = *object.Spec.Replicas
+	}
+
+	if object.Status.ObservedGeneration < object.Metadata.Generation {
+		switch kind {
+		case "Deployment", "StatefulSet", "DaemonSet":
+			return "rollout not observed yet"
+		}
+	}
+
+	switch kind {
+	case "Deployment":
+		if object.Status.UpdatedReplicas < replicas || object.Status.AvailableReplicas < replicas {
+			return fmt.Sprintf("%d of %d replicas available", object.Status.AvailableReplicas, replicas)
+		}
+	case "StatefulSet":
+		if object.Status.UpdatedReplicas < replicas || object.Status.ReadyReplicas < replicas {
+			return fmt.Sprintf("%d of %d replicas ready", object.Status.ReadyReplicas, replicas)
+		}
+	case "DaemonSet":
+		desired := object.Status.DesiredNumberScheduled
+		if object.Status.UpdatedNumberScheduled < desired || object.Status.NumberReady < desired {
+			return fmt.Sprintf("%d of %d pods ready", object.Status.NumberReady, desired)
+		}
+	case "Job":
+		completions := int32(1)
+		if object.Spec.Completions != nil {
+			completions = *object.Spec.Completions
+		}
+		if object.Status.Succeeded < completions {
+			return fmt.Sprintf("%d of %d completions", object.Status.Succeeded, completions)
+		}
+	case "Subscription":
+		if object.Status.State != "AtLatestKnown" {
+			return fmt.Sprintf("state %q", object.Status.State)
+		}
+	case "ClusterServiceVersion":
+		if object.Status.Phase != "Succeeded" {
+			return fmt.Sprintf("phase %q", object.Status.Phase)
+		}
+	}
+
+	return ""
+}
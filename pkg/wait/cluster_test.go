@@ -0,0 +1,180 @@
+package wait
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// returns Params running a fake oc, that answers oc get with the output recorded
+// in testdata/oc/<resource>.json and logs its arguments to the returned file
+func fakeOcParams(t *testing.T) (Params, string) {
+	t.Helper()
+
+	recorded, err := filepath.Abs("testdata/oc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "kni-wait-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	argsLog := filepath.Join(dir, "args")
+
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %s
+if [ "$KUBECONFIG" != "%s" ]; then
+    echo "error: unexpected kubeconfig $KUBECONFIG" >&2
+    exit 1
+fi
+file="%s/$(echo "$2" | tr / _).json"
+if [ ! -f "$file" ]; then
+    echo "Error from server (NotFound): $2 not found" >&2
+    exit 1
+fi
+cat "$file"
+`, argsLog, kubeconfig, recorded)
+
+	ocPath := filepath.Join(dir, "oc")
+	err = ioutil.WriteFile(ocPath, []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Params{OcPath: ocPath, Kubeconfig: kubeconfig}, argsLog
+}
+
+func TestCheckWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		workers int
+		done    bool
+		message string
+	}{
+		{0, true, ""},
+		{2, true, ""},
+		// masters are labelled as workers too, but are not counted
+		{3, false, "2 of 3 workers ready, not ready: worker-2, 2 pending certificate signing request(s)"},
+		{4, false, "2 of 4 workers ready, not ready: worker-2, 2 pending certificate signing request(s)"},
+	} {
+		t.Run(fmt.Sprintf("%d workers", tc.workers), func(t *testing.T) {
+			params, _ := fakeOcParams(t)
+			params.Workers = tc.workers
+
+			done, message, err := params.checkWorkers()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if done != tc.done || message != tc.message {
+				t.Errorf("expected %v %q, got %v %q", tc.done, tc.message, done, message)
+			}
+		})
+	}
+}
+
+func TestCheckWorkersError(t *testing.T) {
+	params, _ := fakeOcParams(t)
+	params.Workers = 1
+	params.Kubeconfig = "/wrong/kubeconfig"
+
+	_, _, err := params.checkWorkers()
+	if err == nil || !strings.Contains(err.Error(), "oc get nodes") || !strings.Contains(err.Error(), "unexpected kubeconfig /wrong/kubeconfig") {
+		t.Errorf("expected the oc error to be reported, got %v", err)
+	}
+}
+
+func TestCheckWorkloads(t *testing.T) {
+	ready := []Object{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "demo", Name: "web"},
+		{APIVersion: "apps/v1", Kind: "StatefulSet", Namespace: "demo", Name: "db"},
+		{APIVersion: "batch/v1", Kind: "Job", Namespace: "demo", Name: "setup"},
+		{APIVersion: "operators.coreos.com/v1alpha1", Kind: "ClusterServiceVersion", Namespace: "openshift-sriov", Name: "sriov.v4.2.0"},
+	}
+
+	params, argsLog := fakeOcParams(t)
+	params.Workloads = ready
+
+	done, message, err := params.checkWorkloads()
+	if err != nil || !done || message != "" {
+		t.Errorf("expected the workloads to be ready, got %v %q %v", done, message, err)
+	}
+
+	args, _ := ioutil.ReadFile(argsLog)
+	if !strings.Contains(string(args), "get deployment.apps/web -n demo -o json --request-timeout=30s\n") {
+		t.Errorf("unexpected oc arguments:\n%s", args)
+	}
+
+	params.Workloads = append(ready,
+		Object{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "demo", Name: "api"},
+		Object{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "demo", Name: "rollout"},
+		Object{APIVersion: "apps/v1", Kind: "DaemonSet", Namespace: "demo", Name: "agent"},
+		Object{APIVersion: "operators.coreos.com/v1alpha1", Kind: "Subscription", Namespace: "openshift-sriov", Name: "sriov"},
+		Object{APIVersion: "v1", Kind: "ConfigMap", Namespace: "demo", Name: "missing"},
+	)
+
+	done, message, err = params.checkWorkloads()
+	if err != nil || done {
+		t.Fatalf("expected the workloads not to be ready, got %v %v", done, err)
+	}
+
+	for _, want := range []string{
+		"4 of 9 workloads ready, not ready: ",
+		"demo/Deployment/api (1 of 3 replicas available)",
+		"demo/Deployment/rollout (rollout not observed yet)",
+		"demo/DaemonSet/agent (4 of 5 pods ready)",
+		`openshift-sriov/Subscription/sriov (state "UpgradePending")`,
+		"demo/ConfigMap/missing (oc get configmap/missing -n demo: ",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("expected %q in %q", want, message)
+		}
+	}
+}
+
+func TestWorkloadNotReady(t *testing.T) {
+	replicas := int32(2)
+
+	for _, tc := range []struct {
+		kind   string
+		object clusterObject
+		reason string
+	}{
+		{"ConfigMap", clusterObject{}, ""},
+		{"Deployment", clusterObject{}, "0 of 1 replicas available"},
+		{"StatefulSet", func() clusterObject {
+			object := clusterObject{}
+			object.Spec.Replicas = &replicas
+			object.Status.UpdatedReplicas = 2
+			object.Status.ReadyReplicas = 1
+			return object
+		}(), "1 of 2 replicas ready"},
+		{"Job", clusterObject{}, "0 of 1 completions"},
+		{"ClusterServiceVersion", clusterObject{}, `phase ""`},
+	} {
+		if reason := workloadNotReady(tc.kind, tc.object); reason != tc.reason {
+			t.Errorf("%s: expected %q, got %q", tc.kind, tc.reason, reason)
+		}
+	}
+}
+
+func TestParseObjects(t *testing.T) {
+	objects, err := ParseObjects([]byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: demo\n---\n# empty\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: demo\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(objects) != 2 || objects[0].resource() != "deployment.apps/web" || objects[1].String() != "Namespace/demo" {
+		t.Errorf("unexpected objects %v", objects)
+	}
+
+	_, err = ParseObjects([]byte("kind: Deployment\nmetadata: {}\n"))
+	if err == nil || !strings.Contains(err.Error(), "Deployment manifest without a name") {
+		t.Errorf("expected an error for the manifest without a name, got %v", err)
+	}
+}
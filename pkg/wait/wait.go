@@ -0,0 +1,222 @@
+package wait
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Milestone : an installation milestone of a site that can be waited for
+type Milestone string
+
+// milestones, in the order they are reached
+const (
+	BootstrapComplete Milestone = "bootstrap-complete"
+	InstallComplete   Milestone = "install-complete"
+	WorkersReady      Milestone = "workers-ready"
+	WorkloadsReady    Milestone = "workloads-ready"
+)
+
+// Milestones lists all the milestones, in the order they are reached
+var Milestones = []Milestone{BootstrapComplete, InstallComplete, WorkersReady, WorkloadsReady}
+
+// how long each milestone is waited for when no timeout is given
+var defaultTimeouts = map[Milestone]time.Duration{
+	BootstrapComplete: 45 * time.Minute,
+	InstallComplete:   60 * time.Minute,
+	WorkersReady:      30 * time.Minute,
+	WorkloadsReady:    30 * time.Minute,
+}
+
+// statuses of the progress events
+const (
+	StatusWaiting = "waiting"
+	StatusReached = "reached"
+	StatusFailed  = "failed"
+	StatusTimeout = "timeout"
+)
+
+// Params : what waiting for a milestone needs. The installer milestones run
+// openshift-install wait-for on InstallerDir, and the others poll the cluster
+// with oc, so they can be checked against any API server the kubeconfig points to
+type Params struct {
+	InstallerPath string        // openshift-install binary
+	InstallerDir  string        // directory the installer generated the ignition configs in
+	OcPath        string        // oc binary
+	Kubeconfig    string        // kubeconfig used by oc, empty to use the oc defaults
+	Workers       int           // number of workers requested by install-config.yaml
+	Workloads     []Object      // objects applied by apply_workloads
+	Timeout       time.Duration // zero to use the default timeout of the milestone
+	Interval      time.Duration // time between two checks of the cluster
+	Output        io.Writer     // where the progress events are written
+	JSON          bool          // write the progress events as JSON lines
+}
+
+// Progress : an event reporting the progress of a wait
+type Progress struct {
+	Time      time.Time `json:"time"`
+	Milestone Milestone `json:"milestone"`
+	Status    string    `json:"status"`
+	Elapsed   string    `json:"elapsed"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// ParseMilestone : returns the milestone with the given name
+func ParseMilestone(name string) (Milestone, error) {
+	names := []string{}
+	for _, milestone := range Milestones {
+		if string(milestone) == name {
+			return milestone, nil
+		}
+		names = append(names, string(milestone))
+	}
+
+	return "", fmt.Errorf("Wait: ParseMilestone: unknown milestone %q, valid milestones are %s", name, strings.Join(names, ", "))
+}
+
+// For : waits until the milestone is reached or the timeout expires, reporting
+// the progress on the way
+func For(milestone Milestone, params Params) error {
+	timeout := params.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeouts[milestone]
+	}
+	if params.Interval <= 0 {
+		params.Interval = 15 * time.Second
+	}
+	if params.Output == nil {
+		params.Output = os.Stdout
+	}
+
+	reporter := progressReporter{milestone: milestone, start: time.Now(), output: params.Output, json: params.JSON}
+	reporter.report(StatusWaiting, fmt.Sprintf("waiting up to %s", timeout))
+
+	var err error
+
+	switch milestone {
+	case BootstrapComplete, InstallComplete:
+		err = params.runInstaller(milestone, timeout)
+	case WorkersReady:
+		err = params.poll(&reporter, timeout, params.checkWorkers)
+	case WorkloadsReady:
+		err = params.poll(&reporter, timeout, params.checkWorkloads)
+	default:
+		err = fmt.Errorf("unknown milestone %q", milestone)
+	}
+
+	if err != nil {
+		status := StatusFailed
+		if err == errTimeout {
+			status = StatusTimeout
+			err = fmt.Errorf("%s not reached after %s", milestone, timeout)
+		}
+		reporter.report(status, err.Error())
+
+		return fmt.Errorf("Wait: For: %s", err)
+	}
+
+	reporter.report(StatusReached, "")
+
+	return nil
+}
+
+// returned by the waits when the timeout expires
+var errTimeout = errors.New("timeout")
+
+// writes the progress events, as text or JSON lines
+type progressReporter struct {
+	milestone   Milestone
+	start       time.Time
+	output      io.Writer
+	json        bool
+	lastMessage string
+}
+
+func (pr *progressReporter) report(status string, message string) {
+	pr.lastMessage = message
+
+	progress := Progress{
+		Time:      time.Now().UTC(),
+		Milestone: pr.milestone,
+		Status:    status,
+		Elapsed:   time.Since(pr.start).Truncate(time.Second).String(),
+		Message:   message,
+	}
+
+	if pr.json {
+		line, _ := json.Marshal(progress)
+		fmt.Fprintf(pr.output, "%s\n", line)
+		return
+	}
+
+	fmt.Fprintf(pr.output, "%s milestone=%s status=%s elapsed=%s", progress.Time.Format(time.RFC3339), progress.Milestone, progress.Status, progress.Elapsed)
+	if message != "" {
+		fmt.Fprintf(pr.output, " message=%q", message)
+	}
+	fmt.Fprintln(pr.output)
+}
+
+// checks the cluster until check reports it ready, or the timeout expires. Errors
+// are reported as progress, as the cluster may not be reachable yet
+func (p Params) poll(reporter *progressReporter, timeout time.Duration, check func() (bool, string, error)) error {
+	deadline := reporter.start.Add(timeout)
+
+	for {
+		ready, message, err := check()
+
+		if err != nil {
+			message = err.Error()
+		}
+
+		if ready {
+			return nil
+		}
+
+		// only changes are reported, to keep the output readable
+		if message != reporter.lastMessage {
+			reporter.report(StatusWaiting, message)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errTimeout
+		}
+		if remaining > p.Interval {
+			remaining = p.Interval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// runs openshift-install wait-for, that reports its own progress on stderr,
+// keeping the progress events parseable on the output
+func (p Params) runInstaller(milestone Milestone, timeout time.Duration) error {
+	if _, err := os.Stat(p.InstallerDir); err != nil {
+		return fmt.Errorf("installer directory %s not found, the cluster may not have been deployed yet", p.InstallerDir)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.InstallerPath, "wait-for", string(milestone), fmt.Sprintf("--dir=%s", p.InstallerDir), "--log-level=info")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTimeout
+	}
+
+	if err != nil {
+		return fmt.Errorf("error running openshift-install wait-for %s, see %s/.openshift_install.log: %s", milestone, p.InstallerDir, err)
+	}
+
+	return nil
+}
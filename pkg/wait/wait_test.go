@@ -0,0 +1,178 @@
+package wait
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fake openshift-install: logs its arguments next to it, and succeeds for
+// bootstrap-complete and fails for install-complete, or never returns when
+// there is a hang file next to it
+const fakeInstaller = `#!/bin/sh
+dir=%s
+echo "$@" >> $dir/args
+[ -f $dir/hang ] && exec sleep 10
+case "$2" in
+bootstrap-complete) exit 0 ;;
+install-complete) echo "FATAL failed to initialize the cluster" >&2; exit 1 ;;
+esac
+exit 2
+`
+
+// writes the fake installer and the directory it waits on
+func fakeInstallerParams(t *testing.T) Params {
+	t.Helper()
+
+	dir := t.TempDir()
+	installerPath := filepath.Join(dir, "openshift-install")
+	err := ioutil.WriteFile(installerPath, []byte(fmt.Sprintf(fakeInstaller, dir)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Params{InstallerPath: installerPath, InstallerDir: dir}
+}
+
+// the progress events written as JSON lines
+func parseProgress(t *testing.T, output string) []Progress {
+	t.Helper()
+
+	events := []Progress{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var event Progress
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid progress line %q: %s", line, err)
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestForInstaller(t *testing.T) {
+	for _, tc := range []struct {
+		milestone Milestone
+		missing   bool // no installer directory
+		status    string
+		err       string
+	}{
+		{BootstrapComplete, false, StatusReached, ""},
+		{InstallComplete, false, StatusFailed, "error running openshift-install wait-for install-complete, see %s/.openshift_install.log: exit status 1"},
+		{BootstrapComplete, true, StatusFailed, "installer directory %s not found, the cluster may not have been deployed yet"},
+	} {
+		t.Run(fmt.Sprintf("%s missing %v", tc.milestone, tc.missing), func(t *testing.T) {
+			params := fakeInstallerParams(t)
+			if tc.missing {
+				params.InstallerDir = filepath.Join(params.InstallerDir, "missing")
+			}
+
+			var output bytes.Buffer
+			params.Output = &output
+			params.JSON = true
+
+			err := For(tc.milestone, params)
+
+			if tc.err != "" {
+				want := "Wait: For: " + fmt.Sprintf(tc.err, params.InstallerDir)
+				if err == nil || err.Error() != want {
+					t.Fatalf("expected error %q, got %v", want, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			events := parseProgress(t, output.String())
+			if len(events) != 2 || events[0].Status != StatusWaiting || events[0].Message != "waiting up to "+defaultTimeouts[tc.milestone].String() || events[1].Status != tc.status {
+				t.Errorf("unexpected progress %+v", events)
+			}
+
+			if !tc.missing {
+				args, _ := ioutil.ReadFile(filepath.Join(params.InstallerDir, "args"))
+				want := fmt.Sprintf("wait-for %s --dir=%s --log-level=info\n", tc.milestone, params.InstallerDir)
+				if string(args) != want {
+					t.Errorf("expected the installer to run with %q, got %q", want, args)
+				}
+			}
+		})
+	}
+}
+
+func TestForInstallerTimeout(t *testing.T) {
+	params := fakeInstallerParams(t)
+	params.Timeout = 100 * time.Millisecond
+	params.JSON = true
+	ioutil.WriteFile(filepath.Join(params.InstallerDir, "hang"), nil, 0644)
+
+	var output bytes.Buffer
+	params.Output = &output
+
+	start := time.Now()
+	err := For(BootstrapComplete, params)
+	if err == nil || err.Error() != "Wait: For: bootstrap-complete not reached after 100ms" {
+		t.Fatalf("expected the timeout to be reported, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the installer to be stopped at the timeout, ran for %s", elapsed)
+	}
+
+	events := parseProgress(t, output.String())
+	if len(events) != 2 || events[1].Status != StatusTimeout || events[1].Message != "bootstrap-complete not reached after 100ms" {
+		t.Errorf("unexpected progress %+v", events)
+	}
+}
+
+func TestForProgress(t *testing.T) {
+	params, _ := fakeOcParams(t)
+	params.Workers = 3
+	params.Timeout = 50 * time.Millisecond
+	params.Interval = 10 * time.Millisecond
+
+	var output bytes.Buffer
+	params.Output = &output
+	params.JSON = true
+
+	err := For(WorkersReady, params)
+	if err == nil || err.Error() != "Wait: For: workers-ready not reached after 50ms" {
+		t.Fatalf("expected the timeout to be reported, got %v", err)
+	}
+
+	// the unchanged status of the workers is only reported once
+	events := parseProgress(t, output.String())
+	want := []struct{ status, message string }{
+		{StatusWaiting, "waiting up to 50ms"},
+		{StatusWaiting, "2 of 3 workers ready, not ready: worker-2, 2 pending certificate signing request(s)"},
+		{StatusTimeout, "workers-ready not reached after 50ms"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, event := range events {
+		if event.Milestone != WorkersReady || event.Status != want[i].status || event.Message != want[i].message || event.Elapsed != "0s" || event.Time.IsZero() {
+			t.Errorf("event %d: expected %s %q, got %+v", i, want[i].status, want[i].message, event)
+		}
+	}
+
+	// as text, one line per event
+	params.Workers = 2
+	params.JSON = false
+	output.Reset()
+
+	err = For(WorkersReady, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	line := regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ milestone=workers-ready status=(\w+) elapsed=0s( message="[^"]*")?$`)
+	if len(lines) != 2 || !line.MatchString(lines[0]) || !line.MatchString(lines[1]) ||
+		!strings.HasSuffix(lines[0], `status=waiting elapsed=0s message="waiting up to 50ms"`) || !strings.HasSuffix(lines[1], "status=reached elapsed=0s") {
+		t.Errorf("unexpected text progress:\n%s", output.String())
+	}
+}
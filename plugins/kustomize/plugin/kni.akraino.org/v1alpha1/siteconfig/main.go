@@ -0,0 +1,64 @@
+// Copyright © 2019 Red Hat <yroblamo@redhat.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is a kustomize exec transformer plugin that allows injecting
+// configuration for a site into the blueprint. It injects the pull secret,
+// ssh public key and other credentials into the site resources such that they
+// don't have to be stored in git with the blueprint. Instead, they are taken
+// from the credentials directory of the site, set by knictl in
+// KNI_SITE_BUILD_PATH, then from the credential set selected by the site and
+// finally from the build path of knictl, set in KNI_BUILD_PATH ($HOME/.kni
+// when run without knictl). Every key of the SiteConfig config block is also exposed as
+// a substitution variable, referenced as $(KEY). Values are replaced on the
+// parsed resources, so the output is always valid YAML.
+//
+// It is built into the SiteConfig executable of this directory with make build.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gerrit.akraino.org/kni/installer/pkg/secrets"
+	"gerrit.akraino.org/kni/installer/pkg/siteconfig"
+)
+
+func main() {
+	// kustomize passes the SiteConfig file name as first argument
+	if len(os.Args) < 2 {
+		log.Fatalln("Please specify the SiteConfig file as first argument")
+	}
+
+	sc, err := siteconfig.Load(os.Args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	globalPath := os.Getenv("KNI_BUILD_PATH")
+	if globalPath == "" {
+		globalPath = fmt.Sprintf("%s/.kni", os.Getenv("HOME"))
+	}
+
+	lookup := secrets.Lookup{SitePath: os.Getenv("KNI_SITE_BUILD_PATH"), GlobalPath: globalPath}
+	variables, err := sc.Variables(lookup)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	err = sc.Transform(variables, os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}